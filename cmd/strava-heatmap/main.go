@@ -1,27 +1,45 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/samuellee/StravaGraph/internal/auth"
+	"github.com/samuellee/StravaGraph/internal/cache"
 	"github.com/samuellee/StravaGraph/internal/config"
 	"github.com/samuellee/StravaGraph/internal/github"
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/server"
 	"github.com/samuellee/StravaGraph/internal/strava"
 	"github.com/samuellee/StravaGraph/internal/svg"
 )
 
 const (
-	configPath = "config.json"
+	configPath = config.DefaultConfigFile
 	readmePath = "README.md"
 	envFile    = ".env"
 )
 
+// cassette records or replays Strava API traffic when -record or -replay is
+// given, nil otherwise
+var cassette *strava.Cassette
+
+// refreshCache ignores cached activities and responses when -refresh-cache is
+// given, forcing a full re-sync; cached tokens are still used
+var refreshCache bool
+
 // loadEnvFile attempts to load variables from .env file
 // It doesn't error if the file doesn't exist, as environment variables
 // might be set through other means (especially in GitHub Actions)
@@ -51,10 +69,36 @@ func loadEnvFile() {
 
 func main() {
 	// Define commands
-	cmdAuth := flag.Bool("auth", false, "Generate authentication instructions")
+	cmdAuth := flag.Bool("auth", false, "Generate authentication instructions, or with -serve authorize in the browser")
 	cmdUpdate := flag.Bool("update", false, "Update the heatmap in the README")
 	cmdGenerate := flag.Bool("generate", false, "Generate SVG without updating README")
 	cmdTest := flag.Bool("test", false, "Test configuration and authentication")
+	cmdStatsJSON := flag.Bool("stats-json", false, "Print the full stats and daily totals of the displayed range as JSON")
+	statsOut := flag.String("out", "", "File -stats-json writes to instead of stdout")
+	cmdServe := flag.Bool("serve", false, "Serve heatmaps for any athlete who connects their Strava account")
+	cmdRelay := flag.Bool("relay", false, "Relay Strava webhook events to a GitHub workflow run")
+	cmdPreflight := flag.Bool("preflight", false, "Check credentials, config, README markers and write access before a run, without calling any API")
+	cmdInit := flag.Bool("init", false, "Write the built-in config, a starter workflow and README markers for customization")
+	authPort := flag.Int("port", auth.DefaultCallbackPort, "Port of the local callback server for -auth -serve")
+	serveAddr := flag.String("addr", ":8080", "Address to listen on in serve and relay mode")
+	serveBaseURL := flag.String("base-url", "http://localhost:8080", "Public URL of the service, used for the OAuth redirect")
+	serveDataDir := flag.String("data-dir", "data", "Directory holding connected users' tokens in serve mode")
+	serveStorage := flag.String("storage", "", "Bucket to publish rendered heatmaps to in serve mode, as s3://bucket/prefix or gs://bucket/prefix")
+	relayRepo := flag.String("repo", "", "Repository, as owner/name, whose workflow the relay dispatches (default: the token owner's profile repository)")
+	relayWorkflow := flag.String("workflow", "", "Workflow file the relay runs with workflow_dispatch, instead of sending a repository_dispatch event")
+	relayRef := flag.String("ref", "", "Branch the relay's workflow_dispatch runs use (default: the repository's default branch)")
+	configFile := flag.String("config", configPath, "Path to the configuration file")
+	readmeFile := flag.String("readme", readmePath, "Path to the README to update")
+	profile := flag.String("profile", "", "Config profile to apply, which also namespaces README markers and the cache")
+	athlete := flag.String("athlete", "", "Roster athlete -auth -serve authorizes, saving their refresh token as STRAVA_REFRESH_TOKEN_<NAME>")
+	record := flag.String("record", "", "Record Strava API responses to a fixture file, with tokens redacted")
+	flag.BoolVar(&refreshCache, "refresh-cache", false, "Re-fetch every activity in the date range instead of syncing from the cache")
+	replay := flag.String("replay", "", "Replay Strava API responses from a fixture file instead of calling the API")
+	format := flag.String("format", "", "Format of the heatmap file and -generate output, svg or png (default: outputFormat from the config)")
+	flag.StringVar(&sourceName, "source", "", "Where activities come from: strava, garmin, files to read exported GPX, TCX and FIT files from -dir, or export to read a Strava bulk export from -path (default: provider from the config, or strava)")
+	flag.StringVar(&activityDir, "dir", "activities", "Directory of exported GPX, TCX and FIT files read with -source files")
+	flag.StringVar(&exportPath, "path", "export.zip", "Strava bulk export archive, or the directory it was extracted to, read with -source export")
+	strict := flag.Bool("strict", false, "Fail on misconfigurations, such as an unknown timezone or theme, instead of falling back to defaults")
 
 	// Parse command line arguments
 	flag.Parse()
@@ -62,33 +106,106 @@ func main() {
 	// Load environment variables from .env file if it exists
 	loadEnvFile()
 
+	// Strict mode applies to every config loaded, including the profiles of
+	// targets, as if set in the environment
+	if *strict {
+		os.Setenv(config.EnvVarName("strict"), "true")
+	}
+
+	// Write the built-in defaults out before any config is needed
+	if *cmdInit {
+		handleInitCommand(*configFile, *readmeFile, *profile)
+		return
+	}
+
+	// Check the run can succeed before anything else can fail, reporting
+	// every problem rather than the first
+	if *cmdPreflight {
+		handlePreflightCommand(*configFile, *readmeFile, *profile, *format)
+		return
+	}
+
 	// Load configuration
-	cfg, err := config.LoadConfig(configPath)
+	cfg, err := loadConfig(*configFile, *profile)
 	if err != nil {
 		fmt.Printf("Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
+	if *format != "" {
+		cfg.OutputFormat = *format
+		if err := config.ValidateConfig(cfg); err != nil {
+			fmt.Printf("Error loading configuration: invalid configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Record or replay API traffic
+	switch {
+	case *record != "" && *replay != "":
+		fmt.Println("Error: -record and -replay cannot be used together")
+		os.Exit(1)
+	case *record != "":
+		cassette = strava.NewCassette(*record)
+	case *replay != "":
+		cassette, err = strava.LoadCassette(*replay)
+		if err != nil {
+			fmt.Printf("Error loading fixture: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Check the provider before any command runs
+	if _, ok := sources[sourceName]; sourceName != "" && !ok {
+		fmt.Printf("Error: invalid source: %s, must be one of %v\n", sourceName, sourceNames())
+		os.Exit(1)
+	}
+
+	// Authorize a roster athlete with their own token and cache
+	if *athlete != "" {
+		if !slices.ContainsFunc(cfg.Roster, func(a config.Athlete) bool { return a.Name == *athlete }) {
+			fmt.Printf("Error: %s is not in the roster\n", *athlete)
+			os.Exit(1)
+		}
+		cfg.Athlete = *athlete
+		cfg.Profile = *athlete
+	}
 
 	// Initialize GitHub Actions handler
 	actionsHandler := github.NewActionsHandler(cfg.Debug)
 
 	// Execute requested command
 	switch {
+	case *cmdAuth && *cmdServe:
+		// Authorize in the browser through a local callback server
+		handleInteractiveAuthCommand(cfg, actionsHandler, *authPort)
+
 	case *cmdAuth:
 		// Generate authentication instructions
 		handleAuthCommand(actionsHandler)
 
 	case *cmdUpdate:
 		// Update the heatmap in the README
-		handleUpdateCommand(cfg, actionsHandler)
+		handleUpdateCommand(cfg, actionsHandler, *configFile, *readmeFile)
 
 	case *cmdGenerate:
 		// Generate SVG without updating README
 		handleGenerateCommand(cfg, actionsHandler)
 
+	case *cmdStatsJSON:
+		// Print stats and daily totals as JSON
+		handleStatsJSONCommand(cfg, actionsHandler, *statsOut)
+
 	case *cmdTest:
 		// Test configuration and authentication
-		handleTestCommand(cfg, actionsHandler)
+		handleTestCommand(cfg, actionsHandler, *readmeFile)
+
+	case *cmdServe:
+		// Serve heatmaps over HTTP
+		handleServeCommand(cfg, actionsHandler, *serveAddr, *serveBaseURL, *serveDataDir, *serveStorage)
+
+	case *cmdRelay:
+		// Turn Strava webhook events into workflow runs
+		handleRelayCommand(cfg, actionsHandler, *serveAddr, *relayRepo, *relayWorkflow, *relayRef)
 
 	default:
 		// No command specified
@@ -98,6 +215,58 @@ func main() {
 	}
 }
 
+// loadConfig loads the configuration, falling back to the built-in defaults
+// when the default config file doesn't exist
+func loadConfig(configFile, profile string) (*config.Config, error) {
+	if _, err := os.Stat(configFile); os.IsNotExist(err) && configFile == configPath {
+		return config.LoadDefaultConfig(profile)
+	}
+	return config.LoadProfileConfig(configFile, profile)
+}
+
+// handleInitCommand writes the config and workflow built into the binary and
+// adds the README markers, skipping files that already exist so it never
+// overwrites customizations
+func handleInitCommand(configFile, readmeFile, profile string) {
+	files := []struct {
+		path    string
+		content []byte
+	}{
+		{configFile, config.DefaultConfig},
+		{github.WorkflowPath, github.StarterWorkflow},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			fmt.Printf("Skipped %s, which already exists\n", file.path)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			fmt.Printf("Error creating directory for %s: %v\n", file.path, err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(file.path, file.content, 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", file.path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s\n", file.path)
+	}
+
+	readmeUpdater := github.NewReadmeUpdater(readmeFile, profile, false)
+	changed, err := readmeUpdater.InitReadme()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if changed {
+		fmt.Printf("Added heatmap markers to %s\n", readmeFile)
+	} else {
+		fmt.Printf("Skipped %s, which already has heatmap markers\n", readmeFile)
+	}
+
+	fmt.Println("\nNext, add STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET and STRAVA_REFRESH_TOKEN as repository secrets; run -auth to get a refresh token.")
+}
+
 // handleAuthCommand generates authentication instructions
 func handleAuthCommand(actionsHandler *github.ActionsHandler) {
 	// Get client ID and secret from environment variables
@@ -114,57 +283,145 @@ func handleAuthCommand(actionsHandler *github.ActionsHandler) {
 	fmt.Println(instructions)
 }
 
-// handleUpdateCommand updates the heatmap in the README
-func handleUpdateCommand(cfg *config.Config, actionsHandler *github.ActionsHandler) {
-	// Authenticate with Strava
-	tokenManager, err := getTokenManager(actionsHandler)
+// handleInteractiveAuthCommand runs the OAuth flow in the browser, catching
+// the redirect on a local server, and saves the resulting refresh token
+func handleInteractiveAuthCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, port int) {
+	clientID := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_ID", "")
+	clientSecret := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_SECRET", "")
+
+	if clientID == "" || clientSecret == "" {
+		fmt.Println("Error: STRAVA_CLIENT_ID and STRAVA_CLIENT_SECRET environment variables must be set.")
+		os.Exit(1)
+	}
+
+	callback, err := auth.NewCallbackServer(clientID, clientSecret, port)
 	if err != nil {
-		actionsHandler.LogError("Failed to authenticate with Strava", err)
+		fmt.Printf("Error starting callback server: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Create Strava client
-	stravaClient := strava.NewClient(tokenManager, cfg.Debug)
+	authURL := callback.AuthorizationURL()
+	fmt.Printf("Opening Strava in your browser. If it doesn't open, visit:\n\n%s\n\n", authURL)
+	if err := auth.OpenBrowser(authURL); err != nil && cfg.Debug {
+		fmt.Printf("[DEBUG] Failed to open browser: %v\n", err)
+	}
+	fmt.Printf("Waiting for authorization on http://localhost:%d/callback...\n", port)
 
-	// Get activity date range
-	startDate, endDate, err := cfg.GetDateRange()
+	token, err := callback.Wait(5 * time.Minute)
 	if err != nil {
-		actionsHandler.LogError("Failed to get date range", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if cfg.Debug {
-		fmt.Printf("Fetching activities from %s to %s\n",
-			startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	fmt.Printf("\nAuthorized as %s. Your refresh token:\n\n%s\n\n", token.Athlete.Firstname, token.RefreshToken)
+
+	// Save the token where later runs pick it up
+	tokenVar := refreshTokenVar(cfg)
+	envPath, err := saveRefreshToken(tokenVar, token.RefreshToken)
+	if err != nil {
+		fmt.Printf("Warning: failed to save refresh token: %v\n", err)
+	} else {
+		fmt.Printf("Saved %s to %s\n", tokenVar, envPath)
+	}
+
+	// The cached token takes precedence over the environment, so replace it
+	if store := openCache(cfg); store != nil {
+		if err := store.SaveToken(&cache.TokenState{
+			RefreshToken: token.RefreshToken,
+			AccessToken:  token.AccessToken,
+			ExpiresAt:    time.Unix(token.ExpiresAt, 0),
+		}); err != nil {
+			fmt.Printf("Warning: failed to update cached token: %v\n", err)
+		}
 	}
 
-	// Fetch activities
-	activities, err := stravaClient.GetAllActivities(startDate, endDate, cfg.ActivityTypes)
+	fmt.Printf("Add it as the %s repository secret to use it in GitHub Actions.\n", tokenVar)
+}
+
+// saveRefreshToken sets the variable holding the refresh token in the .env
+// file in the current directory, creating the file if needed and keeping its
+// other lines, and returns the file's path
+func saveRefreshToken(name, refreshToken string) (string, error) {
+	dir, err := os.Getwd()
 	if err != nil {
-		actionsHandler.LogError("Failed to fetch activities", err)
+		return "", err
+	}
+	envPath := filepath.Join(dir, envFile)
+
+	data, err := os.ReadFile(envPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	line := name + "=" + refreshToken
+	var lines []string
+	replaced := false
+	for _, existing := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimPrefix(strings.TrimSpace(existing), "export "), name+"=") {
+			existing, replaced = line, true
+		}
+		if existing != "" || len(lines) > 0 {
+			lines = append(lines, existing)
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+
+	return envPath, os.WriteFile(envPath, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+// handleUpdateCommand updates the heatmap in the README
+func handleUpdateCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, configFile, readmeFile string) {
+	// Every target is updated from one fetch
+	targets, err := loadTargets(cfg, configFile, readmeFile)
+	if err != nil {
+		actionsHandler.LogError("Invalid configuration", err)
 		os.Exit(1)
 	}
 
-	if cfg.Debug {
-		fmt.Printf("Found %d activities\n", len(activities))
+	// A PNG can only be shown from its own file
+	for _, target := range targets {
+		if target.cfg.OutputFormat == "png" && target.cfg.SVGFile == "" {
+			actionsHandler.LogError("Invalid configuration", fmt.Errorf("outputFormat png needs svgFile set to a .png path"))
+			os.Exit(1)
+		}
 	}
 
-	// Generate SVG
-	svgGenerator := svg.NewGenerator(cfg)
-	svgContent, err := svgGenerator.GenerateHeatmap(activities)
+	// Each athlete of a roster is fetched with their own token
+	if len(cfg.Roster) > 0 {
+		updateRoster(cfg, actionsHandler, targets)
+		return
+	}
+
+	// Connect to the configured provider
+	fetchCfg := fetchConfig(cfg, targets)
+	warn := func(message string) { actionsHandler.LogWarning(message) }
+	source, err := openSource(fetchCfg, targets, actionsHandler, warn)
 	if err != nil {
-		actionsHandler.LogError("Failed to generate heatmap SVG", err)
+		actionsHandler.LogError("Failed to open activity source", err)
 		os.Exit(1)
 	}
 
-	// Update README
-	readmeUpdater := github.NewReadmeUpdater(readmePath, cfg.Debug)
-	if err := readmeUpdater.UpdateReadme(svgContent); err != nil {
-		actionsHandler.LogError("Failed to update README", err)
+	// Get activity date range, including any history needed for intensity
+	// normalization, covering every target
+	startDate, endDate, err := fetchRange(targets)
+	if err != nil {
+		actionsHandler.LogError("Failed to get date range", err)
+		os.Exit(1)
+	}
+
+	// Fetch activities
+	activities, err := source.FetchActivities(startDate, endDate, fetchCfg.ActivityTypes)
+	if err != nil {
+		actionsHandler.LogError("Failed to fetch activities", err)
 		os.Exit(1)
 	}
 
-	actionsHandler.LogInfo("Successfully updated README with Strava heatmap")
+	// The cache directory is saved under the key the fetch left it at
+	setCacheKey(actionsHandler, warn, sourceCacheKey(source))
+
+	updateTargets(actionsHandler, targets, activities)
 
 	// Record metrics if in GitHub Actions
 	if actionsHandler.IsRunningInActions() {
@@ -173,137 +430,1454 @@ func handleUpdateCommand(cfg *config.Config, actionsHandler *github.ActionsHandl
 	}
 }
 
-// handleGenerateCommand generates SVG without updating README
-func handleGenerateCommand(cfg *config.Config, actionsHandler *github.ActionsHandler) {
-	// Authenticate with Strava
-	tokenManager, err := getTokenManager(actionsHandler)
-	if err != nil {
-		// Write errors to stderr, not stdout
-		fmt.Fprintf(os.Stderr, "Error: Failed to authenticate with Strava: %v\n", err)
-		os.Exit(1)
+// setCacheKey sets the cache-key output the action saves the cache
+// directory under, if there is a key and the run is in Actions
+func setCacheKey(actionsHandler *github.ActionsHandler, warn func(string), key string) {
+	if key == "" || os.Getenv("GITHUB_OUTPUT") == "" {
+		return
 	}
+	if err := actionsHandler.SetOutput("cache-key", key); err != nil {
+		warn(fmt.Sprintf("Failed to set cache-key output: %v", err))
+	}
+}
 
-	// Create Strava client
-	stravaClient := strava.NewClient(tokenManager, cfg.Debug)
+// updateRoster fetches and updates each athlete of a roster in turn. An
+// athlete whose activities can't be fetched, for example after revoking the
+// coach's access, is reported and skipped so the rest of the team is still
+// updated, and the run fails at the end.
+func updateRoster(cfg *config.Config, actionsHandler *github.ActionsHandler, targets []target) {
+	warn := func(message string) { actionsHandler.LogWarning(message) }
+	var updated []target
+	var stats []map[string]string
+	var cacheKeys []string
+	total := 0
+	for _, athlete := range targets {
+		athleteTargets := []target{athlete}
+		fetchCfg := fetchConfig(athlete.cfg, athleteTargets)
+		source, err := openSource(fetchCfg, athleteTargets, actionsHandler, warn)
+		if err != nil {
+			actionsHandler.LogError(fmt.Sprintf("Failed to open activity source for %s", athlete.cfg.Athlete), err)
+			continue
+		}
 
-	// Get activity date range
-	startDate, endDate, err := cfg.GetDateRange()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to get date range: %v\n", err)
-		os.Exit(1)
+		startDate, endDate, err := fetchRange(athleteTargets)
+		if err != nil {
+			actionsHandler.LogError(fmt.Sprintf("Failed to get date range for %s", athlete.cfg.Athlete), err)
+			continue
+		}
+
+		activities, err := source.FetchActivities(startDate, endDate, fetchCfg.ActivityTypes)
+		if err != nil {
+			actionsHandler.LogError(fmt.Sprintf("Failed to fetch activities for %s", athlete.cfg.Athlete), err)
+			continue
+		}
+
+		if key := sourceCacheKey(source); key != "" {
+			cacheKeys = append(cacheKeys, key)
+		}
+
+		stats = append(stats, updateTarget(athlete.cfg, actionsHandler, athlete.readme, activities))
+		updated = append(updated, athlete)
+		total += len(activities)
 	}
 
-	// Fetch activities
-	activities, err := stravaClient.GetAllActivities(startDate, endDate, cfg.ActivityTypes)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to fetch activities: %v\n", err)
+	reportTargets(actionsHandler, updated, stats)
+
+	// The athletes' caches share the cache directory, saved under one key
+	if len(cacheKeys) > 0 {
+		setCacheKey(actionsHandler, warn, cache.CombinedKey(cfg.Profile, cacheKeys))
+	}
+
+	if actionsHandler.IsRunningInActions() {
+		actionsHandler.RecordMetric("Athletes", fmt.Sprintf("%d of %d", len(updated), len(targets)))
+		actionsHandler.RecordMetric("Activities", total)
+		actionsHandler.RecordMetric("UpdateTime", actionsHandler.FormatTimestamp(time.Now()))
+	}
+
+	if len(updated) < len(targets) {
 		os.Exit(1)
 	}
+}
+
+// updateTargets updates each target with its own activity types and reports
+// the files written
+func updateTargets(actionsHandler *github.ActionsHandler, targets []target, activities []strava.SummaryActivity) {
+	stats := make([]map[string]string, len(targets))
+	for i, target := range targets {
+		stats[i] = updateTarget(target.cfg, actionsHandler, target.readme, filterActivityTypes(activities, target.cfg.ActivityTypes))
+	}
+	reportTargets(actionsHandler, targets, stats)
+}
+
+// reportTargets sets the outputs listing the READMEs and files the targets
+// were written to, so the action commits them, and each target's key stats
+func reportTargets(actionsHandler *github.ActionsHandler, targets []target, stats []map[string]string) {
+	var svgFiles, statsFiles []string
+	for _, target := range targets {
+		if target.cfg.SVGFile != "" {
+			svgFiles = append(svgFiles, target.cfg.SVGFile)
+		}
+		if target.cfg.MobileSVGFile != "" {
+			svgFiles = append(svgFiles, target.cfg.MobileSVGFile)
+		}
+		if target.cfg.StatsFile != "" {
+			statsFiles = append(statsFiles, target.cfg.StatsFile)
+		}
+	}
+
+	if os.Getenv("GITHUB_OUTPUT") != "" {
+		// Athletes may share a README, which is listed once
+		var readmes []string
+		for _, target := range targets {
+			if !slices.Contains(readmes, target.readme) {
+				readmes = append(readmes, target.readme)
+			}
+		}
+		outputs := []struct {
+			name  string
+			paths []string
+		}{
+			{"readme-files", readmes},
+			{"svg-file", svgFiles},
+			{"stats-file", statsFiles},
+		}
+		for _, output := range outputs {
+			if len(output.paths) == 0 {
+				continue
+			}
+			if err := actionsHandler.SetOutput(output.name, strings.Join(output.paths, "\n")); err != nil {
+				actionsHandler.LogWarning(fmt.Sprintf("Failed to set %s output: %v", output.name, err))
+			}
+		}
+
+		if err := writeStatOutputs(actionsHandler, stats); err != nil {
+			actionsHandler.LogWarning(fmt.Sprintf("Failed to set stats outputs: %v", err))
+		}
+	}
+}
+
+// updateTarget renders the heatmap for one target and updates its README,
+// heatmap file and stats file. It returns the key stats set as outputs, nil
+// in privacy mode.
+func updateTarget(cfg *config.Config, actionsHandler *github.ActionsHandler, readmeFile string, activities []strava.SummaryActivity) map[string]string {
+	// Leave out activities the config ignores
+	activities = processor.IgnoreActivities(activities, cfg.IgnoreActivityIDs)
 
 	// Generate SVG
 	svgGenerator := svg.NewGenerator(cfg)
 	svgContent, err := svgGenerator.GenerateHeatmap(activities)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to generate heatmap SVG: %v\n", err)
+		actionsHandler.LogError("Failed to generate heatmap SVG", err)
 		os.Exit(1)
 	}
 
-	// Verify the SVG starts with an opening tag
-	if !strings.HasPrefix(svgContent, "<svg") {
-		fmt.Fprintf(os.Stderr, "Warning: Generated SVG doesn't start with <svg> tag!\n")
-		svgIndex := strings.Index(svgContent, "<svg")
-		if svgIndex != -1 {
-			// Extract just the SVG content
-			fmt.Fprintf(os.Stderr, "Fixing SVG content...\n")
-			svgContent = svgContent[svgIndex:]
+	// Render the mobile layout too when narrow screens get their own file
+	mobileContent := ""
+	if cfg.MobileSVGFile != "" {
+		mobileCfg := *cfg
+		mobileCfg.Target = "mobile"
+		mobileContent, err = svg.NewGenerator(&mobileCfg).GenerateHeatmap(activities)
+		if err != nil {
+			actionsHandler.LogError("Failed to generate mobile heatmap SVG", err)
+			os.Exit(1)
 		}
 	}
 
-	// Print just the SVG content to stdout with no additional output
-	fmt.Print(svgContent)
-}
+	// Summarize the activities for README variables and the stats file
+	summary, err := summarize(cfg, activities)
+	if err != nil {
+		actionsHandler.LogError("Failed to summarize activities", err)
+		os.Exit(1)
+	}
 
-// handleTestCommand tests configuration and authentication
-func handleTestCommand(cfg *config.Config, actionsHandler *github.ActionsHandler) {
-	fmt.Println("Testing configuration and authentication...")
+	// Reference the heatmap as an image when it's written to its own file
+	readmeContent := svgContent
+	if cfg.SVGFile != "" {
+		readmeContent, err = writeSVGFile(cfg, readmeFile, svgContent, mobileContent, summary.altText(cfg))
+		if err != nil {
+			actionsHandler.LogError("Failed to write heatmap file", err)
+			os.Exit(1)
+		}
+	}
 
-	// Test configuration
-	fmt.Println("\nConfiguration:")
-	fmt.Printf("  Activity Types: %v\n", cfg.ActivityTypes)
-	fmt.Printf("  Metric Type: %s\n", cfg.MetricType)
-	fmt.Printf("  Date Range: %s\n", cfg.DateRange)
+	// Update README, filling in any template variables
+	readmeUpdater := github.NewReadmeUpdater(readmeFile, cfg.Profile, cfg.Debug)
+	readmeUpdater.Variables = summary.templateValues(cfg)
+	if err := readmeUpdater.UpdateReadme(readmeContent); err != nil {
+		actionsHandler.LogError("Failed to update README", err)
+		os.Exit(1)
+	}
 
-	// Test date range
-	startDate, endDate, err := cfg.GetDateRange()
-	if err != nil {
-		fmt.Printf("  Date Range Error: %v\n", err)
-	} else {
-		fmt.Printf("  Start Date: %s\n", startDate.Format("2006-01-02"))
-		fmt.Printf("  End Date: %s\n", endDate.Format("2006-01-02"))
+	actionsHandler.LogInfo(fmt.Sprintf("Successfully updated %s with Strava heatmap", readmeFile))
+
+	// Describe the update in the step summary
+	if os.Getenv("GITHUB_STEP_SUMMARY") != "" {
+		if err := actionsHandler.CreateSummary(updateSummary(cfg, summary)); err != nil {
+			actionsHandler.LogWarning(fmt.Sprintf("Failed to write step summary: %v", err))
+		}
 	}
 
-	// Test Strava authentication
-	fmt.Println("\nStrava Authentication:")
-	tokenManager, err := getTokenManager(actionsHandler)
-	if err != nil {
-		fmt.Printf("  Authentication Error: %v\n", err)
-		return
+	// Publish the stats alongside the README
+	if err := writeStats(cfg, summary); err != nil {
+		actionsHandler.LogError("Failed to write stats file", err)
+		os.Exit(1)
 	}
 
-	// Test token refresh
-	fmt.Println("  Refreshing token...")
-	err = tokenManager.RefreshAccessToken()
-	if err != nil {
-		fmt.Printf("  Token Refresh Error: %v\n", err)
-		return
+	// Point out risky jumps in training load
+	for _, warning := range svgGenerator.RampWarnings {
+		actionsHandler.LogWarning(fmt.Sprintf("Workload ratio %.2f in the week of %s exceeds %.2f",
+			warning.Ratio, warning.WeekStart.Format("2006-01-02"), cfg.ACWRThreshold))
+	}
+	if len(svgGenerator.RampWarnings) > 0 {
+		if err := actionsHandler.CreateSummary(rampSummary(svgGenerator.RampWarnings, cfg.ACWRThreshold)); err != nil {
+			actionsHandler.LogWarning(fmt.Sprintf("Failed to write step summary: %v", err))
+		}
 	}
 
-	// Create Strava client and test connection
-	stravaClient := strava.NewClient(tokenManager, cfg.Debug)
+	// Point out weeks whose heart rate suggests fatigue, keeping heart rate
+	// out of the log of private heatmaps
+	if cfg.PrivacyMode {
+		return nil
+	}
+	for _, warning := range svgGenerator.HeartRateWarnings {
+		actionsHandler.LogWarning(fmt.Sprintf("Average heart rate %.0f bpm in the week of %s is above the %.0f bpm of the weeks before, possible fatigue",
+			warning.AvgHeartRate, warning.WeekStart.Format("2006-01-02"), warning.Baseline))
+	}
+	if len(svgGenerator.HeartRateWarnings) > 0 {
+		if err := actionsHandler.CreateSummary(heartRateSummary(svgGenerator.HeartRateWarnings)); err != nil {
+			actionsHandler.LogWarning(fmt.Sprintf("Failed to write step summary: %v", err))
+		}
+	}
 
-	// Get athlete data
-	fmt.Println("  Fetching athlete data...")
-	athlete, err := stravaClient.GetAthlete()
-	if err != nil {
-		fmt.Printf("  API Error: %v\n", err)
-		return
+	return processor.StatOutputs(summary.aggregator, summary.start, summary.end, summary.now)
+}
+
+// target is a README updated in a run, with the config it's rendered
+// with
+type target struct {
+	cfg    *config.Config
+	readme string
+}
+
+// loadTargets returns the READMEs a run updates: the configured targets,
+// each with its profile applied, the roster's athletes, or else only
+// readmeFile with the config already loaded
+func loadTargets(cfg *config.Config, configFile, readmeFile string) ([]target, error) {
+	if len(cfg.Roster) > 0 {
+		return loadRoster(cfg, configFile)
+	}
+	if len(cfg.Targets) == 0 {
+		return []target{{cfg: cfg, readme: readmeFile}}, nil
 	}
 
-	// Print athlete info
-	if firstName, ok := athlete["firstname"].(string); ok {
-		fmt.Printf("  Athlete: %s", firstName)
-		if lastName, ok := athlete["lastname"].(string); ok {
-			fmt.Printf(" %s", lastName)
+	targets := make([]target, len(cfg.Targets))
+	written := make(map[string]string)
+	for i, t := range cfg.Targets {
+		targetCfg, err := config.LoadProfileConfig(configFile, t.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading target %s: %w", t.Readme, err)
 		}
-		fmt.Println()
+
+		// Targets would overwrite each other's files
+		name := t.Readme
+		if t.Profile != "" {
+			name = t.Profile
+		}
+		if path, other := sharedFile(written, name, targetCfg); path != "" {
+			return nil, fmt.Errorf("targets %s and %s both write %s; set svgFile and statsFile in each target's profile", other, name, path)
+		}
+
+		targets[i] = target{cfg: targetCfg, readme: t.Readme}
 	}
+	return targets, nil
+}
 
-	// Test README markers if updating
-	fmt.Println("\nREADME Validation:")
-	readmeUpdater := github.NewReadmeUpdater(readmePath, cfg.Debug)
-	valid, err := readmeUpdater.ValidateReadme()
-	if err != nil {
-		fmt.Printf("  README Error: %v\n", err)
-	} else if valid {
-		fmt.Println("  README markers are valid")
+// loadRoster returns a target for each athlete of the roster, with their
+// profile applied and their own files. The athlete's name stands in for the
+// profile in the README markers and the cache, so athletes sharing a profile
+// or a README are kept apart.
+func loadRoster(cfg *config.Config, configFile string) ([]target, error) {
+	targets := make([]target, len(cfg.Roster))
+	written := make(map[string]string)
+	for i, athlete := range cfg.Roster {
+		athleteCfg, err := config.LoadProfileConfig(configFile, athlete.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading roster athlete %s: %w", athlete.Name, err)
+		}
+		athleteCfg.Athlete = athlete.Name
+		athleteCfg.Profile = athlete.Name
+		if athlete.SVGFile != "" {
+			athleteCfg.SVGFile = athlete.SVGFile
+		}
+		if athlete.StatsFile != "" {
+			athleteCfg.StatsFile = athlete.StatsFile
+		}
+		if err := config.ValidateConfig(athleteCfg); err != nil {
+			return nil, fmt.Errorf("invalid config for roster athlete %s: %w", athlete.Name, err)
+		}
+
+		// Athletes would overwrite each other's files
+		if path, other := sharedFile(written, athlete.Name, athleteCfg); path != "" {
+			return nil, fmt.Errorf("roster athletes %s and %s both write %s; set svgFile and statsFile for each athlete", other, athlete.Name, path)
+		}
+
+		targets[i] = target{cfg: athleteCfg, readme: athlete.Readme}
 	}
+	return targets, nil
+}
 
-	fmt.Println("\nTest completed successfully!")
+// sharedFile records the heatmap and stats files a target writes under its
+// name in written, and returns the first that an earlier target already
+// writes along with that target's name, or "" if none is shared
+func sharedFile(written map[string]string, name string, cfg *config.Config) (string, string) {
+	for _, path := range []string{cfg.SVGFile, cfg.MobileSVGFile, cfg.StatsFile} {
+		if path == "" {
+			continue
+		}
+		if other, ok := written[filepath.Clean(path)]; ok {
+			return path, other
+		}
+		written[filepath.Clean(path)] = name
+	}
+	return "", ""
 }
 
-// getTokenManager creates and initializes a token manager
-func getTokenManager(actionsHandler *github.ActionsHandler) (*auth.TokenManager, error) {
-	// Get credentials from environment variables
-	clientID := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_ID", "")
-	clientSecret := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_SECRET", "")
-	refreshToken := actionsHandler.GetEnvWithFallback("STRAVA_REFRESH_TOKEN", "")
+// fetchConfig returns the config activities are fetched with so one fetch
+// serves every target, with their activity types and details combined. A
+// target without activity types shows every type, so all are fetched.
+func fetchConfig(cfg *config.Config, targets []target) *config.Config {
+	fetchCfg := *cfg
+	fetchCfg.ActivityTypes = nil
 
-	if clientID == "" || clientSecret == "" || refreshToken == "" {
-		return nil, fmt.Errorf("STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET, and STRAVA_REFRESH_TOKEN environment variables must be set")
+	allTypes := false
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		allTypes = allTypes || len(target.cfg.ActivityTypes) == 0
+		for _, activityType := range target.cfg.ActivityTypes {
+			if !seen[activityType] {
+				seen[activityType] = true
+				fetchCfg.ActivityTypes = append(fetchCfg.ActivityTypes, activityType)
+			}
+		}
+		fetchCfg.FetchDetails = fetchCfg.FetchDetails || target.cfg.FetchDetails
+		fetchCfg.CorrectElevation = fetchCfg.CorrectElevation || target.cfg.CorrectElevation
+	}
+	if allTypes {
+		fetchCfg.ActivityTypes = nil
 	}
 
-	// Create token manager
-	tokenManager := auth.NewTokenManager(clientID, clientSecret, refreshToken)
+	return &fetchCfg
+}
+
+// fetchRange returns the range covering the fetch ranges of every target.
+// It's read once the source is open, which may have looked up where the
+// athlete's history starts.
+func fetchRange(targets []target) (time.Time, time.Time, error) {
+	var start, end time.Time
+	for i, target := range targets {
+		targetStart, targetEnd, err := target.cfg.GetFetchRange()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		// Year-to-date README variables count every activity since January 1st
+		if usesYearToDate(target) {
+			yearStart, _, err := target.cfg.GetYearToDateRange()
+			if err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			if yearStart.Before(targetStart) {
+				targetStart = yearStart
+			}
+		}
+		if i == 0 || targetStart.Before(start) {
+			start = targetStart
+		}
+		if i == 0 || targetEnd.After(end) {
+			end = targetEnd
+		}
+	}
+
+	return start, end, nil
+}
+
+// usesYearToDate reports whether the target's README has a year-to-date
+// variable. A README that can't be read is reported when it's updated.
+func usesYearToDate(t target) bool {
+	if t.readme == "" {
+		return false
+	}
+
+	names, err := github.NewReadmeUpdater(t.readme, t.cfg.Profile, t.cfg.Debug).Placeholders()
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "_ytd") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterActivityTypes returns the activities of the given types
+func filterActivityTypes(activities []strava.SummaryActivity, types []string) []strava.SummaryActivity {
+	var filtered []strava.SummaryActivity
+	for _, activity := range activities {
+		if includesType(types, activity.Type) {
+			filtered = append(filtered, activity)
+		}
+	}
+	return filtered
+}
+
+// handleGenerateCommand generates SVG without updating README
+func handleGenerateCommand(cfg *config.Config, actionsHandler *github.ActionsHandler) {
+	activities := fetchToStderr(cfg, actionsHandler)
+	printHeatmap(cfg, actionsHandler, activities)
+}
+
+// fetchToStderr fetches the activities of a single config, writing errors
+// and warnings to stderr to keep stdout clean for the output
+func fetchToStderr(cfg *config.Config, actionsHandler *github.ActionsHandler) []strava.SummaryActivity {
+	// Connect to the configured provider
+	targets := []target{{cfg: cfg}}
+	fetchCfg := fetchConfig(cfg, targets)
+	warn := func(message string) { fmt.Fprintf(os.Stderr, "Warning: %s\n", message) }
+	source, err := openSource(fetchCfg, targets, actionsHandler, warn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open activity source: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get activity date range, including any history needed for intensity normalization
+	startDate, endDate, err := fetchRange(targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to get date range: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fetch activities
+	activities, err := source.FetchActivities(startDate, endDate, fetchCfg.ActivityTypes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to fetch activities: %v\n", err)
+		os.Exit(1)
+	}
+	return activities
+}
+
+// handleStatsJSONCommand prints the full stats and daily totals of the
+// displayed range as JSON, or writes them to outPath. Like the stats file,
+// they're refused in privacy mode.
+func handleStatsJSONCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, outPath string) {
+	if cfg.PrivacyMode {
+		fmt.Fprintf(os.Stderr, "Error: -stats-json can't be used with privacyMode\n")
+		os.Exit(1)
+	}
+
+	activities := processor.IgnoreActivities(fetchToStderr(cfg, actionsHandler), cfg.IgnoreActivityIDs)
+
+	// Place activities on the same days and range as the heatmap would
+	if cfg.TimeZone == "" {
+		cfg.TimeZone = processor.InferTimeZone(activities)
+	}
+	if cfg.FirstActivity.IsZero() {
+		cfg.FirstActivity = processor.FirstActivityDate(activities)
+	}
+
+	summary, err := summarize(cfg, activities)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to aggregate activities: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := processor.NewStatsExport(summary.aggregator, summary.start, summary.end, cfg.MetricType, cfg.Language, cfg.Units).JSON()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outPath == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to create %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+	}
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write stats: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printHeatmap renders the heatmap and prints just the image to stdout,
+// writing anything else to stderr
+func printHeatmap(cfg *config.Config, actionsHandler *github.ActionsHandler, activities []strava.SummaryActivity) {
+	// Leave out activities the config ignores
+	activities = processor.IgnoreActivities(activities, cfg.IgnoreActivityIDs)
+
+	// Generate SVG
+	svgGenerator := svg.NewGenerator(cfg)
+	svgContent, err := svgGenerator.GenerateHeatmap(activities)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to generate heatmap SVG: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Note risky jumps in training load, keeping stdout clean for the SVG
+	if len(svgGenerator.RampWarnings) > 0 && os.Getenv("GITHUB_STEP_SUMMARY") != "" {
+		if err := actionsHandler.CreateSummary(rampSummary(svgGenerator.RampWarnings, cfg.ACWRThreshold)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to write step summary: %v\n", err)
+		}
+	}
+	if len(svgGenerator.HeartRateWarnings) > 0 && !cfg.PrivacyMode && os.Getenv("GITHUB_STEP_SUMMARY") != "" {
+		if err := actionsHandler.CreateSummary(heartRateSummary(svgGenerator.HeartRateWarnings)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to write step summary: %v\n", err)
+		}
+	}
+
+	// Verify the SVG starts with an opening tag
+	if !strings.HasPrefix(svgContent, "<svg") {
+		fmt.Fprintf(os.Stderr, "Warning: Generated SVG doesn't start with <svg> tag!\n")
+		svgIndex := strings.Index(svgContent, "<svg")
+		if svgIndex != -1 {
+			// Extract just the SVG content
+			fmt.Fprintf(os.Stderr, "Fixing SVG content...\n")
+			svgContent = svgContent[svgIndex:]
+		}
+	}
+
+	// Print just the image to stdout with no additional output
+	if cfg.OutputFormat == "png" {
+		png, err := svg.RasterizePNG(svgContent, cfg.PNGDPI)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to export PNG: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(png)
+		return
+	}
+	fmt.Print(svgContent)
+}
+
+// handleTestCommand tests configuration and authentication
+func handleTestCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, readmeFile string) {
+	fmt.Println("Testing configuration and authentication...")
+
+	// Test configuration
+	fmt.Println("\nConfiguration:")
+	fmt.Printf("  Activity Types: %v\n", cfg.ActivityTypes)
+	fmt.Printf("  Metric Type: %s\n", cfg.MetricType)
+	fmt.Printf("  Date Range: %s\n", cfg.DateRange)
+
+	// Test date range
+	startDate, endDate, err := cfg.GetDateRange()
+	if err != nil {
+		fmt.Printf("  Date Range Error: %v\n", err)
+	} else {
+		fmt.Printf("  Start Date: %s\n", startDate.Format("2006-01-02"))
+		fmt.Printf("  End Date: %s\n", endDate.Format("2006-01-02"))
+	}
+
+	// Test Strava authentication
+	fmt.Println("\nStrava Authentication:")
+	store := openCache(cfg)
+	tokenManager, err := getTokenManager(cfg, actionsHandler, store)
+	if err != nil {
+		fmt.Printf("  Authentication Error: %v\n", err)
+		return
+	}
+
+	// Test token refresh
+	fmt.Println("  Refreshing token...")
+	err = tokenManager.RefreshAccessToken()
+	if err != nil {
+		fmt.Printf("  Token Refresh Error: %v\n", err)
+		return
+	}
+
+	// Create Strava client and test connection
+	stravaClient := strava.NewClient(tokenManager, cfg.Debug, httpOptions(cfg))
+
+	// Get athlete data
+	fmt.Println("  Fetching athlete data...")
+	athlete, err := stravaClient.GetAthlete()
+	if err != nil {
+		fmt.Printf("  API Error: %v\n", err)
+		return
+	}
+
+	// Print athlete info
+	if firstName, ok := athlete["firstname"].(string); ok {
+		fmt.Printf("  Athlete: %s", firstName)
+		if lastName, ok := athlete["lastname"].(string); ok {
+			fmt.Printf(" %s", lastName)
+		}
+		fmt.Println()
+	}
+
+	// Check that a full update fits within the remaining API quota
+	fmt.Println("\nStrava API Usage:")
+	athleteID, _ := athlete["id"].(float64)
+	estimate, err := estimateFullUpdate(cfg, stravaClient, store, int64(athleteID))
+	if err != nil {
+		fmt.Printf("  Estimate Error: %v\n", err)
+	}
+	printAPIUsage(cfg, stravaClient.Stats().RateLimit, estimate)
+
+	// Test README markers if updating
+	fmt.Println("\nREADME Validation:")
+	readmeUpdater := github.NewReadmeUpdater(readmeFile, cfg.Profile, cfg.Debug)
+	valid, err := readmeUpdater.ValidateReadme()
+	if err != nil {
+		fmt.Printf("  README Error: %v\n", err)
+	} else if valid {
+		fmt.Println("  README markers are valid")
+	}
+
+	fmt.Println("\nTest completed successfully!")
+}
+
+// requestEstimate is the number of API requests a full update of the
+// configured range is expected to make
+type requestEstimate struct {
+	Activities int    // Activities expected in the range
+	Source     string // Where the activity count comes from
+	Athlete    int    // Athlete profile requests, for the FTP
+	Pages      int    // Activity list pages
+	Details    int    // Detailed activity requests
+	Streams    int    // Altitude stream requests
+}
+
+// Total returns the number of requests estimated
+func (e requestEstimate) Total() int {
+	return e.Athlete + e.Pages + e.Details + e.Streams
+}
+
+// estimateFullUpdate estimates the requests an update without the cache
+// would make. The activity count comes from the cache when it covers the
+// range, and is otherwise extrapolated from the athlete's Strava totals.
+func estimateFullUpdate(cfg *config.Config, stravaClient *strava.Client, store *cache.Store, athleteID int64) (requestEstimate, error) {
+	var estimate requestEstimate
+	if cfg.MetricType == "tss" && cfg.FTP == 0 {
+		estimate.Athlete = 1
+	}
+
+	startDate, endDate, err := cfg.GetFetchRange()
+	if err != nil {
+		return estimate, fmt.Errorf("error getting date range: %w", err)
+	}
+
+	var state *cache.ActivityState
+	if store != nil {
+		if state, err = store.LoadActivities(); err != nil {
+			return estimate, err
+		}
+	}
+
+	withGPS := 0
+	if state != nil && state.Covers(startDate, cfg.ActivityTypes) {
+		for _, activity := range state.Activities {
+			if activity.StartDate.Before(startDate) || !activity.StartDate.Before(endDate.AddDate(0, 0, 1)) {
+				continue
+			}
+			estimate.Activities++
+			if len(activity.StartLatlng) >= 2 {
+				withGPS++
+			}
+		}
+		estimate.Source = "cached activities"
+	} else {
+		stats, err := stravaClient.GetAthleteStats(athleteID)
+		if err != nil {
+			return estimate, fmt.Errorf("error fetching athlete stats: %w", err)
+		}
+
+		// Extrapolate this year's pace, or the last four weeks' early in
+		// the year, over the range, but never past the all-time count
+		now := time.Now()
+		elapsed := now.Sub(time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())).Hours() / 24
+		count, days := stats.YTDRunTotals.Count+stats.YTDRideTotals.Count+stats.YTDSwimTotals.Count, elapsed
+		if elapsed < 28 {
+			count, days = stats.RecentRunTotals.Count+stats.RecentRideTotals.Count+stats.RecentSwimTotals.Count, 28
+		}
+		rangeDays := endDate.Sub(startDate).Hours()/24 + 1
+		estimate.Activities = min(int(math.Ceil(float64(count)/days*rangeDays)),
+			stats.AllRunTotals.Count+stats.AllRideTotals.Count+stats.AllSwimTotals.Count)
+		withGPS = estimate.Activities // Indoor activities can't be told apart, so assume all have GPS
+		estimate.Source = "Strava's run, ride and swim totals"
+	}
+
+	// A page that isn't full ends the list, so a full last page needs one more
+	estimate.Pages = estimate.Activities/100 + 1
+	if cfg.FetchDetails {
+		estimate.Details = estimate.Activities
+	}
+	if cfg.CorrectElevation {
+		estimate.Streams = withGPS
+	}
+
+	return estimate, nil
+}
+
+// printAPIUsage prints the rate limit status and whether a full update is
+// expected to fit within what remains of it
+func printAPIUsage(cfg *config.Config, rateLimit *strava.RateLimit, estimate requestEstimate) {
+	if rateLimit == nil {
+		fmt.Println("  Rate Limit: not reported by Strava")
+	} else {
+		fmt.Printf("  15-Minute Usage: %d of %d requests, %d remaining\n",
+			rateLimit.ShortTermUsage, rateLimit.ShortTermLimit, rateLimit.ShortTermRemaining)
+		fmt.Printf("  Daily Usage: %d of %d requests, %d remaining\n",
+			rateLimit.DailyUsage, rateLimit.DailyLimit, rateLimit.DailyRemaining)
+	}
+
+	if estimate.Source == "" {
+		return
+	}
+	fmt.Printf("  Full Update: about %d requests for %d activities, estimated from %s\n",
+		estimate.Total(), estimate.Activities, estimate.Source)
+	if estimate.Details > 0 || estimate.Streams > 0 {
+		fmt.Printf("    %d activity pages, %d activity details, %d altitude streams\n",
+			estimate.Pages, estimate.Details, estimate.Streams)
+	}
+
+	total := estimate.Total()
+	switch {
+	case cfg.MaxAPIRequests > 0 && total > cfg.MaxAPIRequests:
+		fmt.Printf("  Quota: a full update exceeds maxApiRequests (%d), so it will take %d runs to complete\n",
+			cfg.MaxAPIRequests, (total+cfg.MaxAPIRequests-1)/cfg.MaxAPIRequests)
+	case rateLimit == nil:
+		fmt.Println("  Quota: unknown until Strava reports its rate limit")
+	case total > rateLimit.DailyRemaining:
+		fmt.Println("  Quota: a full update does not fit in today's remaining requests; set maxApiRequests to spread it over several runs")
+	case total > rateLimit.ShortTermRemaining:
+		fmt.Println("  Quota: a full update does not fit in this 15-minute window; set maxApiRequests to spread it over several runs")
+	default:
+		fmt.Println("  Quota: a full update fits within the remaining requests")
+	}
+}
+
+// handleServeCommand runs the multi-user heatmap service
+func handleServeCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, addr, baseURL, dataDir, storageURL string) {
+	// The service authenticates athletes itself, so only the app credentials are needed
+	clientID := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_ID", "")
+	clientSecret := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_SECRET", "")
+
+	if clientID == "" || clientSecret == "" {
+		fmt.Println("Error: STRAVA_CLIENT_ID and STRAVA_CLIENT_SECRET environment variables must be set.")
+		os.Exit(1)
+	}
+
+	oauth := auth.NewOAuthConfig(clientID, clientSecret,
+		strings.TrimSuffix(baseURL, "/")+"/callback", []string{"read", "activity:read_all"})
+	users := server.NewUserStore(filepath.Join(dataDir, "users"))
+	srv := server.NewServer(cfg, oauth, users, baseURL, cfg.Debug)
+
+	if storageURL != "" {
+		store, err := openBucket(actionsHandler, storageURL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		store.CacheControl = fmt.Sprintf("public, max-age=%d", int(srv.RenderTTL.Seconds()))
+		srv.Store = store
+
+		// Keep the bucket current for viewers the CDN answers on its own
+		go func() {
+			for {
+				srv.RefreshAll()
+				time.Sleep(srv.RenderTTL)
+			}
+		}()
+		fmt.Printf("Publishing heatmaps to %s\n", storageURL)
+	}
+
+	fmt.Printf("Serving heatmaps on %s, connect at %s/connect\n", addr, strings.TrimSuffix(baseURL, "/"))
+	if err := http.ListenAndServe(addr, srv.Handler()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleRelayCommand listens for Strava webhook events and dispatches a
+// workflow run in the repository for each burst of activity events
+func handleRelayCommand(cfg *config.Config, actionsHandler *github.ActionsHandler, addr, repo, workflow, ref string) {
+	verifyToken := actionsHandler.GetEnvWithFallback("STRAVA_WEBHOOK_VERIFY_TOKEN", "")
+	token := actionsHandler.GetEnvWithFallback("GITHUB_TOKEN", "")
+
+	if verifyToken == "" || token == "" {
+		fmt.Println("Error: STRAVA_WEBHOOK_VERIFY_TOKEN and GITHUB_TOKEN environment variables must be set.")
+		os.Exit(1)
+	}
+
+	// Only events of this athlete and subscription are relayed. Strava
+	// assigns the subscription id once the relay has answered its challenge,
+	// so until it is set every event is rejected.
+	ownerID, err := strconv.ParseInt(actionsHandler.GetEnvWithFallback("STRAVA_ATHLETE_ID", ""), 10, 64)
+	if err != nil {
+		fmt.Println("Error: STRAVA_ATHLETE_ID must be set to the id of the athlete whose activities are relayed.")
+		os.Exit(1)
+	}
+	var subscriptionID int64
+	if raw := actionsHandler.GetEnvWithFallback("STRAVA_WEBHOOK_SUBSCRIPTION_ID", ""); raw != "" {
+		subscriptionID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			fmt.Printf("Error: Invalid STRAVA_WEBHOOK_SUBSCRIPTION_ID %q\n", raw)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("Warning: STRAVA_WEBHOOK_SUBSCRIPTION_ID is not set, so events are rejected until the relay restarts with the id returned when subscribing")
+	}
+
+	// Look the repository up, which also checks the token can see it
+	var target *github.Repository
+	if repo == "" {
+		target, err = github.DiscoverProfileRepository(token)
+	} else {
+		target, err = github.GetRepository(token, repo)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if ref == "" {
+		ref = target.DefaultBranch
+	}
+
+	dispatcher := github.NewDispatcher(token, target.FullName, cfg.Debug)
+	dispatcher.Workflow = workflow
+	dispatcher.Ref = ref
+	relay := server.NewRelay(verifyToken, subscriptionID, ownerID, dispatcher, cfg.Debug)
+
+	fmt.Printf("Relaying Strava webhook events on %s/webhook to %s (branch %s", addr, target.FullName, ref)
+	if target.ReadmePath != "" {
+		fmt.Printf(", README at %s", target.ReadmePath)
+	}
+	fmt.Println(")")
+	if err := http.ListenAndServe(addr, relay.Handler()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// openBucket creates the artifact store for a bucket URL. S3 reads the
+// standard AWS_* credentials, and STORAGE_ENDPOINT points it at another
+// S3-compatible service. Google Cloud Storage uses HMAC keys.
+func openBucket(actionsHandler *github.ActionsHandler, storageURL string) (*server.BucketStore, error) {
+	store, err := server.NewBucketStore(storageURL,
+		actionsHandler.GetEnvWithFallback("AWS_REGION", ""),
+		actionsHandler.GetEnvWithFallback("STORAGE_ENDPOINT", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(storageURL, "gs://") {
+		store.AccessKey = actionsHandler.GetEnvWithFallback("GCS_HMAC_ACCESS_ID", "")
+		store.SecretKey = actionsHandler.GetEnvWithFallback("GCS_HMAC_SECRET", "")
+	} else {
+		store.AccessKey = actionsHandler.GetEnvWithFallback("AWS_ACCESS_KEY_ID", "")
+		store.SecretKey = actionsHandler.GetEnvWithFallback("AWS_SECRET_ACCESS_KEY", "")
+	}
+
+	if store.AccessKey == "" || store.SecretKey == "" {
+		return nil, fmt.Errorf("credentials for %s are not set", storageURL)
+	}
+	return store, nil
+}
+
+// resolveFTP reads the athlete's FTP from their Strava profile when the
+// tss metric is used and no FTP is configured
+func resolveFTP(cfg *config.Config, stravaClient *strava.Client) error {
+	if cfg.MetricType != "tss" || cfg.FTP > 0 {
+		return nil
+	}
+
+	athlete, err := stravaClient.GetAthlete()
+	if err != nil {
+		return fmt.Errorf("error fetching athlete profile: %w", err)
+	}
+
+	ftp, ok := athlete["ftp"].(float64)
+	if !ok || ftp <= 0 {
+		return fmt.Errorf("no FTP set in the Strava profile, set ftp in the config")
+	}
+
+	cfg.FTP = int(ftp)
+	return nil
+}
+
+// resolveFirstActivity looks up the athlete's first activity when the config
+// reaches back to it, so all history is fetched from that day rather than
+// from 2009
+func resolveFirstActivity(cfg *config.Config, stravaClient *strava.Client) error {
+	if !cfg.UsesAllHistory() || !cfg.FirstActivity.IsZero() {
+		return nil
+	}
+
+	first, err := stravaClient.GetFirstActivityDate()
+	if err != nil {
+		return err
+	}
+	cfg.FirstActivity = first
+	return nil
+}
+
+// openCache returns the state cache, or nil if no cache directory is configured
+func openCache(cfg *config.Config) *cache.Store {
+	if cfg.CacheDir == "" {
+		return nil
+	}
+	return cache.NewStore(cfg.CacheDir, cfg.Profile, cfg.Debug)
+}
+
+// fetchActivities fetches activities in the given range, syncing only recent
+// activities when the cache already holds the rest
+func fetchActivities(cfg *config.Config, stravaClient *strava.Client, store *cache.Store, startDate, endDate time.Time, report *strava.FetchReport) ([]strava.SummaryActivity, error) {
+	var state *cache.ActivityState
+	if store != nil && !refreshCache {
+		var err error
+		if state, err = store.LoadActivities(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fall back to a full fetch if the cache doesn't cover this range
+	fetchStart := startDate
+	if state != nil && state.Covers(startDate, cfg.ActivityTypes) {
+		fetchStart = state.LastSync.Add(-cache.SyncOverlap)
+		if fetchStart.Before(startDate) {
+			fetchStart = startDate
+		}
+		report.Incremental = true
+		if cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Syncing activities since %s from cache\n", fetchStart.Format("2006-01-02"))
+		}
+	} else {
+		state = &cache.ActivityState{Schema: cache.ActivitySchema, ActivityTypes: cfg.ActivityTypes}
+	}
+
+	// Keep what was fetched if the request budget runs out
+	syncTime := time.Now()
+	activities, err := stravaClient.GetAllActivities(fetchStart, endDate, cfg.ActivityTypes)
+	if errors.Is(err, strava.ErrRequestBudget) {
+		report.BudgetExhausted = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	report.ActivitiesFetched = len(activities)
+	report.ActivitiesAdded, report.ActivitiesUpdated = state.Merge(activities, startDate)
+
+	// A complete fetch shows which cached activities in its window are gone
+	if report.Incremental && !report.BudgetExhausted {
+		report.ActivitiesRemoved = state.Prune(activities, fetchStart, endDate)
+	}
+
+	// Webhook events reach edits and deletions older than the fetch window
+	if !report.BudgetExhausted {
+		if err := applyWebhookEvents(cfg, stravaClient, state, activities, startDate, endDate, report); err != nil {
+			return nil, err
+		}
+	}
+	report.TotalActivities = len(state.Activities)
+
+	if !report.BudgetExhausted {
+		state.LastSync = syncTime
+	} else {
+		// Pages come oldest first, so the next run resumes after the latest
+		// activity fetched rather than from the start
+		for _, activity := range activities {
+			if activity.StartDate.After(state.LastSync) {
+				state.LastSync = activity.StartDate
+			}
+		}
+	}
+
+	// Fetch detailed activities for fields missing from summaries
+	if cfg.FetchDetails && !report.BudgetExhausted {
+		err := stravaClient.FillActivityDetails(state.Activities)
+		if errors.Is(err, strava.ErrRequestBudget) {
+			report.BudgetExhausted = true
+		} else if err != nil {
+			return nil, fmt.Errorf("error fetching activity details: %w", err)
+		}
+	}
+
+	// Recompute elevation gain from altitude streams
+	if cfg.CorrectElevation && !report.BudgetExhausted {
+		err := processor.CorrectElevation(stravaClient, state.Activities)
+		if errors.Is(err, strava.ErrRequestBudget) {
+			report.BudgetExhausted = true
+		} else if err != nil {
+			return nil, fmt.Errorf("error correcting elevation: %w", err)
+		}
+	}
+
+	if store != nil {
+		if err := store.SaveActivities(state); err != nil {
+			return nil, err
+		}
+	}
+
+	// The cache keeps Strava's gain, so turning correction off restores it
+	if cfg.CorrectElevation {
+		return processor.WithCorrectedElevation(state.Activities), nil
+	}
+	return state.Activities, nil
+}
+
+// applyWebhookEvents reconciles the cached activities with the Strava webhook
+// events in STRAVA_WEBHOOK_EVENTS, as forwarded by the relay. Events are
+// only hints: each activity is looked up again, so deleted activities that
+// Strava no longer returns are dropped, and created or edited ones that the
+// fetch didn't return are fetched individually. Events of other athletes are
+// ignored.
+func applyWebhookEvents(cfg *config.Config, stravaClient *strava.Client, state *cache.ActivityState, fetched []strava.SummaryActivity, startDate, endDate time.Time, report *strava.FetchReport) error {
+	raw := os.Getenv("STRAVA_WEBHOOK_EVENTS")
+	if raw == "" {
+		return nil
+	}
+
+	var events []strava.WebhookEvent
+	if err := json.Unmarshal([]byte(raw), &events); err != nil {
+		return fmt.Errorf("error parsing STRAVA_WEBHOOK_EVENTS: %w", err)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	athlete, err := stravaClient.GetAthlete()
+	if errors.Is(err, strava.ErrRequestBudget) {
+		report.BudgetExhausted = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching athlete profile: %w", err)
+	}
+	athleteID, _ := athlete["id"].(float64)
+
+	seen := make(map[int64]bool, len(fetched))
+	for _, activity := range fetched {
+		seen[activity.ID] = true
+	}
+
+	for _, event := range events {
+		if event.ObjectType != "activity" {
+			continue
+		}
+		if event.OwnerID != int64(athleteID) {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping webhook event for activity %d of another athlete\n", event.ObjectID)
+			continue
+		}
+
+		// A fetched activity is current, whatever the event says
+		if seen[event.ObjectID] {
+			continue
+		}
+		seen[event.ObjectID] = true
+
+		detail, err := stravaClient.GetActivity(event.ObjectID)
+		if errors.Is(err, strava.ErrRequestBudget) {
+			report.BudgetExhausted = true
+			return nil
+		}
+		if errors.Is(err, strava.ErrNotFound) {
+			report.ActivitiesRemoved += state.Remove(event.ObjectID)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping webhook event for activity %d: %v\n", event.ObjectID, err)
+			continue
+		}
+
+		// An edit may move the activity out of the heatmap, e.g. by changing its type
+		activity := detail.SummaryActivity
+		if activity.StartDate.Before(startDate) || activity.StartDate.After(endDate) || !includesType(cfg.ActivityTypes, activity.Type) {
+			report.ActivitiesRemoved += state.Remove(activity.ID)
+			continue
+		}
+
+		added, updated := state.Merge([]strava.SummaryActivity{activity}, startDate)
+		report.ActivitiesAdded += added
+		report.ActivitiesUpdated += updated
+	}
+
+	return nil
+}
+
+// includesType reports whether an activity type is among the configured
+// types, where no types means all of them
+func includesType(types []string, activityType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == activityType {
+			return true
+		}
+	}
+	return false
+}
+
+// loadResponses hands the cached API responses to the client, if a cache is
+// configured
+func loadResponses(store *cache.Store, stravaClient *strava.Client) error {
+	if store == nil || refreshCache {
+		return nil
+	}
+
+	responses, err := store.LoadResponses()
+	if err != nil {
+		return err
+	}
+	stravaClient.SetCachedResponses(responses)
+	return nil
+}
+
+// budgetWarning explains that fetching stopped at the request budget
+func budgetWarning(cfg *config.Config) string {
+	return fmt.Sprintf("Stopped after %d API requests (maxApiRequests), so recent activities or details may be missing; the next run picks up where this one left off", cfg.MaxAPIRequests)
+}
+
+// writeReport writes the fetch report to the configured file, if any, and
+// sets it as the fetch-report output when running in GitHub Actions
+func writeReport(cfg *config.Config, actionsHandler *github.ActionsHandler, report *strava.FetchReport) error {
+	if cfg.FetchReport != "" {
+		if err := report.Write(cfg.FetchReport); err != nil {
+			return err
+		}
+	}
+
+	if os.Getenv("GITHUB_OUTPUT") == "" {
+		return nil
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	return actionsHandler.SetOutput("fetch-report", data)
+}
+
+// activitySummary holds the aggregated activities behind the README
+// variables and the stats file
+type activitySummary struct {
+	aggregator *processor.ActivityAggregator
+	start, end time.Time
+	now        time.Time
+}
+
+// summarize aggregates the fetched activities in the timezone the heatmap
+// was drawn in
+func summarize(cfg *config.Config, activities []strava.SummaryActivity) (*activitySummary, error) {
+	location, err := cfg.GetTimeZoneLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	startDate, endDate, err := cfg.GetDateRange()
+	if err != nil {
+		return nil, err
+	}
+
+	aggregator := processor.NewActivityAggregator(activities, location, float64(cfg.FTP))
+	aggregator.TimeBasis = cfg.TimeBasis
+	aggregator.Aggregate()
+
+	return &activitySummary{
+		aggregator: aggregator,
+		start:      startDate,
+		end:        endDate,
+		now:        time.Now().In(location),
+	}, nil
+}
+
+// templateValues returns the README template variables
+func (s *activitySummary) templateValues(cfg *config.Config) map[string]string {
+	return processor.TemplateValues(s.aggregator, s.start, s.end, s.now, cfg.Language, cfg.Units, cfg.DurationStyle, cfg.PrivacyMode)
+}
+
+// altText returns the alt text of the heatmap image
+func (s *activitySummary) altText(cfg *config.Config) string {
+	return processor.AltText(s.aggregator, s.start, s.end, cfg.Language, cfg.Units, cfg.PrivacyMode)
+}
+
+// writeSVGFile writes the heatmap to its own file, and the mobile layout to
+// another if configured, and returns the image tag referencing them from the
+// README
+func writeSVGFile(cfg *config.Config, readmeFile, svgContent, mobileContent, alt string) (string, error) {
+	src, err := writeHeatmapFile(cfg, readmeFile, cfg.SVGFile, svgContent)
+	if err != nil {
+		return "", err
+	}
+	if cfg.MobileSVGFile == "" {
+		return github.ImageTag(src, alt), nil
+	}
+
+	mobileSrc, err := writeHeatmapFile(cfg, readmeFile, cfg.MobileSVGFile, mobileContent)
+	if err != nil {
+		return "", err
+	}
+	return github.PictureTag(src, mobileSrc, alt), nil
+}
+
+// writeHeatmapFile writes a heatmap to a file, rasterized for the png output
+// format, and returns its path relative to the README
+func writeHeatmapFile(cfg *config.Config, readmeFile, path, svgContent string) (string, error) {
+	content := []byte(svgContent)
+	if cfg.OutputFormat == "png" {
+		png, err := svg.RasterizePNG(svgContent, cfg.PNGDPI)
+		if err != nil {
+			return "", err
+		}
+		content = png
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("error creating directory for %s: %w", path, err)
+		}
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", path, err)
+	}
+
+	// The image is resolved relative to the README
+	src, err := filepath.Rel(filepath.Dir(readmeFile), path)
+	if err != nil {
+		return "", fmt.Errorf("error locating %s from the README: %w", path, err)
+	}
+
+	return filepath.ToSlash(src), nil
+}
+
+// writeStats writes the stats file, if configured
+func writeStats(cfg *config.Config, summary *activitySummary) error {
+	if cfg.StatsFile == "" {
+		return nil
+	}
+
+	snapshot := processor.NewStatsSnapshot(summary.aggregator, summary.start, summary.end, summary.now)
+	return snapshot.Write(cfg.StatsFile)
+}
+
+// writeStatOutputs sets the targets' key stats as Actions outputs for later
+// workflow steps, one line per target in order like the files. Targets in
+// privacy mode leave their line empty, like the stats file, and an output
+// no target has is left unset.
+func writeStatOutputs(actionsHandler *github.ActionsHandler, stats []map[string]string) error {
+	var names []string
+	for _, outputs := range stats {
+		for name := range outputs {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := make([]string, len(stats))
+		for i, outputs := range stats {
+			values[i] = outputs[name]
+		}
+		if err := actionsHandler.SetOutput(name, strings.Join(values, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSummary returns a Markdown description of an update: the range
+// shown, the activities in it by type and, when it has its own file, the
+// heatmap. Counts
+// are left out in privacy mode, since a public repository's summaries are
+// public too.
+func updateSummary(cfg *config.Config, summary *activitySummary) string {
+	totals := processor.SumPeriod(summary.aggregator.GetOrderedDates(summary.start, summary.end))
+	nf := processor.GetNumberFormat(cfg.Language)
+
+	var sb strings.Builder
+	sb.WriteString("### Strava heatmap updated\n\n")
+	sb.WriteString(fmt.Sprintf("Showing %s to %s.\n\n", summary.start.Format("Jan 2, 2006"), summary.end.Format("Jan 2, 2006")))
+
+	if !cfg.PrivacyMode {
+		sb.WriteString(fmt.Sprintf("%s activities on %s active days.\n\n", nf.FormatInt(totals.Activities), nf.FormatInt(totals.ActiveDays)))
+
+		if len(totals.Types) > 0 {
+			types := make([]string, 0, len(totals.Types))
+			for activityType := range totals.Types {
+				types = append(types, activityType)
+			}
+			sort.Slice(types, func(i, j int) bool {
+				if totals.Types[types[i]] != totals.Types[types[j]] {
+					return totals.Types[types[i]] > totals.Types[types[j]]
+				}
+				return types[i] < types[j]
+			})
+
+			sb.WriteString("| Type | Activities |\n| --- | --- |\n")
+			for _, activityType := range types {
+				sb.WriteString(fmt.Sprintf("| %s | %s |\n", activityType, nf.FormatInt(totals.Types[activityType])))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if image := github.SummaryImage(cfg.SVGFile, summary.altText(cfg)); image != "" {
+		sb.WriteString(image + "\n")
+	}
+
+	return sb.String()
+}
+
+// rampSummary returns a Markdown note listing the weeks whose acute:chronic
+// workload ratio exceeds the threshold
+func rampSummary(warnings []processor.RampWarning, threshold float64) string {
+	var sb strings.Builder
+	sb.WriteString("### Training load warnings\n\n")
+	sb.WriteString(fmt.Sprintf("These weeks ramped up faster than an acute:chronic workload ratio of %.2f, which is linked to a higher injury risk:\n\n", threshold))
+	sb.WriteString("| Week of | Ratio |\n| --- | --- |\n")
+	for _, warning := range warnings {
+		sb.WriteString(fmt.Sprintf("| %s | %.2f |\n", warning.WeekStart.Format("2006-01-02"), warning.Ratio))
+	}
+	return sb.String()
+}
+
+// heartRateSummary returns a Markdown table of the weeks with an elevated
+// average heart rate, for the step summary
+func heartRateSummary(warnings []processor.HeartRateWarning) string {
+	var sb strings.Builder
+	sb.WriteString("### Elevated heart rate\n\n")
+	sb.WriteString(fmt.Sprintf("Average heart rate in these weeks was more than %.0f bpm above the weeks before, a possible sign of fatigue or illness:\n\n", processor.ElevatedHeartRate))
+	sb.WriteString("| Week of | Average | Baseline |\n| --- | --- | --- |\n")
+	for _, warning := range warnings {
+		sb.WriteString(fmt.Sprintf("| %s | %.0f bpm | %.0f bpm |\n", warning.WeekStart.Format("2006-01-02"), warning.AvgHeartRate, warning.Baseline))
+	}
+	return sb.String()
+}
+
+// saveCache stores the current tokens and API responses and returns the cache
+// key, or an empty key if no cache is configured
+func saveCache(store *cache.Store, tokenManager *auth.TokenManager, stravaClient *strava.Client) (string, error) {
+	// Replayed tokens are redacted, so a replay leaves the cache as it was
+	if store == nil || replaying() {
+		return "", nil
+	}
+
+	if err := store.SaveResponses(stravaClient.CachedResponses()); err != nil {
+		return "", err
+	}
+
+	if err := store.SaveToken(&cache.TokenState{
+		RefreshToken: tokenManager.RefreshToken,
+		AccessToken:  tokenManager.AccessToken,
+		ExpiresAt:    tokenManager.ExpiresAt,
+	}); err != nil {
+		return "", err
+	}
+
+	return store.Key()
+}
+
+// replaying reports whether API traffic is answered from a fixture
+func replaying() bool {
+	return cassette != nil && cassette.Replaying()
+}
+
+// httpOptions returns the configured HTTP options, recording or replaying
+// through the cassette if one is open
+func httpOptions(cfg *config.Config) strava.HTTPOptions {
+	options := cfg.GetHTTPOptions()
+	options.Cassette = cassette
+	return options
+}
+
+// openTokenStore opens the token store described by the tokenStore setting:
+// a JSON file, or an Actions secret of the current repository written with
+// the token in GH_TOKEN
+func openTokenStore(spec string, actionsHandler *github.ActionsHandler) (auth.TokenStore, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	if kind == "file" {
+		return auth.NewFileTokenStore(arg), nil
+	}
+
+	repo := os.Getenv("GITHUB_REPOSITORY")
+	token := actionsHandler.GetEnvWithFallback("GH_TOKEN", "")
+	if repo == "" || token == "" {
+		return nil, fmt.Errorf("tokenStore %q needs GITHUB_REPOSITORY and a GH_TOKEN allowed to write its secrets", spec)
+	}
+	return github.NewSecretTokenStore(token, repo, arg), nil
+}
+
+// refreshTokenVar returns the environment variable holding the refresh
+// token: the roster athlete's own, or STRAVA_REFRESH_TOKEN
+func refreshTokenVar(cfg *config.Config) string {
+	if cfg.Athlete != "" {
+		return config.AthleteTokenVar(cfg.Athlete)
+	}
+	return "STRAVA_REFRESH_TOKEN"
+}
+
+// getTokenManager creates and initializes a token manager, resuming from
+// cached tokens when available
+func getTokenManager(cfg *config.Config, actionsHandler *github.ActionsHandler, store *cache.Store) (*auth.TokenManager, error) {
+	// Get credentials from environment variables, a roster athlete's refresh
+	// token from their own variable next to the coach's application
+	tokenVar := refreshTokenVar(cfg)
+	clientID := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_ID", "")
+	clientSecret := actionsHandler.GetEnvWithFallback("STRAVA_CLIENT_SECRET", "")
+	refreshToken := actionsHandler.GetEnvWithFallback(tokenVar, "")
+
+	if clientID == "" || clientSecret == "" || refreshToken == "" {
+		return nil, fmt.Errorf("STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET, and %s environment variables must be set", tokenVar)
+	}
+
+	// Create token manager
+	tokenManager := auth.NewTokenManager(clientID, clientSecret, refreshToken)
+	tokenManager.HTTPClient = strava.NewHTTPClient(httpOptions(cfg))
+
+	// Save rotated refresh tokens where the next run finds them. A replayed
+	// refresh returns the cassette's redacted token, which must never
+	// replace a real one.
+	if cfg.TokenStore != "" && !replaying() {
+		spec := cfg.TokenStore
+		if cfg.Athlete != "" {
+			spec = "secret:" + tokenVar
+		}
+		tokenStore, err := openTokenStore(spec, actionsHandler)
+		if err != nil {
+			return nil, err
+		}
+		token, err := tokenStore.Load()
+		if err != nil {
+			return nil, err
+		}
+		if token != nil && token.RefreshToken != "" {
+			tokenManager.RefreshToken = token.RefreshToken
+			tokenManager.AccessToken = token.AccessToken
+			tokenManager.ExpiresAt = token.ExpiresAt
+		}
+		tokenManager.Store = tokenStore
+	}
+
+	// Prefer the cached tokens, since Strava may have rotated the refresh token
+	if store != nil {
+		state, err := store.LoadToken()
+		if err != nil {
+			return nil, err
+		}
+		if state != nil && state.RefreshToken != "" {
+			tokenManager.RefreshToken = state.RefreshToken
+			tokenManager.AccessToken = state.AccessToken
+			tokenManager.ExpiresAt = state.ExpiresAt
+		}
+	}
 
 	return tokenManager, nil
 }
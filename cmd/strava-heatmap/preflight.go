@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/internal/github"
+)
+
+// preflightProblem is something that would fail a run, annotated on the
+// file it concerns, if any
+type preflightProblem struct {
+	title string
+	file  string
+	err   error
+}
+
+// sourceSecrets are the environment variables each provider needs set
+var sourceSecrets = map[string][]string{
+	"strava": {"STRAVA_CLIENT_ID", "STRAVA_CLIENT_SECRET", "STRAVA_REFRESH_TOKEN"},
+	"garmin": {"GARMIN_CLIENT_ID", "GARMIN_CLIENT_SECRET", "GARMIN_REFRESH_TOKEN"},
+}
+
+// sourcesWithoutFTP names the providers with no athlete profile to read FTP
+// from, as their errors describe them
+var sourcesWithoutFTP = map[string]string{
+	"garmin": "Garmin Connect",
+	"files":  "exported activities",
+	"export": "exported activities",
+}
+
+// handlePreflightCommand checks that an update can succeed, before any API
+// quota is spent: the provider's secrets are set, the config parses, every
+// README has its markers and the files written can be. Each problem is
+// annotated on its file, and the command fails if there are any.
+func handlePreflightCommand(configFile, readmeFile, profile, format string) {
+	actionsHandler := github.NewActionsHandler(false)
+	problems := preflight(configFile, readmeFile, profile, format)
+
+	for _, problem := range problems {
+		actionsHandler.LogFileError(problem.title, problem.file, problem.err.Error())
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Preflight failed with %d problem(s)\n", len(problems))
+		os.Exit(1)
+	}
+	actionsHandler.LogInfo("Preflight checks passed")
+}
+
+// preflight returns every problem that would fail an update
+func preflight(configFile, readmeFile, profile, format string) []preflightProblem {
+	var problems []preflightProblem
+	add := func(title, file string, err error) {
+		problems = append(problems, preflightProblem{title: title, file: file, err: err})
+	}
+
+	// Without a config only the default provider's secrets can be checked
+	cfg, err := loadConfig(configFile, profile)
+	if err == nil && format != "" {
+		cfg.OutputFormat = format
+		err = config.ValidateConfig(cfg)
+	}
+	if err != nil {
+		add("Invalid configuration", configFile, err)
+	}
+
+	// Without targets the README given is still checked for its markers
+	targets := []target{{cfg: &config.Config{Profile: profile}, readme: readmeFile}}
+	if cfg != nil {
+		if loaded, err := loadTargets(cfg, configFile, readmeFile); err != nil {
+			add("Invalid configuration", configFile, err)
+		} else {
+			targets = loaded
+		}
+	}
+	for _, target := range targets {
+		if target.cfg.OutputFormat == "png" && target.cfg.SVGFile == "" {
+			add("Invalid configuration", configFile, fmt.Errorf("outputFormat png needs svgFile set to a .png path"))
+		}
+	}
+
+	// The provider's credentials, or the exported activities it reads
+	name := sourceName
+	if name == "" && cfg != nil {
+		name = cfg.Provider
+	}
+	if name == "" {
+		name = "strava"
+	}
+	switch name {
+	case "files", "export":
+		path := activityDir
+		if name == "export" {
+			path = exportPath
+		}
+		if _, err := os.Stat(path); err != nil {
+			add("Missing exported activities", "", fmt.Errorf("-source %s reads %s: %w", name, path, err))
+		}
+	default:
+		if _, ok := sources[name]; !ok {
+			add("Invalid provider", configFile, fmt.Errorf("invalid provider: %s, must be one of %v", name, sourceNames()))
+		}
+	}
+	secrets := sourceSecrets[name]
+	if name == "strava" && cfg != nil && len(cfg.Roster) > 0 {
+		// Each roster athlete has a refresh token of their own
+		secrets = []string{"STRAVA_CLIENT_ID", "STRAVA_CLIENT_SECRET"}
+		for _, athlete := range cfg.Roster {
+			secrets = append(secrets, config.AthleteTokenVar(athlete.Name))
+		}
+	}
+	for _, secret := range secrets {
+		if strings.TrimSpace(os.Getenv(secret)) == "" {
+			add("Missing secret", "", fmt.Errorf("%s is not set or empty; add it as a repository secret and pass it to the action", secret))
+		}
+	}
+	if provider, ok := sourcesWithoutFTP[name]; ok {
+		if err := requireFTP(targets, provider); err != nil {
+			add("Invalid configuration", configFile, err)
+		}
+	}
+	if cfg != nil && name == "strava" && cfg.TokenStore != "" && !strings.HasPrefix(cfg.TokenStore, "file") {
+		if strings.TrimSpace(os.Getenv("GH_TOKEN")) == "" || os.Getenv("GITHUB_REPOSITORY") == "" {
+			add("Missing secret", "", fmt.Errorf("tokenStore %q needs GITHUB_REPOSITORY and a GH_TOKEN allowed to write its secrets", cfg.TokenStore))
+		}
+	}
+
+	// Every README needs its markers and must be writable, as must
+	// everything written next to it
+	for _, target := range targets {
+		updater := github.NewReadmeUpdater(target.readme, target.cfg.Profile, false)
+		if _, err := updater.ValidateReadme(); err != nil {
+			add("Missing README markers", target.readme, err)
+		} else if err := checkWritable(target.readme); err != nil {
+			add("README not writable", target.readme, err)
+		}
+
+		for _, path := range []string{target.cfg.SVGFile, target.cfg.MobileSVGFile, target.cfg.StatsFile, target.cfg.FetchReport, target.cfg.CacheDir} {
+			if path == "" {
+				continue
+			}
+			if err := checkWritable(path); err != nil {
+				add("Output not writable", "", err)
+			}
+		}
+
+		// Updated READMEs are pushed from the workflow's checkout
+		if err := checkPush(target.readme); err != nil {
+			add("Push not allowed", target.readme, err)
+		}
+	}
+
+	return problems
+}
+
+// checkWritable checks that path can be written: an existing file is opened
+// for writing, and otherwise a file is created and removed in the nearest
+// directory above it that exists, where missing directories would be made
+func checkWritable(path string) error {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("can't write %s: %w", path, err)
+		}
+		return f.Close()
+	}
+
+	dir := path
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".strava-heatmap-preflight-*")
+	if err != nil {
+		return fmt.Errorf("can't write %s: %w", path, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkPush checks, when running in GitHub Actions, that the checkout
+// holding a README may push, which fails when the workflow lacks the
+// contents: write permission. The dry run asks the remote for push access
+// to a branch that is never created, so it works on a detached HEAD too, and
+// READMEs outside a checkout with an origin are left to the workflow.
+func checkPush(readme string) error {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+
+	dir := filepath.Dir(readme)
+	if err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Run(); err != nil {
+		return nil
+	}
+
+	output, err := exec.Command("git", "-C", dir, "push", "--dry-run", "--quiet", "origin", "HEAD:refs/heads/strava-heatmap-preflight").CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil
+		}
+		return fmt.Errorf("git push --dry-run failed, so the workflow may need contents: write permission: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/auth"
+	"github.com/samuellee/StravaGraph/internal/cache"
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/internal/garmin"
+	"github.com/samuellee/StravaGraph/internal/github"
+	"github.com/samuellee/StravaGraph/internal/importer"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// sourceFactory opens a provider for the targets of a run. warn reports
+// problems the run carries on through, such as a spent request budget.
+type sourceFactory func(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error)
+
+// sources holds the providers -source and the provider setting choose
+// from; a new provider only needs an entry here
+var sources = map[string]sourceFactory{
+	"strava": openStravaSource,
+	"garmin": openGarminSource,
+	"files":  openFilesSource,
+	"export": openExportSource,
+}
+
+// sourceName is the provider given with -source, which takes precedence over
+// provider in the config; empty to use the config's
+var sourceName string
+
+// activityDir and exportPath are read by the files and export providers
+var activityDir, exportPath string
+
+// cachedSource is a provider that saves a state cache, reporting the key
+// its last fetch saved it under, or "" if it saved none
+type cachedSource interface {
+	CacheKey() string
+}
+
+// sourceCacheKey returns the key a provider last saved its cache under, or
+// "" for providers without one
+func sourceCacheKey(source strava.ActivitySource) string {
+	if cached, ok := source.(cachedSource); ok {
+		return cached.CacheKey()
+	}
+	return ""
+}
+
+// sourceNames returns the registered providers in order
+func sourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openSource opens the provider chosen with -source, or else by provider in
+// the config, defaulting to the Strava API
+func openSource(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error) {
+	name := sourceName
+	if name == "" {
+		name = cfg.Provider
+	}
+	if name == "" {
+		name = "strava"
+	}
+
+	open, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid provider: %s, must be one of %v", name, sourceNames())
+	}
+	return open(cfg, targets, actionsHandler, warn)
+}
+
+// requireFTP checks that every target computing TSS has ftp set, for
+// providers with no athlete profile to look it up from
+func requireFTP(targets []target, provider string) error {
+	for _, target := range targets {
+		if target.cfg.MetricType == "tss" && target.cfg.FTP <= 0 {
+			return fmt.Errorf("set ftp in the config to use tss with %s", provider)
+		}
+	}
+	return nil
+}
+
+// stravaSource fetches from the Strava API, syncing through the state cache
+// and reporting what was fetched
+type stravaSource struct {
+	cfg            *config.Config
+	actionsHandler *github.ActionsHandler
+	warn           func(string)
+	store          *cache.Store
+	tokenManager   *auth.TokenManager
+	client         *strava.Client
+	cacheKey       string // Set once FetchActivities saves the cache
+}
+
+// openStravaSource authenticates with Strava, resumes conditional requests
+// from the previous run and fills in each target's FTP from the athlete
+// profile and the date of their first activity if needed
+func openStravaSource(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error) {
+	// Open the state cache, if configured
+	store := openCache(cfg)
+
+	tokenManager, err := getTokenManager(cfg, actionsHandler, store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate with Strava: %w", err)
+	}
+
+	client := strava.NewClient(tokenManager, cfg.Debug, httpOptions(cfg))
+	client.SetRequestBudget(cfg.MaxAPIRequests)
+
+	if err := loadResponses(store, client); err != nil {
+		return nil, fmt.Errorf("failed to load cached responses: %w", err)
+	}
+
+	for _, target := range targets {
+		if err := resolveFTP(target.cfg, client); err != nil {
+			return nil, fmt.Errorf("failed to determine FTP: %w", err)
+		}
+		if err := resolveFirstActivity(target.cfg, client); err != nil {
+			return nil, fmt.Errorf("failed to find the first activity: %w", err)
+		}
+	}
+
+	return &stravaSource{
+		cfg:            cfg,
+		actionsHandler: actionsHandler,
+		warn:           warn,
+		store:          store,
+		tokenManager:   tokenManager,
+		client:         client,
+	}, nil
+}
+
+// FetchActivities fetches the activities, then writes the fetch report and
+// persists tokens and responses for the next run
+func (s *stravaSource) FetchActivities(start, end time.Time, types []string) ([]strava.SummaryActivity, error) {
+	fetchCfg := *s.cfg
+	fetchCfg.ActivityTypes = types
+
+	if s.cfg.Debug {
+		fmt.Fprintf(os.Stderr, "Fetching activities from %s to %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	report := strava.NewFetchReport()
+	activities, err := fetchActivities(&fetchCfg, s.client, s.store, start, end, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cfg.Debug {
+		fmt.Fprintf(os.Stderr, "Found %d activities\n", len(activities))
+	}
+
+	// Carry on with partial data if the request budget ran out
+	if report.BudgetExhausted {
+		s.warn(budgetWarning(s.cfg))
+	}
+
+	// Report what was fetched for monitoring scheduled runs
+	report.Finish(s.client)
+	if err := writeReport(s.cfg, s.actionsHandler, report); err != nil {
+		s.warn(fmt.Sprintf("Failed to write fetch report: %v", err))
+	}
+
+	// Persist tokens for the next run
+	if key, err := saveCache(s.store, s.tokenManager, s.client); err != nil {
+		s.warn(fmt.Sprintf("Failed to save cache: %v", err))
+	} else {
+		s.cacheKey = key
+	}
+
+	// Record retries if in GitHub Actions
+	if stats := s.client.Stats(); stats.Retries > 0 && s.actionsHandler.IsRunningInActions() {
+		s.actionsHandler.RecordMetric("API retries", stats.Retries)
+		s.actionsHandler.RecordMetric("Rate limit wait", fmt.Sprintf("%.0fs", stats.RateLimitWaitSeconds))
+	}
+
+	return activities, nil
+}
+
+// CacheKey returns the key the cache was saved under, with the tokens and
+// responses of the last fetch
+func (s *stravaSource) CacheKey() string {
+	return s.cacheKey
+}
+
+// garminSource fetches from Garmin Connect, keeping the activities in the
+// state cache so later runs only read recent uploads. Detailed activities
+// and webhooks only apply to Strava.
+type garminSource struct {
+	cfg      *config.Config
+	warn     func(string)
+	store    *cache.Store
+	client   *garmin.Client
+	cacheKey string // Set once FetchActivities saves the cache
+}
+
+// openGarminSource connects to Garmin Connect with the GARMIN_* credentials.
+// Garmin has no FTP to look up, so it must be set in the config for TSS.
+func openGarminSource(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error) {
+	if err := requireFTP(targets, "Garmin Connect"); err != nil {
+		return nil, err
+	}
+
+	clientID := actionsHandler.GetEnvWithFallback("GARMIN_CLIENT_ID", "")
+	clientSecret := actionsHandler.GetEnvWithFallback("GARMIN_CLIENT_SECRET", "")
+	refreshToken := actionsHandler.GetEnvWithFallback("GARMIN_REFRESH_TOKEN", "")
+	if clientID == "" || clientSecret == "" || refreshToken == "" {
+		return nil, fmt.Errorf("GARMIN_CLIENT_ID, GARMIN_CLIENT_SECRET, and GARMIN_REFRESH_TOKEN environment variables must be set")
+	}
+
+	tokenManager := auth.NewTokenManager(clientID, clientSecret, refreshToken)
+	tokenManager.TokenURL = garmin.TokenURL
+	tokenManager.HTTPClient = strava.NewHTTPClient(httpOptions(cfg))
+
+	client := garmin.NewClient(tokenManager, cfg.Debug, httpOptions(cfg))
+	client.SetRequestBudget(cfg.MaxAPIRequests)
+
+	// Garmin activities are cached apart from Strava's, so switching
+	// providers never mixes the two
+	var store *cache.Store
+	if cfg.CacheDir != "" {
+		store = cache.NewStore(filepath.Join(cfg.CacheDir, "garmin"), cfg.Profile, cfg.Debug)
+	}
+
+	return &garminSource{cfg: cfg, warn: warn, store: store, client: client}, nil
+}
+
+// FetchActivities fetches the activities, reading only the uploads since
+// the last sync when the cache holds the rest, and carries on with those
+// fetched if the request budget runs out
+func (s *garminSource) FetchActivities(start, end time.Time, types []string) ([]strava.SummaryActivity, error) {
+	var state *cache.ActivityState
+	if s.store != nil && !refreshCache {
+		var err error
+		if state, err = s.store.LoadActivities(); err != nil {
+			return nil, err
+		}
+	}
+
+	syncTime := time.Now()
+	var activities []strava.SummaryActivity
+	var err error
+	if state != nil && state.Covers(start, types) {
+		since := state.LastSync.Add(-cache.SyncOverlap)
+		if s.cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Syncing activities uploaded since %s from cache\n", since.Format("2006-01-02"))
+		}
+		activities, err = s.client.GetActivitiesUploadedSince(since, start, end, types)
+	} else {
+		state = &cache.ActivityState{Schema: cache.ActivitySchema, ActivityTypes: types}
+		activities, err = s.client.GetAllActivities(start, end, types)
+	}
+
+	// Uploads are read oldest first, so a spent budget leaves the sync time
+	// where it was and the next run reads them again
+	budgetExhausted := errors.Is(err, strava.ErrRequestBudget)
+	if budgetExhausted {
+		s.warn(fmt.Sprintf("Stopped after %d API requests (maxApiRequests), so recent activities may be missing", s.cfg.MaxAPIRequests))
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Activities early on the first day east of UTC started before it in UTC
+	state.Merge(activities, start.Add(-garmin.MaxOffset))
+	if !budgetExhausted {
+		state.LastSync = syncTime
+	}
+	if s.cfg.Debug {
+		fmt.Fprintf(os.Stderr, "Fetched %d activities, %d in total\n", len(activities), len(state.Activities))
+	}
+
+	if s.store != nil && !replaying() {
+		key := ""
+		err := s.store.SaveActivities(state)
+		if err == nil {
+			key, err = s.store.Key()
+		}
+		if err != nil {
+			s.warn(fmt.Sprintf("Failed to save cache: %v", err))
+		} else {
+			s.cacheKey = key
+		}
+	}
+	return state.Activities, nil
+}
+
+// CacheKey returns the key the cache was saved under by the last fetch
+func (s *garminSource) CacheKey() string {
+	return s.cacheKey
+}
+
+// fileSource reads exported activities from path with load, needing no API
+// access
+type fileSource struct {
+	cfg  *config.Config
+	path string
+	load func(path string, start, end time.Time, loc *time.Location) ([]strava.SummaryActivity, error)
+}
+
+// openFilesSource reads the GPX, TCX and FIT files under -dir. Without
+// Strava to look it up, FTP must be set in the config for TSS.
+func openFilesSource(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error) {
+	if err := requireFTP(targets, "exported activities"); err != nil {
+		return nil, err
+	}
+	return &fileSource{cfg: cfg, path: activityDir, load: importer.LoadDir}, nil
+}
+
+// openExportSource reads the Strava bulk export at -path. Without Strava to
+// look it up, FTP must be set in the config for TSS.
+func openExportSource(cfg *config.Config, targets []target, actionsHandler *github.ActionsHandler, warn func(string)) (strava.ActivitySource, error) {
+	if err := requireFTP(targets, "exported activities"); err != nil {
+		return nil, err
+	}
+	return &fileSource{cfg: cfg, path: exportPath, load: importer.LoadExport}, nil
+}
+
+// FetchActivities reads the activities and keeps those of the given types.
+// Files only carry UTC times, so local start times follow timeZone.
+func (s *fileSource) FetchActivities(start, end time.Time, types []string) ([]strava.SummaryActivity, error) {
+	loc, err := s.cfg.GetTimeZoneLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	activities, err := s.load(s.path, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Debug {
+		fmt.Fprintf(os.Stderr, "Imported %d activities from %s\n", len(activities), s.path)
+	}
+	return filterActivityTypes(activities, types), nil
+}
@@ -2,4 +2,7 @@ module github.com/samuellee/StravaGraph
 
 go 1.23.4
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/sync v0.16.0
+)
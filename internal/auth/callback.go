@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// DefaultCallbackPort is the port the local callback server listens on
+const DefaultCallbackPort = 8089
+
+// CallbackServer is a temporary server on localhost that receives the
+// redirect at the end of the OAuth flow, so the authorization code doesn't
+// have to be copied out of the browser by hand
+type CallbackServer struct {
+	OAuth *OAuthConfig
+
+	state  string
+	server *http.Server
+	result chan callbackResult
+}
+
+// callbackResult is the outcome of the redirect
+type callbackResult struct {
+	token *strava.TokenResponse
+	err   error
+}
+
+// NewCallbackServer starts listening on a localhost port for the redirect.
+// Strava only redirects to the app's Authorization Callback Domain, which
+// must be set to localhost.
+func NewCallbackServer(clientID, clientSecret string, port int) (*CallbackServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("error listening on port %d: %w", port, err)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error generating state: %w", err)
+	}
+
+	c := &CallbackServer{
+		OAuth: NewOAuthConfig(clientID, clientSecret,
+			fmt.Sprintf("http://localhost:%d/callback", port), []string{"read", "activity:read_all"}),
+		state:  hex.EncodeToString(buf),
+		result: make(chan callbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /callback", c.handleCallback)
+	c.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go c.server.Serve(listener)
+
+	return c, nil
+}
+
+// AuthorizationURL returns the URL that starts the flow in the browser
+func (c *CallbackServer) AuthorizationURL() string {
+	return c.OAuth.GetAuthorizationURL(c.state)
+}
+
+// Wait blocks until the redirect arrives and its code has been exchanged
+// for tokens, or the timeout passes, then shuts the server down
+func (c *CallbackServer) Wait(timeout time.Duration) (*strava.TokenResponse, error) {
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		c.server.Shutdown(ctx)
+	}()
+
+	select {
+	case result := <-c.result:
+		return result.token, result.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out after %s waiting for authorization", timeout)
+	}
+}
+
+// handleCallback exchanges the code Strava redirects back with. Only the
+// first valid redirect is used; a stale or foreign one is rejected.
+func (c *CallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("state") != c.state {
+		http.Error(w, "Authorization was not started here", http.StatusBadRequest)
+		return
+	}
+
+	var result callbackResult
+	switch {
+	case query.Get("error") != "":
+		result.err = fmt.Errorf("authorization was denied: %s", query.Get("error"))
+	case !strings.Contains(query.Get("scope"), "activity:read"):
+		result.err = fmt.Errorf("access to activities was not granted")
+	default:
+		result.token, result.err = c.OAuth.ExchangeCodeForToken(query.Get("code"))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if result.err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<!DOCTYPE html><html><body><h1>Authorization failed</h1><p>%s</p></body></html>",
+			html.EscapeString(result.err.Error()))
+	} else {
+		fmt.Fprint(w, "<!DOCTYPE html><html><body><h1>Authorized</h1><p>You can close this tab and return to the terminal.</p></body></html>")
+	}
+
+	select {
+	case c.result <- result:
+	default:
+	}
+}
+
+// OpenBrowser opens a URL in the default browser
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
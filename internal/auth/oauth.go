@@ -33,13 +33,18 @@ func NewOAuthConfig(clientID, clientSecret, redirectURI string, scopes []string)
 	}
 }
 
-// GetAuthorizationURL returns the URL to redirect the user for authorization
-func (c *OAuthConfig) GetAuthorizationURL() string {
+// GetAuthorizationURL returns the URL to redirect the user for authorization.
+// The state is echoed back to the redirect URI to tie the callback to the
+// request that started it.
+func (c *OAuthConfig) GetAuthorizationURL(state string) string {
 	params := url.Values{}
 	params.Add("client_id", c.ClientID)
 	params.Add("redirect_uri", c.RedirectURI)
 	params.Add("response_type", "code")
 	params.Add("scope", strings.Join(c.Scopes, ","))
+	if state != "" {
+		params.Add("state", state)
+	}
 
 	return fmt.Sprintf("%s?%s", stravaAuthorizeURL, params.Encode())
 }
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Token is a set of Strava tokens as persisted between runs
+type Token struct {
+	RefreshToken string    `json:"refreshToken"`
+	AccessToken  string    `json:"accessToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// TokenStore persists tokens so a refresh token rotated by Strava survives
+// to the next run
+type TokenStore interface {
+	// Load returns the stored tokens, or nil if there are none or the store
+	// can't be read back
+	Load() (*Token, error)
+	// Save replaces the stored tokens
+	Save(token *Token) error
+}
+
+// FileTokenStore keeps tokens in a JSON file
+type FileTokenStore struct {
+	Path string
+}
+
+// NewFileTokenStore creates a store writing tokens to path
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+// Load returns the tokens in the file, or nil if it doesn't exist yet
+func (s *FileTokenStore) Load() (*Token, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading token file: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("error parsing token file %s: %w", s.Path, err)
+	}
+	return &token, nil
+}
+
+// Save writes the tokens to the file, readable only by the current user
+func (s *FileTokenStore) Save(token *Token) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling tokens: %w", err)
+	}
+
+	if dir := filepath.Dir(s.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("error creating token directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(s.Path, data, 0600); err != nil {
+		return fmt.Errorf("error writing token file: %w", err)
+	}
+	return nil
+}
@@ -25,13 +25,17 @@ type TokenResponse struct {
 	} `json:"athlete"`
 }
 
-// TokenManager handles Strava token management
+// TokenManager handles Strava token management, or that of another OAuth 2
+// provider with TokenURL set
 type TokenManager struct {
 	ClientID     string
 	ClientSecret string
 	RefreshToken string
 	AccessToken  string
 	ExpiresAt    time.Time
+	TokenURL     string       // Endpoint refresh tokens are exchanged at, Strava's if empty
+	HTTPClient   *http.Client // Client for token requests, one with a 10 second timeout if nil
+	Store        TokenStore   // Receives rotated refresh tokens, nil to keep them in memory only
 }
 
 // NewTokenManager creates a new token manager
@@ -54,7 +58,7 @@ func (tm *TokenManager) GetAccessToken() (string, error) {
 	return tm.AccessToken, nil
 }
 
-// RefreshAccessToken refreshes the Strava access token using the refresh token
+// RefreshAccessToken refreshes the access token using the refresh token
 func (tm *TokenManager) RefreshAccessToken() error {
 	data := url.Values{}
 	data.Set("client_id", tm.ClientID)
@@ -62,14 +66,21 @@ func (tm *TokenManager) RefreshAccessToken() error {
 	data.Set("refresh_token", tm.RefreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	req, err := http.NewRequest("POST", stravaTokenURL, strings.NewReader(data.Encode()))
+	tokenURL := tm.TokenURL
+	if tokenURL == "" {
+		tokenURL = stravaTokenURL
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return fmt.Errorf("error creating token request: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := tm.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error making token request: %w", err)
@@ -86,9 +97,26 @@ func (tm *TokenManager) RefreshAccessToken() error {
 	}
 
 	// Update the token manager with the new tokens
+	rotated := tokenResp.RefreshToken != tm.RefreshToken
 	tm.AccessToken = tokenResp.AccessToken
 	tm.RefreshToken = tokenResp.RefreshToken
 	tm.ExpiresAt = time.Unix(tokenResp.ExpiresAt, 0)
+	if tokenResp.ExpiresAt == 0 {
+		// Providers other than Strava may only say how long the token lasts
+		tm.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	// The old refresh token stops working, so losing the new one would break
+	// the next run
+	if rotated && tm.Store != nil {
+		if err := tm.Store.Save(&Token{
+			RefreshToken: tm.RefreshToken,
+			AccessToken:  tm.AccessToken,
+			ExpiresAt:    tm.ExpiresAt,
+		}); err != nil {
+			return fmt.Errorf("error saving rotated refresh token: %w", err)
+		}
+	}
 
 	return nil
 }
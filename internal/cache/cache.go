@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+const (
+	tokenFile      = "token.json"
+	activitiesFile = "activities.json"
+	responsesFile  = "responses.json"
+
+	// KeyPrefix starts every cache key, followed by the profile name (or
+	// "default") so a restore can match any earlier state of the same profile
+	KeyPrefix = "strava-heatmap-v1-"
+
+	// SyncOverlap is how far before the last sync activities are fetched again,
+	// picking up activities that were uploaded late, edited or deleted
+	SyncOverlap = 7 * 24 * time.Hour
+
+	// ActivitySchema versions the cached activities, and goes up whenever
+	// activities gain a field read from Strava, so caches written before it
+	// are fetched again in full rather than synced. Version 2 added the
+	// workout type.
+	ActivitySchema = 2
+)
+
+// TokenState holds the most recent Strava tokens. Strava rotates refresh
+// tokens, so the latest one is kept for the next run.
+type TokenState struct {
+	RefreshToken string    `json:"refreshToken"`
+	AccessToken  string    `json:"accessToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// ActivityState holds previously fetched activities for incremental sync
+type ActivityState struct {
+	Schema        int                      `json:"schema"`   // ActivitySchema when written
+	Start         time.Time                `json:"start"`    // Earliest date fetched
+	LastSync      time.Time                `json:"lastSync"` // When activities were last fetched
+	ActivityTypes []string                 `json:"activityTypes"`
+	Activities    []strava.SummaryActivity `json:"activities"`
+}
+
+// Store persists tokens and activities in a directory, laid out so the whole
+// directory can be saved and restored with actions/cache
+type Store struct {
+	Dir     string
+	Profile string // Config profile, each of which gets its own subdirectory
+	Debug   bool
+}
+
+// NewStore creates a new cache store
+func NewStore(dir, profile string, debug bool) *Store {
+	if profile != "" {
+		dir = filepath.Join(dir, profile)
+	}
+	return &Store{
+		Dir:     dir,
+		Profile: profile,
+		Debug:   debug,
+	}
+}
+
+// LoadToken returns the cached tokens, or nil if there are none
+func (s *Store) LoadToken() (*TokenState, error) {
+	var state TokenState
+	found, err := s.load(tokenFile, &state)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveToken writes the tokens to the cache
+func (s *Store) SaveToken(state *TokenState) error {
+	return s.save(tokenFile, state)
+}
+
+// LoadActivities returns the cached activities, or nil if there are none
+func (s *Store) LoadActivities() (*ActivityState, error) {
+	var state ActivityState
+	found, err := s.load(activitiesFile, &state)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveActivities writes the activities to the cache
+func (s *Store) SaveActivities(state *ActivityState) error {
+	return s.save(activitiesFile, state)
+}
+
+// LoadResponses returns the cached API responses for conditional requests,
+// or nil if there are none
+func (s *Store) LoadResponses() (map[string]*strava.CachedResponse, error) {
+	var responses map[string]*strava.CachedResponse
+	if _, err := s.load(responsesFile, &responses); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// SaveResponses writes the API responses to the cache
+func (s *Store) SaveResponses(responses map[string]*strava.CachedResponse) error {
+	return s.save(responsesFile, responses)
+}
+
+// Key returns a cache key derived from the cached files, so unchanged state
+// maps to the same key
+func (s *Store) Key() (string, error) {
+	hash := sha256.New()
+	for _, name := range []string{tokenFile, activitiesFile, responsesFile} {
+		data, err := os.ReadFile(filepath.Join(s.Dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading cache file %s: %w", name, err)
+		}
+		hash.Write([]byte(name))
+		hash.Write(data)
+	}
+	profile := s.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	return KeyPrefix + profile + "-" + hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// CombinedKey returns the key a cache directory holding several stores is
+// saved under, such as those of a coach's roster, changing whenever any of
+// their keys does. The profile of the run, or "default", follows the prefix
+// as in Key.
+func CombinedKey(profile string, keys []string) string {
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	return KeyPrefix + profile + "-" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// Covers reports whether the cached activities can be synced incrementally
+// for the given range start and activity types, and were written with the
+// current schema
+func (a *ActivityState) Covers(start time.Time, types []string) bool {
+	if a.Schema != ActivitySchema || a.LastSync.IsZero() || a.Start.After(start) {
+		return false
+	}
+
+	cached := append([]string(nil), a.ActivityTypes...)
+	wanted := append([]string(nil), types...)
+	sort.Strings(cached)
+	sort.Strings(wanted)
+	if len(cached) != len(wanted) {
+		return false
+	}
+	for i := range cached {
+		if cached[i] != wanted[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge adds freshly fetched activities, replacing cached copies with the
+// same ID and dropping activities that started before start. It returns how
+// many activities were new and how many cached ones changed.
+func (a *ActivityState) Merge(activities []strava.SummaryActivity, start time.Time) (added, updated int) {
+	byID := make(map[int64]strava.SummaryActivity)
+	for _, activity := range a.Activities {
+		byID[activity.ID] = activity
+	}
+	for _, activity := range activities {
+		cached, ok := byID[activity.ID]
+		if ok {
+			// Keep details fetched on an earlier run, including the PRs
+			// counted from its efforts
+			if cached.DetailsFetched && !activity.DetailsFetched {
+				activity.Calories = cached.Calories
+				activity.Description = cached.Description
+				activity.PRCount = cached.PRCount
+				activity.DetailsFetched = true
+			} else if activity.Calories == 0 {
+				activity.Calories = cached.Calories
+			}
+			// The corrected gain holds as long as Strava's own is unchanged
+			if activity.CorrectedElevGain == nil && activity.TotalElevGain == cached.TotalElevGain {
+				activity.CorrectedElevGain = cached.CorrectedElevGain
+			}
+			if !reflect.DeepEqual(cached, activity) {
+				updated++
+			}
+		} else {
+			added++
+		}
+		byID[activity.ID] = activity
+	}
+
+	a.Activities = a.Activities[:0]
+	for _, activity := range byID {
+		if !activity.StartDate.Before(start) {
+			a.Activities = append(a.Activities, activity)
+		}
+	}
+	sort.Slice(a.Activities, func(i, j int) bool {
+		return a.Activities[i].StartDate.Before(a.Activities[j].StartDate)
+	})
+	a.Start = start
+
+	return added, updated
+}
+
+// Prune drops cached activities that started between from and to but are
+// missing from a complete fetch of that window, since they were deleted on
+// Strava or no longer match the activity types. It returns how many were
+// dropped.
+func (a *ActivityState) Prune(fetched []strava.SummaryActivity, from, to time.Time) int {
+	seen := make(map[int64]bool, len(fetched))
+	for _, activity := range fetched {
+		seen[activity.ID] = true
+	}
+
+	kept := a.Activities[:0]
+	for _, activity := range a.Activities {
+		inWindow := !activity.StartDate.Before(from) && !activity.StartDate.After(to)
+		if !inWindow || seen[activity.ID] {
+			kept = append(kept, activity)
+		}
+	}
+
+	removed := len(a.Activities) - len(kept)
+	a.Activities = kept
+	return removed
+}
+
+// Remove drops the cached activities with the given IDs, returning how many
+// were found
+func (a *ActivityState) Remove(ids ...int64) int {
+	drop := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+
+	kept := a.Activities[:0]
+	for _, activity := range a.Activities {
+		if !drop[activity.ID] {
+			kept = append(kept, activity)
+		}
+	}
+
+	removed := len(a.Activities) - len(kept)
+	a.Activities = kept
+	return removed
+}
+
+// load reads a cache file into v, reporting whether it existed
+func (s *Store) load(name string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading cache file %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		// A corrupt cache is discarded rather than failing the run
+		if s.Debug {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Ignoring unreadable cache file %s: %v\n", name, err)
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// save writes v to a cache file, creating the cache directory if needed
+func (s *Store) save(name string, v interface{}) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling cache file %s: %w", name, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.Dir, name), data, 0600); err != nil {
+		return fmt.Errorf("error writing cache file %s: %w", name, err)
+	}
+
+	return nil
+}
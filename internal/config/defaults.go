@@ -0,0 +1,12 @@
+package config
+
+import _ "embed"
+
+// DefaultConfigFile is the config file read when no other is given
+const DefaultConfigFile = "config.json"
+
+// DefaultConfig is the starting configuration built into the binary, used
+// when the default config file doesn't exist and written out by -init
+//
+//go:embed default.json
+var DefaultConfig []byte
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadLayers reads a config file and the chain of files it extends, returning
+// their contents with the furthest base first, so each file can be applied
+// over the ones it extends
+func loadLayers(filePath string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	return resolveExtends(data, filepath.Dir(filePath), []string{filePath})
+}
+
+// resolveExtends returns the files data extends followed by data itself.
+// Paths are relative to dir, the directory of the file that named them, and
+// chain holds the files already on the way down, to detect cycles.
+func resolveExtends(data json.RawMessage, dir string, chain []string) ([]json.RawMessage, error) {
+	var header struct {
+		Extends string `json:"extends"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", chain[len(chain)-1], err)
+	}
+	if header.Extends == "" {
+		return []json.RawMessage{data}, nil
+	}
+
+	base := header.Extends
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(dir, base)
+	}
+	for _, seen := range chain {
+		if sameFile(seen, base) {
+			return nil, fmt.Errorf("config files extend each other: %s -> %s", strings.Join(chain, " -> "), base)
+		}
+	}
+
+	baseData, err := os.ReadFile(base)
+	if err != nil {
+		return nil, fmt.Errorf("error reading base config %s: %w", header.Extends, err)
+	}
+
+	layers, err := resolveExtends(baseData, filepath.Dir(base), append(chain, base))
+	if err != nil {
+		return nil, err
+	}
+	return append(layers, data), nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix is prepended to the upper snake case name of a config field to
+// form the environment variable overriding it, e.g. HEATMAP_METRIC_TYPE
+const EnvPrefix = "HEATMAP_"
+
+// ApplyEnvOverrides overrides config fields with any non-empty environment
+// variables named after their JSON keys. Strings are used as is, string lists
+// may be comma separated, and everything else is parsed as JSON.
+func ApplyEnvOverrides(config *Config) error {
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name := EnvVarName(key)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// EnvVarName returns the environment variable overriding a config key
+func EnvVarName(key string) string {
+	var sb strings.Builder
+	sb.WriteString(EnvPrefix)
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			sb.WriteRune('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// setField parses an override into a config field
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String &&
+		!strings.HasPrefix(strings.TrimSpace(value), "["):
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+		return nil
+	default:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+}
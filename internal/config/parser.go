@@ -1,49 +1,265 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
 )
 
+// profileNamePattern limits profile names to characters safe in file paths
+// and README markers
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Annotation marks a notable date on the heatmap
+type Annotation struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Label string `json:"label"`
+	Icon  string `json:"icon"`
+}
+
+// IntensityScale chooses how metric values are binned into intensity levels
+type IntensityScale struct {
+	Mode       string    `json:"mode"`       // "percentile", "linear", "logarithmic" or "fixed"; percentile if empty
+	Thresholds []float64 `json:"thresholds"` // Upper bounds of the Low, Medium, High and optionally VeryHigh levels in display units, for fixed
+}
+
+// PrivacyZone is an area, such as around home or work, whose activities are
+// never shown on location or route renderings
+type PrivacyZone struct {
+	Name   string  `json:"name"` // Only for the reader's reference
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+	Radius float64 `json:"radius"` // In meters
+}
+
+// Target is a README updated in the same run as the others, rendered with
+// its own profile from activities fetched once for all of them
+type Target struct {
+	Readme  string `json:"readme"`  // Path of the README, which may be in another checkout
+	Profile string `json:"profile"` // Profile applied for it, empty for the main config
+}
+
+// Athlete is a member of a coach's roster who authorized the coach's
+// application, rendered from their own refresh token into their own files
+type Athlete struct {
+	Name      string `json:"name"`      // Names the token variable, README markers and cache of the athlete
+	Readme    string `json:"readme"`    // README updated, which several athletes may share with their own markers
+	Profile   string `json:"profile"`   // Profile applied for the athlete, empty for the main config
+	SVGFile   string `json:"svgFile"`   // Heatmap file, replacing the profile's svgFile if set
+	StatsFile string `json:"statsFile"` // Stats file, replacing the profile's statsFile if set
+}
+
 // Config represents the application configuration
 type Config struct {
-	ActivityTypes   []string `json:"activityTypes"`
-	MetricType      string   `json:"metricType"`
-	ColorScheme     string   `json:"colorScheme"`
-	CustomColors    []string `json:"customColors"`
-	ShowStats       bool     `json:"showStats"`
-	StatTypes       []string `json:"statTypes"`
-	DateRange       string   `json:"dateRange"`
-	CustomDateRange struct {
+	Preset            string   `json:"preset"`
+	ActivityTypes     []string `json:"activityTypes"`
+	IgnoreActivityIDs []int64  `json:"ignoreActivityIds"` // Activities left out of the heatmap and stats, such as bad GPS recordings
+	MetricType        string   `json:"metricType"`
+	SecondaryMetric   string   `json:"secondaryMetric"`   // Second metric drawn on each cell, empty for none
+	SecondaryEncoding string   `json:"secondaryEncoding"` // "border" or "dot"
+	ColorScheme       string   `json:"colorScheme"`
+	CustomColors      []string `json:"customColors"`
+	ShowStats         bool     `json:"showStats"`
+	ShowWeeklyChart   bool     `json:"showWeeklyChart"`  // Weekly bar chart of the metric below the heatmap
+	ShowTrainingLoad  bool     `json:"showTrainingLoad"` // Fitness, fatigue and form chart below the heatmap
+	StatTypes         []string `json:"statTypes"`
+	DateRange         string   `json:"dateRange"`
+	CustomDateRange   struct {
 		Start string `json:"start"`
 		End   string `json:"end"`
 	} `json:"customDateRange"`
-	CellSize               int      `json:"cellSize"`
-	IncludePRs             bool     `json:"includePRs"`
-	IncludeLocationHeatmap bool     `json:"includeLocationHeatmap"`
-	LocationPrivacyRadius  int      `json:"locationPrivacyRadius"`
-	DarkModeSupport        bool     `json:"darkModeSupport"`
-	DarkModeColors         []string `json:"darkModeColors"`
-	WeekStart              string   `json:"weekStart"`
-	Language               string   `json:"language"`
-	TimeZone               string   `json:"timeZone"`
-	Debug                  bool     `json:"debug"`
+	SeasonStart            string              `json:"seasonStart"` // MM-DD
+	DetailYears            int                 `json:"detailYears"` // Years at the end of the all range drawn day by day, earlier weeks as one cell each; 3 if 0
+	CellSize               int                 `json:"cellSize"`
+	IntensityWindow        string              `json:"intensityWindow"`
+	IntensityScale         IntensityScale      `json:"intensityScale"`
+	IncludePRs             bool                `json:"includePRs"`
+	MetricWeights          map[string]float64  `json:"metricWeights"`        // Metric type to its weight in the composite metric
+	DistancelessFallback   bool                `json:"distancelessFallback"` // Score distance-less activities by duration under the distance metric
+	TimeBasis              string              `json:"timeBasis"`            // "moving" or "elapsed" time for durations, moving if empty
+	Provider               string              `json:"provider"`             // Provider activities are fetched from: "strava", "garmin", "files" or "export", strava if empty
+	FetchDetails           bool                `json:"fetchDetails"`
+	CorrectElevation       bool                `json:"correctElevation"` // Recompute elevation gain from altitude streams
+	TokenStore             string              `json:"tokenStore"`       // Where rotated refresh tokens are saved: "file:PATH", "secret" or "secret:NAME"; empty for none
+	CacheDir               string              `json:"cacheDir"`         // Tokens and activities for incremental sync
+	FetchReport            string              `json:"fetchReport"`      // JSON file summarizing API usage, empty for none
+	SVGFile                string              `json:"svgFile"`          // Heatmap file referenced from the README with an image, empty to inline the SVG
+	MobileSVGFile          string              `json:"mobileSvgFile"`    // Heatmap file rendered for the mobile target and shown on narrow screens, empty for none
+	Target                 string              `json:"target"`           // "desktop" or "mobile" layout, desktop if empty
+	Layout                 string              `json:"layout"`           // "grid" of weeks, "radial" ring of days or "github" contribution graph, grid if empty
+	OutputFormat           string              `json:"outputFormat"`     // "svg" or "png" for the heatmap file and -generate output, svg if empty
+	PNGDPI                 int                 `json:"pngDpi"`           // Resolution of PNG output, 96 (the SVG's size) if 0
+	StatsFile              string              `json:"statsFile"`        // JSON file of training stats committed with the README, empty for none
+	HTTPTimeout            int                 `json:"httpTimeout"`      // Seconds per API request, 30 if 0
+	UserAgent              string              `json:"userAgent"`        // Sent with API requests, a default naming this tool if empty
+	MaxAPIRequests         int                 `json:"maxApiRequests"`   // Most API requests per run, 0 for no limit
+	FTP                    int                 `json:"ftp"`              // Watts; read from the Strava profile if 0
+	LegendUnits            bool                `json:"legendUnits"`
+	LegendRanges           bool                `json:"legendRanges"`
+	IncludeLocationHeatmap bool                `json:"includeLocationHeatmap"`
+	LocationPrivacyRadius  int                 `json:"locationPrivacyRadius"`
+	PrivacyZones           []PrivacyZone       `json:"privacyZones"` // Areas whose activities are scrubbed of locations
+	DarkModeSupport        bool                `json:"darkModeSupport"`
+	DarkModeColors         []string            `json:"darkModeColors"`
+	WeekStart              string              `json:"weekStart"` // "Sunday" or "Monday", following the language if empty
+	WeekNumbers            string              `json:"weekNumbers"`
+	ShowWeekLabels         bool                `json:"showWeekLabels"`    // Date labels under every weekLabelInterval-th column
+	WeekLabelInterval      int                 `json:"weekLabelInterval"` // Columns between week labels, 4 if 0
+	ShowAllMonthLabels     bool                `json:"showAllMonthLabels"`
+	DarkMarkers            bool                `json:"darkMarkers"`       // Moon icon on days with an activity started in the dark
+	Periodization          bool                `json:"periodization"`     // Strip of build and recovery weeks under the grid
+	ACWRThreshold          float64             `json:"acwrThreshold"`     // Flag weeks whose acute:chronic workload ratio exceeds this, 0 to disable
+	HighlightStreaks       bool                `json:"highlightStreaks"`  // Outline long runs of active days and show streak callouts
+	StreakMinDays          int                 `json:"streakMinDays"`     // Shortest run of active days outlined, 7 if 0
+	GhostPreviousYear      bool                `json:"ghostPreviousYear"` // Outline each cell with the intensity of the same day 52 weeks earlier
+	ComparisonMode         string              `json:"comparisonMode"`    // "yoy" to compare with the same days 52 weeks earlier, empty for none
+	ComparisonView         string              `json:"comparisonView"`    // "stacked" or "diff", stacked if empty
+	Annotations            []Annotation        `json:"annotations"`
+	DistanceMilestones     []float64           `json:"distanceMilestones"` // Yearly cumulative distances marked on the grid, in km or miles for imperial units
+	Widgets                []string            `json:"widgets"`            // Extra cards rendered below the heatmap
+	YearlyDistanceGoal     float64             `json:"yearlyDistanceGoal"` // In km or miles for imperial units, for the goal_progress widget
+	Tags                   map[string][]string `json:"tags"`               // Tag name to the keywords or hashtags marking it
+	Language               string              `json:"language"`           // Translates labels, tooltips and stats, and sets number and date formats
+	DurationStyle          string              `json:"durationStyle"`      // "short", "long", "clock" or "minutes", each part its own way if empty
+	Units                  string              `json:"units"`              // "metric" or "imperial", metric if empty
+	TimeZone               string              `json:"timeZone"`
+	PrivacyMode            bool                `json:"privacyMode"`
+	DiffFriendly           bool                `json:"diffFriendly"`
+	Interactive            bool                `json:"interactive"` // Focusable cells with hover styles, for SVGs opened directly
+	Animate                bool                `json:"animate"`     // Fade the days in by date when the SVG loads
+	Debug                  bool                `json:"debug"`
+	Strict                 bool                `json:"strict"` // Fail instead of falling back when the timezone, theme or SVG output is off
+
+	// Named partial configs applied over the rest of the file, e.g. one per
+	// sport or athlete in a workflow matrix
+	Profiles map[string]json.RawMessage `json:"profiles"`
+	Profile  string                     `json:"-"` // Selected profile, empty for none
+
+	// READMEs updated together from one activity fetch, replacing the README
+	// given on the command line
+	Targets []Target `json:"targets"`
+
+	// Athletes a coach renders with a token each, in place of the targets
+	Roster  []Athlete `json:"roster"`
+	Athlete string    `json:"-"` // Roster athlete being rendered, empty outside a roster
+
+	// Earliest fetched activity, which starts the "all" range once known
+	FirstActivity time.Time `json:"-"`
 }
 
 // LoadConfig loads the configuration from the specified file
 func LoadConfig(filePath string) (*Config, error) {
-	// Read the config file
-	data, err := os.ReadFile(filePath)
+	return LoadProfileConfig(filePath, "")
+}
+
+// LoadProfileConfig loads the configuration from the specified file with the
+// named profile applied over it
+func LoadProfileConfig(filePath, profile string) (*Config, error) {
+	// Read the config file and the files it extends
+	layers, err := loadLayers(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+		return nil, err
+	}
+
+	return parseLayers(layers, filePath, profile)
+}
+
+// LoadDefaultConfig loads the configuration built into the binary, for runs
+// without a config file, with the named profile applied over it
+func LoadDefaultConfig(profile string) (*Config, error) {
+	return parseLayers([]json.RawMessage{DefaultConfig}, DefaultConfigFile, profile)
+}
+
+// parseLayers parses a config file's layers, base files first, over the
+// selected preset and applies the named profile and environment overrides.
+// filePath is the file the last layer came from, which a profile's extends
+// is relative to.
+func parseLayers(layers []json.RawMessage, filePath, profile string) (*Config, error) {
+	var err error
+
+	// Start from preset defaults if a preset is selected; the file nearest
+	// the one loaded picks it, and profiles from every file are kept
+	var header struct {
+		Preset   string                     `json:"preset"`
+		Profiles map[string]json.RawMessage `json:"profiles"`
+	}
+	for _, data := range layers {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+	}
+
+	// Find the selected profile, which may pick its own preset
+	var profileLayers []json.RawMessage
+	if profile != "" {
+		if !profileNamePattern.MatchString(profile) {
+			return nil, fmt.Errorf("invalid profile name: %s, use only letters, digits, '-' and '_'", profile)
+		}
+
+		profileData, ok := header.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile: %s", profile)
+		}
+
+		// A profile may extend a file of its own, applied between the
+		// main file and the profile
+		if profileLayers, err = resolveExtends(profileData, filepath.Dir(filePath), []string{filePath}); err != nil {
+			return nil, fmt.Errorf("error loading profile %s: %w", profile, err)
+		}
+
+		var profileHeader struct {
+			Preset string `json:"preset"`
+		}
+		for _, data := range profileLayers {
+			if err := json.Unmarshal(data, &profileHeader); err != nil {
+				return nil, fmt.Errorf("error parsing profile %s: %w", profile, err)
+			}
+		}
+		if profileHeader.Preset != "" {
+			header.Preset = profileHeader.Preset
+		}
+	}
+	if preset := os.Getenv(EnvVarName("preset")); preset != "" {
+		header.Preset = preset
 	}
 
-	// Parse the configuration
 	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if header.Preset != "" {
+		preset, err := GetPreset(header.Preset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		config = *preset
+	}
+
+	// Parse the configuration over the defaults, base files first
+	for _, data := range layers {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+	}
+
+	// Apply the profile over the rest of the file
+	if profileLayers != nil {
+		for _, data := range profileLayers {
+			if err := json.Unmarshal(data, &config); err != nil {
+				return nil, fmt.Errorf("error parsing profile %s: %w", profile, err)
+			}
+		}
+		config.Profile = profile
+	}
+
+	// Environment variables take precedence over the file
+	if err := ApplyEnvOverrides(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	// Validate the configuration
@@ -100,8 +316,17 @@ func (c *Config) GetDateRange() (time.Time, time.Time, error) {
 		start := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
 		return start, end, nil
 	case "all":
-		// Use a far past date for "all" - Strava was founded in 2009
-		start := time.Date(2009, 1, 1, 0, 0, 0, 0, loc)
+		return c.historyStart(loc), end, nil
+	case "season":
+		// Most recent occurrence of the configured season start
+		seasonStart, err := c.parseSeasonStart()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start := time.Date(now.Year(), seasonStart.Month(), seasonStart.Day(), 0, 0, 0, 0, loc)
+		if start.After(now) {
+			start = start.AddDate(-1, 0, 0)
+		}
 		return start, end, nil
 	case "custom":
 		// Parse custom date range
@@ -123,3 +348,293 @@ func (c *Config) GetDateRange() (time.Time, time.Time, error) {
 		return time.Time{}, time.Time{}, fmt.Errorf("invalid date range: %s", c.DateRange)
 	}
 }
+
+// GetNormalizationRange returns the start and end time of the history used to
+// compute intensity percentiles, based on the configured intensity window
+func (c *Config) GetNormalizationRange() (time.Time, time.Time, error) {
+	start, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	switch c.IntensityWindow {
+	case "", "range":
+		// Percentiles over the displayed range only
+		return start, end, nil
+	case "12months":
+		// Trailing 12 months ending with the displayed range
+		return end.AddDate(-1, 0, 0), end, nil
+	case "all":
+		// All history
+		return c.historyStart(start.Location()), end, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid intensity window: %s", c.IntensityWindow)
+	}
+}
+
+// GetFetchRange returns the range of activities that must be fetched to cover
+// both the displayed date range and the intensity normalization window
+func (c *Config) GetFetchRange() (time.Time, time.Time, error) {
+	start, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	displayStart := start
+
+	normStart, _, err := c.GetNormalizationRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if normStart.Before(start) {
+		start = normStart
+	}
+
+	// Widgets may compare against earlier history
+	if c.HasWidget("month_comparison") {
+		_, _, prevStart, _, err := c.GetMonthComparisonRange()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if prevStart.Before(start) {
+			start = prevStart
+		}
+	}
+	if c.HasWidget("goal_progress") {
+		yearStart, _, err := c.GetGoalRange()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if yearStart.Before(start) {
+			start = yearStart
+		}
+	}
+
+	// Milestones count distance from the start of each displayed year
+	if len(c.DistanceMilestones) > 0 {
+		yearStart, _, err := c.GetMilestoneRange()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if yearStart.Before(start) {
+			start = yearStart
+		}
+	}
+
+	// Training load is seeded from the weeks before the range
+	if c.ShowTrainingLoad {
+		if warmupStart := displayStart.AddDate(0, 0, -TrainingLoadWarmupDays); warmupStart.Before(start) {
+			start = warmupStart
+		}
+	}
+
+	// The ghost overlay and year-over-year comparison show the same weeks a
+	// year earlier
+	if c.GhostPreviousYear || c.ComparisonMode == "yoy" {
+		ghostStart, _, err := c.GetGhostRange()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if ghostStart.Before(start) {
+			start = ghostStart
+		}
+	}
+
+	return start, end, nil
+}
+
+// GetGoalRange returns the start of the year at the end of the displayed
+// range, and the end of the range
+func (c *Config) GetGoalRange() (time.Time, time.Time, error) {
+	_, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, end.Location()), end, nil
+}
+
+// GetYearToDateRange returns the start of the current year and now, in the
+// configured timezone
+func (c *Config) GetYearToDateRange() (time.Time, time.Time, error) {
+	loc, err := c.GetTimeZoneLocation()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc), now, nil
+}
+
+// GetMilestoneRange returns the start of the year the displayed range starts
+// in, and the end of the range
+func (c *Config) GetMilestoneRange() (time.Time, time.Time, error) {
+	start, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, start.Location()), end, nil
+}
+
+// GetGhostRange returns the displayed range moved back 52 weeks, so each day
+// of it falls on the same weekday as the day it is drawn beneath or compared
+// with
+func (c *Config) GetGhostRange() (time.Time, time.Time, error) {
+	start, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return start.AddDate(0, 0, -364), end.AddDate(0, 0, -364), nil
+}
+
+// GetMonthComparisonRange returns the month to date at the end of the
+// displayed range, and the same calendar window a year earlier
+func (c *Config) GetMonthComparisonRange() (time.Time, time.Time, time.Time, time.Time, error) {
+	_, end, err := c.GetDateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, time.Time{}, time.Time{}, err
+	}
+
+	start := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, end.Location())
+	prevStart := start.AddDate(-1, 0, 0)
+
+	// Feb 29 has no counterpart a year earlier, so the window ends on Feb 28
+	prevEnd := time.Date(end.Year()-1, end.Month(), end.Day(), end.Hour(), end.Minute(), end.Second(), 0, end.Location())
+	if prevEnd.Month() != end.Month() {
+		prevEnd = time.Date(end.Year()-1, end.Month()+1, 0, end.Hour(), end.Minute(), end.Second(), 0, end.Location())
+	}
+
+	return start, end, prevStart, prevEnd, nil
+}
+
+// GetHTTPOptions returns the settings for the Strava API client
+func (c *Config) GetHTTPOptions() strava.HTTPOptions {
+	return strava.HTTPOptions{
+		Timeout:   time.Duration(c.HTTPTimeout) * time.Second,
+		UserAgent: c.UserAgent,
+	}
+}
+
+// UsesAllHistory reports whether the date range or the intensity window
+// reaches back to the first activity
+func (c *Config) UsesAllHistory() bool {
+	return c.DateRange == "all" || c.IntensityWindow == "all"
+}
+
+// historyStart returns the day of the first activity if it's known, or else
+// 2009, when Strava was founded, so fetching covers all history
+func (c *Config) historyStart(loc *time.Location) time.Time {
+	if c.FirstActivity.IsZero() {
+		return time.Date(2009, 1, 1, 0, 0, 0, 0, loc)
+	}
+	first := c.FirstActivity.In(loc)
+	return time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+}
+
+// sundayLanguages are the languages whose calendars usually start the week
+// on Sunday, following their most common regions (en-US, pt-BR)
+var sundayLanguages = map[string]bool{
+	"en": true,
+	"ja": true,
+	"pt": true,
+}
+
+// FirstWeekday returns the day weeks usually start on in a language, Monday
+// for unknown languages as in most of Europe
+func FirstWeekday(language string) time.Weekday {
+	if language == "" || sundayLanguages[language] {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// GetWeekStart returns the configured first day of the week, or the one
+// usual in the configured language when unset
+func (c *Config) GetWeekStart() string {
+	if c.WeekStart != "" {
+		return c.WeekStart
+	}
+	return FirstWeekday(c.Language).String()
+}
+
+// TrainingLoadWarmupDays is how many days before the range are fetched to
+// seed the training load chart, three times the 42-day fitness time constant
+const TrainingLoadWarmupDays = 126
+
+// DefaultDetailYears is how many of the latest years of the all range are
+// drawn day by day when detailYears is 0, few enough that the days stay
+// readable when a README scales the image down
+const DefaultDetailYears = 3
+
+// GetDetailStart returns the date from which the all range ending at end is
+// drawn day by day, with the weeks before summarized, and whether the range
+// starting at start reaches back that far. Other ranges are always drawn day
+// by day.
+func (c *Config) GetDetailStart(start, end time.Time) (time.Time, bool) {
+	if c.DateRange != "all" {
+		return time.Time{}, false
+	}
+	years := c.DetailYears
+	if years <= 0 {
+		years = DefaultDetailYears
+	}
+	detailStart := end.AddDate(-years, 0, 0)
+	return detailStart, start.Before(detailStart)
+}
+
+// GetWeekLabelInterval returns the number of columns between week labels, or
+// 0 if they are hidden
+func (c *Config) GetWeekLabelInterval() int {
+	if !c.ShowWeekLabels {
+		return 0
+	}
+	if c.WeekLabelInterval <= 0 {
+		return 4
+	}
+	return c.WeekLabelInterval
+}
+
+// Hash returns a short SHA-256 digest of the settings a heatmap is rendered
+// with, leaving out debug logging, profiles, targets and the roster, which
+// don't change the image
+func (c *Config) Hash() string {
+	rendered := *c
+	rendered.Debug = false
+	rendered.Profiles = nil
+	rendered.Targets = nil
+	rendered.Roster = nil
+
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// HasWidget reports whether a widget is enabled in the config
+func (c *Config) HasWidget(name string) bool {
+	return contains(c.Widgets, name)
+}
+
+// parseSeasonStart parses the configured season start month and day. Feb 29
+// is rejected rather than moved to Mar 1 in the years without it.
+func (c *Config) parseSeasonStart() (time.Time, error) {
+	seasonStart, err := time.Parse("01-02", c.SeasonStart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid season start %q, expected MM-DD: %w", c.SeasonStart, err)
+	}
+	if seasonStart.Month() == time.February && seasonStart.Day() == 29 {
+		return time.Time{}, fmt.Errorf("invalid season start %q: Feb 29 doesn't occur every year, use 02-28 or 03-01", c.SeasonStart)
+	}
+	return seasonStart, nil
+}
+
+// AthleteTokenVar returns the environment variable holding a roster
+// athlete's refresh token, such as STRAVA_REFRESH_TOKEN_JANE_DOE for
+// "jane-doe"
+func AthleteTokenVar(name string) string {
+	return "STRAVA_REFRESH_TOKEN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,31 @@
+package config
+
+import "fmt"
+
+// ValidPresets contains all valid configuration presets
+var ValidPresets = []string{"climbing"}
+
+// GetPreset returns the default configuration for a named preset.
+// Values set explicitly in the config file override these defaults.
+func GetPreset(name string) (*Config, error) {
+	switch name {
+	case "climbing":
+		// Elevation-focused heatmap for mountain athletes
+		return &Config{
+			ActivityTypes:   []string{"Hike", "Run", "Walk", "BackcountrySki", "Snowshoe", "RockClimbing"},
+			MetricType:      "elevation",
+			ColorScheme:     "snow",
+			DateRange:       "1year",
+			CellSize:        11,
+			IncludePRs:      true,
+			DarkModeSupport: true,
+			DarkModeColors:  []string{"#161b22", "#1c3a5e", "#3f6f9e", "#9cc3e6", "#f5faff"},
+			LegendUnits:     true,
+			WeekStart:       "Monday",
+			Language:        "en",
+			TimeZone:        "UTC",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown preset: %s, must be one of %v", name, ValidPresets)
+	}
+}
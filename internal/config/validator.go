@@ -2,26 +2,82 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ValidMetricTypes contains all valid metric types
-var ValidMetricTypes = []string{"distance", "duration", "elevation", "effort", "heart_rate"}
+var ValidMetricTypes = []string{"distance", "duration", "elevation", "effort", "heart_rate", "energy", "work", "normalized_power", "tss", "composite", "variety"}
+
+// ValidSecondaryEncodings contains all ways a secondary metric can be drawn
+var ValidSecondaryEncodings = []string{"border", "dot"}
 
 // ValidColorSchemes contains all valid color schemes
-var ValidColorSchemes = []string{"github", "strava", "blue", "purple", "custom"}
+var ValidColorSchemes = []string{"github", "strava", "blue", "purple", "snow", "custom"}
 
 // ValidDateRanges contains all valid date ranges
-var ValidDateRanges = []string{"1year", "all", "ytd", "custom"}
+var ValidDateRanges = []string{"1year", "all", "ytd", "season", "custom"}
+
+// ValidIntensityWindows contains all valid intensity normalization windows
+var ValidIntensityWindows = []string{"range", "12months", "all"}
+
+// ValidIntensityScales contains all ways metric values can be binned into
+// intensity levels
+var ValidIntensityScales = []string{"percentile", "linear", "logarithmic", "fixed"}
+
+// ValidLanguages contains all languages with number formatting support;
+// labels, tooltips, the stats panel and widgets are translated to en, de, es,
+// fr and ja
+var ValidLanguages = []string{"en", "de", "es", "fr", "it", "ja", "nl", "pt"}
+
+// ValidDurationStyles contains all ways durations can be written
+var ValidDurationStyles = []string{"short", "long", "clock", "minutes"}
+
+// ValidUnits contains all systems distances and elevation can be shown in
+var ValidUnits = []string{"metric", "imperial"}
+
+// ValidTimeBases contains the activity times durations can be taken from
+var ValidTimeBases = []string{"moving", "elapsed"}
 
 // ValidWeekStarts contains all valid week start days
 var ValidWeekStarts = []string{"Sunday", "Monday"}
 
+// ValidWeekNumberPositions contains all valid positions for ISO week numbers
+var ValidWeekNumberPositions = []string{"top", "bottom"}
+
+// ValidComparisonModes contains all periods the heatmap can be compared with
+var ValidComparisonModes = []string{"yoy"}
+
+// ValidComparisonViews contains all ways a comparison can be drawn
+var ValidComparisonViews = []string{"stacked", "diff"}
+
+// ValidWidgets contains all widgets that can be rendered below the heatmap
+var ValidWidgets = []string{"month_comparison", "goal_progress", "travel", "tags", "heart_rate", "time_of_day", "workouts"}
+
+// ValidOutputFormats contains all formats the heatmap can be written in
+var ValidOutputFormats = []string{"svg", "png"}
+
+// ValidTargets contains the screens the heatmap can be laid out for
+var ValidTargets = []string{"desktop", "mobile"}
+
+// ValidLayouts contains the shapes the heatmap's days can be arranged in
+var ValidLayouts = []string{"grid", "radial", "github"}
+
+// ValidProviders contains the providers activities can be fetched from, the
+// files and export providers reading exported activities instead of an API
+var ValidProviders = []string{"strava", "garmin", "files", "export"}
+
 // ValidStatTypes contains all valid statistic types
 var ValidStatTypes = []string{"weekly", "monthly", "yearly"}
 
 // ValidateConfig validates the configuration
 func ValidateConfig(config *Config) error {
+	// Validate preset
+	if config.Preset != "" && !contains(ValidPresets, config.Preset) {
+		return fmt.Errorf("invalid preset: %s, must be one of %v", config.Preset, ValidPresets)
+	}
+
 	// Validate required fields
 	if len(config.ActivityTypes) == 0 {
 		return fmt.Errorf("activityTypes cannot be empty")
@@ -32,6 +88,39 @@ func ValidateConfig(config *Config) error {
 		return fmt.Errorf("invalid metricType: %s, must be one of %v", config.MetricType, ValidMetricTypes)
 	}
 
+	// Validate secondary metric (empty disables it)
+	if config.SecondaryMetric != "" {
+		if !contains(ValidMetricTypes, config.SecondaryMetric) {
+			return fmt.Errorf("invalid secondaryMetric: %s, must be one of %v", config.SecondaryMetric, ValidMetricTypes)
+		}
+		if config.SecondaryMetric == config.MetricType {
+			return fmt.Errorf("secondaryMetric must differ from metricType")
+		}
+	}
+
+	// Validate metric weights, which the composite metric blends
+	if (config.MetricType == "composite" || config.SecondaryMetric == "composite") && len(config.MetricWeights) == 0 {
+		return fmt.Errorf("metricWeights cannot be empty with the composite metric")
+	}
+	totalWeight := 0.0
+	for metricType, weight := range config.MetricWeights {
+		if metricType == "composite" || !contains(ValidMetricTypes, metricType) {
+			return fmt.Errorf("invalid metric in metricWeights: %s", metricType)
+		}
+		if weight < 0 {
+			return fmt.Errorf("metricWeights.%s cannot be negative", metricType)
+		}
+		totalWeight += weight
+	}
+	if len(config.MetricWeights) > 0 && totalWeight == 0 {
+		return fmt.Errorf("metricWeights must have at least one positive weight")
+	}
+
+	// Validate secondary encoding (empty defaults to border)
+	if config.SecondaryEncoding != "" && !contains(ValidSecondaryEncodings, config.SecondaryEncoding) {
+		return fmt.Errorf("invalid secondaryEncoding: %s, must be one of %v", config.SecondaryEncoding, ValidSecondaryEncodings)
+	}
+
 	// Validate color scheme
 	if !contains(ValidColorSchemes, config.ColorScheme) {
 		return fmt.Errorf("invalid colorScheme: %s, must be one of %v", config.ColorScheme, ValidColorSchemes)
@@ -50,6 +139,26 @@ func ValidateConfig(config *Config) error {
 		}
 	}
 
+	// In strict mode, reject a timezone that can't be loaded here rather
+	// than when the date range is first needed
+	if config.Strict && config.TimeZone != "" {
+		if _, err := time.LoadLocation(config.TimeZone); err != nil {
+			return fmt.Errorf("invalid timeZone: %s", config.TimeZone)
+		}
+	}
+
+	// Validate ignored activities
+	for _, id := range config.IgnoreActivityIDs {
+		if id <= 0 {
+			return fmt.Errorf("invalid ignoreActivityIds entry: %d, must be a positive activity ID", id)
+		}
+	}
+
+	// Validate provider (empty means strava)
+	if config.Provider != "" && !contains(ValidProviders, config.Provider) {
+		return fmt.Errorf("invalid provider: %s, must be one of %v", config.Provider, ValidProviders)
+	}
+
 	// Validate date range
 	if !contains(ValidDateRanges, config.DateRange) {
 		return fmt.Errorf("invalid dateRange: %s, must be one of %v", config.DateRange, ValidDateRanges)
@@ -62,21 +171,286 @@ func ValidateConfig(config *Config) error {
 		}
 	}
 
+	// Validate season start if needed
+	if config.DateRange == "season" {
+		if config.SeasonStart == "" {
+			return fmt.Errorf("seasonStart must be specified when dateRange is season")
+		}
+		if _, err := config.parseSeasonStart(); err != nil {
+			return err
+		}
+	}
+
+	// Validate FTP
+	if config.FTP < 0 {
+		return fmt.Errorf("ftp cannot be negative")
+	}
+
 	// Validate cell size
 	if config.CellSize < 5 || config.CellSize > 20 {
 		return fmt.Errorf("cellSize must be between 5 and 20")
 	}
 
+	// Validate intensity window (empty defaults to the displayed range)
+	if config.IntensityWindow != "" && !contains(ValidIntensityWindows, config.IntensityWindow) {
+		return fmt.Errorf("invalid intensityWindow: %s, must be one of %v", config.IntensityWindow, ValidIntensityWindows)
+	}
+
+	// Validate intensity scale (empty defaults to percentiles)
+	scale := config.IntensityScale
+	if scale.Mode != "" && !contains(ValidIntensityScales, scale.Mode) {
+		return fmt.Errorf("invalid intensityScale mode: %s, must be one of %v", scale.Mode, ValidIntensityScales)
+	}
+	if scale.Mode == "fixed" {
+		if len(scale.Thresholds) != 3 && len(scale.Thresholds) != 4 {
+			return fmt.Errorf("intensityScale thresholds must hold 3 or 4 values for the fixed mode, the upper bounds of the low, medium, high and optionally very high levels")
+		}
+		for i, threshold := range scale.Thresholds {
+			if threshold <= 0 || (i > 0 && threshold <= scale.Thresholds[i-1]) {
+				return fmt.Errorf("intensityScale thresholds must be positive and increasing, got %v", scale.Thresholds)
+			}
+		}
+	}
+
 	// Validate location privacy radius if location heatmap is enabled
 	if config.IncludeLocationHeatmap && config.LocationPrivacyRadius < 0 {
 		return fmt.Errorf("locationPrivacyRadius cannot be negative")
 	}
 
-	// Validate week start
-	if !contains(ValidWeekStarts, config.WeekStart) {
+	// Validate token store (empty keeps rotated tokens in the cache only)
+	if config.TokenStore != "" {
+		kind, arg, _ := strings.Cut(config.TokenStore, ":")
+		switch {
+		case kind == "file" && arg == "":
+			return fmt.Errorf("tokenStore file: needs a path, e.g. file:.strava-token.json")
+		case kind != "file" && kind != "secret":
+			return fmt.Errorf("invalid tokenStore: %s, must be file:PATH, secret or secret:NAME", config.TokenStore)
+		}
+	}
+
+	// Validate output format (empty means svg), which the heatmap file's
+	// extension must match
+	format := config.OutputFormat
+	if format == "" {
+		format = "svg"
+	} else if !contains(ValidOutputFormats, format) {
+		return fmt.Errorf("invalid outputFormat: %s, must be one of %v", format, ValidOutputFormats)
+	}
+	if config.SVGFile != "" && !strings.EqualFold(filepath.Ext(config.SVGFile), "."+format) {
+		return fmt.Errorf("svgFile must end in .%s for outputFormat %s", format, format)
+	}
+	if config.MobileSVGFile != "" {
+		if config.SVGFile == "" {
+			return fmt.Errorf("mobileSvgFile needs svgFile, which narrow screens fall back from")
+		}
+		if !strings.EqualFold(filepath.Ext(config.MobileSVGFile), "."+format) {
+			return fmt.Errorf("mobileSvgFile must end in .%s for outputFormat %s", format, format)
+		}
+	}
+	if config.Target != "" && !contains(ValidTargets, config.Target) {
+		return fmt.Errorf("invalid target: %s, must be one of %v", config.Target, ValidTargets)
+	}
+	if config.Layout != "" && !contains(ValidLayouts, config.Layout) {
+		return fmt.Errorf("invalid layout: %s, must be one of %v", config.Layout, ValidLayouts)
+	}
+
+	// The ring holds one year, so longer ranges would wrap around it
+	if config.Layout == "radial" {
+		if config.DateRange == "all" {
+			return fmt.Errorf("layout radial draws a single year, so it can't be used with dateRange all")
+		}
+		if config.DateRange == "custom" {
+			start, startErr := time.Parse("2006-01-02", config.CustomDateRange.Start)
+			end, endErr := time.Parse("2006-01-02", config.CustomDateRange.End)
+			if startErr == nil && endErr == nil && !end.Before(start.AddDate(1, 0, 0)) {
+				return fmt.Errorf("layout radial draws a single year, so customDateRange can't span more than one")
+			}
+		}
+	}
+	if config.PNGDPI < 0 {
+		return fmt.Errorf("pngDpi cannot be negative")
+	}
+
+	// Location data is never published in privacy mode
+	if config.PrivacyMode && config.IncludeLocationHeatmap {
+		return fmt.Errorf("includeLocationHeatmap cannot be enabled when privacyMode is true")
+	}
+	if config.PrivacyMode && config.StatsFile != "" {
+		return fmt.Errorf("statsFile cannot be set when privacyMode is true")
+	}
+
+	// Validate week start (empty follows the language)
+	if config.WeekStart != "" && !contains(ValidWeekStarts, config.WeekStart) {
 		return fmt.Errorf("invalid weekStart: %s, must be one of %v", config.WeekStart, ValidWeekStarts)
 	}
 
+	// Validate week number position (empty disables week numbers)
+	if config.WeekNumbers != "" && !contains(ValidWeekNumberPositions, config.WeekNumbers) {
+		return fmt.Errorf("invalid weekNumbers: %s, must be one of %v", config.WeekNumbers, ValidWeekNumberPositions)
+	}
+
+	// Validate week label interval
+	if config.WeekLabelInterval < 0 {
+		return fmt.Errorf("weekLabelInterval cannot be negative")
+	}
+	if config.DetailYears < 0 {
+		return fmt.Errorf("detailYears cannot be negative")
+	}
+
+	// Validate annotations
+	for i, annotation := range config.Annotations {
+		if _, err := time.Parse("2006-01-02", annotation.Date); err != nil {
+			return fmt.Errorf("invalid annotation date at position %d: %s", i, annotation.Date)
+		}
+		if annotation.Label == "" {
+			return fmt.Errorf("annotation at position %d must have a label", i)
+		}
+	}
+
+	// Validate targets, which can't share a README
+	readmes := make(map[string]bool)
+	for i, target := range config.Targets {
+		if target.Readme == "" {
+			return fmt.Errorf("target at position %d must have a readme", i)
+		}
+		if readmes[filepath.Clean(target.Readme)] {
+			return fmt.Errorf("duplicate target readme: %s", target.Readme)
+		}
+		readmes[filepath.Clean(target.Readme)] = true
+		if target.Profile != "" && !profileNamePattern.MatchString(target.Profile) {
+			return fmt.Errorf("invalid target profile name: %s, use only letters, digits, '-' and '_'", target.Profile)
+		}
+	}
+
+	// Validate the roster, whose athletes each need a token variable of their
+	// own and so a distinct name
+	if len(config.Roster) > 0 {
+		if len(config.Targets) > 0 {
+			return fmt.Errorf("roster and targets cannot be used together")
+		}
+		if config.Provider != "" && config.Provider != "strava" {
+			return fmt.Errorf("roster needs provider strava, not %s", config.Provider)
+		}
+		if kind, arg, _ := strings.Cut(config.TokenStore, ":"); kind == "file" || arg != "" {
+			return fmt.Errorf("tokenStore %s holds a single token, use secret with a roster", config.TokenStore)
+		}
+	}
+	names := make(map[string]bool)
+	for i, athlete := range config.Roster {
+		if !profileNamePattern.MatchString(athlete.Name) {
+			return fmt.Errorf("invalid roster name at position %d: %q, use only letters, digits, '-' and '_'", i, athlete.Name)
+		}
+		if names[AthleteTokenVar(athlete.Name)] {
+			return fmt.Errorf("duplicate roster name: %s, whose token would be read from the same %s", athlete.Name, AthleteTokenVar(athlete.Name))
+		}
+		names[AthleteTokenVar(athlete.Name)] = true
+		if athlete.Readme == "" {
+			return fmt.Errorf("roster athlete %s must have a readme", athlete.Name)
+		}
+		if athlete.Profile != "" && !profileNamePattern.MatchString(athlete.Profile) {
+			return fmt.Errorf("invalid roster profile name: %s, use only letters, digits, '-' and '_'", athlete.Profile)
+		}
+	}
+
+	// Validate distance milestones
+	for i, milestone := range config.DistanceMilestones {
+		if milestone <= 0 {
+			return fmt.Errorf("invalid distance milestone at position %d: %g, must be positive", i, milestone)
+		}
+	}
+
+	// Validate privacy zones
+	for i, zone := range config.PrivacyZones {
+		if zone.Lat < -90 || zone.Lat > 90 || zone.Lng < -180 || zone.Lng > 180 {
+			return fmt.Errorf("invalid privacy zone coordinates at position %d: %g,%g", i, zone.Lat, zone.Lng)
+		}
+		if zone.Radius <= 0 {
+			return fmt.Errorf("privacy zone at position %d must have a positive radius", i)
+		}
+	}
+
+	// Validate widgets
+	for _, widget := range config.Widgets {
+		if !contains(ValidWidgets, widget) {
+			return fmt.Errorf("invalid widget: %s, must be one of %v", widget, ValidWidgets)
+		}
+	}
+
+	// Validate the yearly goal if the goal widget is enabled
+	if config.YearlyDistanceGoal < 0 {
+		return fmt.Errorf("yearlyDistanceGoal cannot be negative")
+	}
+	if config.HasWidget("goal_progress") && config.YearlyDistanceGoal == 0 {
+		return fmt.Errorf("yearlyDistanceGoal must be set when the goal_progress widget is enabled")
+	}
+
+	// Validate the API request timeout (0 uses the default)
+	if config.HTTPTimeout < 0 {
+		return fmt.Errorf("httpTimeout cannot be negative")
+	}
+
+	// Validate the request budget (0 means no limit)
+	if config.MaxAPIRequests < 0 {
+		return fmt.Errorf("maxApiRequests cannot be negative")
+	}
+
+	// Validate the workload ratio threshold (0 disables ramp warnings)
+	if config.ACWRThreshold < 0 {
+		return fmt.Errorf("acwrThreshold cannot be negative")
+	}
+
+	// Validate the shortest highlighted streak (0 uses the default)
+	if config.StreakMinDays < 0 {
+		return fmt.Errorf("streakMinDays cannot be negative")
+	}
+
+	// Validate the comparison (empty disables it), which replaces the ghost
+	// overlay
+	if config.ComparisonMode != "" {
+		if !contains(ValidComparisonModes, config.ComparisonMode) {
+			return fmt.Errorf("invalid comparisonMode: %s, must be one of %v", config.ComparisonMode, ValidComparisonModes)
+		}
+		if config.GhostPreviousYear {
+			return fmt.Errorf("ghostPreviousYear cannot be combined with comparisonMode")
+		}
+	}
+	if config.ComparisonView != "" && !contains(ValidComparisonViews, config.ComparisonView) {
+		return fmt.Errorf("invalid comparisonView: %s, must be one of %v", config.ComparisonView, ValidComparisonViews)
+	}
+
+	// Validate tags
+	for name, keywords := range config.Tags {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("tag names cannot be empty")
+		}
+		if len(keywords) == 0 {
+			return fmt.Errorf("tag %q must have at least one keyword", name)
+		}
+	}
+	if config.HasWidget("tags") && len(config.Tags) == 0 {
+		return fmt.Errorf("tags must be set when the tags widget is enabled")
+	}
+
+	// Validate language (empty defaults to English)
+	if config.Language != "" && !contains(ValidLanguages, config.Language) {
+		return fmt.Errorf("invalid language: %s, must be one of %v", config.Language, ValidLanguages)
+	}
+
+	// Validate duration style (empty defaults to short)
+	if config.DurationStyle != "" && !contains(ValidDurationStyles, config.DurationStyle) {
+		return fmt.Errorf("invalid durationStyle: %s, must be one of %v", config.DurationStyle, ValidDurationStyles)
+	}
+
+	// Validate units (empty defaults to metric)
+	if config.Units != "" && !contains(ValidUnits, config.Units) {
+		return fmt.Errorf("invalid units: %s, must be one of %v", config.Units, ValidUnits)
+	}
+
+	// Validate time basis (empty defaults to moving time)
+	if config.TimeBasis != "" && !contains(ValidTimeBases, config.TimeBasis) {
+		return fmt.Errorf("invalid timeBasis: %s, must be one of %v", config.TimeBasis, ValidTimeBases)
+	}
+
 	// Validate dark mode colors if dark mode is enabled
 	if config.DarkModeSupport {
 		if len(config.DarkModeColors) != 5 {
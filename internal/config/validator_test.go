@@ -0,0 +1,152 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig returns the repository's example configuration, which passes
+// validation
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	config, err := LoadConfig(filepath.Join("..", "..", "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfig(config); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	return config
+}
+
+func TestValidateMetricWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		weights    map[string]float64
+		wantErr    string // Expected in the error, empty for a valid config
+	}{
+		{"blend", "composite", map[string]float64{"distance": 0.5, "duration": 0.3, "elevation": 0.2}, ""},
+		{"weights not summing to 1", "composite", map[string]float64{"distance": 5, "duration": 3}, ""},
+		{"single weight", "composite", map[string]float64{"distance": 1}, ""},
+		{"zero weight beside a positive one", "composite", map[string]float64{"distance": 1, "elevation": 0}, ""},
+		{"weights unused by the metric", "distance", map[string]float64{"duration": 1}, ""},
+		{"composite without weights", "composite", nil, "metricWeights cannot be empty"},
+		{"negative weight", "composite", map[string]float64{"distance": 1, "duration": -0.5}, "metricWeights.duration cannot be negative"},
+		{"all zero", "composite", map[string]float64{"distance": 0, "duration": 0}, "at least one positive weight"},
+		{"unknown metric", "composite", map[string]float64{"pace": 1}, "invalid metric in metricWeights: pace"},
+		{"composite in its own blend", "composite", map[string]float64{"composite": 1}, "invalid metric in metricWeights: composite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig(t)
+			config.MetricType = tt.metricType
+			config.SecondaryMetric = ""
+			config.MetricWeights = tt.weights
+
+			err := ValidateConfig(config)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSeasonStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   string
+		wantErr string // Expected in the error, empty for a valid config
+	}{
+		{"autumn", "11-01", ""},
+		{"last day of February", "02-28", ""},
+		{"new year", "01-01", ""},
+		{"leap day", "02-29", "Feb 29 doesn't occur every year"},
+		{"missing", "", "seasonStart must be specified"},
+		{"day out of range", "04-31", "expected MM-DD"},
+		{"not a date", "November", "expected MM-DD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig(t)
+			config.DateRange = "season"
+			config.SeasonStart = tt.start
+
+			err := ValidateConfig(config)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFixedIntensityScale(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []float64
+		wantErr    string // Expected in the error, empty for a valid config
+	}{
+		{"three levels", []float64{5, 10, 20}, ""},
+		{"very high bounded too", []float64{5, 10, 20, 40}, ""},
+		{"too few", []float64{5, 10}, "must hold 3 or 4 values"},
+		{"too many", []float64{5, 10, 20, 40, 80}, "must hold 3 or 4 values"},
+		{"decreasing", []float64{5, 20, 10, 40}, "positive and increasing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig(t)
+			config.IntensityScale = IntensityScale{Mode: "fixed", Thresholds: tt.thresholds}
+
+			err := ValidateConfig(config)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRadialLayoutRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		dateRange  string
+		start, end string
+		wantErr    string // Expected in the error, empty for a valid config
+	}{
+		{"one year", "1year", "", "", ""},
+		{"year to date", "ytd", "", "", ""},
+		{"all history", "all", "", "", "can't be used with dateRange all"},
+		{"custom calendar year", "custom", "2024-01-01", "2024-12-31", ""},
+		{"custom over a year", "custom", "2024-01-01", "2025-01-01", "can't span more than one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig(t)
+			config.Layout = "radial"
+			config.DateRange = tt.dateRange
+			config.CustomDateRange.Start, config.CustomDateRange.End = tt.start, tt.end
+
+			err := ValidateConfig(config)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package garmin fetches activities from the Garmin Connect activity API, as
+// an alternative to Strava
+package garmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+const (
+	baseURL        = "https://apis.garmin.com/wellness-api/rest"
+	activitiesPath = "/activities"
+
+	// TokenURL is Garmin's OAuth 2 token endpoint, where refresh tokens are
+	// exchanged for access tokens
+	TokenURL = "https://diauth.garmin.com/di-oauth2-service/oauth/token"
+
+	// maxWindow is the longest upload time range one request may cover
+	maxWindow = 24 * time.Hour
+
+	// MaxOffset is the furthest local time runs ahead of UTC, so an activity
+	// on the first local day of a range may have started and been uploaded
+	// this long before it began in UTC
+	MaxOffset = 14 * time.Hour
+)
+
+// Client handles API communication with Garmin Connect
+type Client struct {
+	httpClient   *http.Client
+	tokenManager strava.TokenManager
+	debug        bool
+	budget       int // Most requests to make, 0 for no limit
+	requests     int
+}
+
+// NewClient creates a new Garmin Connect client. Tokens are refreshed at
+// TokenURL by the token manager.
+func NewClient(tokenManager strava.TokenManager, debug bool, options strava.HTTPOptions) *Client {
+	return &Client{
+		httpClient:   strava.NewHTTPClient(options),
+		tokenManager: tokenManager,
+		debug:        debug,
+	}
+}
+
+// SetRequestBudget caps the requests the client makes, 0 for no limit
+func (c *Client) SetRequestBudget(budget int) {
+	c.budget = budget
+}
+
+// GetAllActivities retrieves the activities started within the given time
+// range, of the given Strava types if any. Garmin lists activities
+// by when they were uploaded, so every upload since the start of the range
+// is read; see GetActivitiesUploadedSince.
+func (c *Client) GetAllActivities(after, before time.Time, types []string) ([]strava.SummaryActivity, error) {
+	return c.GetActivitiesUploadedSince(after.Add(-MaxOffset), after, before, types)
+}
+
+// GetActivitiesUploadedSince retrieves the activities uploaded since the
+// given time that started within the given time range, of the given Strava
+// types if any. Uploads are read a day at a time until now, and activities
+// are matched to the range on the wall clock, by the local time they
+// started at wherever they were recorded. An
+// activity edited after upload is listed again with its changes, so the
+// latest listing wins. If the request budget runs out, the activities
+// fetched so far are returned along with an error wrapping
+// strava.ErrRequestBudget.
+func (c *Client) GetActivitiesUploadedSince(since, after, before time.Time, types []string) ([]strava.SummaryActivity, error) {
+	wanted := make(map[string]bool)
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	// Listings are kept by ID in the order first seen, and only filtered
+	// once the latest of each is known
+	var listed []strava.SummaryActivity
+	index := make(map[int64]int)
+	var err error
+	now := time.Now()
+	for from := since; from.Before(now); from = from.Add(maxWindow) {
+		to := from.Add(maxWindow)
+		if to.After(now) {
+			to = now
+		}
+
+		activities, fetchErr := c.getActivities(from, to)
+		if fetchErr != nil {
+			err = fmt.Errorf("error fetching activities uploaded from %s: %w", from.Format("2006-01-02"), fetchErr)
+			break
+		}
+
+		for _, a := range activities {
+			activity := a.Summary()
+			if i, ok := index[activity.ID]; ok {
+				listed[i] = activity
+				continue
+			}
+			index[activity.ID] = len(listed)
+			listed = append(listed, activity)
+		}
+	}
+
+	var all []strava.SummaryActivity
+	first, last := wallClock(after), wallClock(before)
+	for _, activity := range listed {
+		if activity.StartDateLocal.Before(first) || activity.StartDateLocal.After(last) {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[activity.Type] {
+			continue
+		}
+		all = append(all, activity)
+	}
+	if err != nil {
+		return all, err
+	}
+
+	if c.debug {
+		c.logDebug(fmt.Sprintf("Retrieved a total of %d activities after filtering", len(all)))
+	}
+	return all, nil
+}
+
+// wallClock returns the wall clock time of t written as UTC, as local start
+// times are
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// getActivities retrieves the activities uploaded within a window of at
+// most a day
+func (c *Client) getActivities(from, to time.Time) ([]Activity, error) {
+	params := url.Values{}
+	params.Add("uploadStartTimeInSeconds", strconv.FormatInt(from.Unix(), 10))
+	params.Add("uploadEndTimeInSeconds", strconv.FormatInt(to.Unix(), 10))
+
+	body, err := c.makeRequest(activitiesPath, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var activities []Activity
+	if err := json.Unmarshal(body, &activities); err != nil {
+		return nil, fmt.Errorf("error parsing activities data: %w", err)
+	}
+	return activities, nil
+}
+
+// makeRequest sends an authorized GET request and returns the response body
+func (c *Client) makeRequest(path string, params url.Values) ([]byte, error) {
+	if c.budget > 0 && c.requests >= c.budget {
+		return nil, strava.ErrRequestBudget
+	}
+
+	accessToken, err := c.tokenManager.GetAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	req, err := http.NewRequest("GET", baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	c.requests++
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// logDebug logs debug information to stderr, keeping stdout clean for the SVG
+func (c *Client) logDebug(message string) {
+	fmt.Fprintf(os.Stderr, "[DEBUG] Garmin: %s\n", message)
+}
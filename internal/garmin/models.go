@@ -0,0 +1,105 @@
+package garmin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// Activity is an activity summary from the Garmin Connect activity API
+type Activity struct {
+	SummaryID        string  `json:"summaryId"`
+	ActivityID       int64   `json:"activityId"`
+	ActivityName     string  `json:"activityName"`
+	ActivityType     string  `json:"activityType"`             // e.g. RUNNING or ROAD_BIKING
+	StartTime        int64   `json:"startTimeInSeconds"`       // Unix seconds
+	StartTimeOffset  int     `json:"startTimeOffsetInSeconds"` // Offset of the local time from UTC
+	Duration         int     `json:"durationInSeconds"`
+	Distance         float64 `json:"distanceInMeters"`
+	ElevationGain    float64 `json:"totalElevationGainInMeters"`
+	AverageHeartRate float64 `json:"averageHeartRateInBeatsPerMinute"`
+	MaxHeartRate     float64 `json:"maxHeartRateInBeatsPerMinute"`
+	Calories         float64 `json:"activeKilocalories"`
+	RunCadence       float64 `json:"averageRunCadenceInStepsPerMinute"`
+	BikeCadence      float64 `json:"averageBikeCadenceInRoundsPerMinute"`
+	StartLatitude    float64 `json:"startingLatitudeInDegree"`
+	StartLongitude   float64 `json:"startingLongitudeInDegree"`
+}
+
+// activityTypes maps Garmin activity types to Strava activity types
+var activityTypes = map[string]string{
+	"RUNNING":                          "Run",
+	"STREET_RUNNING":                   "Run",
+	"TRACK_RUNNING":                    "Run",
+	"TREADMILL_RUNNING":                "Run",
+	"INDOOR_RUNNING":                   "Run",
+	"TRAIL_RUNNING":                    "TrailRun",
+	"VIRTUAL_RUN":                      "VirtualRun",
+	"CYCLING":                          "Ride",
+	"ROAD_BIKING":                      "Ride",
+	"INDOOR_CYCLING":                   "Ride",
+	"MOUNTAIN_BIKING":                  "MountainBikeRide",
+	"GRAVEL_CYCLING":                   "GravelRide",
+	"VIRTUAL_RIDE":                     "VirtualRide",
+	"E_BIKE_FITNESS":                   "EBikeRide",
+	"SWIMMING":                         "Swim",
+	"LAP_SWIMMING":                     "Swim",
+	"OPEN_WATER_SWIMMING":              "Swim",
+	"WALKING":                          "Walk",
+	"CASUAL_WALKING":                   "Walk",
+	"SPEED_WALKING":                    "Walk",
+	"HIKING":                           "Hike",
+	"ROWING":                           "Rowing",
+	"INDOOR_ROWING":                    "Rowing",
+	"RESORT_SKIING_SNOWBOARDING":       "AlpineSki",
+	"CROSS_COUNTRY_SKIING":             "NordicSki",
+	"BACKCOUNTRY_SKIING":               "BackcountrySki",
+	"STRENGTH_TRAINING":                "WeightTraining",
+	"YOGA":                             "Yoga",
+	"ELLIPTICAL":                       "Elliptical",
+	"STAIR_CLIMBING":                   "StairStepper",
+	"INDOOR_CARDIO":                    "Workout",
+	"FITNESS_EQUIPMENT":                "Workout",
+	"STAND_UP_PADDLEBOARDING":          "StandUpPaddling",
+	"KAYAKING":                         "Kayaking",
+	"ROCK_CLIMBING":                    "RockClimbing",
+	"INDOOR_CLIMBING":                  "RockClimbing",
+	"MULTI_SPORT":                      "Workout",
+	"BREATHWORK":                       "Workout",
+	"HIGH_INTENSITY_INTERVAL_TRAINING": "HighIntensityIntervalTraining",
+}
+
+// Summary converts the activity to the Strava summary the aggregator and
+// renderers work with. Garmin has no moving time, so the duration stands in
+// for it, and types without a Strava equivalent count as Workout.
+func (a Activity) Summary() strava.SummaryActivity {
+	start := time.Unix(a.StartTime, 0).UTC()
+	activityType, ok := activityTypes[strings.ToUpper(a.ActivityType)]
+	if !ok {
+		activityType = "Workout"
+	}
+
+	summary := strava.SummaryActivity{
+		ID:               a.ActivityID,
+		Name:             a.ActivityName,
+		Type:             activityType,
+		StartDate:        start,
+		StartDateLocal:   start.Add(time.Duration(a.StartTimeOffset) * time.Second),
+		Distance:         a.Distance,
+		MovingTime:       a.Duration,
+		ElapsedTime:      a.Duration,
+		TotalElevGain:    a.ElevationGain,
+		AverageHeartrate: a.AverageHeartRate,
+		MaxHeartrate:     a.MaxHeartRate,
+		Calories:         a.Calories,
+		AverageCadence:   a.RunCadence,
+	}
+	if a.BikeCadence > 0 {
+		summary.AverageCadence = a.BikeCadence
+	}
+	if a.StartLatitude != 0 || a.StartLongitude != 0 {
+		summary.StartLatlng = []float64{a.StartLatitude, a.StartLongitude}
+	}
+	return summary
+}
@@ -2,7 +2,10 @@ package github
 
 import (
 	"fmt"
+	"html"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -20,13 +23,36 @@ func NewActionsHandler(debug bool) *ActionsHandler {
 
 // SetOutput sets a GitHub Actions output variable
 func (a *ActionsHandler) SetOutput(name, value string) error {
-	// In GitHub Actions, outputs are set by writing to a specific file
-	// or using a specific syntax in stdout
-	// Here we'll just use the ::set-output syntax for simplicity
-	fmt.Printf("::set-output name=%s::%s\n", name, value)
+	// In GitHub Actions, outputs are set by appending to the file named by
+	// GITHUB_OUTPUT; local runs just print them
+	if outputPath := os.Getenv("GITHUB_OUTPUT"); outputPath != "" {
+		f, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening GitHub Actions output file: %w", err)
+		}
+		defer f.Close()
+
+		// Values spanning lines are written between delimiters, picking
+		// one the value doesn't contain
+		entry := fmt.Sprintf("%s=%s\n", name, value)
+		if strings.ContainsAny(value, "\r\n") {
+			delimiter := "STRAVA_HEATMAP_EOF"
+			for strings.Contains(value, delimiter) {
+				delimiter += "_"
+			}
+			entry = fmt.Sprintf("%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+		}
+
+		if _, err := f.WriteString(entry); err != nil {
+			return fmt.Errorf("error writing GitHub Actions output: %w", err)
+		}
+	} else {
+		fmt.Printf("Output %s: %s\n", name, value)
+	}
 
+	// Debug output goes to stderr so it can't mix with SVG written to stdout
 	if a.Debug {
-		fmt.Printf("[DEBUG] Set GitHub Actions output: %s=%s\n", name, value)
+		fmt.Fprintf(os.Stderr, "[DEBUG] Set GitHub Actions output: %s=%s\n", name, value)
 	}
 
 	return nil
@@ -42,6 +68,32 @@ func (a *ActionsHandler) LogError(msg string, err error) {
 	}
 }
 
+// LogFileError logs an error annotated with a title and the file it
+// concerns, which GitHub Actions shows on the file in the run summary. An
+// empty file leaves the annotation on the run.
+func (a *ActionsHandler) LogFileError(title, file, msg string) {
+	properties := "title=" + escapeProperty(title)
+	if file != "" {
+		properties = "file=" + escapeProperty(file) + "," + properties
+	}
+	fmt.Printf("::error %s::%s\n", properties, escapeData(msg))
+
+	if a.Debug {
+		fmt.Printf("[DEBUG] Logged error on %s: %s: %s\n", file, title, msg)
+	}
+}
+
+// escapeData escapes a workflow command's message
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a workflow command's property value, which also
+// can't contain the separators between properties
+func escapeProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeData(s))
+}
+
 // LogWarning logs a warning in a GitHub Actions friendly format
 func (a *ActionsHandler) LogWarning(msg string) {
 	// GitHub Actions specific warning logging format
@@ -90,10 +142,23 @@ func (a *ActionsHandler) RecordMetric(name string, value interface{}) {
 	}
 }
 
-// CreateSummary adds content to the GitHub Actions step summary
+// CreateSummary adds Markdown content to the GitHub Actions step summary,
+// printing it to stdout when not running in Actions
 func (a *ActionsHandler) CreateSummary(content string) error {
-	// In actual GitHub Actions, this would write to $GITHUB_STEP_SUMMARY
-	// For simplicity, we'll just print to stdout
+	// The step summary is the file named by GITHUB_STEP_SUMMARY
+	if summaryPath := os.Getenv("GITHUB_STEP_SUMMARY"); summaryPath != "" {
+		f, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening GitHub Actions step summary: %w", err)
+		}
+		defer f.Close()
+
+		if _, err := fmt.Fprintln(f, content); err != nil {
+			return fmt.Errorf("error writing GitHub Actions step summary: %w", err)
+		}
+		return nil
+	}
+
 	fmt.Println("\n--- Summary ---")
 	fmt.Println(content)
 	fmt.Println("---------------")
@@ -101,6 +166,23 @@ func (a *ActionsHandler) CreateSummary(content string) error {
 	return nil
 }
 
+// SummaryImage returns an <img> for a step summary showing the file at path,
+// a path relative to the repository root, on the branch the workflow runs
+// on, or "" outside GitHub Actions. GitHub strips data URIs from summaries,
+// so the image is linked rather than embedded, and shows once committed.
+func SummaryImage(path, alt string) string {
+	server := strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/")
+	repository := os.Getenv("GITHUB_REPOSITORY")
+	ref := os.Getenv("GITHUB_REF_NAME")
+	if server == "" || repository == "" || ref == "" || path == "" {
+		return ""
+	}
+
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	src := fmt.Sprintf("%s/%s/blob/%s/%s?raw=true", server, repository, ref, path)
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(alt))
+}
+
 // FormatTimestamp formats a timestamp for GitHub Actions logs
 func (a *ActionsHandler) FormatTimestamp(t time.Time) string {
 	return fmt.Sprintf("%s (UTC)", t.UTC().Format(time.RFC3339))
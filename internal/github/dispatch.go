@@ -0,0 +1,85 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultEventType is the repository_dispatch event type sent when none is
+// configured. Workflows subscribe to it with
+// on: { repository_dispatch: { types: [strava-activity] } }.
+const DefaultEventType = "strava-activity"
+
+// Dispatcher starts workflow runs in a repository through the GitHub API
+type Dispatcher struct {
+	Token     string // Token allowed to write the repository's contents or actions
+	Repo      string // owner/name
+	EventType string // repository_dispatch event type, DefaultEventType if empty
+	Workflow  string // Workflow file or ID to run with workflow_dispatch instead
+	Ref       string // Branch the workflow_dispatch run uses, "main" if empty
+	Debug     bool
+}
+
+// NewDispatcher creates a dispatcher for a repository
+func NewDispatcher(token, repo string, debug bool) *Dispatcher {
+	return &Dispatcher{
+		Token: token,
+		Repo:  repo,
+		Debug: debug,
+	}
+}
+
+// Dispatch starts a run. With a Workflow configured it sends a
+// workflow_dispatch for that workflow; otherwise it sends a
+// repository_dispatch event carrying payload as its client_payload.
+func (d *Dispatcher) Dispatch(payload map[string]interface{}) error {
+	var path string
+	var body interface{}
+
+	if d.Workflow != "" {
+		ref := d.Ref
+		if ref == "" {
+			ref = "main"
+		}
+		path = fmt.Sprintf("/repos/%s/actions/workflows/%s/dispatches", d.Repo, d.Workflow)
+		body = map[string]interface{}{"ref": ref}
+	} else {
+		eventType := d.EventType
+		if eventType == "" {
+			eventType = DefaultEventType
+		}
+		path = fmt.Sprintf("/repos/%s/dispatches", d.Repo)
+		body = map[string]interface{}{"event_type": eventType, "client_payload": payload}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshaling dispatch: %w", err)
+	}
+
+	req, err := newAPIRequest(http.MethodPost, path, d.Token, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending dispatch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Both endpoints answer 204 No Content on success
+	if resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("dispatch failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	if d.Debug {
+		fmt.Printf("[DEBUG] Dispatched workflow run in %s\n", d.Repo)
+	}
+
+	return nil
+}
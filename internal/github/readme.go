@@ -2,30 +2,55 @@ package github
 
 import (
 	"fmt"
+	"html"
 	"os"
 	"regexp"
 	"strings"
 )
 
 const (
-	startMarker = "<!-- STRAVA-HEATMAP-START -->"
-	endMarker   = "<!-- STRAVA-HEATMAP-END -->"
+	markerPrefix = "<!-- STRAVA-HEATMAP-"
+	startMarker  = "<!-- STRAVA-HEATMAP-START -->"
+	endMarker    = "<!-- STRAVA-HEATMAP-END -->"
 )
 
+// placeholderPattern matches a template variable, either as written, e.g.
+// {{strava.current_streak}} or {{strava.run.current_streak}} for the "run"
+// profile, or as substituted on an earlier update, wrapped in comments
+// naming the variable so it can be updated again
+var placeholderPattern = regexp.MustCompile(
+	`\{\{\s*strava(?:\.([\w-]+))?\.(\w+)\s*\}\}|<!-- strava(?:\.([\w-]+))?\.(\w+) -->.*?<!-- /strava(?:\.([\w-]+))?\.(\w+) -->`)
+
+// blockPattern matches the heatmap block of any profile, markers included
+var blockPattern = regexp.MustCompile(regexp.QuoteMeta(markerPrefix) + `START[\s\S]*?` + regexp.QuoteMeta(markerPrefix) + `END[^>]*-->`)
+
 // ReadmeUpdater handles updating the GitHub profile README
 type ReadmeUpdater struct {
-	FilePath string
-	Debug    bool
+	FilePath  string
+	Profile   string            // Namespaces the markers, empty for the default block
+	Variables map[string]string // Values substituted for placeholders outside the heatmap blocks
+	Debug     bool
 }
 
 // NewReadmeUpdater creates a new README updater
-func NewReadmeUpdater(filePath string, debug bool) *ReadmeUpdater {
+func NewReadmeUpdater(filePath, profile string, debug bool) *ReadmeUpdater {
 	return &ReadmeUpdater{
 		FilePath: filePath,
+		Profile:  profile,
 		Debug:    debug,
 	}
 }
 
+// Markers returns the start and end markers of the updater's block, e.g.
+// <!-- STRAVA-HEATMAP-START:run --> for the "run" profile
+func (r *ReadmeUpdater) Markers() (string, string) {
+	if r.Profile == "" {
+		return startMarker, endMarker
+	}
+	return fmt.Sprintf("%sSTART:%s -->", markerPrefix, r.Profile),
+		fmt.Sprintf("%sEND:%s -->", markerPrefix, r.Profile)
+}
+
 // UpdateReadme updates the README with the generated SVG
 func (r *ReadmeUpdater) UpdateReadme(svgContent string) error {
 	// Read the current README
@@ -35,19 +60,25 @@ func (r *ReadmeUpdater) UpdateReadme(svgContent string) error {
 	}
 
 	contentStr := string(content)
+	startMarker, endMarker := r.Markers()
 
 	// Check for markers
 	if !strings.Contains(contentStr, startMarker) || !strings.Contains(contentStr, endMarker) {
 		return fmt.Errorf("README does not contain required markers: %s and %s", startMarker, endMarker)
 	}
 
-	// Create the new content to insert
-	newContent := fmt.Sprintf("%s\n%s\n%s", startMarker, svgContent, endMarker)
+	// Create the new content to insert, escaping any marker that slipped
+	// into the SVG so it can't end the block early on the next update
+	newContent := fmt.Sprintf("%s\n%s\n%s", startMarker, escapeMarkers(svgContent), endMarker)
+
+	// Weave live values into the prose around the heatmap
+	contentStr = r.substituteVariables(contentStr)
 
-	// Replace the content between markers
+	// Replace the content between markers, inserting the content literally
+	// so "$" in the SVG isn't expanded as a group reference
 	pattern := fmt.Sprintf("%s[\\s\\S]*?%s", regexp.QuoteMeta(startMarker), regexp.QuoteMeta(endMarker))
 	re := regexp.MustCompile(pattern)
-	updatedContent := re.ReplaceAllString(contentStr, newContent)
+	updatedContent := re.ReplaceAllLiteralString(contentStr, newContent)
 
 	// Write back to the file
 	if err := os.WriteFile(r.FilePath, []byte(updatedContent), 0644); err != nil {
@@ -61,6 +92,149 @@ func (r *ReadmeUpdater) UpdateReadme(svgContent string) error {
 	return nil
 }
 
+// ImageTag returns an HTML image referencing a heatmap written to its own
+// file, for READMEs that embed the SVG by URL rather than inline
+func ImageTag(src, alt string) string {
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(alt))
+}
+
+// mobileBreakpoint is the widest screen, in CSS pixels, shown the mobile
+// heatmap. GitHub switches to its narrow layout below 768px.
+const mobileBreakpoint = 767
+
+// PictureTag returns an HTML picture showing the mobile heatmap on narrow
+// screens and the desktop heatmap everywhere else
+func PictureTag(src, mobileSrc, alt string) string {
+	return fmt.Sprintf(`<picture><source media="(max-width: %dpx)" srcset="%s">%s</picture>`,
+		mobileBreakpoint, html.EscapeString(mobileSrc), ImageTag(src, alt))
+}
+
+// Placeholders returns the names of the variables this updater's profile
+// uses outside the heatmap blocks, whether still written as placeholders or
+// already substituted
+func (r *ReadmeUpdater) Placeholders() ([]string, error) {
+	content, err := os.ReadFile(r.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading README: %w", err)
+	}
+
+	var names []string
+	for _, text := range outsideBlocks(string(content)) {
+		for _, groups := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			profile, name := groups[1], groups[2]
+			if strings.HasPrefix(groups[0], "<!--") {
+				profile, name = groups[3], groups[4]
+			}
+			if profile == r.Profile {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// substituteVariables replaces the placeholders of this updater's profile
+// with their values, leaving every heatmap block and unknown variables as
+// they are
+func (r *ReadmeUpdater) substituteVariables(content string) string {
+	if len(r.Variables) == 0 {
+		return content
+	}
+
+	// Heatmap blocks of any profile are copied verbatim
+	var sb strings.Builder
+	last := 0
+	for _, loc := range blockPattern.FindAllStringIndex(content, -1) {
+		sb.WriteString(placeholderPattern.ReplaceAllStringFunc(content[last:loc[0]], r.substitute))
+		sb.WriteString(content[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(placeholderPattern.ReplaceAllStringFunc(content[last:], r.substitute))
+
+	return sb.String()
+}
+
+// outsideBlocks returns the parts of the README between the heatmap blocks
+// of any profile
+func outsideBlocks(content string) []string {
+	var parts []string
+	last := 0
+	for _, loc := range blockPattern.FindAllStringIndex(content, -1) {
+		parts = append(parts, content[last:loc[0]])
+		last = loc[1]
+	}
+	return append(parts, content[last:])
+}
+
+// substitute returns the wrapped value of a single placeholder match, or the
+// match unchanged if it belongs to another profile or names no variable
+func (r *ReadmeUpdater) substitute(match string) string {
+	groups := placeholderPattern.FindStringSubmatch(match)
+
+	profile, name := groups[1], groups[2]
+	if strings.HasPrefix(match, "<!--") {
+		// Both comments of a substituted value must name the same variable
+		if groups[3] != groups[5] || groups[4] != groups[6] {
+			return match
+		}
+		profile, name = groups[3], groups[4]
+	}
+
+	value, ok := r.Variables[name]
+	if profile != r.Profile || !ok {
+		if r.Debug && profile == r.Profile {
+			fmt.Printf("[DEBUG] Unknown README variable: %s\n", name)
+		}
+		return match
+	}
+
+	key := "strava." + name
+	if profile != "" {
+		key = "strava." + profile + "." + name
+	}
+	return fmt.Sprintf("<!-- %s -->%s<!-- /%s -->", key, value, key)
+}
+
+// escapeMarkers replaces the opening of any marker comment, for this or any
+// other profile, with its entity-escaped form, which renders the same inside
+// SVG text
+func escapeMarkers(content string) string {
+	return strings.ReplaceAll(content, markerPrefix, "&lt;"+strings.TrimPrefix(markerPrefix, "<"))
+}
+
+// InitReadme adds the updater's markers to the end of the README, creating
+// it if needed, and reports whether it changed anything. A README that
+// already has both markers is left alone.
+func (r *ReadmeUpdater) InitReadme() (bool, error) {
+	content, err := os.ReadFile(r.FilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("error reading README: %w", err)
+	}
+
+	contentStr := string(content)
+	startMarker, endMarker := r.Markers()
+	if strings.Contains(contentStr, startMarker) && strings.Contains(contentStr, endMarker) {
+		return false, nil
+	}
+	if strings.Contains(contentStr, startMarker) || strings.Contains(contentStr, endMarker) {
+		return false, fmt.Errorf("README has only one of the markers %s and %s, fix it by hand", startMarker, endMarker)
+	}
+
+	if contentStr != "" && !strings.HasSuffix(contentStr, "\n") {
+		contentStr += "\n"
+	}
+	if contentStr != "" {
+		contentStr += "\n"
+	}
+	contentStr += fmt.Sprintf("%s\n%s\n", startMarker, endMarker)
+
+	if err := os.WriteFile(r.FilePath, []byte(contentStr), 0644); err != nil {
+		return false, fmt.Errorf("error writing README: %w", err)
+	}
+
+	return true, nil
+}
+
 // ValidateReadme checks if the README has the required markers
 func (r *ReadmeUpdater) ValidateReadme() (bool, error) {
 	// Read the README
@@ -70,6 +244,7 @@ func (r *ReadmeUpdater) ValidateReadme() (bool, error) {
 	}
 
 	contentStr := string(content)
+	startMarker, endMarker := r.Markers()
 
 	// Check for markers
 	hasStartMarker := strings.Contains(contentStr, startMarker)
@@ -0,0 +1,200 @@
+package github
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// adversarialNames are activity names that look like parts of the README
+// block or of an HTML comment
+var adversarialNames = []string{
+	"Tempo <!-- STRAVA-HEATMAP-END --> run",
+	"Long run <!-- STRAVA-HEATMAP-START -->",
+	"Intervals <!-- STRAVA-HEATMAP-END:run -->",
+	"Easy --> jog",
+	"Hill <!-- repeats",
+	"Fartlek $1 ${2}",
+}
+
+// activitySVG is a heatmap whose tooltip carries an activity name as given
+func activitySVG(name string) string {
+	return `<svg xmlns="http://www.w3.org/2000/svg"><rect width="11" height="11"><title>` + name + `</title></rect></svg>`
+}
+
+// writeReadme writes a README with prose around the given blocks' markers
+// and returns its path
+func writeReadme(t *testing.T, profiles ...string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	sb.WriteString("# Hello\n\nSome prose before.\n\n")
+	for _, profile := range profiles {
+		start, end := NewReadmeUpdater("", profile, false).Markers()
+		sb.WriteString(start + "\nold heatmap\n" + end + "\n\n")
+	}
+	sb.WriteString("Some prose after.\n")
+
+	path := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readReadme returns the contents of the README at path
+func readReadme(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// block returns the content between an updater's markers, failing unless
+// each marker appears exactly once
+func block(t *testing.T, readme string, r *ReadmeUpdater) string {
+	t.Helper()
+
+	start, end := r.Markers()
+	if n := strings.Count(readme, start); n != 1 {
+		t.Fatalf("README has %d start markers %s, want 1:\n%s", n, start, readme)
+	}
+	if n := strings.Count(readme, end); n != 1 {
+		t.Fatalf("README has %d end markers %s, want 1:\n%s", n, end, readme)
+	}
+
+	from := strings.Index(readme, start) + len(start)
+	to := strings.Index(readme, end)
+	if to < from {
+		t.Fatalf("end marker comes before the start marker:\n%s", readme)
+	}
+	return readme[from:to]
+}
+
+func TestUpdateReadmeAdversarialNames(t *testing.T) {
+	for _, name := range adversarialNames {
+		// Names reach the SVG raw if a widget forgets to escape them, and
+		// HTML-escaped otherwise
+		for escaping, svg := range map[string]string{"raw": activitySVG(name), "escaped": activitySVG(html.EscapeString(name))} {
+			t.Run(escaping+" "+name, func(t *testing.T) {
+				path := writeReadme(t, "", "run")
+				r := NewReadmeUpdater(path, "", false)
+
+				if err := r.UpdateReadme(svg); err != nil {
+					t.Fatal(err)
+				}
+				first := readReadme(t, path)
+
+				want := "\n" + escapeMarkers(svg) + "\n"
+				if got := block(t, first, r); got != want {
+					t.Errorf("block = %q, want %q", got, want)
+				}
+				if strings.Contains(block(t, first, r), markerPrefix) {
+					t.Errorf("block still holds a marker: %q", block(t, first, r))
+				}
+
+				// The other profile's block and the prose are untouched
+				run := NewReadmeUpdater(path, "run", false)
+				if got := block(t, first, run); got != "\nold heatmap\n" {
+					t.Errorf("run block = %q, want the old heatmap", got)
+				}
+				if !strings.HasPrefix(first, "# Hello\n\nSome prose before.\n\n") || !strings.HasSuffix(first, "\n\nSome prose after.\n") {
+					t.Errorf("prose around the blocks changed:\n%s", first)
+				}
+
+				// Updating again with the same heatmap leaves the README as it was
+				if err := r.UpdateReadme(svg); err != nil {
+					t.Fatal(err)
+				}
+				if second := readReadme(t, path); second != first {
+					t.Errorf("second update changed the README:\n%s\nwant:\n%s", second, first)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdateReadmeReplacesAdversarialBlock(t *testing.T) {
+	path := writeReadme(t, "")
+	r := NewReadmeUpdater(path, "", false)
+
+	// Every name in turn replaces the last, never leaving any of it behind
+	for _, name := range adversarialNames {
+		if err := r.UpdateReadme(activitySVG(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.UpdateReadme(activitySVG("Recovery")); err != nil {
+		t.Fatal(err)
+	}
+
+	readme := readReadme(t, path)
+	if got, want := block(t, readme, r), "\n"+activitySVG("Recovery")+"\n"; got != want {
+		t.Errorf("block = %q, want %q", got, want)
+	}
+	if n := strings.Count(readme, "<svg"); n != 1 {
+		t.Errorf("README holds %d heatmaps, want 1:\n%s", n, readme)
+	}
+}
+
+func TestUpdateReadmeAdversarialProfile(t *testing.T) {
+	// Both blocks get a heatmap holding the other's end marker
+	path := writeReadme(t, "", "run")
+	def := NewReadmeUpdater(path, "", false)
+	run := NewReadmeUpdater(path, "run", false)
+
+	for i := 0; i < 2; i++ {
+		if err := def.UpdateReadme(activitySVG("Intervals <!-- STRAVA-HEATMAP-END:run -->")); err != nil {
+			t.Fatal(err)
+		}
+		if err := run.UpdateReadme(activitySVG("Tempo <!-- STRAVA-HEATMAP-END -->")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readme := readReadme(t, path)
+	if got, want := block(t, readme, def), "\n"+escapeMarkers(activitySVG("Intervals <!-- STRAVA-HEATMAP-END:run -->"))+"\n"; got != want {
+		t.Errorf("default block = %q, want %q", got, want)
+	}
+	if got, want := block(t, readme, run), "\n"+escapeMarkers(activitySVG("Tempo <!-- STRAVA-HEATMAP-END -->"))+"\n"; got != want {
+		t.Errorf("run block = %q, want %q", got, want)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	path := writeReadme(t, "", "run")
+	content := readReadme(t, path)
+	content += "Run {{strava.total_distance_ytd}}, {{ strava.run.current_streak }} days, " +
+		"<!-- strava.activities -->12<!-- /strava.activities -->\n"
+	content = strings.Replace(content, "old heatmap", "{{strava.longest_streak}}", 1)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		// Placeholders inside a heatmap block don't count
+		{profile: "", want: []string{"total_distance_ytd", "activities"}},
+		{profile: "run", want: []string{"current_streak"}},
+		{profile: "ride", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run("profile "+tt.profile, func(t *testing.T) {
+			got, err := NewReadmeUpdater(path, tt.profile, false).Placeholders()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Placeholders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Repository describes the repository a heatmap is published to
+type Repository struct {
+	FullName      string // owner/name
+	DefaultBranch string
+	ReadmePath    string // README GitHub displays for the repository, empty if there is none
+}
+
+// apiBaseURL is the base URL of the GitHub REST API
+const apiBaseURL = "https://api.github.com"
+
+// apiClient is shared by requests to the GitHub API
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// DiscoverProfileRepository finds the profile repository of the user the
+// token belongs to: the repository named after their username, whose README
+// GitHub shows on their profile page
+func DiscoverProfileRepository(token string) (*Repository, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := apiGet(token, "/user", &user); err != nil {
+		return nil, fmt.Errorf("error getting authenticated user: %w", err)
+	}
+
+	return GetRepository(token, user.Login+"/"+user.Login)
+}
+
+// GetRepository looks up a repository's default branch and README path
+func GetRepository(token, fullName string) (*Repository, error) {
+	var repo struct {
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := apiGet(token, "/repos/"+fullName, &repo); err != nil {
+		return nil, fmt.Errorf("error getting repository %s: %w", fullName, err)
+	}
+
+	// A repository without a README answers 404, which leaves the path empty
+	var readme struct {
+		Path string `json:"path"`
+	}
+	if err := apiGet(token, "/repos/"+fullName+"/readme", &readme); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error getting README of %s: %w", fullName, err)
+	}
+
+	return &Repository{
+		FullName:      repo.FullName,
+		DefaultBranch: repo.DefaultBranch,
+		ReadmePath:    readme.Path,
+	}, nil
+}
+
+// apiError is a GitHub API response with an unexpected status
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("GitHub API returned status %d: %s", e.Status, e.Body)
+}
+
+// isNotFound reports whether err is a 404 response from the API
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.Status == http.StatusNotFound
+}
+
+// apiGet fetches an API path and decodes the JSON response into v
+func apiGet(token, path string, v interface{}) error {
+	req, err := newAPIRequest(http.MethodGet, path, token, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling GitHub API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &apiError{Status: resp.StatusCode, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing GitHub API response: %w", err)
+	}
+	return nil
+}
+
+// newAPIRequest creates an authenticated request to a GitHub API path
+func newAPIRequest(method, path, token string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, apiBaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GitHub API request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
@@ -0,0 +1,56 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/auth"
+)
+
+// DefaultSecretName is the Actions secret holding the Strava refresh token
+const DefaultSecretName = "STRAVA_REFRESH_TOKEN"
+
+// SecretTokenStore saves rotated refresh tokens as a GitHub Actions secret,
+// so the next scheduled run starts from the current token. Secret values are
+// encrypted with the repository's public key, which the gh CLI preinstalled
+// on GitHub-hosted runners takes care of.
+type SecretTokenStore struct {
+	Token string // Token allowed to write the repository's secrets, such as a PAT
+	Repo  string // owner/name
+	Name  string // Secret name, DefaultSecretName if empty
+}
+
+// NewSecretTokenStore creates a store writing the named secret of a repository
+func NewSecretTokenStore(token, repo, name string) *SecretTokenStore {
+	return &SecretTokenStore{
+		Token: token,
+		Repo:  repo,
+		Name:  name,
+	}
+}
+
+// Load returns nil, since secrets can't be read back through the API. The
+// workflow passes the current value in STRAVA_REFRESH_TOKEN instead.
+func (s *SecretTokenStore) Load() (*auth.Token, error) {
+	return nil, nil
+}
+
+// Save sets the secret to the refresh token
+func (s *SecretTokenStore) Save(token *auth.Token) error {
+	name := s.Name
+	if name == "" {
+		name = DefaultSecretName
+	}
+
+	// The value goes through stdin so it never shows in the process list
+	cmd := exec.Command("gh", "secret", "set", name, "--repo", s.Repo)
+	cmd.Env = append(os.Environ(), "GH_TOKEN="+s.Token)
+	cmd.Stdin = strings.NewReader(token.RefreshToken)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error setting secret %s in %s: %w: %s", name, s.Repo, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
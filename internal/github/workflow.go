@@ -0,0 +1,13 @@
+package github
+
+import _ "embed"
+
+// WorkflowPath is where -init writes the starter workflow, relative to the
+// repository root
+const WorkflowPath = ".github/workflows/strava-heatmap.yml"
+
+// StarterWorkflow is a workflow running the published action daily and on
+// demand, with the Strava credentials read from repository secrets
+//
+//go:embed workflow.yml
+var StarterWorkflow []byte
@@ -0,0 +1,220 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// exportIndex is the file of Strava's bulk export listing every activity
+const exportIndex = "activities.csv"
+
+// exportDateLayouts are the ways activities.csv writes start times, which
+// are in UTC
+var exportDateLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM",
+	"2 Jan 2006, 15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// exportColumns finds the values of a row of activities.csv by column name.
+// Some names appear twice, first in the athlete's units and again in meters
+// and seconds, so each name keeps every index it appears at.
+type exportColumns map[string][]int
+
+// first returns the first non-empty value of a column
+func (c exportColumns) first(row []string, name string) string {
+	for _, i := range c[name] {
+		if i < len(row) && strings.TrimSpace(row[i]) != "" {
+			return strings.TrimSpace(row[i])
+		}
+	}
+	return ""
+}
+
+// date returns the start time of a row, or false if it has none
+func (c exportColumns) date(row []string) (time.Time, bool) {
+	date := c.first(row, "Activity Date")
+	for _, layout := range exportDateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// distance returns the distance of a row in meters. Exports list it first
+// in kilometers and again in meters, and older ones only in kilometers.
+func (c exportColumns) distance(row []string) float64 {
+	if len(c["Distance"]) == 1 {
+		return c.number(row, "Distance") * 1000
+	}
+	return c.number(row, "Distance")
+}
+
+// number returns a numeric column, taking the last non-empty value so the
+// metric copy of a repeated column wins, or 0 if there is none
+func (c exportColumns) number(row []string, name string) float64 {
+	indices := c[name]
+	for i := len(indices) - 1; i >= 0; i-- {
+		if indices[i] >= len(row) || strings.TrimSpace(row[indices[i]]) == "" {
+			continue
+		}
+		value, _ := strconv.ParseFloat(strings.TrimSpace(row[indices[i]]), 64)
+		return value
+	}
+	return 0
+}
+
+// LoadExport reads the activities starting between start and end from a
+// Strava bulk export, either the ZIP archive or the directory it was
+// extracted to, ordered by start time. Each activity takes the name, type
+// and totals listed in activities.csv, which include edits made on Strava,
+// and its route, heart rate and power from the raw file alongside, if any.
+// Local start times are read in loc. Only the raw files of activities in
+// the range are read, and one that can't be read is skipped with a warning,
+// leaving its activity with the listed values.
+func LoadExport(path string, start, end time.Time, loc *time.Location) ([]strava.SummaryActivity, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening export: %w", err)
+	}
+
+	var export fs.FS
+	if info.IsDir() {
+		export = os.DirFS(path)
+	} else {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening export archive: %w", err)
+		}
+		defer archive.Close()
+		export = archive
+	}
+
+	index, err := export.Open(exportIndex)
+	if err != nil {
+		return nil, fmt.Errorf("export has no %s: %w", exportIndex, err)
+	}
+	defer index.Close()
+
+	reader := csv.NewReader(index)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", exportIndex, err)
+	}
+	columns := make(exportColumns)
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		columns[name] = append(columns[name], i)
+	}
+
+	var activities []strava.SummaryActivity
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", exportIndex, err)
+		}
+
+		// Rows out of range are skipped before their files are parsed
+		if t, ok := columns.date(row); ok && (t.Before(start) || t.After(end)) {
+			continue
+		}
+
+		activity := exportActivity(export, columns, row, loc)
+		if activity.StartDate.IsZero() || activity.StartDate.Before(start) || activity.StartDate.After(end) {
+			continue
+		}
+		activities = append(activities, activity)
+	}
+
+	sortByStart(activities)
+	return activities, nil
+}
+
+// exportActivity builds the activity of a row of activities.csv, read from
+// its raw file first so the row's values take precedence over the file's.
+// Manual entries have no file and keep just the row's values, as do
+// activities whose file can't be read.
+func exportActivity(export fs.FS, columns exportColumns, row []string, loc *time.Location) strava.SummaryActivity {
+	var activity strava.SummaryActivity
+	if filename := columns.first(row, "Filename"); filename != "" && Supported(filename) {
+		parsed, err := parseExportFile(export, filename, loc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping the file of activity %s: %v\n", columns.first(row, "Activity ID"), err)
+		} else if len(parsed) > 0 {
+			activity = parsed[0]
+		}
+	}
+
+	if id, err := strconv.ParseInt(columns.first(row, "Activity ID"), 10, 64); err == nil {
+		activity.ID = id
+	}
+	if name := columns.first(row, "Activity Name"); name != "" {
+		activity.Name = name
+	}
+	if kind := columns.first(row, "Activity Type"); kind != "" {
+		activity.Type = exportType(kind)
+	}
+	if t, ok := columns.date(row); ok {
+		activity.StartDate, activity.StartDateLocal = t, localTime(t, loc)
+	}
+	activity.Description = columns.first(row, "Activity Description")
+
+	// Totals the row leaves empty keep the values read from the file
+	setNumber := func(field *float64, name string) {
+		if value := columns.number(row, name); value != 0 {
+			*field = value
+		}
+	}
+	setSeconds := func(field *int, name string) {
+		if value := columns.number(row, name); value != 0 {
+			*field = int(value)
+		}
+	}
+	if distance := columns.distance(row); distance != 0 {
+		activity.Distance = distance
+	}
+	setSeconds(&activity.ElapsedTime, "Elapsed Time")
+	setSeconds(&activity.MovingTime, "Moving Time")
+	setNumber(&activity.TotalElevGain, "Elevation Gain")
+	setNumber(&activity.AverageHeartrate, "Average Heart Rate")
+	setNumber(&activity.MaxHeartrate, "Max Heart Rate")
+	setNumber(&activity.Calories, "Calories")
+	setNumber(&activity.AverageWatts, "Average Watts")
+	setNumber(&activity.WeightedAvgWatts, "Weighted Average Power")
+	setNumber(&activity.AverageCadence, "Average Cadence")
+	if activity.MovingTime == 0 {
+		activity.MovingTime = activity.ElapsedTime
+	}
+	return activity
+}
+
+// parseExportFile reads the activities in a raw file of the export
+func parseExportFile(export fs.FS, filename string, loc *time.Location) ([]strava.SummaryActivity, error) {
+	file, err := export.Open(strings.TrimPrefix(filename, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s from the export: %w", filename, err)
+	}
+	defer file.Close()
+
+	return parse(filename, file, loc)
+}
+
+// exportType turns the activity type shown in activities.csv, such as
+// "Weight Training" or "E-Bike Ride", into the Strava API's type
+func exportType(kind string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(kind)
+}
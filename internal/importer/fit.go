@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// fitEpoch is the Unix time of the FIT epoch, 1989-12-31 00:00 UTC, from
+// which FIT timestamps count seconds
+const fitEpoch = 631065600
+
+// Global numbers of the FIT messages read for activities
+const (
+	fitSport   = 12
+	fitSession = 18
+	fitRecord  = 20
+)
+
+// fitTimestamp is the field number of every message's timestamp
+const fitTimestamp = 253
+
+// fitSports and fitSubSports name the values of the FIT sport and sub_sport
+// enums that map to Strava activity types
+var (
+	fitSports = map[uint64]string{
+		1: "running", 2: "cycling", 4: "fitness_equipment", 5: "swimming",
+		10: "training", 11: "walking", 12: "cross_country_skiing", 13: "alpine_skiing",
+		14: "snowboarding", 15: "rowing", 17: "hiking",
+	}
+	fitSubSports = map[uint64]string{
+		1: "treadmill", 3: "trail_running", 6: "indoor_cycling", 8: "mountain",
+		17: "lap_swimming", 18: "open_water", 20: "strength_training", 28: "e_bike_fitness",
+		43: "yoga", 46: "gravel_cycling", 58: "virtual_activity",
+	}
+)
+
+// fitField is a field of a FIT message definition
+type fitField struct {
+	num, size, baseType byte
+}
+
+// fitDefinition describes the layout of the data messages of a local
+// message type
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitField
+	size      int // Bytes of a data message, including developer fields
+}
+
+// fitMessage holds the valid values of a data message's numeric fields by
+// field number, as raw unsigned integers
+type fitMessage map[byte]uint64
+
+// parseFIT reads a FIT activity file, returning a recording per session with
+// the records that fall within it. Files without sessions are read as one
+// recording of all their records. Only the first FIT file of a chained file
+// is read.
+func parseFIT(r io.Reader) ([]recording, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || int(data[0]) < 12 || len(data) < int(data[0]) || string(data[8:12]) != ".FIT" {
+		return nil, fmt.Errorf("not a FIT file")
+	}
+	start := int(data[0])
+	end := start + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end > len(data) {
+		return nil, fmt.Errorf("FIT file is truncated")
+	}
+
+	var sessions []fitMessage
+	var points []point
+	var sport fitMessage
+	definitions := make(map[byte]*fitDefinition)
+	var last uint64
+	for i := start; i < end; {
+		header := data[i]
+		i++
+
+		// Compressed timestamp headers carry the low five bits of the
+		// timestamp, which rolls over from the last full one
+		var local byte
+		compressed := false
+		switch {
+		case header&0x80 != 0:
+			local = (header >> 5) & 0x03
+			offset := uint64(header & 0x1f)
+			timestamp := last&^0x1f + offset
+			if offset < last&0x1f {
+				timestamp += 0x20
+			}
+			last, compressed = timestamp, true
+		case header&0x40 != 0:
+			definition, n, err := readFITDefinition(data[i:end], header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			definitions[header&0x0f] = definition
+			i += n
+			continue
+		default:
+			local = header & 0x0f
+		}
+
+		definition, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("FIT data message has no definition")
+		}
+		if i+definition.size > end {
+			return nil, fmt.Errorf("FIT file is truncated")
+		}
+		message := definition.decode(data[i : i+definition.size])
+		i += definition.size
+
+		if timestamp, ok := message[fitTimestamp]; ok {
+			last = timestamp
+		} else if compressed {
+			message[fitTimestamp] = last
+		}
+
+		switch definition.global {
+		case fitSession:
+			sessions = append(sessions, message)
+		case fitSport:
+			sport = message
+		case fitRecord:
+			points = append(points, fitPoint(message))
+		}
+	}
+
+	if len(sessions) == 0 {
+		return []recording{{Type: activityType(fitSubSports[sport[1]], fitSports[sport[0]]), Points: points}}, nil
+	}
+
+	recordings := make([]recording, len(sessions))
+	for i, session := range sessions {
+		rec := recording{
+			Type:       activityType(fitSubSports[session[6]], fitSports[session[5]]),
+			Start:      fitTime(session[2]),
+			Elapsed:    float64(session[7]) / 1000,
+			Moving:     float64(session[8]) / 1000,
+			Distance:   float64(session[9]) / 100,
+			Calories:   float64(session[11]),
+			AvgHR:      float64(session[16]),
+			MaxHR:      float64(session[17]),
+			AvgCadence: float64(session[18]),
+			AvgPower:   float64(session[20]),
+			Ascent:     float64(session[22]),
+		}
+
+		// A lone session takes every record, and sessions of a multisport
+		// activity take those recorded while they ran
+		for _, p := range points {
+			if len(sessions) == 1 || (!p.Time.Before(rec.Start) && p.Time.Sub(rec.Start).Seconds() <= rec.Elapsed) {
+				rec.Points = append(rec.Points, p)
+			}
+		}
+		recordings[i] = rec
+	}
+	return recordings, nil
+}
+
+// readFITDefinition reads a definition message, returning it and its length
+func readFITDefinition(data []byte, developer bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("FIT file is truncated")
+	}
+
+	definition := &fitDefinition{bigEndian: data[1] == 1}
+	if definition.bigEndian {
+		definition.global = binary.BigEndian.Uint16(data[2:4])
+	} else {
+		definition.global = binary.LittleEndian.Uint16(data[2:4])
+	}
+
+	n := 5
+	count := int(data[4])
+	if len(data) < n+3*count {
+		return nil, 0, fmt.Errorf("FIT file is truncated")
+	}
+	for f := 0; f < count; f++ {
+		field := fitField{num: data[n], size: data[n+1], baseType: data[n+2]}
+		definition.fields = append(definition.fields, field)
+		definition.size += int(field.size)
+		n += 3
+	}
+
+	// Developer fields are skipped, so only their sizes are kept
+	if developer {
+		if len(data) < n+1 || len(data) < n+1+3*int(data[n]) {
+			return nil, 0, fmt.Errorf("FIT file is truncated")
+		}
+		count := int(data[n])
+		n++
+		for f := 0; f < count; f++ {
+			definition.size += int(data[n+1])
+			n += 3
+		}
+	}
+
+	return definition, n, nil
+}
+
+// decode reads the numeric fields of a data message, leaving out strings,
+// arrays and fields holding their type's invalid value
+func (d *fitDefinition) decode(data []byte) fitMessage {
+	message := make(fitMessage)
+	offset := 0
+	for _, field := range d.fields {
+		raw := data[offset : offset+int(field.size)]
+		offset += int(field.size)
+
+		var value uint64
+		switch {
+		case field.size == 1:
+			value = uint64(raw[0])
+		case field.size == 2 && d.bigEndian:
+			value = uint64(binary.BigEndian.Uint16(raw))
+		case field.size == 2:
+			value = uint64(binary.LittleEndian.Uint16(raw))
+		case field.size == 4 && d.bigEndian:
+			value = uint64(binary.BigEndian.Uint32(raw))
+		case field.size == 4:
+			value = uint64(binary.LittleEndian.Uint32(raw))
+		default:
+			continue
+		}
+
+		if fitValid(field.baseType, value, int(field.size)) {
+			message[field.num] = value
+		}
+	}
+	return message
+}
+
+// fitValid reports whether a value isn't the invalid value of its base type,
+// which devices write for fields they have no data for
+func fitValid(baseType byte, value uint64, size int) bool {
+	switch baseType & 0x1f {
+	case 0x07, 0x08, 0x09: // Strings and floats
+		return false
+	case 0x0a, 0x0b, 0x0c: // Unsigned integers where zero is invalid
+		return value != 0
+	case 0x01, 0x03, 0x05: // Signed integers
+		return value != uint64(1)<<(8*size-1)-1
+	default:
+		return value != uint64(1)<<(8*size)-1
+	}
+}
+
+// fitPoint reads a record message as a point
+func fitPoint(message fitMessage) point {
+	p := point{
+		Time:      fitTime(message[fitTimestamp]),
+		HeartRate: float64(message[3]),
+		Cadence:   float64(message[4]),
+		Power:     float64(message[7]),
+	}
+
+	// Positions are in semicircles, 2^31 to 180 degrees
+	lat, hasLat := message[0]
+	lng, hasLng := message[1]
+	if hasLat && hasLng {
+		p.Lat = float64(int32(uint32(lat))) * 180 / (1 << 31)
+		p.Lng = float64(int32(uint32(lng))) * 180 / (1 << 31)
+		p.HasPosition = true
+	}
+
+	// Altitude is scaled by 5 and offset by 500 m, preferring the enhanced
+	// field with the wider range
+	if altitude, ok := message[78]; ok {
+		p.Altitude, p.HasAltitude = float64(altitude)/5-500, true
+	} else if altitude, ok := message[2]; ok {
+		p.Altitude, p.HasAltitude = float64(altitude)/5-500, true
+	}
+	return p
+}
+
+// fitTime converts a FIT timestamp to a time, or the zero time for none
+func fitTime(timestamp uint64) time.Time {
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(timestamp)+fitEpoch, 0).UTC()
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// gpxFile is the part of a GPX document read for activities. Elements match
+// regardless of namespace, which covers the heart rate, cadence and power
+// extensions written by Garmin and Strava.
+type gpxFile struct {
+	Metadata struct {
+		Time time.Time `xml:"time"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat        float64   `xml:"lat,attr"`
+				Lon        float64   `xml:"lon,attr"`
+				Elevation  *float64  `xml:"ele"`
+				Time       time.Time `xml:"time"`
+				Extensions struct {
+					HeartRate float64 `xml:"TrackPointExtension>hr"`
+					Cadence   float64 `xml:"TrackPointExtension>cad"`
+					Power     float64 `xml:"power"`
+				} `xml:"extensions"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// parseGPX reads each track of a GPX file as a recording, started at its
+// first point or, failing that, the file's metadata time
+func parseGPX(r io.Reader) ([]recording, error) {
+	var file gpxFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	var recordings []recording
+	for _, track := range file.Tracks {
+		rec := recording{Name: track.Name, Type: activityType(track.Type), Start: file.Metadata.Time}
+		for _, segment := range track.Segments {
+			for _, p := range segment.Points {
+				pt := point{
+					Time:        p.Time,
+					Lat:         p.Lat,
+					Lng:         p.Lon,
+					HasPosition: true,
+					HeartRate:   p.Extensions.HeartRate,
+					Cadence:     p.Extensions.Cadence,
+					Power:       p.Extensions.Power,
+				}
+				if p.Elevation != nil {
+					pt.Altitude, pt.HasAltitude = *p.Elevation, true
+				}
+				rec.Points = append(rec.Points, pt)
+			}
+		}
+		if len(rec.Points) > 0 && !rec.Points[0].Time.IsZero() {
+			rec.Start = rec.Points[0].Time
+		}
+		recordings = append(recordings, rec)
+	}
+	return recordings, nil
+}
@@ -0,0 +1,143 @@
+// Package importer reads activities from exported GPX, TCX and FIT files and
+// from Strava's bulk export, so heatmaps can be generated offline without
+// Strava API access
+package importer
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// parsers read the recordings in a file of each supported format, keyed by
+// extension
+var parsers = map[string]func(io.Reader) ([]recording, error){
+	".gpx": parseGPX,
+	".tcx": parseTCX,
+	".fit": parseFIT,
+}
+
+// Supported reports whether a file is in a supported format, including the
+// gzipped copies found in Strava's bulk export
+func Supported(path string) bool {
+	_, ok := parsers[formatExtension(path)]
+	return ok
+}
+
+// formatExtension returns the lowercased extension of a file's format,
+// looking past a .gz extension
+func formatExtension(path string) string {
+	path = strings.ToLower(path)
+	return filepath.Ext(strings.TrimSuffix(path, ".gz"))
+}
+
+// LoadDir reads every supported file under dir, recursively, and returns the
+// activities starting between start and end, ordered by start time, with
+// their local start times in loc. The same activity exported in more than
+// one file, recognized by its start time, is only returned once.
+func LoadDir(dir string, start, end time.Time, loc *time.Location) ([]strava.SummaryActivity, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && Supported(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading activity directory: %w", err)
+	}
+	sort.Strings(paths)
+
+	var activities []strava.SummaryActivity
+	seen := make(map[int64]bool)
+	for _, path := range paths {
+		parsed, err := ParseFile(path, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, activity := range parsed {
+			if activity.StartDate.Before(start) || activity.StartDate.After(end) || seen[activity.ID] {
+				continue
+			}
+			seen[activity.ID] = true
+			activities = append(activities, activity)
+		}
+	}
+
+	sortByStart(activities)
+	return activities, nil
+}
+
+// sortByStart orders activities by start time
+func sortByStart(activities []strava.SummaryActivity) {
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartDate.Before(activities[j].StartDate)
+	})
+}
+
+// ParseFile reads the activities in a GPX, TCX or FIT file, which may be
+// gzipped. Activities are named after the file when the file has no name
+// for them, recordings without a start time are skipped, and local start
+// times are read in loc.
+func ParseFile(path string, loc *time.Location) ([]strava.SummaryActivity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parse(path, file, loc)
+}
+
+// parse reads the activities in a file read from r, whose format and default
+// activity name come from its path, with local start times in loc
+func parse(path string, r io.Reader, loc *time.Location) ([]strava.SummaryActivity, error) {
+	parseFormat, ok := parsers[formatExtension(path)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported activity file: %s", path)
+	}
+
+	name := filepath.Base(path)
+	gzipped := strings.HasSuffix(strings.ToLower(name), ".gz")
+	if gzipped {
+		name = name[:len(name)-len(".gz")]
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	recordings, err := parseFormat(r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	var activities []strava.SummaryActivity
+	for _, rec := range recordings {
+		if rec.Name == "" {
+			rec.Name = name
+		}
+		if activity, ok := rec.summarize(loc); ok {
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixtureDir holds a small GPX, TCX and FIT activity, each starting at
+// 02:30 UTC, which is the evening before in New York
+var fixtureDir = filepath.Join("..", "..", "testdata", "activities")
+
+func TestParseFile(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file         string
+		name, typ    string
+		start, local time.Time
+		distance     float64 // Meters, to the nearest
+		elapsed      int
+		avgHR, maxHR float64
+	}{
+		{
+			file: "evening-run.gpx", name: "Evening Run", typ: "Run",
+			start:    time.Date(2024, time.June, 1, 2, 30, 0, 0, time.UTC),
+			local:    time.Date(2024, time.May, 31, 22, 30, 0, 0, time.UTC),
+			distance: 109, elapsed: 20, avgHR: 145, maxHR: 150,
+		},
+		{
+			file: "evening-ride.tcx", name: "Evening Ride", typ: "Ride",
+			start:    time.Date(2024, time.June, 2, 2, 30, 0, 0, time.UTC),
+			local:    time.Date(2024, time.June, 1, 22, 30, 0, 0, time.UTC),
+			distance: 4000, elapsed: 600, avgHR: 130, maxHR: 155,
+		},
+		{
+			file: "tempo-run.fit", name: "tempo-run", typ: "Run",
+			start:    time.Date(2024, time.June, 3, 2, 30, 0, 0, time.UTC),
+			local:    time.Date(2024, time.June, 2, 22, 30, 0, 0, time.UTC),
+			distance: 5000, elapsed: 1800, avgHR: 145, maxHR: 170,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			activities, err := ParseFile(filepath.Join(fixtureDir, tt.file), newYork)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			if len(activities) != 1 {
+				t.Fatalf("ParseFile() returned %d activities, want 1", len(activities))
+			}
+
+			a := activities[0]
+			if a.Name != tt.name || a.Type != tt.typ {
+				t.Errorf("name, type = %q, %q, want %q, %q", a.Name, a.Type, tt.name, tt.typ)
+			}
+			if !a.StartDate.Equal(tt.start) || a.ID != tt.start.Unix() {
+				t.Errorf("StartDate, ID = %v, %d, want %v, %d", a.StartDate, a.ID, tt.start, tt.start.Unix())
+			}
+			if !a.StartDateLocal.Equal(tt.local) {
+				t.Errorf("StartDateLocal = %v, want %v", a.StartDateLocal, tt.local)
+			}
+			if math.Round(a.Distance) != tt.distance {
+				t.Errorf("Distance = %.1f, want %.0f", a.Distance, tt.distance)
+			}
+			if a.ElapsedTime != tt.elapsed {
+				t.Errorf("ElapsedTime = %d, want %d", a.ElapsedTime, tt.elapsed)
+			}
+			if a.AverageHeartrate != tt.avgHR || a.MaxHeartrate != tt.maxHR {
+				t.Errorf("heart rate = %.0f/%.0f, want %.0f/%.0f", a.AverageHeartrate, a.MaxHeartrate, tt.avgHR, tt.maxHR)
+			}
+			if a.Map.SummaryPolyline == "" {
+				t.Error("SummaryPolyline is empty, want the track")
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	start := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+	activities, err := LoadDir(fixtureDir, start, end, time.UTC)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	// The run on June 1 starts before the range
+	var types []string
+	for _, a := range activities {
+		types = append(types, a.Type)
+		if !a.StartDateLocal.Equal(a.StartDate) {
+			t.Errorf("StartDateLocal = %v, want %v in UTC", a.StartDateLocal, a.StartDate)
+		}
+	}
+	if len(types) != 2 || types[0] != "Ride" || types[1] != "Run" {
+		t.Errorf("LoadDir() types = %v, want [Ride Run]", types)
+	}
+}
+
+func TestLoadExport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "activities"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gpx, err := os.ReadFile(filepath.Join(fixtureDir, "evening-run.gpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"activities/1.gpx": string(gpx),
+		"activities/2.fit": "corrupt",
+		"activities/3.fit": "corrupt",
+		exportIndex: "Activity ID,Activity Date,Activity Name,Activity Type,Distance,Elapsed Time,Filename\n" +
+			"1,\"Jun 1, 2024, 2:30:00 AM\",Edited Run,Run,5.5,1800,activities/1.gpx\n" +
+			"2,\"Jun 2, 2024, 2:30:00 AM\",Broken Ride,Ride,20.0,3600,activities/2.fit\n" +
+			"3,\"Jan 1, 2023, 2:30:00 AM\",Old Ride,Ride,10.0,1800,activities/3.fit\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+	activities, err := LoadExport(dir, start, end, time.UTC)
+	if err != nil {
+		t.Fatalf("LoadExport() error = %v", err)
+	}
+	if len(activities) != 2 {
+		t.Fatalf("LoadExport() returned %d activities, want 2", len(activities))
+	}
+
+	// The listing's name and kilometers win over the file's, whose heart
+	// rate and route are kept
+	run := activities[0]
+	if run.ID != 1 || run.Name != "Edited Run" || run.Distance != 5500 {
+		t.Errorf("run = %d %q %.0f m, want 1 \"Edited Run\" 5500 m", run.ID, run.Name, run.Distance)
+	}
+	if run.MaxHeartrate != 150 || run.Map.SummaryPolyline == "" {
+		t.Errorf("run heart rate, route = %.0f, %q, want 150 and the track", run.MaxHeartrate, run.Map.SummaryPolyline)
+	}
+
+	// A file that can't be parsed leaves the listed values
+	ride := activities[1]
+	if ride.ID != 2 || ride.Type != "Ride" || ride.Distance != 20000 || ride.ElapsedTime != 3600 {
+		t.Errorf("ride = %d %s %.0f m %d s, want 2 Ride 20000 m 3600 s", ride.ID, ride.Type, ride.Distance, ride.ElapsedTime)
+	}
+}
@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+const (
+	// movingSpeed is the slowest speed, in meters per second, at which time
+	// between two positions counts toward the moving time
+	movingSpeed = 0.5
+
+	// pauseGap is the longest time between two samples that counts toward
+	// the moving time, so a paused recording doesn't
+	pauseGap = 30 * time.Second
+
+	// earthRadius is the mean radius of the Earth in meters
+	earthRadius = 6371000.0
+)
+
+// sportTypes maps the sport names used by the file formats, lowercased, to
+// Strava activity types
+var sportTypes = map[string]string{
+	"run":                  "Run",
+	"running":              "Run",
+	"trail_running":        "TrailRun",
+	"treadmill":            "Run",
+	"ride":                 "Ride",
+	"biking":               "Ride",
+	"cycling":              "Ride",
+	"mountain":             "MountainBikeRide",
+	"mountain_biking":      "MountainBikeRide",
+	"gravel_cycling":       "GravelRide",
+	"virtual_activity":     "VirtualRide",
+	"indoor_cycling":       "Ride",
+	"e_bike_fitness":       "EBikeRide",
+	"swim":                 "Swim",
+	"swimming":             "Swim",
+	"lap_swimming":         "Swim",
+	"open_water":           "Swim",
+	"walk":                 "Walk",
+	"walking":              "Walk",
+	"hike":                 "Hike",
+	"hiking":               "Hike",
+	"rowing":               "Rowing",
+	"alpine_skiing":        "AlpineSki",
+	"cross_country_skiing": "NordicSki",
+	"snowboarding":         "Snowboard",
+	"yoga":                 "Yoga",
+	"strength_training":    "WeightTraining",
+	"training":             "Workout",
+	"fitness_equipment":    "Workout",
+}
+
+// activityType returns the Strava activity type of a sport name, trying a
+// more specific sub-sport first, or Workout for sports it doesn't know
+func activityType(sports ...string) string {
+	for _, sport := range sports {
+		if t, ok := sportTypes[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(sport), " ", "_"))]; ok {
+			return t
+		}
+	}
+	return "Workout"
+}
+
+// point is a sample along a recording. Values a device didn't record are 0.
+type point struct {
+	Time        time.Time
+	Lat, Lng    float64
+	HasPosition bool
+	Altitude    float64
+	HasAltitude bool
+	HeartRate   float64 // In bpm
+	Cadence     float64 // In rpm or steps per minute, as recorded
+	Power       float64 // In watts
+}
+
+// recording is an activity read from a file. Totals the file carries are
+// kept as recorded, and the rest are computed from the points.
+type recording struct {
+	Name   string
+	Type   string // Strava activity type, Workout when unknown
+	Start  time.Time
+	Points []point
+
+	Elapsed    float64 // In seconds
+	Moving     float64 // In seconds
+	Distance   float64 // In meters
+	Ascent     float64 // In meters
+	AvgHR      float64
+	MaxHR      float64
+	Calories   float64
+	AvgPower   float64
+	AvgCadence float64
+}
+
+// summarize turns a recording into the activity Strava would report for it,
+// or reports false if it has no start time. The ID is the start time in Unix
+// seconds, which stays the same across re-exports of the activity, and the
+// local start time is read in loc, as files only carry UTC times.
+func (r recording) summarize(loc *time.Location) (strava.SummaryActivity, bool) {
+	start := r.Start
+	if start.IsZero() && len(r.Points) > 0 {
+		start = r.Points[0].Time
+	}
+	if start.IsZero() {
+		return strava.SummaryActivity{}, false
+	}
+	start = start.UTC()
+
+	var positions [][]float64
+	var altitudes []float64
+	var distance, moving float64
+	var heartRate, cadence, power averager
+	maxHR := 0.0
+	for i, p := range r.Points {
+		if p.HasPosition {
+			positions = append(positions, []float64{p.Lat, p.Lng})
+		}
+		if p.HasAltitude {
+			altitudes = append(altitudes, p.Altitude)
+		}
+		heartRate.add(p.HeartRate)
+		cadence.add(p.Cadence)
+		power.add(p.Power)
+		maxHR = math.Max(maxHR, p.HeartRate)
+
+		if i == 0 {
+			continue
+		}
+		prev := r.Points[i-1]
+		gap := p.Time.Sub(prev.Time)
+		step := 0.0
+		if p.HasPosition && prev.HasPosition {
+			step = haversine(prev.Lat, prev.Lng, p.Lat, p.Lng)
+			distance += step
+		}
+		if gap > 0 && gap <= pauseGap && (!p.HasPosition || !prev.HasPosition || step/gap.Seconds() >= movingSpeed) {
+			moving += gap.Seconds()
+		}
+	}
+
+	elapsed := r.Elapsed
+	if elapsed == 0 && len(r.Points) > 1 {
+		elapsed = r.Points[len(r.Points)-1].Time.Sub(start).Seconds()
+	}
+
+	activity := strava.SummaryActivity{
+		ID:               start.Unix(),
+		Name:             r.Name,
+		Type:             r.Type,
+		StartDate:        start,
+		StartDateLocal:   localTime(start, loc),
+		Distance:         firstNonZero(r.Distance, distance),
+		MovingTime:       int(math.Round(firstNonZero(r.Moving, moving, elapsed))),
+		ElapsedTime:      int(math.Round(elapsed)),
+		TotalElevGain:    firstNonZero(r.Ascent, processor.ElevationGain(altitudes)),
+		AverageHeartrate: firstNonZero(r.AvgHR, heartRate.mean()),
+		MaxHeartrate:     firstNonZero(r.MaxHR, maxHR),
+		Calories:         r.Calories,
+		AverageWatts:     firstNonZero(r.AvgPower, power.mean()),
+		DeviceWatts:      r.AvgPower > 0 || power.count > 0,
+		AverageCadence:   firstNonZero(r.AvgCadence, cadence.mean()),
+	}
+	if len(positions) > 0 {
+		activity.StartLatlng = positions[0]
+		activity.EndLatlng = positions[len(positions)-1]
+		activity.Map.SummaryPolyline = processor.EncodePolyline(positions)
+	}
+	return activity, true
+}
+
+// averager keeps the mean of the non-zero values added to it
+type averager struct {
+	sum   float64
+	count int
+}
+
+func (a *averager) add(value float64) {
+	if value > 0 {
+		a.sum += value
+		a.count++
+	}
+}
+
+func (a *averager) mean() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.sum / float64(a.count)
+}
+
+// localTime returns the wall clock time of t in loc written as UTC, the way
+// Strava gives start_date_local
+func localTime(t time.Time, loc *time.Location) time.Time {
+	wall := t.In(loc)
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+}
+
+// firstNonZero returns the first of the values that isn't zero, or zero
+func firstNonZero(values ...float64) float64 {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+	return 0
+}
+
+// haversine returns the distance between two coordinates in meters
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"time"
+)
+
+// tcxFile is the part of a Training Center XML document read for activities
+type tcxFile struct {
+	Activities []struct {
+		Sport string    `xml:"Sport,attr"`
+		ID    time.Time `xml:"Id"`
+		Notes string    `xml:"Notes"`
+		Laps  []struct {
+			StartTime    time.Time  `xml:"StartTime,attr"`
+			TotalTime    float64    `xml:"TotalTimeSeconds"`
+			Distance     float64    `xml:"DistanceMeters"`
+			Calories     float64    `xml:"Calories"`
+			AvgHeartRate float64    `xml:"AverageHeartRateBpm>Value"`
+			MaxHeartRate float64    `xml:"MaximumHeartRateBpm>Value"`
+			TrackPoints  []tcxPoint `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// tcxPoint is a trackpoint of a TCX lap, with run cadence and power read
+// from Garmin's activity extension
+type tcxPoint struct {
+	Time     time.Time `xml:"Time"`
+	Position *struct {
+		Lat float64 `xml:"LatitudeDegrees"`
+		Lng float64 `xml:"LongitudeDegrees"`
+	} `xml:"Position"`
+	Altitude   *float64 `xml:"AltitudeMeters"`
+	HeartRate  float64  `xml:"HeartRateBpm>Value"`
+	Cadence    float64  `xml:"Cadence"`
+	Extensions struct {
+		RunCadence float64 `xml:"TPX>RunCadence"`
+		Watts      float64 `xml:"TPX>Watts"`
+	} `xml:"Extensions"`
+}
+
+// parseTCX reads each activity of a TCX file as a recording. Lap totals add
+// up to the activity's, with heart rate averaged over the laps by duration.
+func parseTCX(r io.Reader) ([]recording, error) {
+	var file tcxFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	var recordings []recording
+	for _, activity := range file.Activities {
+		rec := recording{Name: activity.Notes, Type: activityType(activity.Sport), Start: activity.ID}
+
+		var heartRateTime float64
+		for _, lap := range activity.Laps {
+			if rec.Start.IsZero() {
+				rec.Start = lap.StartTime
+			}
+			rec.Moving += lap.TotalTime
+			rec.Distance += lap.Distance
+			rec.Calories += lap.Calories
+			rec.MaxHR = math.Max(rec.MaxHR, lap.MaxHeartRate)
+			if lap.AvgHeartRate > 0 {
+				rec.AvgHR += lap.AvgHeartRate * lap.TotalTime
+				heartRateTime += lap.TotalTime
+			}
+
+			for _, p := range lap.TrackPoints {
+				pt := point{
+					Time:      p.Time,
+					HeartRate: p.HeartRate,
+					Cadence:   firstNonZero(p.Cadence, p.Extensions.RunCadence),
+					Power:     p.Extensions.Watts,
+				}
+				if p.Position != nil {
+					pt.Lat, pt.Lng, pt.HasPosition = p.Position.Lat, p.Position.Lng, true
+				}
+				if p.Altitude != nil {
+					pt.Altitude, pt.HasAltitude = *p.Altitude, true
+				}
+				rec.Points = append(rec.Points, pt)
+			}
+		}
+		if heartRateTime > 0 {
+			rec.AvgHR /= heartRateTime
+		}
+
+		recordings = append(recordings, rec)
+	}
+	return recordings, nil
+}
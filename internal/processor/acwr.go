@@ -0,0 +1,33 @@
+package processor
+
+import "time"
+
+// chronicWeeks is the number of weeks, including the current one, averaged
+// into the chronic workload
+const chronicWeeks = 4
+
+// RampWarning is a week whose acute:chronic workload ratio exceeds the
+// configured threshold
+type RampWarning struct {
+	WeekStart time.Time
+	Ratio     float64
+}
+
+// ACWR returns the acute:chronic workload ratio of each week: its load
+// divided by the average weekly load of the four weeks ending with it.
+// Weeks with less than four weeks of history, or no chronic load, get 0.
+func ACWR(loads []float64) []float64 {
+	ratios := make([]float64, len(loads))
+	for i := chronicWeeks - 1; i < len(loads); i++ {
+		chronic := 0.0
+		for _, load := range loads[i-chronicWeeks+1 : i+1] {
+			chronic += load
+		}
+		chronic /= chronicWeeks
+
+		if chronic > 0 {
+			ratios[i] = loads[i] / chronic
+		}
+	}
+	return ratios
+}
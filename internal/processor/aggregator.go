@@ -1,7 +1,9 @@
 package processor
 
 import (
+	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/samuellee/StravaGraph/internal/strava"
@@ -11,20 +13,30 @@ import (
 type ActivityAggregator struct {
 	Activities []strava.SummaryActivity
 	TimeZone   *time.Location
+	FTP        float64                          // Functional threshold power in watts, 0 if unknown
+	Tagger     *Tagger                          // Config-defined activity tags, nil for none
+	Fallback   bool                             // Credit distance-less activities with distance from their duration
+	TimeBasis  string                           // "elapsed" to total elapsed rather than moving time
 	DailyData  map[string]*strava.DailyActivity // key: YYYY-MM-DD
 }
 
 // NewActivityAggregator creates a new activity aggregator
-func NewActivityAggregator(activities []strava.SummaryActivity, location *time.Location) *ActivityAggregator {
+func NewActivityAggregator(activities []strava.SummaryActivity, location *time.Location, ftp float64) *ActivityAggregator {
 	return &ActivityAggregator{
 		Activities: activities,
 		TimeZone:   location,
+		FTP:        ftp,
 		DailyData:  make(map[string]*strava.DailyActivity),
 	}
 }
 
 // Aggregate processes activities and aggregates them by day
 func (a *ActivityAggregator) Aggregate() map[string]*strava.DailyActivity {
+	var fallbackSpeed float64
+	if a.Fallback {
+		fallbackSpeed = averageSpeed(a.Activities)
+	}
+
 	for _, activity := range a.Activities {
 		// Convert to the configured timezone
 		localDate := activity.StartDate.In(a.TimeZone)
@@ -36,6 +48,7 @@ func (a *ActivityAggregator) Aggregate() map[string]*strava.DailyActivity {
 			dailyActivity = &strava.DailyActivity{
 				Date:       localDate,
 				Types:      make(map[string]int),
+				Workouts:   make(map[string]int),
 				Activities: []int64{},
 			}
 			a.DailyData[dateKey] = dailyActivity
@@ -44,18 +57,39 @@ func (a *ActivityAggregator) Aggregate() map[string]*strava.DailyActivity {
 		// Update counts and totals
 		dailyActivity.Count++
 		dailyActivity.TotalDistance += activity.Distance
-		dailyActivity.TotalDuration += activity.MovingTime
+		if activity.Distance == 0 {
+			dailyActivity.EquivalentDistance += float64(activity.MovingTime) * fallbackSpeed
+		}
+		dailyActivity.TotalDuration += a.duration(activity)
 		dailyActivity.TotalElevation += activity.TotalElevGain
+		dailyActivity.TotalKilojoules += activityWork(activity)
+		dailyActivity.TotalCalories += activityCalories(activity)
 		dailyActivity.Activities = append(dailyActivity.Activities, activity.ID)
 
-		// Record activity type
+		// Record activity type, workout kind and tags
 		dailyActivity.Types[activity.Type]++
+		dailyActivity.Workouts[WorkoutKind(activity)]++
+		for _, tag := range a.Tagger.Tags(activity) {
+			if dailyActivity.Tags == nil {
+				dailyActivity.Tags = make(map[string]int)
+			}
+			dailyActivity.Tags[tag]++
+		}
 
 		// Update PR status
 		if activity.PRCount > 0 {
 			dailyActivity.HasPR = true
 		}
 
+		// Count activities started in the dark
+		if dark, beforeDawn := DarkStart(activity); dark {
+			if beforeDawn {
+				dailyActivity.PreDawnCount++
+			} else {
+				dailyActivity.AfterDarkCount++
+			}
+		}
+
 		// Update heart rate if available
 		if activity.AverageHeartrate > 0 {
 			// If this is the first activity with heart rate data
@@ -71,6 +105,43 @@ func (a *ActivityAggregator) Aggregate() map[string]*strava.DailyActivity {
 		if activity.MaxHeartrate > dailyActivity.MaxHeartRate {
 			dailyActivity.MaxHeartRate = activity.MaxHeartrate
 		}
+
+		// Update power if available, weighting each activity by its moving time
+		if activity.AverageWatts > 0 && activity.MovingTime > 0 {
+			prev := float64(dailyActivity.PowerDuration)
+			t := float64(activity.MovingTime)
+
+			dailyActivity.AvgPower = (dailyActivity.AvgPower*prev + activity.AverageWatts*t) / (prev + t)
+
+			// Normalized power is a fourth-power mean, so combine it as one
+			weighted := activity.WeightedAvgWatts
+			if weighted == 0 {
+				weighted = activity.AverageWatts
+			}
+			np4 := math.Pow(dailyActivity.NormalizedPower, 4)
+			dailyActivity.NormalizedPower = math.Pow((np4*prev+math.Pow(weighted, 4)*t)/(prev+t), 0.25)
+
+			dailyActivity.PowerDuration += activity.MovingTime
+
+			// Training stress relative to FTP
+			dailyActivity.TrainingStress += activityTrainingStress(weighted, activity.MovingTime, a.FTP)
+		}
+
+		// Update cadence if available, weighting each activity by its moving time
+		if activity.AverageCadence > 0 && activity.MovingTime > 0 {
+			prev := float64(dailyActivity.CadenceDuration)
+			t := float64(activity.MovingTime)
+
+			dailyActivity.AvgCadence = (dailyActivity.AvgCadence*prev + activity.AverageCadence*t) / (prev + t)
+			dailyActivity.CadenceDuration += activity.MovingTime
+		}
+	}
+
+	// Daily intensity factor from the combined normalized power
+	if a.FTP > 0 {
+		for _, dailyActivity := range a.DailyData {
+			dailyActivity.IntensityFactor = dailyActivity.NormalizedPower / a.FTP
+		}
 	}
 
 	return a.DailyData
@@ -80,9 +151,12 @@ func (a *ActivityAggregator) Aggregate() map[string]*strava.DailyActivity {
 func (a *ActivityAggregator) GetOrderedDates(startDate, endDate time.Time) []*strava.DailyActivity {
 	var result []*strava.DailyActivity
 
-	// Fill in all dates in the range for continuity
-	current := startDate
-	for !current.After(endDate) {
+	// Fill in all dates in the range for continuity, stepping over civil
+	// dates so DST transitions never skip or repeat a day
+	first := CivilDate(startDate)
+	totalDays := DaysBetween(startDate, endDate)
+	for i := 0; i <= totalDays; i++ {
+		current := first.AddDate(0, 0, i)
 		dateKey := current.Format("2006-01-02")
 
 		// Check if we have data for this date
@@ -96,7 +170,6 @@ func (a *ActivityAggregator) GetOrderedDates(startDate, endDate time.Time) []*st
 		}
 
 		result = append(result, dailyActivity)
-		current = current.AddDate(0, 0, 1) // Next day
 	}
 
 	// Sort by date
@@ -120,25 +193,7 @@ func (a *ActivityAggregator) CalculateIntensity(metricType string, day *strava.D
 			continue
 		}
 
-		var value float64
-		switch metricType {
-		case "distance":
-			value = data.TotalDistance
-		case "duration":
-			value = float64(data.TotalDuration)
-		case "elevation":
-			value = data.TotalElevation
-		case "heart_rate":
-			value = data.AvgHeartRate
-		case "effort":
-			// Simple effort formula: distance * elevation gain / duration
-			// This rewards activities with higher distance, more elevation, but shorter time
-			if data.TotalDuration > 0 {
-				value = (data.TotalDistance * (1 + data.TotalElevation/100)) / float64(data.TotalDuration)
-			}
-		default:
-			value = float64(data.Count) // Default to count-based intensity
-		}
+		value := MetricValue(data, metricType)
 
 		if value > 0 {
 			values = append(values, value)
@@ -154,23 +209,7 @@ func (a *ActivityAggregator) CalculateIntensity(metricType string, day *strava.D
 	sort.Float64s(values)
 
 	// Get the value for this day
-	var dayValue float64
-	switch metricType {
-	case "distance":
-		dayValue = day.TotalDistance
-	case "duration":
-		dayValue = float64(day.TotalDuration)
-	case "elevation":
-		dayValue = day.TotalElevation
-	case "heart_rate":
-		dayValue = day.AvgHeartRate
-	case "effort":
-		if day.TotalDuration > 0 {
-			dayValue = (day.TotalDistance * (1 + day.TotalElevation/100)) / float64(day.TotalDuration)
-		}
-	default:
-		dayValue = float64(day.Count)
-	}
+	dayValue := MetricValue(day, metricType)
 
 	// Determine which percentile the day falls into
 	percentile := getPercentileRank(values, dayValue)
@@ -199,3 +238,101 @@ func getPercentileRank(sortedValues []float64, value float64) float64 {
 	// Calculate percentile rank
 	return float64(pos) / float64(len(sortedValues))
 }
+
+// InferTimeZone returns the IANA timezone most activities were recorded in,
+// or an empty string if none of them carry a usable timezone
+func InferTimeZone(activities []strava.SummaryActivity) string {
+	counts := make(map[string]int)
+	for _, activity := range activities {
+		// Strava reports timezones as "(GMT-08:00) America/Los_Angeles"
+		name := activity.Timezone
+		if idx := strings.LastIndex(name, " "); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if name == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(name); err != nil {
+			continue
+		}
+		counts[name]++
+	}
+
+	// Pick the most common zone, breaking ties alphabetically for stable output
+	best := ""
+	for name, count := range counts {
+		if count > counts[best] || (count == counts[best] && name < best) {
+			best = name
+		}
+	}
+
+	return best
+}
+
+// FirstActivityDate returns the start of the earliest activity, or the zero
+// time if there are none
+func FirstActivityDate(activities []strava.SummaryActivity) time.Time {
+	var first time.Time
+	for _, activity := range activities {
+		if first.IsZero() || activity.StartDate.Before(first) {
+			first = activity.StartDate
+		}
+	}
+	return first
+}
+
+// duration returns the time of an activity counted towards daily totals.
+// Elapsed time includes stops, which matter more for hikes and climbs than
+// for runs; activities without an elapsed time fall back to moving time.
+func (a *ActivityAggregator) duration(activity strava.SummaryActivity) int {
+	if a.TimeBasis == "elapsed" && activity.ElapsedTime > 0 {
+		return activity.ElapsedTime
+	}
+	return activity.MovingTime
+}
+
+// averageSpeed returns the mean speed in meters per second over the
+// activities that cover a distance, or 0 if there are none
+func averageSpeed(activities []strava.SummaryActivity) float64 {
+	var distance float64
+	var seconds int
+	for _, activity := range activities {
+		if activity.Distance > 0 && activity.MovingTime > 0 {
+			distance += activity.Distance
+			seconds += activity.MovingTime
+		}
+	}
+	if seconds == 0 {
+		return 0
+	}
+	return distance / float64(seconds)
+}
+
+// activityCalories returns the energy burned during an activity in kcal.
+// Calories are only present on detailed activities; for rides with power
+// the mechanical work in kilojoules is a close approximation of kcal burned.
+func activityCalories(activity strava.SummaryActivity) float64 {
+	if activity.Calories > 0 {
+		return activity.Calories
+	}
+	return activityWork(activity)
+}
+
+// activityTrainingStress returns a TSS-like score for an activity:
+// 100 points equals one hour at FTP
+func activityTrainingStress(normalizedPower float64, seconds int, ftp float64) float64 {
+	if ftp <= 0 || normalizedPower <= 0 {
+		return 0
+	}
+	intensityFactor := normalizedPower / ftp
+	return float64(seconds) * normalizedPower * intensityFactor / (ftp * 3600) * 100
+}
+
+// activityWork returns the work done during an activity in kilojoules,
+// estimating it from average power when Strava doesn't report it
+func activityWork(activity strava.SummaryActivity) float64 {
+	if activity.Kilojoules > 0 {
+		return activity.Kilojoules
+	}
+	return activity.AverageWatts * float64(activity.MovingTime) / 1000
+}
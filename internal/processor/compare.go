@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// PeriodTotals sums the activity over a run of days
+type PeriodTotals struct {
+	Distance   float64        // In meters
+	Duration   int            // In seconds
+	Elevation  float64        // In meters
+	ActiveDays int            // Days with at least one activity
+	Activities int            // Number of activities
+	Types      map[string]int // Count of each activity type
+}
+
+// PeriodComparison holds the totals of a period and of the period it is
+// compared against
+type PeriodComparison struct {
+	Current  PeriodTotals
+	Previous PeriodTotals
+}
+
+// SumPeriod totals the given days
+func SumPeriod(days []*strava.DailyActivity) PeriodTotals {
+	totals := PeriodTotals{Types: make(map[string]int)}
+	for _, day := range days {
+		if day.Count == 0 {
+			continue
+		}
+		totals.Distance += day.TotalDistance
+		totals.Duration += day.TotalDuration
+		totals.Elevation += day.TotalElevation
+		totals.ActiveDays++
+		totals.Activities += day.Count
+		for t, count := range day.Types {
+			totals.Types[t] += count
+		}
+	}
+	return totals
+}
+
+// PercentChange returns the relative change from previous to current, and
+// false if there is nothing to compare against
+func PercentChange(current, previous float64) (float64, bool) {
+	if previous == 0 {
+		return 0, false
+	}
+	return (current - previous) / previous * 100, true
+}
@@ -0,0 +1,15 @@
+package processor
+
+import "time"
+
+// CivilDate returns the calendar date of t as midnight UTC. Day arithmetic on
+// civil dates is unaffected by DST transitions in t's own location, where a
+// day can be 23 or 25 hours long.
+func CivilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DaysBetween returns the number of calendar days from start to end
+func DaysBetween(start, end time.Time) int {
+	return int(CivilDate(end).Sub(CivilDate(start)).Hours() / 24)
+}
@@ -0,0 +1,35 @@
+package processor
+
+import "fmt"
+
+// FormatDuration writes a number of seconds in one of the duration styles,
+// so tooltips, stats panels, widgets and README variables agree:
+//
+//   - "long" (the default for an empty style): 1 hour 23 minutes
+//   - "short": 1h 23m
+//   - "clock": 1:23
+//   - "minutes": 83 min
+//
+// Partial minutes are dropped, numbers follow the number format and the
+// long style is written in the language of the translation.
+func FormatDuration(seconds int, style string, tr Translation, nf NumberFormat) string {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+
+	switch style {
+	case "short":
+		if hours > 0 {
+			return fmt.Sprintf("%sh %dm", nf.FormatInt(hours), minutes)
+		}
+		return fmt.Sprintf("%dm", minutes)
+	case "clock":
+		return fmt.Sprintf("%s:%02d", nf.FormatInt(hours), minutes)
+	case "minutes":
+		return nf.WithUnit(nf.FormatInt(seconds/60), "min")
+	default:
+		if hours > 0 {
+			return tr.Plural(hours, "%s hour", "%s hours", nf) + " " + tr.Plural(minutes, "%s minute", "%s minutes", nf)
+		}
+		return tr.Plural(minutes, "%s minute", "%s minutes", nf)
+	}
+}
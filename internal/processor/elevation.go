@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// elevationSmoothingWindow is the number of altitude samples averaged around
+// each point, which flattens the jitter GPS altitude shows on level ground
+const elevationSmoothingWindow = 7
+
+// elevationThreshold is how far in meters the smoothed altitude must rise
+// above its last low before the climb counts toward the gain
+const elevationThreshold = 3.0
+
+// ElevationGain returns the total climb in meters of an altitude stream. The
+// samples are smoothed with a moving average, then only rises of at least
+// elevationThreshold count, so noise that goes up and down again adds nothing.
+func ElevationGain(altitude []float64) float64 {
+	if len(altitude) < 2 {
+		return 0
+	}
+
+	smoothed := movingAverage(altitude, elevationSmoothingWindow)
+
+	gain := 0.0
+	low := smoothed[0]
+	for _, value := range smoothed[1:] {
+		switch {
+		case value-low >= elevationThreshold:
+			gain += value - low
+			low = value
+		case value < low:
+			low = value
+		}
+	}
+	return gain
+}
+
+// movingAverage returns the mean of each value and its neighbours within a
+// centred window, shrinking the window at either end
+func movingAverage(values []float64, window int) []float64 {
+	half := window / 2
+	averaged := make([]float64, len(values))
+	for i := range values {
+		from, to := max(i-half, 0), min(i+half+1, len(values))
+		sum := 0.0
+		for _, value := range values[from:to] {
+			sum += value
+		}
+		averaged[i] = sum / float64(to-from)
+	}
+	return averaged
+}
+
+// CorrectElevation recomputes the elevation gain of each activity from its
+// altitude stream. This costs one API request per activity with GPS data;
+// indoor activities are skipped. If the request budget runs out, the
+// activities corrected so far keep their corrected gain.
+func CorrectElevation(client *strava.Client, activities []strava.SummaryActivity) error {
+	for i := range activities {
+		// Skip activities already corrected, e.g. restored from a cache, and
+		// those without a GPS track to take altitude from
+		if activities[i].CorrectedElevGain != nil || len(activities[i].StartLatlng) == 0 {
+			continue
+		}
+
+		altitude, err := client.GetAltitudeStream(activities[i].ID)
+		if err != nil {
+			return fmt.Errorf("error fetching altitude stream for activity %d: %w", activities[i].ID, err)
+		}
+
+		// Without altitude data there is nothing better than Strava's figure
+		gain := activities[i].TotalElevGain
+		if len(altitude) > 0 {
+			gain = ElevationGain(altitude)
+		}
+		activities[i].CorrectedElevGain = &gain
+
+		// Stay within Strava's rate limits, as in GetAllActivities
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// WithCorrectedElevation returns a copy of the activities in which the
+// elevation gain of each corrected activity is replaced by its corrected gain
+func WithCorrectedElevation(activities []strava.SummaryActivity) []strava.SummaryActivity {
+	corrected := make([]strava.SummaryActivity, len(activities))
+	for i, activity := range activities {
+		if activity.CorrectedElevGain != nil {
+			activity.TotalElevGain = *activity.CorrectedElevGain
+		}
+		corrected[i] = activity
+	}
+	return corrected
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DayExport holds the raw totals of one day for the stats JSON, in SI units
+type DayExport struct {
+	Date            string         `json:"date"` // YYYY-MM-DD
+	Activities      int            `json:"activities"`
+	DistanceMeters  float64        `json:"distanceMeters"`
+	DurationSeconds int            `json:"durationSeconds"`
+	ElevationMeters float64        `json:"elevationMeters"`
+	Calories        float64        `json:"calories,omitempty"`
+	Kilojoules      float64        `json:"kilojoules,omitempty"`
+	TrainingStress  float64        `json:"trainingStress,omitempty"`
+	AvgHeartRate    float64        `json:"avgHeartRate,omitempty"`
+	MaxHeartRate    float64        `json:"maxHeartRate,omitempty"`
+	NormalizedPower float64        `json:"normalizedPower,omitempty"`
+	AvgCadence      float64        `json:"avgCadence,omitempty"`
+	HasPR           bool           `json:"hasPR,omitempty"`
+	ActivityIDs     []int64        `json:"activityIds,omitempty"`
+	Types           map[string]int `json:"types,omitempty"`    // Activities of each type
+	Workouts        map[string]int `json:"workouts,omitempty"` // Activities of each workout kind
+	Tags            map[string]int `json:"tags,omitempty"`     // Activities with each config-defined tag
+}
+
+// StatsExport is everything the stats panel is computed from over the
+// displayed range, for piping into dashboards or badges: the full
+// GenerateStats result and every day's totals, rest days included
+type StatsExport struct {
+	Stats map[string]interface{} `json:"stats"`
+	Days  []DayExport            `json:"days"`
+}
+
+// NewStatsExport computes the stats and daily totals between start and end
+func NewStatsExport(aggregator *ActivityAggregator, start, end time.Time, metricType, language, units string) *StatsExport {
+	days := aggregator.GetOrderedDates(start, end)
+	export := &StatsExport{
+		Stats: NewStatsGenerator(days, start, end, metricType, language, units).GenerateStats(),
+		Days:  make([]DayExport, len(days)),
+	}
+
+	for i, day := range days {
+		export.Days[i] = DayExport{
+			Date:            day.Date.Format("2006-01-02"),
+			Activities:      day.Count,
+			DistanceMeters:  math.Round(day.TotalDistance*10) / 10,
+			DurationSeconds: day.TotalDuration,
+			ElevationMeters: math.Round(day.TotalElevation*10) / 10,
+			Calories:        math.Round(day.TotalCalories),
+			Kilojoules:      math.Round(day.TotalKilojoules),
+			TrainingStress:  math.Round(day.TrainingStress*10) / 10,
+			AvgHeartRate:    math.Round(day.AvgHeartRate),
+			MaxHeartRate:    math.Round(day.MaxHeartRate),
+			NormalizedPower: math.Round(day.NormalizedPower),
+			AvgCadence:      math.Round(day.AvgCadence),
+			HasPR:           day.HasPR,
+			ActivityIDs:     day.Activities,
+			Types:           day.Types,
+			Workouts:        day.Workouts,
+			Tags:            day.Tags,
+		}
+	}
+
+	return export
+}
+
+// JSON returns the export as indented JSON
+func (e *StatsExport) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling stats: %w", err)
+	}
+	return append(data, '\n'), nil
+}
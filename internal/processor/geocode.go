@@ -0,0 +1,150 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// cities.csv is a small offline dataset of cities popular for training,
+// keeping reverse geocoding free of network calls and API keys
+//
+//go:embed cities.csv
+var citiesCSV string
+
+const (
+	cityRadius    = 50.0  // Kilometers from a city's center still counted as that city
+	countryRadius = 400.0 // Kilometers from the nearest city still counted as its country
+	earthRadius   = 6371.0
+)
+
+// Place is a city from the offline dataset
+type Place struct {
+	City    string
+	Country string // ISO 3166-1 alpha-2 code
+	Lat     float64
+	Lng     float64
+}
+
+var (
+	placesOnce sync.Once
+	places     []Place
+)
+
+// loadPlaces parses the embedded city dataset once
+func loadPlaces() []Place {
+	placesOnce.Do(func() {
+		records, err := csv.NewReader(strings.NewReader(citiesCSV)).ReadAll()
+		if err != nil {
+			return
+		}
+
+		// Skip the header row
+		for _, record := range records[1:] {
+			lat, latErr := strconv.ParseFloat(record[2], 64)
+			lng, lngErr := strconv.ParseFloat(record[3], 64)
+			if latErr != nil || lngErr != nil {
+				continue
+			}
+			places = append(places, Place{City: record[0], Country: record[1], Lat: lat, Lng: lng})
+		}
+	})
+	return places
+}
+
+// ReverseGeocode returns the known city nearest to a coordinate and its
+// distance in kilometers
+func ReverseGeocode(lat, lng float64) (Place, float64) {
+	var nearest Place
+	best := math.Inf(1)
+	for _, place := range loadPlaces() {
+		if d := greatCircleDistance(lat, lng, place.Lat, place.Lng); d < best {
+			nearest, best = place, d
+		}
+	}
+	return nearest, best
+}
+
+// TravelSummary counts the activities started in each country and city
+type TravelSummary struct {
+	Countries map[string]int // Activities per country code
+	Cities    map[string]int // Activities per city name
+}
+
+// SummarizeTravel reverse-geocodes the start of each activity between start
+// and end. Activities far from any known city still count toward the
+// nearest city's country when it is within countryRadius.
+func SummarizeTravel(activities []strava.SummaryActivity, start, end time.Time) TravelSummary {
+	summary := TravelSummary{
+		Countries: make(map[string]int),
+		Cities:    make(map[string]int),
+	}
+
+	for _, activity := range activities {
+		if len(activity.StartLatlng) < 2 {
+			continue
+		}
+		if activity.StartDate.Before(start) || !activity.StartDate.Before(end.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		place, distance := ReverseGeocode(activity.StartLatlng[0], activity.StartLatlng[1])
+		if distance > countryRadius {
+			continue
+		}
+		summary.Countries[place.Country]++
+		if distance <= cityRadius {
+			summary.Cities[place.City]++
+		}
+	}
+
+	return summary
+}
+
+// TopCountries returns country codes ordered by activity count, breaking
+// ties alphabetically for stable output
+func (t TravelSummary) TopCountries() []string {
+	return sortedByCount(t.Countries)
+}
+
+// TopCities returns up to n city names ordered by activity count
+func (t TravelSummary) TopCities(n int) []string {
+	cities := sortedByCount(t.Cities)
+	if len(cities) > n {
+		cities = cities[:n]
+	}
+	return cities
+}
+
+// sortedByCount returns the keys of counts from most to least frequent
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// greatCircleDistance returns the distance between two coordinates in
+// kilometers using the haversine formula
+func greatCircleDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
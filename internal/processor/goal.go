@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"sort"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// GoalProgress tracks cumulative distance over a year against an even pace
+// toward a yearly distance goal
+type GoalProgress struct {
+	Goal       float64   // Yearly goal in meters
+	DaysInYear int       // 365 or 366
+	Cumulative []float64 // Distance in meters by the end of each day so far
+}
+
+// NewGoalProgress accumulates the distance of the days since January 1st
+func NewGoalProgress(days []*strava.DailyActivity, goal float64, daysInYear int) *GoalProgress {
+	progress := &GoalProgress{
+		Goal:       goal,
+		DaysInYear: daysInYear,
+		Cumulative: make([]float64, 0, len(days)),
+	}
+
+	total := 0.0
+	for _, day := range days {
+		total += day.TotalDistance
+		progress.Cumulative = append(progress.Cumulative, total)
+	}
+
+	return progress
+}
+
+// Actual returns the distance covered so far in meters
+func (p *GoalProgress) Actual() float64 {
+	if len(p.Cumulative) == 0 {
+		return 0
+	}
+	return p.Cumulative[len(p.Cumulative)-1]
+}
+
+// Expected returns the distance an even pace would have covered by the end
+// of the given number of days
+func (p *GoalProgress) Expected(days int) float64 {
+	if p.DaysInYear == 0 {
+		return 0
+	}
+	return p.Goal * float64(days) / float64(p.DaysInYear)
+}
+
+// Ahead returns how far ahead of an even pace the athlete is in meters,
+// negative when behind
+func (p *GoalProgress) Ahead() float64 {
+	return p.Actual() - p.Expected(len(p.Cumulative))
+}
+
+// MilestoneCrossing is the day cumulative distance for a year first reached
+// a milestone
+type MilestoneCrossing struct {
+	Date      time.Time
+	Milestone float64 // In the distance unit the milestones were given in
+}
+
+// MilestoneCrossings returns the days on which the distance covered since
+// January 1st of each year reached each milestone, given in a distance unit
+// of scale meters, such as 1000 for kilometers. Days must be ordered and
+// start on a January 1st for the first year to count fully.
+func MilestoneCrossings(days []*strava.DailyActivity, milestones []float64, scale float64) []MilestoneCrossing {
+	sorted := append([]float64(nil), milestones...)
+	sort.Float64s(sorted)
+
+	var crossings []MilestoneCrossing
+	total, next, year := 0.0, 0, 0
+	for _, day := range days {
+		// Every year starts again from nothing
+		if day.Date.Year() != year {
+			total, next, year = 0, 0, day.Date.Year()
+		}
+
+		total += day.TotalDistance
+		for next < len(sorted) && total >= sorted[next]*scale {
+			if sorted[next] > 0 {
+				crossings = append(crossings, MilestoneCrossing{Date: day.Date, Milestone: sorted[next]})
+			}
+			next++
+		}
+	}
+	return crossings
+}
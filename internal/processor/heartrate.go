@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// ElevatedHeartRate is how many beats per minute a week's average heart rate
+// must exceed its baseline by to be flagged as possible fatigue
+const ElevatedHeartRate = 5.0
+
+// RecentHeartRateWeeks is how many weeks back from the end of the range an
+// elevated week is still reported
+const RecentHeartRateWeeks = 4
+
+const (
+	heartRateLookback    = 8 // Earlier weeks with heart rate averaged into the baseline
+	heartRateMinBaseline = 4 // Weeks with heart rate needed before flagging any
+)
+
+// HeartRateWeek is the heart rate recorded over one week. Weeks without
+// heart rate data have zero values.
+type HeartRateWeek struct {
+	WeekStart    time.Time
+	AvgHeartRate float64 // Mean of the daily averages, in bpm
+	MaxHeartRate float64 // Highest of the week, in bpm
+}
+
+// HeartRateWarning is a week whose average heart rate sat well above that
+// of the weeks before it, which can be a sign of fatigue or illness
+type HeartRateWarning struct {
+	WeekStart    time.Time
+	AvgHeartRate float64
+	Baseline     float64 // Mean weekly average of the weeks before
+}
+
+// WeeklyHeartRate groups ordered days into weeks starting on weekStart and
+// averages the heart rate of the days that recorded one
+func WeeklyHeartRate(days []*strava.DailyActivity, weekStart time.Weekday) []HeartRateWeek {
+	var weeks []HeartRateWeek
+	var daysWithHR int
+	for _, day := range days {
+		date := CivilDate(day.Date)
+		start := date.AddDate(0, 0, -((int(date.Weekday()) - int(weekStart) + 7) % 7))
+
+		if len(weeks) == 0 || !weeks[len(weeks)-1].WeekStart.Equal(start) {
+			weeks = append(weeks, HeartRateWeek{WeekStart: start})
+			daysWithHR = 0
+		}
+
+		week := &weeks[len(weeks)-1]
+		if day.AvgHeartRate > 0 {
+			daysWithHR++
+			week.AvgHeartRate += (day.AvgHeartRate - week.AvgHeartRate) / float64(daysWithHR)
+		}
+		week.MaxHeartRate = max(week.MaxHeartRate, day.MaxHeartRate)
+	}
+	return weeks
+}
+
+// ElevatedHeartRateWeeks returns the weeks whose average heart rate exceeds
+// the mean of up to eight earlier weeks with heart rate by more than
+// ElevatedHeartRate. Strava doesn't share resting heart rate, so activity
+// averages stand in for it; a week of harder sessions can raise them too.
+func ElevatedHeartRateWeeks(weeks []HeartRateWeek) []HeartRateWarning {
+	var warnings []HeartRateWarning
+	var history []float64
+	for _, week := range weeks {
+		if week.AvgHeartRate == 0 {
+			continue
+		}
+
+		if len(history) >= heartRateMinBaseline {
+			baseline := 0.0
+			for _, avg := range history {
+				baseline += avg
+			}
+			baseline /= float64(len(history))
+
+			if week.AvgHeartRate > baseline+ElevatedHeartRate {
+				warnings = append(warnings, HeartRateWarning{
+					WeekStart:    week.WeekStart,
+					AvgHeartRate: week.AvgHeartRate,
+					Baseline:     baseline,
+				})
+			}
+		}
+
+		history = append(history, week.AvgHeartRate)
+		if len(history) > heartRateLookback {
+			history = history[1:]
+		}
+	}
+	return warnings
+}
+
+// RecentHeartRateWarnings keeps the warnings for the RecentHeartRateWeeks
+// weeks up to end; older weeks are history rather than something to act on
+func RecentHeartRateWarnings(warnings []HeartRateWarning, end time.Time) []HeartRateWarning {
+	cutoff := CivilDate(end).AddDate(0, 0, -7*RecentHeartRateWeeks)
+	var recent []HeartRateWarning
+	for _, warning := range warnings {
+		if warning.WeekStart.After(cutoff) {
+			recent = append(recent, warning)
+		}
+	}
+	return recent
+}
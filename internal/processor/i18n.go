@@ -0,0 +1,685 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Translation holds the words and date formats of a language. Layouts mark
+// the parts of a date with {day}, {month}, {year}, {weekday} and {date}
+type Translation struct {
+	Months            [12]string        // Abbreviated month names, from January
+	LongMonths        [12]string        // Full month names, from January
+	Weekdays          [7]string         // Abbreviated weekday names, from Sunday
+	LongWeekdays      [7]string         // Full weekday names, from Sunday
+	DateLayout        string            // Date with an abbreviated month
+	LongDateLayout    string            // Date with the full month name
+	MonthDayLayout    string            // Day and abbreviated month, without the year
+	WeekdayDateLayout string            // Full weekday before a long date
+	MonthYearLayout   string            // Full month name and year, without the day
+	Messages          map[string]string // English message formats mapped to their translations
+}
+
+// english is used for English and the languages without a translation; its
+// messages are the keys of the other translations
+var english = Translation{
+	Months:            [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	LongMonths:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	Weekdays:          [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	LongWeekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	DateLayout:        "{month} {day}, {year}",
+	LongDateLayout:    "{month} {day}, {year}",
+	MonthDayLayout:    "{month} {day}",
+	WeekdayDateLayout: "{weekday}, {date}",
+	MonthYearLayout:   "{month} {year}",
+}
+
+// translations maps language codes to their translations
+var translations = map[string]Translation{
+	"en": english,
+	"de": {
+		Months:            [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		LongMonths:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		Weekdays:          [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		LongWeekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		DateLayout:        "{day}. {month} {year}",
+		LongDateLayout:    "{day}. {month} {year}",
+		MonthDayLayout:    "{day}. {month}",
+		WeekdayDateLayout: "{weekday}, {date}",
+		MonthYearLayout:   "{month} {year}",
+		Messages: map[string]string{
+			"No activities on %s":                 "Keine Aktivitäten am %s",
+			"No activities on this day":           "Keine Aktivitäten an diesem Tag",
+			"Passed %s this year on %s":           "%s dieses Jahr am %s überschritten",
+			"No activities in the week of %s":     "Keine Aktivitäten in der Woche vom %s",
+			"Week of %s":                          "Woche vom %s",
+			"%s activity":                         "%s Aktivität",
+			"%s activities":                       "%s Aktivitäten",
+			"%s day":                              "%s Tag",
+			"%s days":                             "%s Tage",
+			"%s week":                             "%s Woche",
+			"%s weeks":                            "%s Wochen",
+			"%s-day streak, %s to %s":             "%s-Tage-Serie, %s bis %s",
+			"%s day at this level":                "%s Tag auf dieser Stufe",
+			"%s days at this level":               "%s Tage auf dieser Stufe",
+			"Current streak: %s · Longest: %s":    "Aktuelle Serie: %s · Längste: %s",
+			"Total distance: %s":                  "Gesamtdistanz: %s",
+			"Total time: %s":                      "Gesamtzeit: %s",
+			"Total elevation: %s":                 "Gesamthöhenmeter: %s",
+			"Pace: %s":                            "Pace: %s",
+			"Power: %s avg, %s normalized":        "Leistung: %s Ø, %s normalisiert",
+			"Training stress: %s TSS (IF %s)":     "Trainingsbelastung: %s TSS (IF %s)",
+			"Cadence: %s":                         "Kadenz: %s",
+			"Tags: %s":                            "Tags: %s",
+			"Personal Record!":                    "Persönliche Bestleistung!",
+			"Race day!":                           "Wettkampftag!",
+			"Race week!":                          "Wettkampfwoche!",
+			"light day":                           "leichter Tag",
+			"moderate day":                        "mittlerer Tag",
+			"hard day":                            "harter Tag",
+			"very hard day":                       "sehr harter Tag",
+			"light week":                          "leichte Woche",
+			"moderate week":                       "mittlere Woche",
+			"hard week":                           "harte Woche",
+			"very hard week":                      "sehr harte Woche",
+			"Less":                                "Wenig",
+			"More":                                "Viel",
+			"than last year":                      "als im Vorjahr",
+			"Distance (km)":                       "Distanz (km)",
+			"Distance (mi)":                       "Distanz (mi)",
+			"Duration (hours)":                    "Dauer (Stunden)",
+			"Elevation gain (m)":                  "Höhenmeter (m)",
+			"Elevation gain (ft)":                 "Höhenmeter (ft)",
+			"Avg heart rate (bpm)":                "Ø Herzfrequenz (bpm)",
+			"Energy (kcal)":                       "Energie (kcal)",
+			"Work (kJ)":                           "Arbeit (kJ)",
+			"Normalized power (W)":                "Normalisierte Leistung (W)",
+			"Training stress (TSS)":               "Trainingsbelastung (TSS)",
+			"Effort":                              "Anstrengung",
+			"Composite score":                     "Gesamtwert",
+			"Sports":                              "Sportarten",
+			"Activities":                          "Aktivitäten",
+			"Activity Summary":                    "Aktivitätsübersicht",
+			"Total Activities":                    "Aktivitäten",
+			"Total Distance":                      "Gesamtdistanz",
+			"Total Duration":                      "Gesamtdauer",
+			"Average Pace":                        "Ø Pace",
+			"Avg Weekly Energy":                   "Ø Energie pro Woche",
+			"Peak Weekly Energy":                  "Max. Energie pro Woche",
+			"In the Dark":                         "Im Dunkeln",
+			"%s pre-dawn":                         "%s vor Sonnenaufgang",
+			"Ramp Warnings":                       "Belastungswarnungen",
+			"Elevated HR":                         "Erhöhte HF",
+			"Active Days":                         "Aktive Tage",
+			"Longest Streak":                      "Längste Serie",
+			"Personal Records":                    "Bestleistungen",
+			"weeks":                               "Wochen",
+			"days":                                "Tage",
+			"Distance":                            "Distanz",
+			"Time":                                "Zeit",
+			"%s vs %d":                            "%s vs. %d",
+			"new":                                 "neu",
+			"%d Goal":                             "Ziel %d",
+			"On schedule":                         "Im Plan",
+			"Ahead of schedule":                   "Vor dem Plan",
+			"Behind schedule":                     "Hinter dem Plan",
+			"Ahead by %s":                         "%s voraus",
+			"Behind by %s":                        "%s zurück",
+			"%s of %s":                            "%s von %s",
+			"Trained In":                          "Trainiert in",
+			"%s country":                          "%s Land",
+			"%s countries":                        "%s Länder",
+			"%s city":                             "%s Stadt",
+			"%s cities":                           "%s Städte",
+			"Most active: %s":                     "Am aktivsten: %s",
+			"Tags":                                "Tags",
+			"No tagged activities":                "Keine getaggten Aktivitäten",
+			"Workouts":                            "Workouts",
+			"No activities in this period":        "Keine Aktivitäten in diesem Zeitraum",
+			"Races":                               "Wettkämpfe",
+			"Long runs":                           "Lange Läufe",
+			"Other":                               "Sonstige",
+			"Heart Rate":                          "Herzfrequenz",
+			"No heart rate data":                  "Keine Herzfrequenzdaten",
+			"Steady":                              "Stabil",
+			"Last week avg %s, max %s":            "Letzte Woche Ø %s, max. %s",
+			"%s elevated week, possible fatigue":  "%s Woche erhöht, mögliche Ermüdung",
+			"%s elevated weeks, possible fatigue": "%s Wochen erhöht, mögliche Ermüdung",
+			"%s: avg %s vs %s before":             "%s: Ø %s statt zuvor %s",
+			"Time of Day":                         "Tageszeit",
+			"%s: no activities":                   "%s: keine Aktivitäten",
+			"%s: most often %d:00–%d:00":          "%s: meist %d:00–%d:00",
+			"%s: %s, most often %d:00–%d:00":      "%s: %s, meist %d:00–%d:00",
+			"Weekly %s":                           "%s pro Woche",
+			"Training Load":                       "Trainingsbelastung",
+			"Fitness":                             "Fitness",
+			"Fatigue":                             "Ermüdung",
+			"Form":                                "Form",
+			"Where I Train":                       "Wo ich trainiere",
+			"No routes in this period":            "Keine Routen in diesem Zeitraum",
+			"Ramp warning: workload ratio %s":     "Belastungswarnung: Verhältnis %s",
+			"Cycle":                               "Zyklus",
+			"Build week":                          "Aufbauwoche",
+			"Recovery week":                       "Erholungswoche",
+			"Same day last year: no activities":   "Gleicher Tag im Vorjahr: keine Aktivitäten",
+			"Same day last year: %s":              "Gleicher Tag im Vorjahr: %s",
+			"Same day last year: %s (%s)":         "Gleicher Tag im Vorjahr: %s (%s)",
+			"Strava activity heatmap %s":          "Strava-Aktivitäts-Heatmap %s",
+			"Strava heatmap: no activities %s":    "Strava-Heatmap: keine Aktivitäten %s",
+			"Strava heatmap: %s, %s %s":           "Strava-Heatmap: %s, %s %s",
+			"%s active day":                       "%s aktiver Tag",
+			"%s active days":                      "%s aktive Tage",
+			"in %s":                               "im Jahr %s",
+			"from %s to %s":                       "von %s bis %s",
+			"%s hour":                             "%s Stunde",
+			"%s hours":                            "%s Stunden",
+			"%s minute":                           "%s Minute",
+			"%s minutes":                          "%s Minuten",
+			"hours":                               "Stunden",
+		},
+	},
+	"es": {
+		Months:            [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		LongMonths:        [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		Weekdays:          [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		LongWeekdays:      [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		DateLayout:        "{day} {month} {year}",
+		LongDateLayout:    "{day} de {month} de {year}",
+		MonthDayLayout:    "{day} {month}",
+		WeekdayDateLayout: "{weekday}, {date}",
+		MonthYearLayout:   "{month} de {year}",
+		Messages: map[string]string{
+			"No activities on %s":                 "Sin actividades el %s",
+			"No activities on this day":           "Sin actividades este día",
+			"Passed %s this year on %s":           "%s superados este año el %s",
+			"No activities in the week of %s":     "Sin actividades la semana del %s",
+			"Week of %s":                          "Semana del %s",
+			"%s activity":                         "%s actividad",
+			"%s activities":                       "%s actividades",
+			"%s day":                              "%s día",
+			"%s days":                             "%s días",
+			"%s week":                             "%s semana",
+			"%s weeks":                            "%s semanas",
+			"%s-day streak, %s to %s":             "Racha de %s días, del %s al %s",
+			"%s day at this level":                "%s día en este nivel",
+			"%s days at this level":               "%s días en este nivel",
+			"Current streak: %s · Longest: %s":    "Racha actual: %s · Más larga: %s",
+			"Total distance: %s":                  "Distancia total: %s",
+			"Total time: %s":                      "Tiempo total: %s",
+			"Total elevation: %s":                 "Desnivel total: %s",
+			"Pace: %s":                            "Ritmo: %s",
+			"Power: %s avg, %s normalized":        "Potencia: %s media, %s normalizada",
+			"Training stress: %s TSS (IF %s)":     "Carga de entrenamiento: %s TSS (IF %s)",
+			"Cadence: %s":                         "Cadencia: %s",
+			"Tags: %s":                            "Etiquetas: %s",
+			"Personal Record!":                    "¡Récord personal!",
+			"Race day!":                           "¡Día de carrera!",
+			"Race week!":                          "¡Semana de carrera!",
+			"light day":                           "día suave",
+			"moderate day":                        "día moderado",
+			"hard day":                            "día duro",
+			"very hard day":                       "día muy duro",
+			"light week":                          "semana suave",
+			"moderate week":                       "semana moderada",
+			"hard week":                           "semana dura",
+			"very hard week":                      "semana muy dura",
+			"Less":                                "Menos",
+			"More":                                "Más",
+			"than last year":                      "que el año pasado",
+			"Distance (km)":                       "Distancia (km)",
+			"Distance (mi)":                       "Distancia (mi)",
+			"Duration (hours)":                    "Duración (horas)",
+			"Elevation gain (m)":                  "Desnivel positivo (m)",
+			"Elevation gain (ft)":                 "Desnivel positivo (ft)",
+			"Avg heart rate (bpm)":                "FC media (ppm)",
+			"Energy (kcal)":                       "Energía (kcal)",
+			"Work (kJ)":                           "Trabajo (kJ)",
+			"Normalized power (W)":                "Potencia normalizada (W)",
+			"Training stress (TSS)":               "Carga de entrenamiento (TSS)",
+			"Effort":                              "Esfuerzo",
+			"Composite score":                     "Puntuación compuesta",
+			"Sports":                              "Deportes",
+			"Activities":                          "Actividades",
+			"Activity Summary":                    "Resumen de actividad",
+			"Total Activities":                    "Actividades",
+			"Total Distance":                      "Distancia total",
+			"Total Duration":                      "Duración total",
+			"Average Pace":                        "Ritmo medio",
+			"Avg Weekly Energy":                   "Energía semanal media",
+			"Peak Weekly Energy":                  "Energía semanal máx.",
+			"In the Dark":                         "A oscuras",
+			"%s pre-dawn":                         "%s antes del alba",
+			"Ramp Warnings":                       "Avisos de carga",
+			"Elevated HR":                         "FC elevada",
+			"Active Days":                         "Días activos",
+			"Longest Streak":                      "Racha más larga",
+			"Personal Records":                    "Récords personales",
+			"weeks":                               "semanas",
+			"days":                                "días",
+			"Distance":                            "Distancia",
+			"Time":                                "Tiempo",
+			"%s vs %d":                            "%s frente a %d",
+			"new":                                 "nuevo",
+			"%d Goal":                             "Objetivo %d",
+			"On schedule":                         "Según lo previsto",
+			"Ahead of schedule":                   "Por delante de lo previsto",
+			"Behind schedule":                     "Por detrás de lo previsto",
+			"Ahead by %s":                         "%s por delante",
+			"Behind by %s":                        "%s por detrás",
+			"%s of %s":                            "%s de %s",
+			"Trained In":                          "Entrenado en",
+			"%s country":                          "%s país",
+			"%s countries":                        "%s países",
+			"%s city":                             "%s ciudad",
+			"%s cities":                           "%s ciudades",
+			"Most active: %s":                     "Más activo en: %s",
+			"Tags":                                "Etiquetas",
+			"No tagged activities":                "Sin actividades etiquetadas",
+			"Workouts":                            "Entrenamientos",
+			"No activities in this period":        "Sin actividades en este periodo",
+			"Races":                               "Carreras",
+			"Long runs":                           "Tiradas largas",
+			"Other":                               "Otros",
+			"Heart Rate":                          "Frecuencia cardíaca",
+			"No heart rate data":                  "Sin datos de frecuencia cardíaca",
+			"Steady":                              "Estable",
+			"Last week avg %s, max %s":            "Semana pasada: media %s, máx. %s",
+			"%s elevated week, possible fatigue":  "%s semana elevada, posible fatiga",
+			"%s elevated weeks, possible fatigue": "%s semanas elevadas, posible fatiga",
+			"%s: avg %s vs %s before":             "%s: media %s frente a %s antes",
+			"Time of Day":                         "Hora del día",
+			"%s: no activities":                   "%s: sin actividades",
+			"%s: most often %d:00–%d:00":          "%s: sobre todo de %d:00 a %d:00",
+			"%s: %s, most often %d:00–%d:00":      "%s: %s, sobre todo de %d:00 a %d:00",
+			"Weekly %s":                           "%s por semana",
+			"Training Load":                       "Carga de entrenamiento",
+			"Fitness":                             "Forma física",
+			"Fatigue":                             "Fatiga",
+			"Form":                                "Estado de forma",
+			"Where I Train":                       "Dónde entreno",
+			"No routes in this period":            "Sin rutas en este periodo",
+			"Ramp warning: workload ratio %s":     "Aviso de carga: ratio %s",
+			"Cycle":                               "Ciclo",
+			"Build week":                          "Semana de carga",
+			"Recovery week":                       "Semana de recuperación",
+			"Same day last year: no activities":   "Mismo día del año pasado: sin actividades",
+			"Same day last year: %s":              "Mismo día del año pasado: %s",
+			"Same day last year: %s (%s)":         "Mismo día del año pasado: %s (%s)",
+			"Strava activity heatmap %s":          "Mapa de calor de actividad de Strava %s",
+			"Strava heatmap: no activities %s":    "Mapa de calor de Strava: sin actividades %s",
+			"Strava heatmap: %s, %s %s":           "Mapa de calor de Strava: %s, %s %s",
+			"%s active day":                       "%s día activo",
+			"%s active days":                      "%s días activos",
+			"in %s":                               "en %s",
+			"from %s to %s":                       "de %s a %s",
+			"%s hour":                             "%s hora",
+			"%s hours":                            "%s horas",
+			"%s minute":                           "%s minuto",
+			"%s minutes":                          "%s minutos",
+			"hours":                               "horas",
+		},
+	},
+	"fr": {
+		Months:            [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		LongMonths:        [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		Weekdays:          [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		LongWeekdays:      [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		DateLayout:        "{day} {month} {year}",
+		LongDateLayout:    "{day} {month} {year}",
+		MonthDayLayout:    "{day} {month}",
+		WeekdayDateLayout: "{weekday} {date}",
+		MonthYearLayout:   "{month} {year}",
+		Messages: map[string]string{
+			"No activities on %s":                 "Aucune activité le %s",
+			"No activities on this day":           "Aucune activité ce jour-là",
+			"Passed %s this year on %s":           "%s dépassés cette année le %s",
+			"No activities in the week of %s":     "Aucune activité la semaine du %s",
+			"Week of %s":                          "Semaine du %s",
+			"%s activity":                         "%s activité",
+			"%s activities":                       "%s activités",
+			"%s day":                              "%s jour",
+			"%s days":                             "%s jours",
+			"%s week":                             "%s semaine",
+			"%s weeks":                            "%s semaines",
+			"%s-day streak, %s to %s":             "Série de %s jours, du %s au %s",
+			"%s day at this level":                "%s jour à ce niveau",
+			"%s days at this level":               "%s jours à ce niveau",
+			"Current streak: %s · Longest: %s":    "Série en cours : %s · Record : %s",
+			"Total distance: %s":                  "Distance totale : %s",
+			"Total time: %s":                      "Temps total : %s",
+			"Total elevation: %s":                 "Dénivelé total : %s",
+			"Pace: %s":                            "Allure : %s",
+			"Power: %s avg, %s normalized":        "Puissance : %s moy., %s normalisée",
+			"Training stress: %s TSS (IF %s)":     "Charge d'entraînement : %s TSS (IF %s)",
+			"Cadence: %s":                         "Cadence : %s",
+			"Tags: %s":                            "Étiquettes : %s",
+			"Personal Record!":                    "Record personnel !",
+			"Race day!":                           "Jour de course !",
+			"Race week!":                          "Semaine de course !",
+			"light day":                           "journée légère",
+			"moderate day":                        "journée modérée",
+			"hard day":                            "journée difficile",
+			"very hard day":                       "journée très difficile",
+			"light week":                          "semaine légère",
+			"moderate week":                       "semaine modérée",
+			"hard week":                           "semaine difficile",
+			"very hard week":                      "semaine très difficile",
+			"Less":                                "Moins",
+			"More":                                "Plus",
+			"than last year":                      "que l'an dernier",
+			"Distance (km)":                       "Distance (km)",
+			"Distance (mi)":                       "Distance (mi)",
+			"Duration (hours)":                    "Durée (heures)",
+			"Elevation gain (m)":                  "Dénivelé positif (m)",
+			"Elevation gain (ft)":                 "Dénivelé positif (ft)",
+			"Avg heart rate (bpm)":                "FC moyenne (bpm)",
+			"Energy (kcal)":                       "Énergie (kcal)",
+			"Work (kJ)":                           "Travail (kJ)",
+			"Normalized power (W)":                "Puissance normalisée (W)",
+			"Training stress (TSS)":               "Charge d'entraînement (TSS)",
+			"Effort":                              "Effort",
+			"Composite score":                     "Score composite",
+			"Sports":                              "Sports",
+			"Activities":                          "Activités",
+			"Activity Summary":                    "Résumé d'activité",
+			"Total Activities":                    "Activités",
+			"Total Distance":                      "Distance totale",
+			"Total Duration":                      "Durée totale",
+			"Average Pace":                        "Allure moyenne",
+			"Avg Weekly Energy":                   "Énergie hebdo moy.",
+			"Peak Weekly Energy":                  "Énergie hebdo max.",
+			"In the Dark":                         "De nuit",
+			"%s pre-dawn":                         "%s avant l'aube",
+			"Ramp Warnings":                       "Alertes de charge",
+			"Elevated HR":                         "FC élevée",
+			"Active Days":                         "Jours actifs",
+			"Longest Streak":                      "Plus longue série",
+			"Personal Records":                    "Records personnels",
+			"weeks":                               "semaines",
+			"days":                                "jours",
+			"Distance":                            "Distance",
+			"Time":                                "Temps",
+			"%s vs %d":                            "%s vs %d",
+			"new":                                 "nouveau",
+			"%d Goal":                             "Objectif %d",
+			"On schedule":                         "Dans les temps",
+			"Ahead of schedule":                   "En avance",
+			"Behind schedule":                     "En retard",
+			"Ahead by %s":                         "En avance de %s",
+			"Behind by %s":                        "En retard de %s",
+			"%s of %s":                            "%s sur %s",
+			"Trained In":                          "Lieux d'entraînement",
+			"%s country":                          "%s pays",
+			"%s countries":                        "%s pays",
+			"%s city":                             "%s ville",
+			"%s cities":                           "%s villes",
+			"Most active: %s":                     "Plus actif : %s",
+			"Tags":                                "Étiquettes",
+			"No tagged activities":                "Aucune activité étiquetée",
+			"Workouts":                            "Séances",
+			"No activities in this period":        "Aucune activité sur cette période",
+			"Races":                               "Courses",
+			"Long runs":                           "Sorties longues",
+			"Other":                               "Autres",
+			"Heart Rate":                          "Fréquence cardiaque",
+			"No heart rate data":                  "Aucune donnée de fréquence cardiaque",
+			"Steady":                              "Stable",
+			"Last week avg %s, max %s":            "Semaine dernière : moy. %s, max. %s",
+			"%s elevated week, possible fatigue":  "%s semaine élevée, fatigue possible",
+			"%s elevated weeks, possible fatigue": "%s semaines élevées, fatigue possible",
+			"%s: avg %s vs %s before":             "%s : moy. %s contre %s avant",
+			"Time of Day":                         "Moment de la journée",
+			"%s: no activities":                   "%s : aucune activité",
+			"%s: most often %d:00–%d:00":          "%s : surtout %d:00–%d:00",
+			"%s: %s, most often %d:00–%d:00":      "%s : %s, surtout %d:00–%d:00",
+			"Weekly %s":                           "%s par semaine",
+			"Training Load":                       "Charge d'entraînement",
+			"Fitness":                             "Condition",
+			"Fatigue":                             "Fatigue",
+			"Form":                                "Forme",
+			"Where I Train":                       "Où je m'entraîne",
+			"No routes in this period":            "Aucun parcours sur cette période",
+			"Ramp warning: workload ratio %s":     "Alerte de charge : ratio %s",
+			"Cycle":                               "Cycle",
+			"Build week":                          "Semaine de charge",
+			"Recovery week":                       "Semaine de récupération",
+			"Same day last year: no activities":   "Même jour l'an dernier : aucune activité",
+			"Same day last year: %s":              "Même jour l'an dernier : %s",
+			"Same day last year: %s (%s)":         "Même jour l'an dernier : %s (%s)",
+			"Strava activity heatmap %s":          "Carte de chaleur des activités Strava %s",
+			"Strava heatmap: no activities %s":    "Carte de chaleur Strava : aucune activité %s",
+			"Strava heatmap: %s, %s %s":           "Carte de chaleur Strava : %s, %s %s",
+			"%s active day":                       "%s jour actif",
+			"%s active days":                      "%s jours actifs",
+			"in %s":                               "en %s",
+			"from %s to %s":                       "de %s à %s",
+			"%s hour":                             "%s heure",
+			"%s hours":                            "%s heures",
+			"%s minute":                           "%s minute",
+			"%s minutes":                          "%s minutes",
+			"hours":                               "heures",
+		},
+	},
+	"ja": {
+		Months:            [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		LongMonths:        [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		Weekdays:          [7]string{"日", "月", "火", "水", "木", "金", "土"},
+		LongWeekdays:      [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		DateLayout:        "{year}年{month}{day}日",
+		LongDateLayout:    "{year}年{month}{day}日",
+		MonthDayLayout:    "{month}{day}日",
+		WeekdayDateLayout: "{date}({weekday})",
+		MonthYearLayout:   "{year}年{month}",
+		Messages: map[string]string{
+			"No activities on %s":                 "%sのアクティビティはありません",
+			"No activities on this day":           "この日のアクティビティはありません",
+			"Passed %s this year on %s":           "%[2]sに今年の累計%[1]sを突破",
+			"No activities in the week of %s":     "%sの週のアクティビティはありません",
+			"Week of %s":                          "%sの週",
+			"%s activity":                         "%s件のアクティビティ",
+			"%s activities":                       "%s件のアクティビティ",
+			"%s day":                              "%s日",
+			"%s days":                             "%s日",
+			"%s week":                             "%s週",
+			"%s weeks":                            "%s週",
+			"%s-day streak, %s to %s":             "%s日連続 (%s〜%s)",
+			"%s day at this level":                "このレベルの日: %s日",
+			"%s days at this level":               "このレベルの日: %s日",
+			"Current streak: %s · Longest: %s":    "現在の連続: %s · 最長: %s",
+			"Total distance: %s":                  "合計距離: %s",
+			"Total time: %s":                      "合計時間: %s",
+			"Total elevation: %s":                 "合計獲得標高: %s",
+			"Pace: %s":                            "ペース: %s",
+			"Power: %s avg, %s normalized":        "パワー: 平均%s、NP %s",
+			"Training stress: %s TSS (IF %s)":     "トレーニングストレス: %s TSS (IF %s)",
+			"Cadence: %s":                         "ケイデンス: %s",
+			"Tags: %s":                            "タグ: %s",
+			"Personal Record!":                    "自己ベスト!",
+			"Race day!":                           "レース日!",
+			"Race week!":                          "レース週!",
+			"light day":                           "軽めの日",
+			"moderate day":                        "普通の日",
+			"hard day":                            "きつい日",
+			"very hard day":                       "非常にきつい日",
+			"light week":                          "軽めの週",
+			"moderate week":                       "普通の週",
+			"hard week":                           "きつい週",
+			"very hard week":                      "非常にきつい週",
+			"Less":                                "少",
+			"More":                                "多",
+			"than last year":                      "昨年比",
+			"Distance (km)":                       "距離 (km)",
+			"Distance (mi)":                       "距離 (mi)",
+			"Duration (hours)":                    "時間 (時間)",
+			"Elevation gain (m)":                  "獲得標高 (m)",
+			"Elevation gain (ft)":                 "獲得標高 (ft)",
+			"Avg heart rate (bpm)":                "平均心拍数 (bpm)",
+			"Energy (kcal)":                       "エネルギー (kcal)",
+			"Work (kJ)":                           "仕事量 (kJ)",
+			"Normalized power (W)":                "NP (W)",
+			"Training stress (TSS)":               "トレーニングストレス (TSS)",
+			"Effort":                              "努力度",
+			"Composite score":                     "総合スコア",
+			"Sports":                              "種目数",
+			"Activities":                          "アクティビティ",
+			"Activity Summary":                    "アクティビティ概要",
+			"Total Activities":                    "アクティビティ数",
+			"Total Distance":                      "合計距離",
+			"Total Duration":                      "合計時間",
+			"Average Pace":                        "平均ペース",
+			"Avg Weekly Energy":                   "週平均エネルギー",
+			"Peak Weekly Energy":                  "週最大エネルギー",
+			"In the Dark":                         "暗い時間帯",
+			"%s pre-dawn":                         "うち夜明け前 %s",
+			"Ramp Warnings":                       "負荷増加の警告",
+			"Elevated HR":                         "心拍数上昇",
+			"Active Days":                         "活動日数",
+			"Longest Streak":                      "最長連続",
+			"Personal Records":                    "自己ベスト",
+			"weeks":                               "週",
+			"days":                                "日",
+			"Distance":                            "距離",
+			"Time":                                "時間",
+			"%s vs %d":                            "%s vs %d年",
+			"new":                                 "新規",
+			"%d Goal":                             "%d年の目標",
+			"On schedule":                         "予定どおり",
+			"Ahead of schedule":                   "予定より先行",
+			"Behind schedule":                     "予定より遅れ",
+			"Ahead by %s":                         "%s先行",
+			"Behind by %s":                        "%s遅れ",
+			"%s of %s":                            "%s / %s",
+			"Trained In":                          "練習した場所",
+			"%s country":                          "%sか国",
+			"%s countries":                        "%sか国",
+			"%s city":                             "%s都市",
+			"%s cities":                           "%s都市",
+			"Most active: %s":                     "最も多い都市: %s",
+			"Tags":                                "タグ",
+			"No tagged activities":                "タグ付きのアクティビティはありません",
+			"Workouts":                            "ワークアウト",
+			"No activities in this period":        "この期間のアクティビティはありません",
+			"Races":                               "レース",
+			"Long runs":                           "ロング走",
+			"Other":                               "その他",
+			"Heart Rate":                          "心拍数",
+			"No heart rate data":                  "心拍数データがありません",
+			"Steady":                              "安定",
+			"Last week avg %s, max %s":            "先週 平均%s、最大%s",
+			"%s elevated week, possible fatigue":  "%s週で上昇、疲労の可能性",
+			"%s elevated weeks, possible fatigue": "%s週で上昇、疲労の可能性",
+			"%s: avg %s vs %s before":             "%s: 平均%s (以前は%s)",
+			"Time of Day":                         "時間帯",
+			"%s: no activities":                   "%s: アクティビティなし",
+			"%s: most often %d:00–%d:00":          "%s: 主に%d:00〜%d:00",
+			"%s: %s, most often %d:00–%d:00":      "%s: %s、主に%d:00〜%d:00",
+			"Weekly %s":                           "週間%s",
+			"Training Load":                       "トレーニング負荷",
+			"Fitness":                             "フィットネス",
+			"Fatigue":                             "疲労",
+			"Form":                                "フォーム",
+			"Where I Train":                       "練習エリア",
+			"No routes in this period":            "この期間のルートはありません",
+			"Ramp warning: workload ratio %s":     "負荷増加の警告: 比率 %s",
+			"Cycle":                               "サイクル",
+			"Build week":                          "強化週",
+			"Recovery week":                       "回復週",
+			"Same day last year: no activities":   "前年同日: アクティビティなし",
+			"Same day last year: %s":              "前年同日: %s",
+			"Same day last year: %s (%s)":         "前年同日: %s (%s)",
+			"Strava activity heatmap %s":          "Stravaアクティビティのヒートマップ(%s)",
+			"Strava heatmap: no activities %s":    "Stravaヒートマップ: %sのアクティビティなし",
+			"Strava heatmap: %s, %s %s":           "Stravaヒートマップ: %[3]s %[1]s、%[2]s",
+			"%s active day":                       "活動日%s日",
+			"%s active days":                      "活動日%s日",
+			"in %s":                               "%s年",
+			"from %s to %s":                       "%sから%sまで",
+			"%s hour":                             "%s時間",
+			"%s hours":                            "%s時間",
+			"%s minute":                           "%s分",
+			"%s minutes":                          "%s分",
+			"hours":                               "時間",
+		},
+	},
+}
+
+// GetTranslation returns the translation for a language code, falling back
+// to English
+func GetTranslation(language string) Translation {
+	if translation, ok := translations[language]; ok {
+		return translation
+	}
+	return english
+}
+
+// T translates an English message format and fills in its arguments.
+// Messages without a translation stay in English
+func (t Translation) T(format string, args ...any) string {
+	if translated, ok := t.Messages[format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Plural translates the singular or plural form of a message about a count,
+// such as "%s day" and "%s days", filling in the formatted count
+func (t Translation) Plural(count int, one, other string, nf NumberFormat) string {
+	if count == 1 {
+		return t.T(one, nf.FormatInt(count))
+	}
+	return t.T(other, nf.FormatInt(count))
+}
+
+// Month returns the abbreviated name of a month
+func (t Translation) Month(month time.Month) string {
+	return t.Months[month-1]
+}
+
+// Weekday returns the abbreviated name of a weekday
+func (t Translation) Weekday(day time.Weekday) string {
+	return t.Weekdays[day]
+}
+
+// FormatDate writes a date with an abbreviated month, like "Jan 2, 2006"
+func (t Translation) FormatDate(date time.Time) string {
+	return t.layout(t.DateLayout, date, t.Months)
+}
+
+// FormatLongDate writes a date with the full month name, like
+// "January 2, 2006"
+func (t Translation) FormatLongDate(date time.Time) string {
+	return t.layout(t.LongDateLayout, date, t.LongMonths)
+}
+
+// FormatMonthDay writes a date without its year, like "Jan 2"
+func (t Translation) FormatMonthDay(date time.Time) string {
+	return t.layout(t.MonthDayLayout, date, t.Months)
+}
+
+// FormatWeekdayDate writes a long date after its weekday, like
+// "Monday, January 2, 2006"
+func (t Translation) FormatWeekdayDate(date time.Time) string {
+	return strings.NewReplacer(
+		"{weekday}", t.LongWeekdays[date.Weekday()],
+		"{date}", t.FormatLongDate(date),
+	).Replace(t.WeekdayDateLayout)
+}
+
+// FormatMonthYear writes a month with its year, like "January 2006"
+func (t Translation) FormatMonthYear(date time.Time) string {
+	return t.layout(t.MonthYearLayout, date, t.LongMonths)
+}
+
+// layout fills in the day, month and year of a date layout
+func (t Translation) layout(layout string, date time.Time, months [12]string) string {
+	return strings.NewReplacer(
+		"{day}", strconv.Itoa(date.Day()),
+		"{month}", months[date.Month()-1],
+		"{year}", strconv.Itoa(date.Year()),
+	).Replace(layout)
+}
@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// verb matches a formatting verb, with or without an explicit argument index
+var verb = regexp.MustCompile(`%(?:\[\d+\])?[sd]`)
+
+func TestTranslationsHaveEveryMessage(t *testing.T) {
+	// German is the reference; every other translation covers the same
+	// messages
+	reference := translations["de"].Messages
+
+	for language, translation := range translations {
+		if language == "en" {
+			continue
+		}
+
+		for key := range reference {
+			if _, ok := translation.Messages[key]; !ok {
+				t.Errorf("%s is missing %q", language, key)
+			}
+		}
+		for key, message := range translation.Messages {
+			if _, ok := reference[key]; !ok {
+				t.Errorf("%s translates %q, which de doesn't", language, key)
+			}
+
+			// Translations fill in as many arguments as the English message
+			want, got := verbs(key), verbs(message)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, want %v like %q", language, message, got, want, key)
+			}
+		}
+		if translation.MonthYearLayout == "" {
+			t.Errorf("%s has no MonthYearLayout", language)
+		}
+	}
+}
+
+// verbs returns the kinds of the formatting verbs of a message, sorted since
+// a translation may take its arguments in another order
+func verbs(message string) []string {
+	matches := verb.FindAllString(message, -1)
+	kinds := make([]string, len(matches))
+	for i, match := range matches {
+		kinds[i] = match[len(match)-1:]
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func TestFormatMonthYear(t *testing.T) {
+	tests := []struct {
+		language, want string
+	}{
+		{"en", "February 2024"},
+		{"de", "Februar 2024"},
+		{"es", "febrero de 2024"},
+		{"fr", "février 2024"},
+		{"ja", "2024年2月"},
+		{"pt", "February 2024"}, // Falls back to English
+	}
+
+	for _, tt := range tests {
+		if got := GetTranslation(tt.language).FormatMonthYear(date(2024, 2, 29)); got != tt.want {
+			t.Errorf("%s: FormatMonthYear = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestAltTextTranslated(t *testing.T) {
+	activities := []strava.SummaryActivity{
+		{ID: 1, Type: "Run", Distance: 12000, MovingTime: 3600, StartDate: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	aggregator := NewActivityAggregator(activities, time.UTC, 0)
+	aggregator.Aggregate()
+
+	tests := []struct {
+		language string
+		private  bool
+		want     string
+	}{
+		{"en", false, "Strava heatmap: 1 active day, 12 km in 2024"},
+		{"de", false, "Strava-Heatmap: 1 aktiver Tag, 12 km im Jahr 2024"},
+		{"ja", false, "Stravaヒートマップ: 2024年 活動日1日、12 km"},
+		{"fr", true, "Carte de chaleur des activités Strava en 2024"},
+	}
+
+	for _, tt := range tests {
+		got := AltText(aggregator, date(2024, 1, 1), date(2024, 12, 31), tt.language, "metric", tt.private)
+		if got != tt.want {
+			t.Errorf("%s: AltText = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		style, language string
+		seconds         int
+		want            string
+	}{
+		{"", "en", 4980, "1 hour 23 minutes"},
+		{"long", "en", 60, "1 minute"},
+		{"long", "de", 7260, "2 Stunden 1 Minute"},
+		{"long", "ja", 4980, "1時間 23分"},
+		{"short", "de", 4980, "1h 23m"},
+		{"clock", "en", 4980, "1:23"},
+		{"minutes", "en", 4980, "83 min"},
+	}
+
+	for _, tt := range tests {
+		got := FormatDuration(tt.seconds, tt.style, GetTranslation(tt.language), GetNumberFormat(tt.language))
+		if got != tt.want {
+			t.Errorf("%s/%s: FormatDuration(%d) = %q, want %q", tt.style, tt.language, tt.seconds, got, tt.want)
+		}
+	}
+}
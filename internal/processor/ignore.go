@@ -0,0 +1,25 @@
+package processor
+
+import "github.com/samuellee/StravaGraph/internal/strava"
+
+// IgnoreActivities returns the activities whose IDs aren't listed, so bad
+// GPS recordings or duplicate uploads that can't be deleted are left out of
+// aggregation without being touched on Strava
+func IgnoreActivities(activities []strava.SummaryActivity, ids []int64) []strava.SummaryActivity {
+	if len(ids) == 0 {
+		return activities
+	}
+
+	ignored := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		ignored[id] = true
+	}
+
+	var kept []strava.SummaryActivity
+	for _, activity := range activities {
+		if !ignored[activity.ID] {
+			kept = append(kept, activity)
+		}
+	}
+	return kept
+}
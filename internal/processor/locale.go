@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumberFormat describes how numbers and units are written in a language
+type NumberFormat struct {
+	DecimalSeparator string
+	GroupSeparator   string
+	UnitSeparator    string // Placed between a value and its unit
+}
+
+// defaultNumberFormat is used for English and unknown languages
+var defaultNumberFormat = NumberFormat{DecimalSeparator: ".", GroupSeparator: ",", UnitSeparator: " "}
+
+// numberFormats maps language codes to their number formatting conventions
+var numberFormats = map[string]NumberFormat{
+	"en": defaultNumberFormat,
+	"de": {DecimalSeparator: ",", GroupSeparator: ".", UnitSeparator: " "},
+	"es": {DecimalSeparator: ",", GroupSeparator: ".", UnitSeparator: " "},
+	"fr": {DecimalSeparator: ",", GroupSeparator: " ", UnitSeparator: " "},
+	"it": {DecimalSeparator: ",", GroupSeparator: ".", UnitSeparator: " "},
+	"ja": defaultNumberFormat,
+	"nl": {DecimalSeparator: ",", GroupSeparator: ".", UnitSeparator: " "},
+	"pt": {DecimalSeparator: ",", GroupSeparator: ".", UnitSeparator: " "},
+}
+
+// GetNumberFormat returns the number format for a language code
+func GetNumberFormat(language string) NumberFormat {
+	if format, ok := numberFormats[language]; ok {
+		return format
+	}
+	return defaultNumberFormat
+}
+
+// FormatFloat formats a number with the given number of decimal places
+func (f NumberFormat) FormatFloat(value float64, precision int) string {
+	s := strconv.FormatFloat(math.Abs(value), 'f', precision, 64)
+
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+	result := groupDigits(intPart, f.GroupSeparator)
+	if hasFrac {
+		result += f.DecimalSeparator + fracPart
+	}
+
+	if value < 0 && strings.Trim(s, "0.") != "" {
+		result = "-" + result
+	}
+	return result
+}
+
+// FormatInt formats an integer with thousands grouping
+func (f NumberFormat) FormatInt(value int) string {
+	return f.FormatFloat(float64(value), 0)
+}
+
+// WithUnit joins a formatted value and its unit
+func (f NumberFormat) WithUnit(value, unit string) string {
+	if unit == "" {
+		return value
+	}
+	// Units that attach to the value, like "/100m", aren't separated
+	if strings.HasPrefix(unit, "/") {
+		return value + unit
+	}
+	return fmt.Sprintf("%s%s%s", value, f.UnitSeparator, unit)
+}
+
+// groupDigits inserts a separator between groups of three digits
+func groupDigits(digits, separator string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var sb strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		sb.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if sb.Len() > 0 {
+			sb.WriteString(separator)
+		}
+		sb.WriteString(digits[i : i+3])
+	}
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"math"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// boundsPercentile trims the bounds of a density grid to this share of route
+// points on each side, so one trip abroad doesn't shrink the usual routes
+// to a dot
+const boundsPercentile = 0.02
+
+// DensityGrid counts the activities whose routes pass through each cell of a
+// grid laid over the area they cover
+type DensityGrid struct {
+	Cols   int
+	Rows   int
+	Counts []int // Row by row from the north west corner
+	Max    int   // Highest count in any cell
+}
+
+// Count returns the number of activities passing through a cell
+func (d *DensityGrid) Count(col, row int) int {
+	return d.Counts[row*d.Cols+col]
+}
+
+// projectedPoint is a route point in Web Mercator coordinates, in degrees so
+// that x is the longitude
+type projectedPoint struct {
+	X, Y float64
+}
+
+// BuildDensityGrid bins the routes of activities started between start and
+// end into a cols by rows grid. Route points within privacyRadius meters of
+// an activity's start or end are dropped, so the grid doesn't show where
+// home is. The grid covers the routes with the same scale on both axes.
+func BuildDensityGrid(activities []strava.SummaryActivity, start, end time.Time, cols, rows int, privacyRadius float64) *DensityGrid {
+	grid := &DensityGrid{
+		Cols:   cols,
+		Rows:   rows,
+		Counts: make([]int, cols*rows),
+	}
+
+	// Each route is split wherever privacy removed points, so no line is
+	// drawn across a hidden stretch
+	var routes [][][]projectedPoint
+	var xs, ys []float64
+	for _, activity := range activities {
+		if activity.Map.SummaryPolyline == "" {
+			continue
+		}
+		if activity.StartDate.Before(start) || !activity.StartDate.Before(end.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		points, err := DecodePolyline(activity.Map.SummaryPolyline)
+		if err != nil || len(points) == 0 {
+			continue
+		}
+
+		var route [][]projectedPoint
+		var run []projectedPoint
+		first, last := points[0], points[len(points)-1]
+		for _, point := range points {
+			if privacyRadius > 0 &&
+				(greatCircleDistance(point[0], point[1], first[0], first[1])*1000 <= privacyRadius ||
+					greatCircleDistance(point[0], point[1], last[0], last[1])*1000 <= privacyRadius) {
+				if len(run) > 0 {
+					route = append(route, run)
+					run = nil
+				}
+				continue
+			}
+
+			p := project(point[0], point[1])
+			run = append(run, p)
+			xs = append(xs, p.X)
+			ys = append(ys, p.Y)
+		}
+		if len(run) > 0 {
+			route = append(route, run)
+		}
+		if len(route) > 0 {
+			routes = append(routes, route)
+		}
+	}
+
+	if len(routes) == 0 {
+		return grid
+	}
+
+	minX, maxX := percentileValue(xs, boundsPercentile), percentileValue(xs, 1-boundsPercentile)
+	minY, maxY := percentileValue(ys, boundsPercentile), percentileValue(ys, 1-boundsPercentile)
+
+	// Widen the narrower side so cells are square, with a margin of one cell
+	scale := math.Max((maxX-minX)/float64(cols-2), (maxY-minY)/float64(rows-2))
+	if scale == 0 {
+		scale = 1e-4 // A single point, shown at roughly street scale
+	}
+	originX := (minX+maxX)/2 - scale*float64(cols)/2
+	originY := (minY+maxY)/2 + scale*float64(rows)/2
+
+	// Mark the cells each route passes through, counting each activity
+	// once per cell
+	visited := make([]int, cols*rows)
+	for i, route := range routes {
+		stamp := i + 1
+		mark := func(p projectedPoint) {
+			col := int(math.Floor((p.X - originX) / scale))
+			row := int(math.Floor((originY - p.Y) / scale))
+			if col < 0 || col >= cols || row < 0 || row >= rows {
+				return
+			}
+			cell := row*cols + col
+			if visited[cell] != stamp {
+				visited[cell] = stamp
+				grid.Counts[cell]++
+				grid.Max = max(grid.Max, grid.Counts[cell])
+			}
+		}
+
+		for _, run := range route {
+			mark(run[0])
+			for j := 1; j < len(run); j++ {
+				// Step along the segment at most half a cell at a time
+				a, b := run[j-1], run[j]
+				steps := int(math.Ceil(math.Hypot(b.X-a.X, b.Y-a.Y) / scale * 2))
+				for s := 1; s <= steps; s++ {
+					t := float64(s) / float64(steps)
+					mark(projectedPoint{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t})
+				}
+				if steps == 0 {
+					mark(b)
+				}
+			}
+		}
+	}
+
+	return grid
+}
+
+// project converts a coordinate to Web Mercator, which keeps the shapes of
+// routes at the scale of a city
+func project(lat, lng float64) projectedPoint {
+	rad := math.Pi / 180
+	return projectedPoint{
+		X: lng,
+		Y: math.Log(math.Tan(math.Pi/4+lat*rad/2)) / rad,
+	}
+}
@@ -1,12 +1,116 @@
 package processor
 
 import (
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/samuellee/StravaGraph/internal/strava"
 )
 
+// MetricValue returns the value of the given metric for a day in raw units
+// (meters, seconds, bpm, kcal, kJ, watts)
+func MetricValue(day *strava.DailyActivity, metricType string) float64 {
+	switch metricType {
+	case "distance":
+		// Distance-less activities such as yoga count with their equivalent
+		return day.TotalDistance + day.EquivalentDistance
+	case "duration":
+		return float64(day.TotalDuration)
+	case "elevation":
+		return day.TotalElevation
+	case "heart_rate":
+		return day.AvgHeartRate
+	case "energy":
+		return day.TotalCalories
+	case "work":
+		return day.TotalKilojoules
+	case "normalized_power":
+		return day.NormalizedPower
+	case "tss":
+		return day.TrainingStress
+	case "composite":
+		return day.CompositeScore
+	case "variety":
+		// Distinct sports, so cross-training outweighs more of the same
+		return float64(len(day.Types))
+	case "effort":
+		// Simple effort formula: distance * elevation gain / duration
+		// This rewards activities with higher distance, more elevation, but shorter time
+		if day.TotalDuration > 0 {
+			return (day.TotalDistance * (1 + day.TotalElevation/100)) / float64(day.TotalDuration)
+		}
+		return 0
+	default:
+		return float64(day.Count) // Default to count-based intensity
+	}
+}
+
+// TotalValue returns what a day adds to reported totals of the metric,
+// which unlike MetricValue leaves out the distance credited to distance-less
+// activities, since it was never covered
+func TotalValue(day *strava.DailyActivity, metricType string) float64 {
+	if metricType == "distance" {
+		return day.TotalDistance
+	}
+	return MetricValue(day, metricType)
+}
+
+// MetricDisplayValue converts a raw metric value to its display unit in the
+// given units, "imperial" or else metric
+func MetricDisplayValue(value float64, metricType, units string) float64 {
+	switch metricType {
+	case "distance":
+		if units == "imperial" {
+			return value / metersPerMile
+		}
+		return value / 1000 // km
+	case "elevation":
+		if units == "imperial" {
+			return value / metersPerFoot
+		}
+		return value
+	case "duration":
+		return value / 3600 // hours
+	case "composite":
+		return value * 100 // score out of 100
+	default:
+		return value
+	}
+}
+
+// MetricUnit returns the display unit of a metric in the given units
+func MetricUnit(metricType, units string) string {
+	switch metricType {
+	case "distance":
+		if units == "imperial" {
+			return "mi"
+		}
+		return "km"
+	case "duration":
+		return "hours"
+	case "elevation":
+		if units == "imperial" {
+			return "ft"
+		}
+		return "m"
+	case "heart_rate":
+		return "bpm"
+	case "energy":
+		return "kcal"
+	case "work":
+		return "kJ"
+	case "normalized_power":
+		return "W"
+	case "tss":
+		return "TSS"
+	case "variety":
+		return "sports"
+	default:
+		return ""
+	}
+}
+
 // MetricsCalculator calculates activity metrics
 type MetricsCalculator struct {
 	DailyData []*strava.DailyActivity
@@ -37,6 +141,7 @@ func (m *MetricsCalculator) CalculateOverallStats() *strava.ActivityStats {
 			stats.TotalActivities += day.Count
 			stats.TotalDistance += day.TotalDistance / 1000 // Convert to kilometers
 			stats.TotalDuration += day.TotalDuration / 3600 // Convert to hours
+			stats.TotalSeconds += day.TotalDuration
 			stats.TotalElevation += day.TotalElevation
 			stats.ActiveDays++
 
@@ -44,6 +149,9 @@ func (m *MetricsCalculator) CalculateOverallStats() *strava.ActivityStats {
 				stats.PRCount++
 			}
 
+			stats.PreDawn += day.PreDawnCount
+			stats.AfterDark += day.AfterDarkCount
+
 			// Add activity types
 			for t, count := range day.Types {
 				stats.ActivityTypes[t] += count
@@ -65,18 +173,38 @@ func (m *MetricsCalculator) CalculateOverallStats() *strava.ActivityStats {
 	return stats
 }
 
-// CalculatePeriodStats calculates statistics for specific time periods
+// CalculatePeriodStats calculates statistics for specific time periods, in
+// order, leaving out periods without activities
 func (m *MetricsCalculator) CalculatePeriodStats(periodType string) []*strava.DatePeriodStats {
 	var stats []*strava.DatePeriodStats
 
-	// Group days by period
-	periods := make(map[string]*strava.DatePeriodStats)
-
-	for _, day := range m.DailyData {
-		if day.Count == 0 {
-			continue
+	keys, periods := groupByPeriod(m.DailyData, periodType)
+	for i, days := range periods {
+		period := &strava.DatePeriodStats{Period: keys[i]}
+		for _, day := range days {
+			period.TotalDistance += day.TotalDistance / 1000 // km
+			period.TotalDuration += day.TotalDuration / 3600 // hours
+			period.TotalElevation += day.TotalElevation
+			period.TotalEnergy += day.TotalCalories
+			period.ActivityCount += day.Count
+		}
+		if period.ActivityCount > 0 {
+			stats = append(stats, period)
 		}
+	}
+
+	return stats
+}
 
+// groupByPeriod splits days into ISO weeks, months or years, in order of
+// their first day, and returns each period's key, e.g. "2020-W53", "2020-01"
+// or "2020", with its days
+func groupByPeriod(days []*strava.DailyActivity, periodType string) ([]string, [][]*strava.DailyActivity) {
+	var keys []string
+	var periods [][]*strava.DailyActivity
+	index := make(map[string]int)
+
+	for _, day := range days {
 		var periodKey string
 		switch periodType {
 		case "weekly":
@@ -93,28 +221,17 @@ func (m *MetricsCalculator) CalculatePeriodStats(periodType string) []*strava.Da
 			continue
 		}
 
-		// Create or update period stats
-		period, exists := periods[periodKey]
+		i, exists := index[periodKey]
 		if !exists {
-			period = &strava.DatePeriodStats{
-				Period: periodKey,
-			}
-			periods[periodKey] = period
+			i = len(keys)
+			index[periodKey] = i
+			keys = append(keys, periodKey)
+			periods = append(periods, nil)
 		}
-
-		// Add day's stats to period
-		period.TotalDistance += day.TotalDistance / 1000 // km
-		period.TotalDuration += day.TotalDuration / 3600 // hours
-		period.TotalElevation += day.TotalElevation
-		period.ActivityCount += day.Count
-	}
-
-	// Convert map to slice
-	for _, period := range periods {
-		stats = append(stats, period)
+		periods[i] = append(periods[i], day)
 	}
 
-	return stats
+	return keys, periods
 }
 
 // CalculateAverages calculates average metrics per active day
@@ -129,8 +246,9 @@ func (m *MetricsCalculator) CalculateAverages() map[string]float64 {
 		averages["activitiesPerDay"] = float64(stats.TotalActivities) / float64(stats.ActiveDays)
 	}
 
-	// Calculate activity frequency (percentage of days with activity)
-	totalDays := m.EndDate.Sub(m.StartDate).Hours() / 24
+	// Calculate activity frequency (percentage of days with activity), counting
+	// both the first and last day of the range
+	totalDays := float64(DaysBetween(m.StartDate, m.EndDate) + 1)
 	if totalDays > 0 {
 		averages["activityFrequency"] = float64(stats.ActiveDays) / totalDays
 	}
@@ -138,10 +256,11 @@ func (m *MetricsCalculator) CalculateAverages() map[string]float64 {
 	return averages
 }
 
-// formatPeriodKey formats a period key based on year and period number
+// formatPeriodKey formats an ISO year and week as a period key, e.g.
+// "2020-W53". Formatting a date with "W02" would print its day of the month
+// rather than the week, merging unrelated weeks into one period.
 func formatPeriodKey(year, period int) string {
-	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).
-		AddDate(0, 0, (period-1)*7).Format("2006-W02")
+	return fmt.Sprintf("%04d-W%02d", year, period)
 }
 
 // CalculateEffortScore calculates an overall effort score
@@ -161,7 +280,7 @@ func (m *MetricsCalculator) CalculateEffortScore() float64 {
 	durationFactor := math.Min(float64(stats.TotalDuration)/5, 100)
 
 	// Frequency bonus from active days percentage
-	totalDays := m.EndDate.Sub(m.StartDate).Hours() / 24
+	totalDays := float64(DaysBetween(m.StartDate, m.EndDate) + 1)
 	frequencyBonus := 0.0
 	if totalDays > 0 {
 		frequencyBonus = math.Min(float64(stats.ActiveDays)/totalDays*50, 50)
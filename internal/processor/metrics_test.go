@@ -0,0 +1,185 @@
+package processor
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// date returns midnight UTC of a civil date
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// activeDay is a day with a single 5 km activity
+func activeDay(day time.Time) *strava.DailyActivity {
+	return &strava.DailyActivity{Date: day, Count: 1, TotalDistance: 5000, TotalDuration: 1800}
+}
+
+func TestFormatPeriodKey(t *testing.T) {
+	tests := []struct {
+		year, week int
+		want       string
+	}{
+		{2020, 1, "2020-W01"},
+		{2020, 14, "2020-W14"},
+		{2020, 53, "2020-W53"},
+		{2026, 53, "2026-W53"},
+		{999, 5, "0999-W05"},
+	}
+
+	for _, tt := range tests {
+		if got := formatPeriodKey(tt.year, tt.week); got != tt.want {
+			t.Errorf("formatPeriodKey(%d, %d) = %q, want %q", tt.year, tt.week, got, tt.want)
+		}
+	}
+}
+
+func TestWeeklyPeriodKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		days []time.Time
+		want map[string]int // Activities in each weekly period
+	}{
+		{
+			// Week 14 printed with a day-of-month layout collided with week 1
+			name: "weeks with the same day of the month",
+			days: []time.Time{date(2020, 1, 1), date(2020, 4, 1)},
+			want: map[string]int{"2020-W01": 1, "2020-W14": 1},
+		},
+		{
+			name: "53rd week of 2020 spanning New Year",
+			days: []time.Time{date(2020, 12, 28), date(2020, 12, 31), date(2021, 1, 1), date(2021, 1, 3), date(2021, 1, 4)},
+			want: map[string]int{"2020-W53": 4, "2021-W01": 1},
+		},
+		{
+			name: "53rd week of 2026",
+			days: []time.Time{date(2026, 12, 27), date(2026, 12, 28), date(2027, 1, 3)},
+			want: map[string]int{"2026-W52": 1, "2026-W53": 2},
+		},
+		{
+			// December 29th 2025 already falls in the first week of 2026
+			name: "first ISO week starting in December",
+			days: []time.Time{date(2025, 12, 28), date(2025, 12, 29), date(2026, 1, 1)},
+			want: map[string]int{"2025-W52": 1, "2026-W01": 2},
+		},
+		{
+			name: "leap day",
+			days: []time.Time{date(2024, 2, 26), date(2024, 2, 29), date(2024, 3, 1), date(2024, 3, 4)},
+			want: map[string]int{"2024-W09": 3, "2024-W10": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var days []*strava.DailyActivity
+			for _, day := range tt.days {
+				days = append(days, activeDay(day))
+			}
+			calculator := NewMetricsCalculator(days, tt.days[0], tt.days[len(tt.days)-1])
+
+			got := make(map[string]int)
+			for _, period := range calculator.CalculatePeriodStats("weekly") {
+				got[period.Period] = period.ActivityCount
+			}
+
+			if len(got) != len(tt.want) {
+				t.Errorf("got periods %v, want %v", sortedKeys(got), sortedKeys(tt.want))
+			}
+			for key, count := range tt.want {
+				if got[key] != count {
+					t.Errorf("period %s has %d activities, want %d", key, got[key], count)
+				}
+			}
+		})
+	}
+}
+
+func TestActivityFrequencyCountsBothEnds(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		active     int
+		want       float64
+	}{
+		{"single day", date(2024, 2, 29), date(2024, 2, 29), 1, 1},
+		{"leap February", date(2024, 2, 1), date(2024, 2, 29), 29, 1},
+		{"half of a fortnight", date(2024, 2, 16), date(2024, 2, 29), 7, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var days []*strava.DailyActivity
+			for i := 0; i < tt.active; i++ {
+				days = append(days, activeDay(tt.start.AddDate(0, 0, i)))
+			}
+
+			averages := NewMetricsCalculator(days, tt.start, tt.end).CalculateAverages()
+			if got := averages["activityFrequency"]; got != tt.want {
+				t.Errorf("activityFrequency = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEquivalentDistanceLeftOutOfTotals(t *testing.T) {
+	// A 5 km run and an hour of yoga credited with 10 km
+	run := activeDay(date(2024, 1, 1))
+	yoga := &strava.DailyActivity{Date: date(2024, 1, 2), Count: 1, TotalDuration: 3600, EquivalentDistance: 10000}
+	days := []*strava.DailyActivity{run, yoga}
+
+	if got := PeriodValue(days, "distance"); got != 15000 {
+		t.Errorf("PeriodValue = %v, want 15000 with the yoga's equivalent", got)
+	}
+	weeks := WeeklyTotals(days, "distance")
+	if len(weeks) != 1 || weeks[0].Value != 5000 {
+		t.Errorf("WeeklyTotals = %+v, want one week of 5000 covered", weeks)
+	}
+	if got := TotalValue(yoga, "duration"); got != 3600 {
+		t.Errorf("TotalValue(duration) = %v, want 3600", got)
+	}
+}
+
+// sortedKeys lists the keys of a map in order, for readable failures
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestRecentHeartRateWarnings(t *testing.T) {
+	warnings := []HeartRateWarning{
+		{WeekStart: date(2024, 4, 29)},
+		{WeekStart: date(2024, 5, 6)},
+		{WeekStart: date(2024, 5, 27)},
+	}
+
+	recent := RecentHeartRateWarnings(warnings, date(2024, 6, 2))
+	if len(recent) != 2 || !recent[0].WeekStart.Equal(date(2024, 5, 6)) {
+		t.Errorf("RecentHeartRateWarnings = %+v, want the weeks of May 6 and May 27", recent)
+	}
+}
+
+func TestTrainingLoadsSeededFromHistory(t *testing.T) {
+	// An hour a day for the 126 days before the range, then a rest day
+	var history []*strava.DailyActivity
+	for i := 126; i > 0; i-- {
+		history = append(history, &strava.DailyActivity{Date: date(2024, 5, 1).AddDate(0, 0, -i), Count: 1, TotalDuration: 3600})
+	}
+	days := []*strava.DailyActivity{{Date: date(2024, 5, 1)}}
+
+	cold := TrainingLoads(nil, days)[0]
+	if cold.Fitness != 0 || cold.Form != 0 {
+		t.Errorf("without history = %+v, want zero fitness and form", cold)
+	}
+
+	seeded := TrainingLoads(history, days)[0]
+	if seeded.Fitness < 50 || seeded.Fatigue < 50 {
+		t.Errorf("seeded = %+v, want fitness and fatigue near the 60 minutes a day", seeded)
+	}
+}
@@ -0,0 +1,49 @@
+package processor
+
+// RecoveryDrop is how far a week's volume must fall below the average of
+// the weeks before it to count as a recovery week
+const RecoveryDrop = 0.4
+
+// recoveryLookback is the number of earlier weeks averaged when detecting a
+// recovery week
+const recoveryLookback = 3
+
+// WeekPhase is the role of a week in a training cycle
+type WeekPhase int
+
+const (
+	PhaseOff     WeekPhase = iota // No training, and none in the weeks before
+	PhaseBuild                    // Regular or increasing volume
+	PhaseRecover                  // Volume dropped by more than RecoveryDrop
+)
+
+// ClassifyWeeks labels consecutive weekly training volumes as build or
+// recovery weeks. A week is a recovery week when its volume is more than
+// RecoveryDrop below the average of up to three weeks before it, so a
+// build/build/build/recover cycle shows its fourth week as recovery.
+func ClassifyWeeks(volumes []float64) []WeekPhase {
+	phases := make([]WeekPhase, len(volumes))
+	for i, volume := range volumes {
+		// Average the available earlier weeks
+		first := max(0, i-recoveryLookback)
+		total := 0.0
+		for _, earlier := range volumes[first:i] {
+			total += earlier
+		}
+
+		average := 0.0
+		if i > first {
+			average = total / float64(i-first)
+		}
+
+		switch {
+		case average > 0 && volume < average*(1-RecoveryDrop):
+			phases[i] = PhaseRecover
+		case volume > 0:
+			phases[i] = PhaseBuild
+		default:
+			phases[i] = PhaseOff
+		}
+	}
+	return phases
+}
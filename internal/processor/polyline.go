@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DecodePolyline decodes a route in Google's encoded polyline format, as
+// used for Strava's summary polylines, into latitude and longitude pairs
+func DecodePolyline(encoded string) ([][]float64, error) {
+	var points [][]float64
+	var lat, lng int
+
+	for i := 0; i < len(encoded); {
+		// Each point is a latitude and longitude delta from the last one
+		var deltas [2]int
+		for d := range deltas {
+			var result, shift int
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("polyline ends in the middle of a point")
+				}
+				b := int(encoded[i]) - 63
+				i++
+				if b < 0 || b > 63 {
+					return nil, fmt.Errorf("invalid polyline character %q", encoded[i-1])
+				}
+
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+
+			// The lowest bit holds the sign
+			if result&1 != 0 {
+				deltas[d] = ^(result >> 1)
+			} else {
+				deltas[d] = result >> 1
+			}
+		}
+
+		lat += deltas[0]
+		lng += deltas[1]
+		points = append(points, []float64{float64(lat) / 1e5, float64(lng) / 1e5})
+	}
+
+	return points, nil
+}
+
+// EncodePolyline encodes latitude and longitude pairs in Google's encoded
+// polyline format, the reverse of DecodePolyline
+func EncodePolyline(points [][]float64) string {
+	var sb strings.Builder
+	var lat, lng int
+
+	for _, point := range points {
+		next := [2]int{int(math.Round(point[0] * 1e5)), int(math.Round(point[1] * 1e5))}
+		for _, delta := range [2]int{next[0] - lat, next[1] - lng} {
+			// The lowest bit holds the sign
+			value := delta << 1
+			if delta < 0 {
+				value = ^value
+			}
+			for value >= 0x20 {
+				sb.WriteByte(byte(0x20|value&0x1f) + 63)
+				value >>= 5
+			}
+			sb.WriteByte(byte(value) + 63)
+		}
+		lat, lng = next[0], next[1]
+	}
+
+	return sb.String()
+}
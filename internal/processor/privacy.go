@@ -0,0 +1,44 @@
+package processor
+
+import "github.com/samuellee/StravaGraph/internal/strava"
+
+// PrivacyZone is a circle, such as around home or work, inside which
+// activities never reveal where they started, ended or went
+type PrivacyZone struct {
+	Lat    float64
+	Lng    float64
+	Radius float64 // In meters
+}
+
+// Contains reports whether a coordinate lies inside the zone
+func (z PrivacyZone) Contains(latlng []float64) bool {
+	if len(latlng) < 2 {
+		return false
+	}
+	return greatCircleDistance(z.Lat, z.Lng, latlng[0], latlng[1])*1000 <= z.Radius
+}
+
+// ScrubLocations returns a copy of the activities in which those starting or
+// ending inside any zone have their coordinates and route removed. The
+// originals are left untouched, since sunrise and sunset times still need
+// them, so only location and route rendering should use the copy.
+func ScrubLocations(activities []strava.SummaryActivity, zones []PrivacyZone) []strava.SummaryActivity {
+	if len(zones) == 0 {
+		return activities
+	}
+
+	scrubbed := make([]strava.SummaryActivity, len(activities))
+	copy(scrubbed, activities)
+	for i := range scrubbed {
+		activity := &scrubbed[i]
+		for _, zone := range zones {
+			if zone.Contains(activity.StartLatlng) || zone.Contains(activity.EndLatlng) {
+				activity.StartLatlng = nil
+				activity.EndLatlng = nil
+				activity.Map.SummaryPolyline = ""
+				break
+			}
+		}
+	}
+	return scrubbed
+}
@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"sort"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// scalePercentile is the percentile of active days that scores 1 on each
+// metric, so a single epic day doesn't flatten every other day's score
+const scalePercentile = 0.95
+
+// Scorer blends several metrics into one composite score per day. Each
+// metric is normalized against the reference days before weighting, so a
+// weight says how much a metric matters regardless of its units.
+type Scorer struct {
+	Weights map[string]float64 // Metric type to its weight, need not sum to 1
+	Scales  map[string]float64 // Metric type to the value scoring 1
+}
+
+// NewScorer creates a scorer normalizing each weighted metric against the
+// given days
+func NewScorer(weights map[string]float64, days []*strava.DailyActivity) *Scorer {
+	scales := make(map[string]float64, len(weights))
+	for metricType := range weights {
+		var values []float64
+		for _, day := range days {
+			if day.Count == 0 {
+				continue
+			}
+			if value := MetricValue(day, metricType); value > 0 {
+				values = append(values, value)
+			}
+		}
+		scales[metricType] = percentileValue(values, scalePercentile)
+	}
+
+	return &Scorer{
+		Weights: weights,
+		Scales:  scales,
+	}
+}
+
+// Score returns a day's composite score between 0 and 1: the weighted mean
+// of its normalized metrics, each capped at 1
+func (s *Scorer) Score(day *strava.DailyActivity) float64 {
+	if day.Count == 0 {
+		return 0
+	}
+
+	var score, totalWeight float64
+	for metricType, weight := range s.Weights {
+		totalWeight += weight
+
+		scale := s.Scales[metricType]
+		if scale <= 0 {
+			continue
+		}
+		score += weight * min(MetricValue(day, metricType)/scale, 1)
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+	return score / totalWeight
+}
+
+// ScoreAll sets the composite score of every day
+func (s *Scorer) ScoreAll(days map[string]*strava.DailyActivity) {
+	for _, day := range days {
+		day.CompositeScore = s.Score(day)
+	}
+}
+
+// percentileValue returns the value at percentile p (0 to 1) of values, or
+// 0 if there are none
+func percentileValue(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
@@ -0,0 +1,209 @@
+package processor
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// scoredDays are active days with distinct distances, durations and climbs
+// in different orders, and a rest day
+func scoredDays() []*strava.DailyActivity {
+	days := make([]*strava.DailyActivity, 0, 21)
+	for i := 0; i < 20; i++ {
+		days = append(days, &strava.DailyActivity{
+			Date:           date(2024, 3, 1+i),
+			Count:          1,
+			TotalDistance:  float64(1000 * (i + 1)),
+			TotalDuration:  600 * (20 - i),
+			TotalElevation: float64((i * 7) % 20 * 10),
+		})
+	}
+	return append(days, &strava.DailyActivity{Date: date(2024, 3, 21)})
+}
+
+// almostEqual compares scores, which are sums of fractions
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestScoreNormalizesWeights(t *testing.T) {
+	days := scoredDays()
+
+	tests := []struct {
+		name            string
+		weights, scaled map[string]float64 // scaled gives the same blend in other units
+	}{
+		{
+			name:    "weights summing to 1",
+			weights: map[string]float64{"distance": 0.5, "duration": 0.3, "elevation": 0.2},
+			scaled:  map[string]float64{"distance": 5, "duration": 3, "elevation": 2},
+		},
+		{
+			name:    "equal weights",
+			weights: map[string]float64{"distance": 1, "duration": 1},
+			scaled:  map[string]float64{"distance": 0.01, "duration": 0.01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := NewScorer(tt.weights, days)
+			scaled := NewScorer(tt.scaled, days)
+
+			for _, day := range days {
+				score := scorer.Score(day)
+				if score < 0 || score > 1 {
+					t.Errorf("%s scores %g, want between 0 and 1", day.Date.Format("2006-01-02"), score)
+				}
+				if other := scaled.Score(day); !almostEqual(score, other) {
+					t.Errorf("%s scores %g with weights %v and %g with %v", day.Date.Format("2006-01-02"), score, tt.weights, other, tt.scaled)
+				}
+			}
+
+			// The busiest day on every metric scores 1
+			best := &strava.DailyActivity{Count: 1, TotalDistance: 1e6, TotalDuration: 1e6, TotalElevation: 1e6}
+			if score := scorer.Score(best); !almostEqual(score, 1) {
+				t.Errorf("a day beyond every scale scores %g, want 1", score)
+			}
+		})
+	}
+}
+
+func TestScoreScalesEachMetric(t *testing.T) {
+	days := scoredDays()
+	scorer := NewScorer(map[string]float64{"distance": 0.5, "duration": 0.3, "elevation": 0.2}, days)
+
+	// The 95th percentile of 20 values is the 19th lowest
+	want := map[string]float64{"distance": 19000, "duration": 11400, "elevation": 180}
+	for metricType, scale := range want {
+		if scorer.Scales[metricType] != scale {
+			t.Errorf("scale of %s = %g, want %g", metricType, scorer.Scales[metricType], scale)
+		}
+	}
+
+	day := days[9] // 10 km in 6600 s climbing 30 m
+	wantScore := 0.5*10000/19000 + 0.3*6600/11400 + 0.2*30/180
+	if score := scorer.Score(day); !almostEqual(score, wantScore) {
+		t.Errorf("score = %g, want %g", score, wantScore)
+	}
+}
+
+func TestScoreZeroAndMissingMetrics(t *testing.T) {
+	days := scoredDays()
+	weights := map[string]float64{"distance": 0.5, "heart_rate": 0.5}
+	scorer := NewScorer(weights, days)
+
+	// No day has a heart rate, so it can't be scaled and scores nothing,
+	// while still counting towards the total weight
+	if scale := scorer.Scales["heart_rate"]; scale != 0 {
+		t.Errorf("scale of heart_rate = %g, want 0", scale)
+	}
+	for _, day := range days[:20] {
+		want := 0.5 * min(day.TotalDistance/scorer.Scales["distance"], 1)
+		if score := scorer.Score(day); !almostEqual(score, want) {
+			t.Errorf("%s scores %g, want %g", day.Date.Format("2006-01-02"), score, want)
+		}
+	}
+
+	tests := []struct {
+		name string
+		day  *strava.DailyActivity
+		want float64
+	}{
+		{"rest day", days[20], 0},
+		{"rest day with stray values", &strava.DailyActivity{TotalDistance: 5000, AvgHeartRate: 150}, 0},
+		{"activity without distance", &strava.DailyActivity{Count: 1, TotalDuration: 1800}, 0},
+		{"heart rate without a scale", &strava.DailyActivity{Count: 1, AvgHeartRate: 150}, 0},
+	}
+	for _, tt := range tests {
+		if score := scorer.Score(tt.day); score != tt.want {
+			t.Errorf("%s scores %g, want %g", tt.name, score, tt.want)
+		}
+	}
+
+	// With no reference days at all, nothing can be scaled
+	empty := NewScorer(weights, nil)
+	if score := empty.Score(days[0]); score != 0 {
+		t.Errorf("score without reference days = %g, want 0", score)
+	}
+}
+
+func TestScoreSingleWeightMatchesMetric(t *testing.T) {
+	days := scoredDays()
+
+	for _, metricType := range []string{"distance", "duration", "elevation"} {
+		t.Run(metricType, func(t *testing.T) {
+			// Other metrics weighted zero change nothing
+			weights := map[string]float64{"distance": 0, "duration": 0, "elevation": 0}
+			weights[metricType] = 2
+			scorer := NewScorer(weights, days)
+
+			scale := scorer.Scales[metricType]
+			for _, day := range days {
+				want := 0.0
+				if day.Count > 0 {
+					want = min(MetricValue(day, metricType)/scale, 1)
+				}
+				if score := scorer.Score(day); !almostEqual(score, want) {
+					t.Errorf("%s scores %g, want %s/%g = %g", day.Date.Format("2006-01-02"), score, metricType, scale, want)
+				}
+			}
+
+			// Days rank the same by score as by the plain metric, up to the cap
+			active := append([]*strava.DailyActivity(nil), days[:20]...)
+			sort.SliceStable(active, func(i, j int) bool {
+				return MetricValue(active[i], metricType) < MetricValue(active[j], metricType)
+			})
+			for i := 1; i < len(active); i++ {
+				if scorer.Score(active[i]) < scorer.Score(active[i-1]) {
+					t.Errorf("%s outranks %s on %s but scores lower", active[i].Date.Format("2006-01-02"), active[i-1].Date.Format("2006-01-02"), metricType)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreAllSetsCompositeMetric(t *testing.T) {
+	days := scoredDays()
+	scorer := NewScorer(map[string]float64{"distance": 1}, days)
+
+	byDate := make(map[string]*strava.DailyActivity)
+	for _, day := range days {
+		byDate[day.Date.Format("2006-01-02")] = day
+	}
+	scorer.ScoreAll(byDate)
+
+	for key, day := range byDate {
+		if got, want := MetricValue(day, "composite"), scorer.Score(day); got != want {
+			t.Errorf("%s has composite %g, want %g", key, got, want)
+		}
+	}
+}
+
+func TestScoreInvalidWeights(t *testing.T) {
+	days := scoredDays()
+
+	// The validator rejects these, and the scorer stays defined
+	tests := []struct {
+		name    string
+		weights map[string]float64
+	}{
+		{"no weights", nil},
+		{"all zero", map[string]float64{"distance": 0, "duration": 0}},
+		{"cancelling out", map[string]float64{"distance": 1, "duration": -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := NewScorer(tt.weights, days)
+			for _, day := range days {
+				if score := scorer.Score(day); score != 0 {
+					t.Errorf("%s scores %g, want 0", day.Date.Format("2006-01-02"), score)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// PeriodSnapshot holds the raw totals of a period for the stats file
+type PeriodSnapshot struct {
+	Start           string         `json:"start"` // YYYY-MM-DD
+	End             string         `json:"end"`   // YYYY-MM-DD
+	DistanceMeters  float64        `json:"distanceMeters"`
+	DurationSeconds int            `json:"durationSeconds"`
+	ElevationMeters float64        `json:"elevationMeters"`
+	Activities      int            `json:"activities"`
+	ActiveDays      int            `json:"activeDays"`
+	Types           map[string]int `json:"types"` // Activities of each type
+}
+
+// StatsSnapshot is the athlete's latest training numbers, written as JSON so
+// other tools can read them from the repository. Values are unformatted and
+// in SI units regardless of language.
+type StatsSnapshot struct {
+	AsOf          string         `json:"asOf"` // Date the stats were computed, YYYY-MM-DD
+	Range         PeriodSnapshot `json:"range"`
+	YearToDate    PeriodSnapshot `json:"yearToDate"`
+	CurrentStreak int            `json:"currentStreak"`          // Consecutive active days ending today or yesterday
+	LongestStreak int            `json:"longestStreak"`          // Longest streak within the range
+	LastActivity  string         `json:"lastActivity,omitempty"` // YYYY-MM-DD, omitted if there are no activities
+}
+
+// NewStatsSnapshot computes the stats over the displayed range and the year
+// so far. Only the date of now is recorded, so the file only changes when
+// the numbers or the day do.
+func NewStatsSnapshot(aggregator *ActivityAggregator, start, end, now time.Time) *StatsSnapshot {
+	today := CivilDate(now)
+	yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	displayed := aggregator.GetOrderedDates(start, end)
+
+	snapshot := &StatsSnapshot{
+		AsOf:          today.Format("2006-01-02"),
+		Range:         newPeriodSnapshot(displayed, start, end),
+		YearToDate:    newPeriodSnapshot(aggregator.GetOrderedDates(yearStart, today), yearStart, today),
+		CurrentStreak: currentStreak(aggregator, today),
+		LongestStreak: NewMetricsCalculator(displayed, start, end).CalculateOverallStats().LongestStreak,
+	}
+	if last := lastActivityDate(aggregator); !last.IsZero() {
+		snapshot.LastActivity = last.Format("2006-01-02")
+	}
+
+	return snapshot
+}
+
+// newPeriodSnapshot totals the days between start and end
+func newPeriodSnapshot(days []*strava.DailyActivity, start, end time.Time) PeriodSnapshot {
+	totals := SumPeriod(days)
+	return PeriodSnapshot{
+		Start:           start.Format("2006-01-02"),
+		End:             end.Format("2006-01-02"),
+		DistanceMeters:  math.Round(totals.Distance*10) / 10,
+		DurationSeconds: totals.Duration,
+		ElevationMeters: math.Round(totals.Elevation*10) / 10,
+		Activities:      totals.Activities,
+		ActiveDays:      totals.ActiveDays,
+		Types:           totals.Types,
+	}
+}
+
+// Write saves the snapshot as indented JSON, creating its directory if needed
+func (s *StatsSnapshot) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling stats: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating stats directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing stats file: %w", err)
+	}
+
+	return nil
+}
+
+// StatOutputs returns key stats over the displayed range, keyed by the name
+// of the Actions output they are set as. Values are unformatted so later
+// workflow steps can compare or template them: the total distance in
+// kilometers, active days, the current streak and the effort score.
+func StatOutputs(aggregator *ActivityAggregator, start, end, now time.Time) map[string]string {
+	displayed := aggregator.GetOrderedDates(start, end)
+	totals := SumPeriod(displayed)
+	effort := NewMetricsCalculator(displayed, start, end).CalculateEffortScore()
+
+	return map[string]string{
+		"total-distance": strconv.FormatFloat(math.Round(totals.Distance/100)/10, 'f', -1, 64),
+		"active-days":    strconv.Itoa(totals.ActiveDays),
+		"current-streak": strconv.Itoa(currentStreak(aggregator, CivilDate(now))),
+		"effort-score":   strconv.FormatFloat(effort, 'f', -1, 64),
+	}
+}
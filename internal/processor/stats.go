@@ -13,15 +13,19 @@ type StatsGenerator struct {
 	StartDate  time.Time
 	EndDate    time.Time
 	MetricType string
+	Language   string
+	Units      string // "imperial" or metric, for top day values and the pace
 }
 
 // NewStatsGenerator creates a new stats generator
-func NewStatsGenerator(dailyData []*strava.DailyActivity, startDate, endDate time.Time, metricType string) *StatsGenerator {
+func NewStatsGenerator(dailyData []*strava.DailyActivity, startDate, endDate time.Time, metricType, language, units string) *StatsGenerator {
 	return &StatsGenerator{
 		DailyData:  dailyData,
 		StartDate:  startDate,
 		EndDate:    endDate,
 		MetricType: metricType,
+		Language:   language,
+		Units:      units,
 	}
 }
 
@@ -51,11 +55,17 @@ func (sg *StatsGenerator) GenerateStats() map[string]interface{} {
 	// Activity type breakdown
 	stats["activityBreakdown"] = sg.getActivityTypeBreakdown()
 
+	// Average pace in the units of the dominant activity type
+	stats["pace"] = sg.getAveragePace()
+
+	// Weekly energy totals
+	stats["weeklyEnergy"] = sg.getWeeklyEnergy(calculator)
+
 	// Time period metadata
 	stats["timePeriod"] = map[string]interface{}{
 		"start":     sg.StartDate.Format("2006-01-02"),
 		"end":       sg.EndDate.Format("2006-01-02"),
-		"totalDays": int(sg.EndDate.Sub(sg.StartDate).Hours()/24) + 1,
+		"totalDays": DaysBetween(sg.StartDate, sg.EndDate) + 1,
 	}
 
 	return stats
@@ -77,23 +87,7 @@ func (sg *StatsGenerator) getTopDays(n int) []map[string]interface{} {
 			continue
 		}
 
-		var value float64
-		switch sg.MetricType {
-		case "distance":
-			value = day.TotalDistance / 1000 // km
-		case "duration":
-			value = float64(day.TotalDuration) / 3600 // hours
-		case "elevation":
-			value = day.TotalElevation
-		case "heart_rate":
-			value = day.AvgHeartRate
-		case "effort":
-			if day.TotalDuration > 0 {
-				value = (day.TotalDistance * (1 + day.TotalElevation/100)) / float64(day.TotalDuration)
-			}
-		default:
-			value = float64(day.Count)
-		}
+		value := MetricDisplayValue(TotalValue(day, sg.MetricType), sg.MetricType, sg.Units)
 
 		days = append(days, dayData{day, value})
 	}
@@ -110,17 +104,7 @@ func (sg *StatsGenerator) getTopDays(n int) []map[string]interface{} {
 
 		// Format the value based on metric type
 		formattedValue := day.value
-		unit := ""
-		switch sg.MetricType {
-		case "distance":
-			unit = "km"
-		case "duration":
-			unit = "hours"
-		case "elevation":
-			unit = "m"
-		case "heart_rate":
-			unit = "bpm"
-		}
+		unit := MetricUnit(sg.MetricType, sg.Units)
 
 		topDay := map[string]interface{}{
 			"date":          day.day.Date.Format("2006-01-02"),
@@ -139,6 +123,55 @@ func (sg *StatsGenerator) getTopDays(n int) []map[string]interface{} {
 	return result
 }
 
+// getWeeklyEnergy returns the average and peak weekly energy in kcal
+func (sg *StatsGenerator) getWeeklyEnergy(calculator *MetricsCalculator) map[string]float64 {
+	var total, peak float64
+	for _, week := range calculator.CalculatePeriodStats("weekly") {
+		total += week.TotalEnergy
+		if week.TotalEnergy > peak {
+			peak = week.TotalEnergy
+		}
+	}
+
+	// Average over every week in the range, not just active ones
+	weeks := float64(DaysBetween(sg.StartDate, sg.EndDate)+1) / 7
+	average := 0.0
+	if weeks >= 1 {
+		average = total / weeks
+	} else {
+		average = total
+	}
+
+	return map[string]float64{
+		"average": average,
+		"peak":    peak,
+	}
+}
+
+// getAveragePace returns the overall pace formatted with the unit rule of the
+// dominant activity type, or an empty string if that type has no pace
+func (sg *StatsGenerator) getAveragePace() string {
+	types := make(map[string]int)
+	for _, day := range sg.DailyData {
+		for t, count := range day.Types {
+			types[t] += count
+		}
+	}
+	dominant := DominantType(types)
+
+	// Only include days dominated by the same type so paces aren't mixed
+	var distance float64
+	var duration int
+	for _, day := range sg.DailyData {
+		if day.Count > 0 && DominantType(day.Types) == dominant {
+			distance += day.TotalDistance
+			duration += day.TotalDuration
+		}
+	}
+
+	return GetUnitRule(dominant, sg.Language, sg.Units).FormatPace(distance, duration)
+}
+
 // getActivityTypeBreakdown returns the breakdown of activity types
 func (sg *StatsGenerator) getActivityTypeBreakdown() map[string]interface{} {
 	typeCounts := make(map[string]int)
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"math"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// sunsetElevation is the solar elevation in degrees at sunrise and sunset,
+// when the sun's upper limb touches the horizon after refraction
+const sunsetElevation = -0.833
+
+// DarkStart reports whether an activity started with the sun below the
+// horizon at its start coordinates, and if so whether that was before dawn
+// rather than after dusk. Activities without coordinates are never dark.
+func DarkStart(activity strava.SummaryActivity) (dark, beforeDawn bool) {
+	if len(activity.StartLatlng) < 2 {
+		return false, false
+	}
+
+	elevation, hourAngle := solarPosition(activity.StartDate, activity.StartLatlng[0], activity.StartLatlng[1])
+	if elevation >= sunsetElevation {
+		return false, false
+	}
+
+	// The hour angle is negative before solar noon
+	return true, hourAngle < 0
+}
+
+// solarPosition returns the sun's elevation above the horizon and its hour
+// angle, both in degrees, at an instant and position. It follows NOAA's
+// solar calculator, so it tracks the length of the day through the year
+// and at any latitude.
+func solarPosition(t time.Time, lat, lng float64) (elevation, hourAngle float64) {
+	t = t.UTC()
+	rad := math.Pi / 180
+
+	// Julian century since J2000
+	jd := float64(t.Unix())/86400 + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	ecc := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+
+	center := math.Sin(meanAnom*rad)*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(2*meanAnom*rad)*(0.019993-0.000101*jc) +
+		math.Sin(3*meanAnom*rad)*0.000289
+	omega := (125.04 - 1934.136*jc) * rad
+	appLong := meanLong + center - 0.00569 - 0.00478*math.Sin(omega)
+
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := (meanObliq + 0.00256*math.Cos(omega)) * rad
+
+	declination := math.Asin(math.Sin(obliq) * math.Sin(appLong*rad))
+
+	// Equation of time in minutes
+	y := math.Pow(math.Tan(obliq/2), 2)
+	eqTime := 4 / rad * (y*math.Sin(2*meanLong*rad) -
+		2*ecc*math.Sin(meanAnom*rad) +
+		4*ecc*y*math.Sin(meanAnom*rad)*math.Cos(2*meanLong*rad) -
+		0.5*y*y*math.Sin(4*meanLong*rad) -
+		1.25*ecc*ecc*math.Sin(2*meanAnom*rad))
+
+	minutes := float64(t.Hour()*60+t.Minute()) + float64(t.Second())/60
+	solarTime := math.Mod(minutes+eqTime+4*lng, 1440)
+	if solarTime < 0 {
+		solarTime += 1440
+	}
+	hourAngle = solarTime/4 - 180
+
+	cosZenith := math.Sin(lat*rad)*math.Sin(declination) +
+		math.Cos(lat*rad)*math.Cos(declination)*math.Cos(hourAngle*rad)
+	zenith := math.Acos(math.Max(-1, math.Min(1, cosZenith))) / rad
+
+	return 90 - zenith, hourAngle
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// Tagger labels activities with config-defined tags whose keywords or
+// hashtags appear in their name or description
+type Tagger struct {
+	names    []string
+	patterns []*regexp.Regexp
+}
+
+// NewTagger compiles tags keyed by name to the keywords that mark them, e.g.
+// "race": ["#race", "parkrun"]. Keywords match whole words regardless of
+// case, so "#race" doesn't match "#racecar" and "tempo" doesn't match
+// "#tempo".
+func NewTagger(tags map[string][]string) *Tagger {
+	t := &Tagger{}
+
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var alternatives []string
+		for _, keyword := range tags[name] {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				alternatives = append(alternatives, regexp.QuoteMeta(keyword))
+			}
+		}
+		if len(alternatives) == 0 {
+			continue
+		}
+
+		pattern := `(?i)(?:^|[^\pL\pN#])(?:` + strings.Join(alternatives, "|") + `)(?:$|[^\pL\pN])`
+		t.names = append(t.names, name)
+		t.patterns = append(t.patterns, regexp.MustCompile(pattern))
+	}
+
+	return t
+}
+
+// Tags returns the tags of an activity in alphabetical order. Descriptions
+// are only known for activities fetched with details.
+func (t *Tagger) Tags(activity strava.SummaryActivity) []string {
+	if t == nil {
+		return nil
+	}
+
+	text := activity.Name + "\n" + activity.Description
+
+	var tags []string
+	for i, pattern := range t.patterns {
+		if pattern.MatchString(text) {
+			tags = append(tags, t.names[i])
+		}
+	}
+	return tags
+}
+
+// SumTags totals the tagged activities over a run of days
+func SumTags(days []*strava.DailyActivity) map[string]int {
+	totals := make(map[string]int)
+	for _, day := range days {
+		for tag, count := range day.Tags {
+			totals[tag] += count
+		}
+	}
+	return totals
+}
+
+// SortedTags returns tag names from most to least used, breaking ties
+// alphabetically
+func SortedTags(counts map[string]int) []string {
+	return sortedByCount(counts)
+}
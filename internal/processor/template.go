@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// privateValue stands in for the totals of private heatmaps, replacing any
+// exact value an earlier update filled in
+const privateValue = "–"
+
+// TemplateValues returns the values of the README placeholders, such as
+// {{strava.total_distance_ytd}}, keyed by variable name. Year-to-date
+// values only cover the activities that were fetched. Private heatmaps get
+// no distance, time, elevation or activity totals.
+func TemplateValues(aggregator *ActivityAggregator, start, end, now time.Time, language, units, durationStyle string, private bool) map[string]string {
+	today := CivilDate(now)
+	yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	ytd := SumPeriod(aggregator.GetOrderedDates(yearStart, today))
+	displayed := aggregator.GetOrderedDates(start, end)
+	inRange := SumPeriod(displayed)
+
+	// Distances follow the dominant type of the displayed range
+	rule := GetUnitRule(DominantType(inRange.Types), language, units)
+	nf := rule.Number
+	hours := func(seconds int) string {
+		if durationStyle == "" {
+			return nf.WithUnit(nf.FormatFloat(float64(seconds)/3600, 1), "h")
+		}
+		return FormatDuration(seconds, durationStyle, GetTranslation(language), nf)
+	}
+
+	values := map[string]string{
+		"total_distance_ytd":  rule.FormatDistance(ytd.Distance),
+		"total_time_ytd":      hours(ytd.Duration),
+		"total_elevation_ytd": rule.FormatElevation(ytd.Elevation),
+		"activities_ytd":      nf.FormatInt(ytd.Activities),
+		"active_days_ytd":     nf.FormatInt(ytd.ActiveDays),
+		"total_distance":      rule.FormatDistance(inRange.Distance),
+		"total_time":          hours(inRange.Duration),
+		"total_elevation":     rule.FormatElevation(inRange.Elevation),
+		"activities":          nf.FormatInt(inRange.Activities),
+		"active_days":         nf.FormatInt(inRange.ActiveDays),
+		"current_streak":      nf.FormatInt(currentStreak(aggregator, today)),
+		"longest_streak":      nf.FormatInt(NewMetricsCalculator(displayed, start, end).CalculateOverallStats().LongestStreak),
+		"last_activity":       "never",
+		"updated":             now.Format("Jan 2, 2006"),
+	}
+
+	if last := lastActivityDate(aggregator); !last.IsZero() {
+		values["last_activity"] = last.Format("Jan 2, 2006")
+	}
+
+	if private {
+		for _, name := range []string{"total_distance", "total_time", "total_elevation", "activities", "active_days"} {
+			values[name] = privateValue
+			values[name+"_ytd"] = privateValue
+		}
+	}
+
+	return values
+}
+
+// AltText describes the heatmap for the alt text of its image, e.g.
+// "Strava heatmap: 212 active days, 2,400 km in 2024". Private heatmaps
+// leave out the totals.
+func AltText(aggregator *ActivityAggregator, start, end time.Time, language, units string, private bool) string {
+	tr := GetTranslation(language)
+	period := tr.T("from %s to %s", tr.FormatMonthYear(start), tr.FormatMonthYear(end))
+	if start.Year() == end.Year() {
+		period = tr.T("in %s", strconv.Itoa(start.Year()))
+	}
+
+	if private {
+		return tr.T("Strava activity heatmap %s", period)
+	}
+
+	totals := SumPeriod(aggregator.GetOrderedDates(start, end))
+	if totals.ActiveDays == 0 {
+		return tr.T("Strava heatmap: no activities %s", period)
+	}
+
+	// Whole units read better than the precision of a single activity
+	rule := GetUnitRule(DominantType(totals.Types), language, units)
+	nf := rule.Number
+	distance := nf.WithUnit(nf.FormatFloat(rule.ConvertDistance(totals.Distance), 0), rule.DistanceUnit)
+	days := tr.Plural(totals.ActiveDays, "%s active day", "%s active days", nf)
+
+	return tr.T("Strava heatmap: %s, %s %s", days, distance, period)
+}
+
+// firstActivityDate returns the earliest day with an activity, or the zero
+// time if there are none
+func firstActivityDate(aggregator *ActivityAggregator) time.Time {
+	var first time.Time
+	for _, day := range aggregator.DailyData {
+		if day.Count > 0 && (first.IsZero() || day.Date.Before(first)) {
+			first = day.Date
+		}
+	}
+	return first
+}
+
+// lastActivityDate returns the most recent day with an activity, or the zero
+// time if there are none
+func lastActivityDate(aggregator *ActivityAggregator) time.Time {
+	var last time.Time
+	for _, day := range aggregator.DailyData {
+		if day.Count > 0 && day.Date.After(last) {
+			last = day.Date
+		}
+	}
+	return last
+}
+
+// currentStreak counts the consecutive active days ending today, or
+// yesterday if there's been no activity yet today
+func currentStreak(aggregator *ActivityAggregator, today time.Time) int {
+	first := firstActivityDate(aggregator)
+	if first.IsZero() || first.After(today) {
+		return 0
+	}
+	return CurrentStreak(aggregator.GetOrderedDates(first, today))
+}
+
+// CurrentStreak counts the consecutive active days ending with the last of
+// the ordered days, or the day before if the last has no activity yet
+func CurrentStreak(days []*strava.DailyActivity) int {
+	end := len(days)
+	if end > 0 && days[end-1].Count == 0 {
+		end--
+	}
+
+	streak := 0
+	for i := end - 1; i >= 0 && days[i].Count > 0; i-- {
+		streak++
+	}
+	return streak
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"math"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// startTimeBandwidth is the spread, in hours, each start time is smoothed
+// over when estimating a month's distribution
+const startTimeBandwidth = 0.75
+
+// MonthStartTimes is when in the day a month's activities started
+type MonthStartTimes struct {
+	Month time.Time // First day of the month
+	Hours []float64 // Start of each activity, in hours after midnight
+}
+
+// StartTimesByMonth groups the start times of activities in the given
+// timezone by calendar month, for every month from start to end including
+// those without activities
+func StartTimesByMonth(activities []strava.SummaryActivity, location *time.Location, start, end time.Time) []MonthStartTimes {
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var months []MonthStartTimes
+	index := make(map[time.Time]int)
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		index[month] = len(months)
+		months = append(months, MonthStartTimes{Month: month})
+	}
+
+	for _, activity := range activities {
+		local := activity.StartDate.In(location)
+		if CivilDate(local).Before(CivilDate(start)) || CivilDate(local).After(CivilDate(end)) {
+			continue
+		}
+
+		i, ok := index[time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC)]
+		if !ok {
+			continue
+		}
+		hours := float64(local.Hour()) + float64(local.Minute())/60
+		months[i].Hours = append(months[i].Hours, hours)
+	}
+
+	return months
+}
+
+// Density estimates how starts spread over the day at evenly spaced points
+// from midnight to midnight, smoothing each start time with a Gaussian
+// kernel that wraps around midnight. Values are scaled so the peak is 1, and
+// are all 0 for a month without activities.
+func (m MonthStartTimes) Density(points int) []float64 {
+	density := make([]float64, points)
+	if len(m.Hours) == 0 || points < 2 {
+		return density
+	}
+
+	peak := 0.0
+	for i := range density {
+		at := 24 * float64(i) / float64(points-1)
+		for _, hours := range m.Hours {
+			distance := math.Abs(at - hours)
+			distance = math.Min(distance, 24-distance)
+			density[i] += math.Exp(-distance * distance / (2 * startTimeBandwidth * startTimeBandwidth))
+		}
+		peak = math.Max(peak, density[i])
+	}
+
+	for i := range density {
+		density[i] /= peak
+	}
+	return density
+}
+
+// PeakHour returns the hour of the day in which most of the month's
+// activities started, the earliest on a tie
+func (m MonthStartTimes) PeakHour() int {
+	var counts [24]int
+	for _, hours := range m.Hours {
+		counts[int(hours)]++
+	}
+
+	peak := 0
+	for hour, count := range counts {
+		if count > counts[peak] {
+			peak = hour
+		}
+	}
+	return peak
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+const (
+	fitnessDays = 42 // Time constant of chronic training load, in days
+	fatigueDays = 7  // Time constant of acute training load, in days
+)
+
+const (
+	// loadHeartRate is the average heart rate, in bpm, at which a minute of
+	// activity counts as one unit of load
+	loadHeartRate = 140.0
+
+	// loadMinutesPerKm is the pace credited to activities with a distance
+	// but no duration
+	loadMinutesPerKm = 6.0
+)
+
+// TrainingLoad is the fitness, fatigue and form at the end of one day
+type TrainingLoad struct {
+	Date    time.Time
+	Load    float64 // The day's own load
+	Fitness float64 // Chronic training load (CTL)
+	Fatigue float64 // Acute training load (ATL)
+	Form    float64 // Training stress balance (TSB), fitness less fatigue as of the day before
+}
+
+// DailyLoad scores the training load of a day from its duration, in minutes,
+// scaled by its average heart rate relative to loadHeartRate when recorded.
+// Days with a distance but no duration are credited at loadMinutesPerKm.
+func DailyLoad(day *strava.DailyActivity) float64 {
+	if day.Count == 0 {
+		return 0
+	}
+
+	minutes := float64(day.TotalDuration) / 60
+	if minutes == 0 {
+		minutes = day.TotalDistance / 1000 * loadMinutesPerKm
+	}
+	if day.AvgHeartRate > 0 {
+		return minutes * day.AvgHeartRate / loadHeartRate
+	}
+	return minutes
+}
+
+// TrainingLoads returns the fitness, fatigue and form after each of the
+// ordered days. Fitness and fatigue are exponentially weighted moving
+// averages of the daily load over fitnessDays and fatigueDays, seeded from
+// the ordered days of history just before them; fitness needs a few times
+// fitnessDays of history to settle rather than read low from zero.
+func TrainingLoads(history, days []*strava.DailyActivity) []TrainingLoad {
+	var fitness, fatigue float64
+	for _, day := range history {
+		load := DailyLoad(day)
+		fitness += (load - fitness) / fitnessDays
+		fatigue += (load - fatigue) / fatigueDays
+	}
+
+	loads := make([]TrainingLoad, len(days))
+	for i, day := range days {
+		load := DailyLoad(day)
+		form := fitness - fatigue
+
+		fitness += (load - fitness) / fitnessDays
+		fatigue += (load - fatigue) / fatigueDays
+
+		loads[i] = TrainingLoad{
+			Date:    CivilDate(day.Date),
+			Load:    load,
+			Fitness: fitness,
+			Fatigue: fatigue,
+			Form:    form,
+		}
+	}
+	return loads
+}
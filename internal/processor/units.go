@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+)
+
+// Meters in the imperial units
+const (
+	metersPerMile = 1609.344
+	metersPerYard = 0.9144
+	metersPerFoot = 0.3048
+)
+
+// UnitRule describes how distance, pace and elevation are displayed for an
+// activity type
+type UnitRule struct {
+	DistanceUnit   string  // Unit label for distances, e.g. "km" or "m"
+	DistanceScale  float64 // Meters per distance unit
+	Precision      int     // Decimal places for distances
+	PaceUnit       string  // Unit label for pace, e.g. "/km" or "/100m"; empty if pace isn't shown
+	PaceDistance   float64 // Meters covered per pace unit
+	ElevationUnit  string  // Unit label for elevation, "m" or "ft"
+	ElevationScale float64 // Meters per elevation unit
+	Number         NumberFormat
+}
+
+// defaultUnitRule is used for activity types without a specific rule
+var defaultUnitRule = UnitRule{DistanceUnit: "km", DistanceScale: 1000, Precision: 1}
+
+// unitRules maps activity types to their display units
+var unitRules = map[string]UnitRule{
+	"Run":        {DistanceUnit: "km", DistanceScale: 1000, Precision: 1, PaceUnit: "/km", PaceDistance: 1000},
+	"VirtualRun": {DistanceUnit: "km", DistanceScale: 1000, Precision: 1, PaceUnit: "/km", PaceDistance: 1000},
+	"Walk":       {DistanceUnit: "km", DistanceScale: 1000, Precision: 1, PaceUnit: "/km", PaceDistance: 1000},
+	"Hike":       {DistanceUnit: "km", DistanceScale: 1000, Precision: 1, PaceUnit: "/km", PaceDistance: 1000},
+	"Swim":       {DistanceUnit: "m", DistanceScale: 1, Precision: 0, PaceUnit: "/100m", PaceDistance: 100},
+}
+
+// defaultImperialUnitRule is used in imperial units for activity types
+// without a specific rule
+var defaultImperialUnitRule = UnitRule{DistanceUnit: "mi", DistanceScale: metersPerMile, Precision: 1}
+
+// imperialUnitRules maps activity types to their display units in miles,
+// yards and feet
+var imperialUnitRules = map[string]UnitRule{
+	"Run":        {DistanceUnit: "mi", DistanceScale: metersPerMile, Precision: 1, PaceUnit: "/mi", PaceDistance: metersPerMile},
+	"VirtualRun": {DistanceUnit: "mi", DistanceScale: metersPerMile, Precision: 1, PaceUnit: "/mi", PaceDistance: metersPerMile},
+	"Walk":       {DistanceUnit: "mi", DistanceScale: metersPerMile, Precision: 1, PaceUnit: "/mi", PaceDistance: metersPerMile},
+	"Hike":       {DistanceUnit: "mi", DistanceScale: metersPerMile, Precision: 1, PaceUnit: "/mi", PaceDistance: metersPerMile},
+	"Swim":       {DistanceUnit: "yd", DistanceScale: metersPerYard, Precision: 0, PaceUnit: "/100yd", PaceDistance: 100 * metersPerYard},
+}
+
+// GetUnitRule returns the unit rule for an activity type in the given units,
+// "imperial" or else metric, formatting numbers according to the given
+// language
+func GetUnitRule(activityType, language, units string) UnitRule {
+	rule, ok := unitRules[activityType]
+	if !ok {
+		rule = defaultUnitRule
+	}
+	rule.ElevationUnit, rule.ElevationScale = "m", 1
+
+	if units == "imperial" {
+		rule, ok = imperialUnitRules[activityType]
+		if !ok {
+			rule = defaultImperialUnitRule
+		}
+		rule.ElevationUnit, rule.ElevationScale = "ft", metersPerFoot
+	}
+
+	rule.Number = GetNumberFormat(language)
+	return rule
+}
+
+// DominantType returns the most frequent activity type in a type count map.
+// Ties are broken alphabetically so the result is stable.
+func DominantType(types map[string]int) string {
+	dominant := ""
+	maxCount := 0
+	for t, count := range types {
+		if count > maxCount || (count == maxCount && t < dominant) {
+			dominant = t
+			maxCount = count
+		}
+	}
+	return dominant
+}
+
+// ConvertDistance converts meters to the rule's distance unit
+func (r UnitRule) ConvertDistance(meters float64) float64 {
+	return meters / r.DistanceScale
+}
+
+// FormatDistanceValue formats a distance in meters without its unit
+func (r UnitRule) FormatDistanceValue(meters float64) string {
+	return r.Number.FormatFloat(r.ConvertDistance(meters), r.Precision)
+}
+
+// FormatDistance formats a distance in meters with its unit
+func (r UnitRule) FormatDistance(meters float64) string {
+	return r.Number.WithUnit(r.FormatDistanceValue(meters), r.DistanceUnit)
+}
+
+// FormatElevation formats an elevation gain in meters, in whole units
+func (r UnitRule) FormatElevation(meters float64) string {
+	return r.Number.WithUnit(r.Number.FormatFloat(meters/r.ElevationScale, 0), r.ElevationUnit)
+}
+
+// FormatPace formats the pace for a distance covered in the given time,
+// returning an empty string if the rule has no pace or the pace is undefined
+func (r UnitRule) FormatPace(meters float64, seconds int) string {
+	if r.PaceUnit == "" || meters <= 0 || seconds <= 0 {
+		return ""
+	}
+
+	secondsPerUnit := int(math.Round(float64(seconds) / (meters / r.PaceDistance)))
+	return r.Number.WithUnit(fmt.Sprintf("%d:%02d", secondsPerUnit/60, secondsPerUnit%60), r.PaceUnit)
+}
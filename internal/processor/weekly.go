@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// WeekTotal is a metric's value over one ISO week
+type WeekTotal struct {
+	WeekStart time.Time // Monday of the week
+	Value     float64   // In the metric's raw units
+	Days      int       // Days of the week within the range
+}
+
+// WeeklyTotals groups ordered days into ISO weeks, starting on Monday, and
+// totals a metric over each like PeriodValue, counting only the distance
+// actually covered. Weeks with no activities are included with a zero value.
+func WeeklyTotals(days []*strava.DailyActivity, metricType string) []WeekTotal {
+	_, periods := groupByPeriod(days, "weekly")
+
+	weeks := make([]WeekTotal, len(periods))
+	for i, weekDays := range periods {
+		date := CivilDate(weekDays[0].Date)
+		weeks[i] = WeekTotal{
+			WeekStart: date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7)),
+			Value:     periodValue(weekDays, metricType, TotalValue),
+			Days:      len(weekDays),
+		}
+	}
+	return weeks
+}
+
+// PeriodValue combines a metric over several days into one value: their
+// total, or for metrics that are rates rather than amounts, such as heart
+// rate and power, the average over the active days. Variety counts the
+// distinct sports of the whole period.
+func PeriodValue(days []*strava.DailyActivity, metricType string) float64 {
+	return periodValue(days, metricType, MetricValue)
+}
+
+// periodValue combines the days' values of a metric as PeriodValue does,
+// reading each day's value with dayValue
+func periodValue(days []*strava.DailyActivity, metricType string, dayValue func(*strava.DailyActivity, string) float64) float64 {
+	averaged := metricType == "heart_rate" || metricType == "normalized_power" || metricType == "effort"
+
+	value := 0.0
+	activeDays := 0
+	types := make(map[string]bool)
+	for _, day := range days {
+		if day.Count == 0 {
+			continue
+		}
+
+		if metricType == "variety" {
+			for activityType := range day.Types {
+				types[activityType] = true
+			}
+			value = float64(len(types))
+			continue
+		}
+
+		if averaged {
+			activeDays++
+			value += (dayValue(day, metricType) - value) / float64(activeDays)
+		} else {
+			value += dayValue(day, metricType)
+		}
+	}
+	return value
+}
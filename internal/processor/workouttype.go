@@ -0,0 +1,40 @@
+package processor
+
+import "github.com/samuellee/StravaGraph/internal/strava"
+
+// Kinds of workout an athlete can mark an activity as in Strava
+const (
+	WorkoutRace    = "race"
+	WorkoutLongRun = "long_run"
+	WorkoutSession = "workout"
+	WorkoutOther   = "other" // Activities not marked as any of the above
+)
+
+// WorkoutKinds lists the workout kinds in display order
+var WorkoutKinds = []string{WorkoutRace, WorkoutLongRun, WorkoutSession, WorkoutOther}
+
+// WorkoutKind returns the kind of workout an activity is marked as, from
+// Strava's workout_type: 1 to 3 for runs and 11 and 12 for rides, with 0 and
+// 10 the unmarked defaults
+func WorkoutKind(activity strava.SummaryActivity) string {
+	switch activity.WorkoutType {
+	case 1, 11:
+		return WorkoutRace
+	case 2:
+		return WorkoutLongRun
+	case 3, 12:
+		return WorkoutSession
+	}
+	return WorkoutOther
+}
+
+// SumWorkouts totals the activities of each workout kind over a run of days
+func SumWorkouts(days []*strava.DailyActivity) map[string]int {
+	totals := make(map[string]int)
+	for _, day := range days {
+		for kind, count := range day.Workouts {
+			totals[kind] += count
+		}
+	}
+	return totals
+}
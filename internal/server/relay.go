@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/github"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// DefaultRelayDelay is how long the relay waits for further events before
+// dispatching, so an upload followed by a quick title edit starts one run
+const DefaultRelayDelay = time.Minute
+
+// maxPendingEvents caps the activities waiting for a dispatch. The run
+// fetches recent activities anyway, so events past the cap only lose the
+// edits and deletions of older activities until the next full sync.
+const maxPendingEvents = 100
+
+// Relay receives Strava webhook events and dispatches a GitHub workflow run
+// for them, so the README updates minutes after an activity without polling
+type Relay struct {
+	VerifyToken    string // Must match the verify_token given when subscribing
+	SubscriptionID int64  // Events from other subscriptions are rejected
+	OwnerID        int64  // Athlete whose events are relayed, others are rejected
+	Dispatcher     *github.Dispatcher
+	Delay          time.Duration
+	Debug          bool
+
+	mu      sync.Mutex
+	pending []strava.WebhookEvent // Events waiting for the next dispatch, one per activity
+	timer   *time.Timer
+}
+
+// NewRelay creates a webhook relay dispatching runs with dispatcher for the
+// events of one subscription and athlete
+func NewRelay(verifyToken string, subscriptionID, ownerID int64, dispatcher *github.Dispatcher, debug bool) *Relay {
+	return &Relay{
+		VerifyToken:    verifyToken,
+		SubscriptionID: subscriptionID,
+		OwnerID:        ownerID,
+		Dispatcher:     dispatcher,
+		Delay:          DefaultRelayDelay,
+		Debug:          debug,
+	}
+}
+
+// Handler returns the HTTP handler for the webhook callback URL
+func (r *Relay) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /webhook", r.handleVerify)
+	mux.HandleFunc("POST /webhook", r.handleEvent)
+	return mux
+}
+
+// handleVerify answers the challenge Strava sends when a subscription is
+// created
+func (r *Relay) handleVerify(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("hub.mode") != "subscribe" || query.Get("hub.verify_token") != r.VerifyToken {
+		http.Error(w, "Invalid verification request", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"hub.challenge": query.Get("hub.challenge")})
+}
+
+// handleEvent queues an activity event. Strava expects a reply within two
+// seconds, so the dispatch happens later in the background.
+func (r *Relay) handleEvent(w http.ResponseWriter, req *http.Request) {
+	var event strava.WebhookEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 64<<10)).Decode(&event); err != nil {
+		http.Error(w, "Invalid event", http.StatusBadRequest)
+		return
+	}
+
+	if r.Debug {
+		fmt.Printf("[DEBUG] Webhook event: %s %s %d\n", event.AspectType, event.ObjectType, event.ObjectID)
+	}
+
+	// The callback URL is public, so only events of our own subscription
+	// and athlete are passed on
+	if event.SubscriptionID != r.SubscriptionID || event.OwnerID != r.OwnerID {
+		http.Error(w, "Unknown subscription", http.StatusForbidden)
+		return
+	}
+
+	// Athlete events only report revoked access, which leaves nothing to redraw
+	if event.ObjectType == "activity" {
+		r.queue(event)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// queue adds an event to the next dispatch, starting the delay if it is the
+// first one waiting. The run looks each activity up again, so a later event
+// replaces an earlier one for the same activity.
+func (r *Relay) queue(event strava.WebhookEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := false
+	for i, pending := range r.pending {
+		if pending.ObjectID == event.ObjectID {
+			r.pending[i] = event
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		if len(r.pending) >= maxPendingEvents {
+			fmt.Printf("Warning: Dropping webhook event for activity %d, %d events are already waiting\n", event.ObjectID, len(r.pending))
+			return
+		}
+		r.pending = append(r.pending, event)
+	}
+
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.Delay, r.flush)
+	}
+}
+
+// flush dispatches a run for the queued events
+func (r *Relay) flush() {
+	r.mu.Lock()
+	events := r.pending
+	r.pending = nil
+	r.timer = nil
+	r.mu.Unlock()
+
+	if err := r.Dispatcher.Dispatch(map[string]interface{}{"events": events}); err != nil {
+		fmt.Printf("Error dispatching workflow for %d webhook events: %v\n", len(events), err)
+	}
+}
@@ -0,0 +1,399 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/auth"
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+	"github.com/samuellee/StravaGraph/internal/svg"
+)
+
+const (
+	// stateTTL is how long a /connect request has to complete the OAuth flow
+	stateTTL = 10 * time.Minute
+
+	// DefaultRenderTTL is how long a rendered heatmap is served before
+	// activities are fetched again
+	DefaultRenderTTL = time.Hour
+)
+
+// renderedHeatmap is a heatmap SVG cached in memory
+type renderedHeatmap struct {
+	SVG        string
+	RenderedAt time.Time
+}
+
+// Server serves heatmaps for every athlete who connected their Strava
+// account, rendered with a shared base configuration
+type Server struct {
+	Config    *config.Config
+	OAuth     *auth.OAuthConfig
+	Users     *UserStore
+	BaseURL   string // Public URL of the service, without a trailing slash
+	RenderTTL time.Duration
+	Store     ArtifactStore // Receives every rendered heatmap and its stats, nil to keep them in memory only
+	Debug     bool
+
+	mu       sync.Mutex
+	states   map[string]time.Time // Pending OAuth states and their expiry
+	rendered map[string]renderedHeatmap
+	locks    map[string]*sync.Mutex // One render at a time per user
+}
+
+// NewServer creates a new heatmap server
+func NewServer(cfg *config.Config, oauth *auth.OAuthConfig, users *UserStore, baseURL string, debug bool) *Server {
+	return &Server{
+		Config:    cfg,
+		OAuth:     oauth,
+		Users:     users,
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		RenderTTL: DefaultRenderTTL,
+		Debug:     debug,
+		states:    make(map[string]time.Time),
+		rendered:  make(map[string]renderedHeatmap),
+		locks:     make(map[string]*sync.Mutex),
+	}
+}
+
+// Handler returns the HTTP handler for the service
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /connect", s.handleConnect)
+	mux.HandleFunc("GET /callback", s.handleCallback)
+	mux.HandleFunc("GET /u/{slug}/heatmap.svg", s.handleHeatmap)
+	return mux
+}
+
+// handleIndex shows a short landing page linking to /connect
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html><head><title>Strava Heatmap</title></head>
+<body>
+<h1>Strava Heatmap</h1>
+<p>Connect your Strava account to get a contribution-style heatmap you can embed in your GitHub profile.</p>
+<p><a href="/connect">Connect with Strava</a></p>
+</body></html>`)
+}
+
+// handleConnect starts the OAuth flow
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	state, err := s.newState()
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError, "Failed to start authorization", err)
+		return
+	}
+
+	http.Redirect(w, r, s.OAuth.GetAuthorizationURL(state), http.StatusFound)
+}
+
+// handleCallback completes the OAuth flow and stores the athlete's tokens
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if !s.consumeState(query.Get("state")) {
+		s.fail(w, http.StatusBadRequest, "Authorization expired or was not started here, please connect again", nil)
+		return
+	}
+
+	if errParam := query.Get("error"); errParam != "" {
+		s.fail(w, http.StatusBadRequest, "Authorization was denied", fmt.Errorf("%s", errParam))
+		return
+	}
+
+	// Strava reports the scopes the athlete actually granted
+	if !strings.Contains(query.Get("scope"), "activity:read") {
+		s.fail(w, http.StatusBadRequest, "Access to activities is required to draw a heatmap, please connect again", nil)
+		return
+	}
+
+	token, err := s.OAuth.ExchangeCodeForToken(query.Get("code"))
+	if err != nil {
+		s.fail(w, http.StatusBadGateway, "Failed to exchange the authorization code", err)
+		return
+	}
+
+	user, err := s.Users.Connect(&User{
+		AthleteID:    token.Athlete.ID,
+		Name:         token.Athlete.Firstname,
+		RefreshToken: token.RefreshToken,
+		AccessToken:  token.AccessToken,
+		ExpiresAt:    time.Unix(token.ExpiresAt, 0),
+		ConnectedAt:  time.Now(),
+	}, token.Athlete.Username)
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError, "Failed to save your connection", err)
+		return
+	}
+
+	// Drop any heatmap rendered with an earlier connection
+	s.mu.Lock()
+	delete(s.rendered, user.Slug)
+	s.mu.Unlock()
+
+	// Links are built from the configured URL, since the Host header is
+	// whatever the client sent
+	heatmapURL := fmt.Sprintf("%s/u/%s/heatmap.svg", s.BaseURL, user.Slug)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><title>Strava Heatmap</title></head>
+<body>
+<h1>Connected!</h1>
+<p>Your heatmap is available at <a href="%[1]s">%[1]s</a></p>
+<p>Add it to your GitHub profile README with:</p>
+<pre>![Strava Activity Heatmap](%[1]s)</pre>
+</body></html>`, html.EscapeString(heatmapURL))
+}
+
+// handleHeatmap serves a user's heatmap, rendering it if the cached copy is stale
+func (s *Server) handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	user, err := s.Users.Get(slug)
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError, "Failed to load user", err)
+		return
+	}
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := s.heatmapFor(user)
+	if err != nil {
+		s.fail(w, http.StatusBadGateway, "Failed to render heatmap", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.RenderTTL.Seconds())))
+	fmt.Fprint(w, content)
+}
+
+// heatmapFor returns the user's cached heatmap, or renders a fresh one
+func (s *Server) heatmapFor(user *User) (string, error) {
+	// Only one request per user fetches from Strava at a time
+	lock := s.userLock(user.Slug)
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.mu.Lock()
+	cached, ok := s.rendered[user.Slug]
+	s.mu.Unlock()
+	if ok && time.Since(cached.RenderedAt) < s.RenderTTL {
+		return cached.SVG, nil
+	}
+
+	content, err := s.render(user)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.rendered[user.Slug] = renderedHeatmap{SVG: content, RenderedAt: time.Now()}
+	s.mu.Unlock()
+
+	return content, nil
+}
+
+// render fetches the user's activities and draws their heatmap
+func (s *Server) render(user *User) (string, error) {
+	tokenManager := auth.NewTokenManager(s.OAuth.ClientID, s.OAuth.ClientSecret, user.RefreshToken)
+	tokenManager.AccessToken = user.AccessToken
+	tokenManager.ExpiresAt = user.ExpiresAt
+
+	// Each render gets its own copy, since rendering may fill in defaults.
+	// Served heatmaps can be opened directly, where hover and focus work.
+	cfg := *s.Config
+	cfg.Interactive = true
+	client := strava.NewClient(tokenManager, s.Debug, cfg.GetHTTPOptions())
+	client.SetRequestBudget(cfg.MaxAPIRequests)
+
+	// All history starts at the athlete's first activity. Without it the
+	// fetch reaches back to 2009, and the tokens are saved either way below.
+	if cfg.UsesAllHistory() {
+		first, err := client.GetFirstActivityDate()
+		if err != nil && s.Debug {
+			fmt.Printf("[DEBUG] Failed to find the first activity of %s: %v\n", user.Slug, err)
+		}
+		cfg.FirstActivity = first
+	}
+
+	startDate, endDate, err := cfg.GetFetchRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	activities, err := client.GetAllActivities(startDate, endDate, cfg.ActivityTypes)
+
+	// Strava may have rotated the tokens, even if fetching failed later on
+	if tokenManager.RefreshToken != user.RefreshToken || tokenManager.AccessToken != user.AccessToken {
+		user.RefreshToken = tokenManager.RefreshToken
+		user.AccessToken = tokenManager.AccessToken
+		user.ExpiresAt = tokenManager.ExpiresAt
+		if saveErr := s.Users.Save(user); saveErr != nil {
+			return "", saveErr
+		}
+	}
+
+	// Render whatever was fetched if the request budget ran out
+	if errors.Is(err, strava.ErrRequestBudget) {
+		if s.Debug {
+			fmt.Printf("[DEBUG] Request budget exhausted for %s, rendering partial data\n", user.Slug)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("error fetching activities: %w", err)
+	}
+
+	if cfg.FetchDetails && err == nil {
+		if err := client.FillActivityDetails(activities); err != nil && !errors.Is(err, strava.ErrRequestBudget) {
+			return "", fmt.Errorf("error fetching activity details: %w", err)
+		}
+	}
+
+	if cfg.CorrectElevation && err == nil {
+		if err := processor.CorrectElevation(client, activities); err != nil && !errors.Is(err, strava.ErrRequestBudget) {
+			return "", fmt.Errorf("error correcting elevation: %w", err)
+		}
+		activities = processor.WithCorrectedElevation(activities)
+	}
+
+	activities = processor.IgnoreActivities(activities, cfg.IgnoreActivityIDs)
+	content, err := svg.NewGenerator(&cfg).GenerateHeatmap(activities)
+	if err != nil {
+		return "", err
+	}
+
+	// A failed upload still leaves the heatmap served from memory
+	if s.Store != nil {
+		if err := s.publish(user, &cfg, activities, content); err != nil && s.Debug {
+			fmt.Printf("[DEBUG] Failed to publish heatmap for %s: %v\n", user.Slug, err)
+		}
+	}
+
+	return content, nil
+}
+
+// publish uploads a user's heatmap and stats to the artifact store, under
+// the same paths the service serves the heatmap from. Stats are left out in
+// privacy mode, as they reveal distances and durations.
+func (s *Server) publish(user *User, cfg *config.Config, activities []strava.SummaryActivity, content string) error {
+	prefix := "u/" + user.Slug + "/"
+	if err := s.Store.Put(prefix+"heatmap.svg", "image/svg+xml", []byte(content)); err != nil {
+		return err
+	}
+
+	if cfg.PrivacyMode {
+		return nil
+	}
+
+	location, err := cfg.GetTimeZoneLocation()
+	if err != nil {
+		return err
+	}
+	startDate, endDate, err := cfg.GetDateRange()
+	if err != nil {
+		return err
+	}
+
+	aggregator := processor.NewActivityAggregator(activities, location, float64(cfg.FTP))
+	aggregator.TimeBasis = cfg.TimeBasis
+	aggregator.Aggregate()
+	snapshot := processor.NewStatsSnapshot(aggregator, startDate, endDate, time.Now().In(location))
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling stats: %w", err)
+	}
+	return s.Store.Put(prefix+"stats.json", "application/json", data)
+}
+
+// RefreshAll renders every connected user's heatmap whose cached copy is
+// stale, so published artifacts stay current even when every view is served
+// by a CDN and never reaches the service
+func (s *Server) RefreshAll() {
+	slugs, err := s.Users.List()
+	if err != nil {
+		if s.Debug {
+			fmt.Printf("[DEBUG] Failed to list users: %v\n", err)
+		}
+		return
+	}
+
+	for _, slug := range slugs {
+		user, err := s.Users.Get(slug)
+		if err != nil || user == nil {
+			continue
+		}
+		if _, err := s.heatmapFor(user); err != nil && s.Debug {
+			fmt.Printf("[DEBUG] Failed to refresh heatmap for %s: %v\n", slug, err)
+		}
+	}
+}
+
+// userLock returns the mutex serializing renders for a user
+func (s *Server) userLock(slug string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[slug]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[slug] = lock
+	}
+	return lock
+}
+
+// newState returns a random OAuth state and remembers it until it expires
+func (s *Server) newState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating state: %w", err)
+	}
+	state := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget expired states so abandoned flows don't accumulate
+	now := time.Now()
+	for pending, expiry := range s.states {
+		if now.After(expiry) {
+			delete(s.states, pending)
+		}
+	}
+	s.states[state] = now.Add(stateTTL)
+
+	return state, nil
+}
+
+// consumeState reports whether the state was issued by /connect and is
+// still valid, and invalidates it
+func (s *Server) consumeState(state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.states[state]
+	delete(s.states, state)
+	return ok && time.Now().Before(expiry)
+}
+
+// fail logs an error and responds with a plain text message
+func (s *Server) fail(w http.ResponseWriter, status int, msg string, err error) {
+	if err != nil && s.Debug {
+		fmt.Printf("[DEBUG] %s: %v\n", msg, err)
+	}
+	http.Error(w, msg, status)
+}
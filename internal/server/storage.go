@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArtifactStore publishes rendered artifacts outside the service, so they
+// can be served from a bucket behind a CDN
+type ArtifactStore interface {
+	Put(key, contentType string, data []byte) error
+}
+
+// BucketStore writes artifacts to an S3-compatible bucket. Google Cloud
+// Storage is reached through its S3-compatible XML API with HMAC keys.
+type BucketStore struct {
+	Endpoint     string // Scheme and host, e.g. https://s3.us-east-1.amazonaws.com
+	Bucket       string
+	Prefix       string // Prepended to every key, without a trailing slash
+	Region       string // "auto" for Google Cloud Storage
+	PathStyle    bool   // Address the bucket in the path rather than the host
+	AccessKey    string
+	SecretKey    string
+	CacheControl string // Sent with every object, e.g. "public, max-age=3600"
+
+	client *http.Client
+}
+
+// NewBucketStore creates a store from a bucket URL: s3://bucket/prefix for
+// Amazon S3 in region, or gs://bucket/prefix for Google Cloud Storage. A
+// non-empty endpoint points s3:// URLs at another S3-compatible service,
+// such as MinIO or Cloudflare R2, using path-style addressing.
+func NewBucketStore(rawURL, region, endpoint string) (*BucketStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid storage URL %q: missing bucket", rawURL)
+	}
+
+	store := &BucketStore{
+		Bucket: u.Host,
+		Prefix: strings.Trim(u.Path, "/"),
+		Region: region,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	switch u.Scheme {
+	case "s3":
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if endpoint != "" {
+			store.Endpoint = strings.TrimSuffix(endpoint, "/")
+			store.PathStyle = true
+		} else {
+			store.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", store.Region)
+		}
+	case "gs":
+		store.Endpoint = "https://storage.googleapis.com"
+		store.Region = "auto"
+		store.PathStyle = true
+	default:
+		return nil, fmt.Errorf("invalid storage URL %q: scheme must be s3 or gs", rawURL)
+	}
+
+	return store, nil
+}
+
+// Put uploads an object, replacing any earlier version
+func (b *BucketStore) Put(key, contentType string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, b.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creating upload request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if b.CacheControl != "" {
+		req.Header.Set("Cache-Control", b.CacheControl)
+	}
+	signRequest(req, data, b.AccessKey, b.SecretKey, b.Region, "s3", time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error uploading %s (status %d): %s", key, resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// objectURL returns the URL of the object stored under a key
+func (b *BucketStore) objectURL(key string) string {
+	key = path.Join(b.Prefix, key)
+	if b.PathStyle {
+		return b.Endpoint + "/" + b.Bucket + "/" + key
+	}
+
+	// Virtual-hosted style puts the bucket in front of the endpoint host
+	scheme, host, _ := strings.Cut(b.Endpoint, "://")
+	return scheme + "://" + b.Bucket + "." + host + "/" + key
+}
+
+// signRequest adds an AWS Signature Version 4 Authorization header covering
+// the host and every header already set on the request
+func signRequest(req *http.Request, payload []byte, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// Canonical headers are lowercase, sorted, and include the host
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := uriEncode(req.URL.Path, false)
+	if uri == "" {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query parameters sorted by name and then
+// value, each encoded as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	encoded := make(map[string][]string, len(query))
+	names := make([]string, 0, len(query))
+	for name, values := range query {
+		name = uriEncode(name, true)
+		for _, value := range values {
+			encoded[name] = append(encoded[name], uriEncode(value, true))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := encoded[name]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters
+// A-Z, a-z, 0-9, "-", ".", "_" and "~", and "/" unless encodeSlash is set.
+// This is stricter than url.PathEscape, which leaves characters such as "$"
+// and "+" as they are and so signs a different path than S3 checks.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
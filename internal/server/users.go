@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slugPattern limits slugs to characters safe in URLs and file names
+var slugPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// User is an athlete who connected their Strava account to the service
+type User struct {
+	Slug         string    `json:"slug"`
+	AthleteID    int64     `json:"athleteId"`
+	Name         string    `json:"name"`
+	RefreshToken string    `json:"refreshToken"`
+	AccessToken  string    `json:"accessToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	ConnectedAt  time.Time `json:"connectedAt"`
+}
+
+// UserStore keeps one JSON file of tokens per user in a directory
+type UserStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewUserStore creates a new user store
+func NewUserStore(dir string) *UserStore {
+	return &UserStore{
+		Dir: dir,
+	}
+}
+
+// Get returns the user with the given slug, or nil if there is none
+func (s *UserStore) Get(slug string) (*User, error) {
+	if !slugPattern.MatchString(slug) {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(slug)
+}
+
+// Save writes a user's record, replacing any earlier one
+func (s *UserStore) Save(user *User) error {
+	if !slugPattern.MatchString(user.Slug) {
+		return fmt.Errorf("invalid slug: %s", user.Slug)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(user)
+}
+
+// Connect stores the tokens of a newly authorized athlete. Athletes who
+// connected before keep their slug; new athletes get one derived from their
+// Strava username, falling back to their athlete ID.
+func (s *UserStore) Connect(user *User, username string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Reuse the slug of an earlier connection
+	existing, err := s.findByAthlete(user.AthleteID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case existing != nil:
+		user.Slug = existing.Slug
+	default:
+		user.Slug = strconv.FormatInt(user.AthleteID, 10)
+		if candidate := strings.ToLower(username); slugPattern.MatchString(candidate) {
+			taken, err := s.read(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if taken == nil {
+				user.Slug = candidate
+			}
+		}
+	}
+
+	if err := s.write(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List returns the slugs of every connected user
+func (s *UserStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.slugs()
+}
+
+// findByAthlete returns the user for a Strava athlete, or nil if there is none
+func (s *UserStore) findByAthlete(athleteID int64) (*User, error) {
+	slugs, err := s.slugs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, slug := range slugs {
+		user, err := s.read(slug)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil && user.AthleteID == athleteID {
+			return user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// slugs lists the users with a file in the store directory
+func (s *UserStore) slugs() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %w", err)
+	}
+
+	slugs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		slugs = append(slugs, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	return slugs, nil
+}
+
+// read loads a user's file, returning nil if it doesn't exist
+func (s *UserStore) read(slug string) (*User, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, slug+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading user %s: %w", slug, err)
+	}
+
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("error parsing user %s: %w", slug, err)
+	}
+
+	return &user, nil
+}
+
+// write stores a user's file, readable only by the service
+func (s *UserStore) write(user *User) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("error creating user directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(user, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling user %s: %w", user.Slug, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.Dir, user.Slug+".json"), data, 0600); err != nil {
+		return fmt.Errorf("error writing user %s: %w", user.Slug, err)
+	}
+
+	return nil
+}
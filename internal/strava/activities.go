@@ -2,6 +2,7 @@ package strava
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -17,9 +18,16 @@ func (c *Client) GetActivities(after, before time.Time, page, perPage int) ([]Su
 		page = 1 // Default page
 	}
 
+	// Round the start down to midnight and leave out an end that hasn't
+	// passed yet, so runs on the same day request the same URL and can be
+	// answered with 304 Not Modified
 	params := url.Values{}
-	params.Add("after", strconv.FormatInt(after.Unix(), 10))
-	params.Add("before", strconv.FormatInt(before.Unix(), 10))
+	startOfDay := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, after.Location())
+	params.Add("after", strconv.FormatInt(startOfDay.Unix(), 10))
+	now := time.Now().In(before.Location())
+	if before.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		params.Add("before", strconv.FormatInt(before.Unix(), 10))
+	}
 	params.Add("page", strconv.Itoa(page))
 	params.Add("per_page", strconv.Itoa(perPage))
 
@@ -45,7 +53,9 @@ func (c *Client) GetActivities(after, before time.Time, page, perPage int) ([]Su
 	return activities, nil
 }
 
-// GetAllActivities retrieves all activities within the given time range
+// GetAllActivities retrieves all activities within the given time range. If
+// the request budget runs out, the activities fetched so far are returned
+// along with an error wrapping ErrRequestBudget.
 func (c *Client) GetAllActivities(after, before time.Time, types []string) ([]SummaryActivity, error) {
 	var allActivities []SummaryActivity
 	var page int = 1
@@ -66,9 +76,13 @@ func (c *Client) GetAllActivities(after, before time.Time, types []string) ([]Su
 	for hasMorePages {
 		// Get a page of activities
 		activities, err := c.GetActivities(after, before, page, perPage)
+		if errors.Is(err, ErrRequestBudget) {
+			return allActivities, fmt.Errorf("stopped before activities page %d: %w", page, err)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error fetching activities (page %d): %w", page, err)
 		}
+		c.stats.PagesFetched++
 
 		// If we get fewer than perPage, we've reached the last page
 		if len(activities) < perPage {
@@ -101,3 +115,98 @@ func (c *Client) GetAllActivities(after, before time.Time, types []string) ([]Su
 
 	return allActivities, nil
 }
+
+// GetFirstActivityDate returns the start of the athlete's earliest activity,
+// or the zero time if they have none. Activities listed after a date come
+// oldest first, so this takes a single request.
+func (c *Client) GetFirstActivityDate() (time.Time, error) {
+	activities, err := c.GetActivities(time.Unix(0, 0).UTC(), time.Now(), 1, 1)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error fetching first activity: %w", err)
+	}
+	if len(activities) == 0 {
+		return time.Time{}, nil
+	}
+	return activities[0].StartDate, nil
+}
+
+// GetActivity retrieves the detailed representation of an activity
+func (c *Client) GetActivity(id int64) (*DetailedActivity, error) {
+	if c.debug {
+		c.logDebug(fmt.Sprintf("Fetching details for activity %d", id))
+	}
+
+	body, err := c.makeRequest("GET", fmt.Sprintf("/activities/%d", id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var activity DetailedActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return nil, fmt.Errorf("error parsing activity data: %w", err)
+	}
+
+	return &activity, nil
+}
+
+// GetAltitudeStream retrieves the altitude samples recorded during an
+// activity in meters. It returns nil if the activity has no altitude data.
+func (c *Client) GetAltitudeStream(id int64) ([]float64, error) {
+	if c.debug {
+		c.logDebug(fmt.Sprintf("Fetching altitude stream for activity %d", id))
+	}
+
+	params := url.Values{}
+	params.Add("keys", "altitude")
+	params.Add("key_by_type", "true")
+
+	body, err := c.makeRequest("GET", fmt.Sprintf("/activities/%d/streams", id), params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Streams are keyed by type; distance is always included as the series
+	var streams map[string]struct {
+		Data []float64 `json:"data"`
+	}
+	if err := json.Unmarshal(body, &streams); err != nil {
+		return nil, fmt.Errorf("error parsing activity streams: %w", err)
+	}
+
+	return streams["altitude"].Data, nil
+}
+
+// FillActivityDetails fetches the detailed representation of each activity
+// to populate fields missing from summaries, such as calories, and to count
+// its personal records from the segment and best efforts that set one. This
+// costs one API request per activity. If the request budget runs out, the
+// activities filled in so far keep their details.
+func (c *Client) FillActivityDetails(activities []SummaryActivity) error {
+	fetched := 0
+	for i := range activities {
+		// Skip activities already filled in, e.g. restored from a cache
+		if activities[i].DetailsFetched {
+			continue
+		}
+
+		detail, err := c.GetActivity(activities[i].ID)
+		if err != nil {
+			return fmt.Errorf("error fetching details for activity %d: %w", activities[i].ID, err)
+		}
+
+		activities[i].Calories = detail.Calories
+		activities[i].Description = detail.Description
+		activities[i].PRCount = detail.PRs()
+		activities[i].DetailsFetched = true
+		fetched++
+
+		// Stay within Strava's rate limits, as in GetAllActivities
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if c.debug {
+		c.logDebug(fmt.Sprintf("Fetched details for %d activities", fetched))
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package strava
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets and names in recorded responses
+const redacted = "REDACTED"
+
+// recordedHeaders are the response headers kept in a cassette. Everything
+// else, including cookies, is dropped.
+var recordedHeaders = []string{
+	"Content-Type",
+	"ETag",
+	"Last-Modified",
+	"Retry-After",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Usage",
+	"X-RateLimit-Reset",
+}
+
+// redactedFields are JSON fields replaced in recorded response bodies, at
+// any depth, with the value they get: tokens, the athlete's name and
+// profile, and where activities went
+var redactedFields = map[string]interface{}{
+	"access_token":     redacted,
+	"refresh_token":    redacted,
+	"firstname":        redacted,
+	"lastname":         redacted,
+	"username":         redacted,
+	"bio":              redacted,
+	"city":             redacted,
+	"state":            redacted,
+	"profile":          redacted,
+	"profile_medium":   redacted,
+	"start_latlng":     []float64{},
+	"end_latlng":       []float64{},
+	"summary_polyline": "",
+	"polyline":         "",
+}
+
+// Interaction is a recorded request and the response it got. Request
+// headers and bodies aren't recorded, so tokens and client secrets never
+// reach the file.
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Cassette records API interactions to a JSON file, or replays them from one
+// so runs can be reproduced without network access or real credentials
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+
+	path   string
+	replay bool
+	mu     sync.Mutex
+	played map[string]int // Interactions already replayed for each request
+}
+
+// NewCassette starts recording to path. The file is rewritten after every
+// interaction, so it is complete even if the run exits early.
+func NewCassette(path string) *Cassette {
+	return &Cassette{path: path}
+}
+
+// LoadCassette opens a recorded file for replay
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+
+	c := &Cassette{path: path, replay: true, played: make(map[string]int)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error parsing cassette: %w", err)
+	}
+	return c, nil
+}
+
+// Replaying reports whether the cassette answers requests from its recording
+// rather than recording them
+func (c *Cassette) Replaying() bool {
+	return c.replay
+}
+
+// Transport returns a RoundTripper that records the requests sent through
+// base, or that answers them from the recording when replaying
+func (c *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{cassette: c, base: base}
+}
+
+// cassetteTransport records or replays requests for a cassette
+type cassetteTransport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+// RoundTrip records or replays a single request
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cassette.replay {
+		return t.cassette.play(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the body so it can be both recorded and returned
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.cassette.record(req, resp, body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// record appends an interaction and saves the cassette
+func (c *Cassette) record(req *http.Request, resp *http.Response, body []byte) error {
+	interaction := Interaction{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: make(http.Header),
+		Body:   string(body),
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Values(name); len(value) > 0 {
+			interaction.Header[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	if sanitized, ok := redactBody(body, strings.HasSuffix(req.URL.Path, "/athlete")); ok {
+		interaction.Body = string(sanitized)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, interaction)
+	return c.save()
+}
+
+// play answers a request with the next recorded interaction for the same
+// method and URL. Once they have all been played the last one repeats, so a
+// token refreshed more often than during recording still gets a reply.
+func (c *Cassette) play(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := req.Method + " " + req.URL.String()
+
+	var matches []Interaction
+	for _, interaction := range c.Interactions {
+		if interaction.Method+" "+interaction.URL == key {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+
+	interaction := matches[min(c.played[key], len(matches)-1)]
+	c.played[key]++
+
+	header := make(http.Header)
+	for name, values := range interaction.Header {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}, nil
+}
+
+// save writes the recorded interactions as indented JSON
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling cassette: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+
+	return nil
+}
+
+// redactBody replaces redactedFields and athlete ids in a JSON body, which
+// is the athlete's profile if isAthlete is set, reporting whether any were
+// found
+func redactBody(body []byte, isAthlete bool) ([]byte, bool) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	if !redactValue(value, isAthlete) {
+		return nil, false
+	}
+
+	sanitized, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return sanitized, true
+}
+
+// redactValue redacts a decoded JSON value in place, zeroing its id if it's
+// an athlete
+func redactValue(value interface{}, isAthlete bool) bool {
+	found := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if replacement, ok := redactedFields[key]; ok {
+				v[key] = replacement
+				found = true
+			} else if key == "id" && isAthlete {
+				v[key] = 0
+				found = true
+			} else if redactValue(field, key == "athlete") {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item, false) {
+				found = true
+			}
+		}
+	}
+	return found
+}
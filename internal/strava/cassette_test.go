@@ -0,0 +1,271 @@
+package strava
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureRange is the date range the activity pages in the cassettes were
+// requested for
+var (
+	fixtureAfter  = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtureBefore = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// staticToken hands out a fixed access token without ever refreshing it
+type staticToken string
+
+func (t staticToken) GetAccessToken() (string, error) { return string(t), nil }
+func (t staticToken) RefreshAccessToken() error       { return nil }
+
+// replayClient returns a client answering from a cassette in testdata, which
+// records the waits it would sleep instead of sleeping
+func replayClient(t *testing.T, name string) (*Client, *[]time.Duration) {
+	t.Helper()
+
+	cassette, err := LoadCassette(filepath.Join("..", "..", "testdata", "cassettes", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cassette.Replaying() {
+		t.Fatalf("cassette %s loaded for recording", name)
+	}
+
+	client := NewClient(staticToken("test-token"), false, HTTPOptions{Cassette: cassette})
+	waits := []time.Duration{}
+	client.sleep = func(wait time.Duration) { waits = append(waits, wait) }
+	return client, &waits
+}
+
+func TestReplayPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  int
+	}{
+		{"all types", nil, 105},
+		{"filtered by type", []string{"Ride"}, 35},
+		{"several types", []string{"Run", "Swim"}, 70},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, waits := replayClient(t, "pagination.json")
+
+			activities, err := client.GetAllActivities(fixtureAfter, fixtureBefore, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(activities) != tt.want {
+				t.Errorf("got %d activities, want %d", len(activities), tt.want)
+			}
+
+			// A full page of 100 asks for the next, and the short one ends it
+			stats := client.Stats()
+			if stats.PagesFetched != 2 || stats.Requests != 2 {
+				t.Errorf("fetched %d pages in %d requests, want 2 in 2", stats.PagesFetched, stats.Requests)
+			}
+			if stats.RateLimit == nil || stats.RateLimit.ShortTermUsage != 2 || stats.RateLimit.DailyRemaining != 998 {
+				t.Errorf("rate limit = %+v, want the last page's usage of 2 of 100 and 2 of 1000", stats.RateLimit)
+			}
+			if len(*waits) != 0 {
+				t.Errorf("waited %v, want no waits", *waits)
+			}
+		})
+	}
+}
+
+func TestReplayBudget(t *testing.T) {
+	client, _ := replayClient(t, "pagination.json")
+	client.SetRequestBudget(1)
+
+	activities, err := client.GetAllActivities(fixtureAfter, fixtureBefore, nil)
+	if !errors.Is(err, ErrRequestBudget) {
+		t.Fatalf("err = %v, want ErrRequestBudget", err)
+	}
+	if len(activities) != 100 {
+		t.Errorf("got %d activities, want the first page's 100", len(activities))
+	}
+}
+
+func TestReplayRateLimit(t *testing.T) {
+	t.Run("short term window", func(t *testing.T) {
+		client, waits := replayClient(t, "rate_limit.json")
+
+		activities, err := client.GetAllActivities(fixtureAfter, fixtureBefore, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(activities) != 3 {
+			t.Errorf("got %d activities, want 3", len(activities))
+		}
+
+		// Retry-After is waited out, with a second to spare
+		if len(*waits) != 1 || (*waits)[0] != 31*time.Second {
+			t.Errorf("waited %v, want [31s]", *waits)
+		}
+		stats := client.Stats()
+		if stats.Retries != 1 || stats.Requests != 2 || stats.RateLimitWaitSeconds != 31 {
+			t.Errorf("stats = %+v, want 1 retry, 2 requests and 31s waited", stats)
+		}
+	})
+
+	t.Run("daily limit", func(t *testing.T) {
+		client, waits := replayClient(t, "rate_limit.json")
+
+		_, err := client.GetAthlete()
+		if err == nil || !strings.Contains(err.Error(), "daily rate limit exceeded") {
+			t.Fatalf("err = %v, want the daily rate limit", err)
+		}
+		if len(*waits) != 0 || client.Stats().Requests != 1 {
+			t.Errorf("waited %v over %d requests, want to fail on the first without waiting", *waits, client.Stats().Requests)
+		}
+	})
+}
+
+func TestReplayServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int64
+		retries int
+		wantErr error  // Checked with errors.Is, if set
+		errText string // Expected in the error, if set
+	}{
+		{name: "recovers after 503 and 502", id: 1, retries: 2},
+		// The single recorded 500 is repeated for every retry
+		{name: "gives up after repeated 500s", id: 2, retries: maxRetries, errText: "API error (status 500)"},
+		{name: "not found is not retried", id: 3, retries: 0, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, waits := replayClient(t, "server_errors.json")
+
+			activity, err := client.GetActivity(tt.id)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("err = %v, want %q", err, tt.errText)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if activity.ID != tt.id || activity.Calories != 400 {
+					t.Errorf("got activity %d with %.0f kcal, want %d with 400", activity.ID, activity.Calories, tt.id)
+				}
+			}
+
+			stats := client.Stats()
+			if stats.Retries != tt.retries || stats.Requests != tt.retries+1 {
+				t.Errorf("made %d requests with %d retries, want %d with %d", stats.Requests, stats.Retries, tt.retries+1, tt.retries)
+			}
+
+			// Backoff doubles from retryBaseDelay, jittered down to half
+			if len(*waits) != tt.retries {
+				t.Fatalf("waited %v, want %d backoffs", *waits, tt.retries)
+			}
+			for i, wait := range *waits {
+				full := min(retryBaseDelay<<i, maxRetryDelay)
+				if wait < full/2 || wait > full {
+					t.Errorf("backoff %d = %s, want between %s and %s", i+1, wait, full/2, full)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayUnrecordedRequest(t *testing.T) {
+	client, _ := replayClient(t, "server_errors.json")
+
+	_, err := client.GetActivity(4)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response for GET "+baseURL+"/activities/4") {
+		t.Fatalf("err = %v, want the unrecorded request named", err)
+	}
+}
+
+// bodyTransport answers every request with a fixed JSON body
+type bodyTransport string
+
+func (b bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(b))),
+		Request:    req,
+	}, nil
+}
+
+func TestRecordRedacts(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		leaked []string // Must not reach the cassette
+		kept   []string // Must reach it unchanged
+	}{
+		{
+			name:   "token refresh",
+			path:   "/oauth/token",
+			body:   `{"token_type":"Bearer","access_token":"a1b2c3","refresh_token":"d4e5f6","expires_at":1704070800,"athlete":{"id":12345,"firstname":"Jane","lastname":"Doe"}}`,
+			leaked: []string{"a1b2c3", "d4e5f6", "12345", "Jane", "Doe"},
+			kept:   []string{`"expires_at":1704070800`, `"token_type":"Bearer"`},
+		},
+		{
+			name:   "athlete profile",
+			path:   "/api/v3/athlete",
+			body:   `{"id":12345,"username":"jdoe","firstname":"Jane","lastname":"Doe","bio":"Runs a lot","city":"Leeds","state":"England","country":"United Kingdom","profile":"https://example.com/jane.jpg","ftp":250}`,
+			leaked: []string{"12345", "jdoe", "Jane", "Doe", "Runs a lot", "Leeds", "England", "jane.jpg"},
+			kept:   []string{`"ftp":250`, `"country":"United Kingdom"`},
+		},
+		{
+			name:   "activity page",
+			path:   "/api/v3/athlete/activities",
+			body:   `[{"id":1000,"name":"Morning Run","distance":5000.0,"athlete":{"id":12345,"resource_state":1},"start_latlng":[53.8008,-1.5491],"end_latlng":[53.8011,-1.5488],"map":{"id":"a1000","summary_polyline":"_p~iF~ps|U_ulLnnqC"}}]`,
+			leaked: []string{"12345", "53.8008", "-1.5491", "53.8011", "_p~iF~ps|U_ulLnnqC"},
+			kept:   []string{`"id":1000`, `"distance":5000.0`, `"name":"Morning Run"`, `"resource_state":1`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cassette.json")
+			client := &http.Client{Transport: NewCassette(path).Transport(bodyTransport(tt.body))}
+
+			resp, err := client.Get("https://www.strava.com" + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			live, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(live) != tt.body {
+				t.Errorf("the live response was changed: %s", live)
+			}
+
+			cassette, err := LoadCassette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded := cassette.Interactions[0].Body
+			for _, value := range tt.leaked {
+				if strings.Contains(recorded, value) {
+					t.Errorf("recorded body holds %q: %s", value, recorded)
+				}
+			}
+			for _, value := range tt.kept {
+				if !strings.Contains(recorded, value) {
+					t.Errorf("recorded body lost %s: %s", value, recorded)
+				}
+			}
+		})
+	}
+}
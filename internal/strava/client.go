@@ -2,11 +2,13 @@ package strava
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,29 +17,102 @@ const (
 	activitiesPath = "/athlete/activities"
 )
 
+// ErrRequestBudget is returned once a client has made as many requests as
+// its budget allows
+var ErrRequestBudget = errors.New("API request budget exhausted")
+
+// ErrNotFound is returned for a resource that doesn't exist, such as a
+// deleted activity
+var ErrNotFound = errors.New("not found")
+
 // TokenManager interface defines methods for token management
 type TokenManager interface {
 	GetAccessToken() (string, error)
 	RefreshAccessToken() error
 }
 
+// CachedResponse is a response body kept with its validators, so a later
+// request for the same URL can be answered with 304 Not Modified
+type CachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
 // Client handles API communication with Strava
 type Client struct {
 	httpClient   *http.Client
 	tokenManager TokenManager
 	debug        bool
+
+	previous  map[string]*CachedResponse // Responses from an earlier run, keyed by URL
+	responses map[string]*CachedResponse // Responses requested during this run
+	budget    int                        // Most requests to make, 0 for no limit
+	stats     RequestStats
+	sleep     func(time.Duration) // Waits out backoffs and rate limit windows
+}
+
+// RequestStats counts the API requests made by a client
+type RequestStats struct {
+	Requests             int        `json:"requests"`    // Requests sent, including those answered with 304
+	NotModified          int        `json:"notModified"` // Requests answered from the response cache
+	PagesFetched         int        `json:"pagesFetched"`
+	Retries              int        `json:"retries"`              // Requests sent again after a transient error or rate limiting
+	RateLimitWaitSeconds float64    `json:"rateLimitWaitSeconds"` // Time spent waiting for rate limit windows to reset
+	RateLimit            *RateLimit `json:"rateLimit,omitempty"`  // As of the last response, nil if never reported
+}
+
+// RateLimit is Strava's rate limit status, reported for a 15 minute window
+// and for the day
+type RateLimit struct {
+	ShortTermLimit     int `json:"shortTermLimit"`
+	ShortTermUsage     int `json:"shortTermUsage"`
+	ShortTermRemaining int `json:"shortTermRemaining"`
+	DailyLimit         int `json:"dailyLimit"`
+	DailyUsage         int `json:"dailyUsage"`
+	DailyRemaining     int `json:"dailyRemaining"`
 }
 
-// NewClient creates a new Strava API client
-func NewClient(tokenManager TokenManager, debug bool) *Client {
+// NewClient creates a new Strava API client whose requests share one
+// kept-alive transport
+func NewClient(tokenManager TokenManager, debug bool, options HTTPOptions) *Client {
 	return &Client{
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		httpClient:   NewHTTPClient(options),
 		tokenManager: tokenManager,
 		debug:        debug,
+		previous:     make(map[string]*CachedResponse),
+		responses:    make(map[string]*CachedResponse),
+		sleep:        time.Sleep,
 	}
 }
 
-// makeRequest makes an authenticated request to the Strava API
+// SetCachedResponses provides responses saved by an earlier run, whose
+// validators are sent with matching requests
+func (c *Client) SetCachedResponses(responses map[string]*CachedResponse) {
+	if responses == nil {
+		responses = make(map[string]*CachedResponse)
+	}
+	c.previous = responses
+}
+
+// SetRequestBudget limits the number of requests the client makes, so a run
+// can't use up a daily quota shared with other tools. Requests beyond the
+// budget fail with ErrRequestBudget; 0 removes the limit.
+func (c *Client) SetRequestBudget(budget int) {
+	c.budget = budget
+}
+
+// CachedResponses returns the cacheable responses requested during this run.
+// Responses that weren't requested again are left out, so saving them
+// doesn't accumulate URLs that are no longer used.
+func (c *Client) CachedResponses() map[string]*CachedResponse {
+	return c.responses
+}
+
+// makeRequest makes an authenticated request to the Strava API. Network
+// errors and transient server errors are retried with exponential backoff,
+// and a request refused by the 15 minute rate limit is retried once the
+// window resets.
 func (c *Client) makeRequest(method, path string, params url.Values) ([]byte, error) {
 	// Get a valid access token
 	accessToken, err := c.tokenManager.GetAccessToken()
@@ -60,30 +135,85 @@ func (c *Client) makeRequest(method, path string, params url.Values) ([]byte, er
 	// Add authorization header
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	// Make the request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check for rate limiting
-	if resp.StatusCode == http.StatusTooManyRequests {
-		// Extract rate limit reset time
-		resetHeader := resp.Header.Get("X-RateLimit-Reset")
-		if resetHeader != "" {
-			resetTime, err := strconv.ParseInt(resetHeader, 10, 64)
-			if err == nil {
-				resetTimeFormatted := time.Unix(resetTime, 0).Format(time.RFC3339)
-				return nil, fmt.Errorf("rate limit exceeded, reset at %s", resetTimeFormatted)
+	// Ask for the body only if it changed since it was last fetched
+	conditional := method == "GET" && isConditionalPath(path)
+	var cached *CachedResponse
+	if conditional {
+		cached = c.cachedResponse(reqURL)
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
 			}
 		}
-		return nil, fmt.Errorf("rate limit exceeded")
+	}
+
+	// Make the request, retrying transient failures
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		if c.budget > 0 && c.stats.Requests >= c.budget {
+			return nil, ErrRequestBudget
+		}
+		c.waitForRateLimit()
+
+		resp, err = c.httpClient.Do(req)
+		if err != nil {
+			if attempt < maxRetries {
+				c.retry(attempt, path, err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("error making request: %w", err)
+		}
+
+		c.stats.Requests++
+		if rateLimit := parseRateLimit(resp.Header); rateLimit != nil {
+			c.stats.RateLimit = rateLimit
+		}
+
+		// Wait out the rate limit window, unless the daily limit is used up
+		if resp.StatusCode == http.StatusTooManyRequests {
+			closeBody(resp.Body)
+			wait, err := rateLimitWait(resp.Header, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			if attempt >= maxRetries {
+				return nil, fmt.Errorf("rate limit exceeded")
+			}
+
+			c.logDebug(fmt.Sprintf("Rate limited on %s, waiting %s for the next window", path, wait.Round(time.Second)))
+			c.stats.Retries++
+			c.pause(wait)
+			continue
+		}
+
+		if isRetryableStatus(resp.StatusCode) && attempt < maxRetries {
+			closeBody(resp.Body)
+			c.retry(attempt, path, resp.Status)
+			continue
+		}
+		break
+	}
+	defer closeBody(resp.Body)
+
+	// An unchanged resource is served from the cache
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		if c.debug {
+			c.logDebug(fmt.Sprintf("Not modified, using cached response for %s", path))
+		}
+		c.responses[reqURL] = cached
+		c.stats.NotModified++
+		return cached.Body, nil
 	}
 
 	// Check for other error responses
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("API error (status %d): %s: %w", resp.StatusCode, bodyBytes, ErrNotFound)
+		}
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, bodyBytes)
 	}
 
@@ -93,9 +223,83 @@ func (c *Client) makeRequest(method, path string, params url.Values) ([]byte, er
 		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 
+	// Remember the validators for the next request of this URL
+	if conditional {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			c.responses[reqURL] = &CachedResponse{ETag: etag, LastModified: lastModified, Body: body}
+		}
+	}
+
 	return body, nil
 }
 
+// retry waits before sending a failed request again
+func (c *Client) retry(attempt int, path, reason string) {
+	delay := backoff(attempt)
+	c.logDebug(fmt.Sprintf("Request to %s failed (%s), retry %d of %d in %s",
+		path, reason, attempt+1, maxRetries, delay.Round(time.Millisecond)))
+	c.stats.Retries++
+	c.sleep(delay)
+}
+
+// closeBody reads whatever is left of a response body before closing it, so
+// the connection can be reused for the next request
+func closeBody(body io.ReadCloser) {
+	io.Copy(io.Discard, body)
+	body.Close()
+}
+
+// Stats returns the requests made so far
+func (c *Client) Stats() RequestStats {
+	return c.stats
+}
+
+// parseRateLimit reads Strava's rate limit headers, which hold the 15 minute
+// and daily values separated by a comma, e.g. "100,1000". It returns nil if
+// the headers are missing or malformed.
+func parseRateLimit(header http.Header) *RateLimit {
+	limits := strings.Split(header.Get("X-RateLimit-Limit"), ",")
+	usage := strings.Split(header.Get("X-RateLimit-Usage"), ",")
+	if len(limits) != 2 || len(usage) != 2 {
+		return nil
+	}
+
+	var values [4]int
+	for i, s := range []string{limits[0], usage[0], limits[1], usage[1]} {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil
+		}
+		values[i] = v
+	}
+
+	return &RateLimit{
+		ShortTermLimit:     values[0],
+		ShortTermUsage:     values[1],
+		ShortTermRemaining: values[0] - values[1],
+		DailyLimit:         values[2],
+		DailyUsage:         values[3],
+		DailyRemaining:     values[2] - values[3],
+	}
+}
+
+// cachedResponse returns the cached response for a URL, preferring one
+// fetched during this run
+func (c *Client) cachedResponse(reqURL string) *CachedResponse {
+	if cached, ok := c.responses[reqURL]; ok {
+		return cached
+	}
+	return c.previous[reqURL]
+}
+
+// isConditionalPath reports whether responses from an API path are cached
+// for conditional requests. Only the athlete profile and activity pages are
+// requested on every run; detailed activities are cached with the activities.
+func isConditionalPath(path string) bool {
+	return path == "/athlete" || path == activitiesPath
+}
+
 // GetAthlete gets the authenticated athlete's profile
 func (c *Client) GetAthlete() (map[string]interface{}, error) {
 	body, err := c.makeRequest("GET", "/athlete", nil)
@@ -111,6 +315,22 @@ func (c *Client) GetAthlete() (map[string]interface{}, error) {
 	return athlete, nil
 }
 
+// GetAthleteStats gets the activity totals of an athlete, who must be the
+// authenticated one
+func (c *Client) GetAthleteStats(athleteID int64) (*AthleteStats, error) {
+	body, err := c.makeRequest("GET", fmt.Sprintf("/athletes/%d/stats", athleteID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats AthleteStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return nil, fmt.Errorf("error parsing athlete stats: %w", err)
+	}
+
+	return &stats, nil
+}
+
 // logDebug logs debug information if debug mode is enabled
 func (c *Client) logDebug(message string) {
 	if c.debug {
@@ -0,0 +1,247 @@
+package strava
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubResponse is a scripted answer to one request: a status with headers,
+// or a network error
+type stubResponse struct {
+	status int
+	header map[string]string
+	err    error
+}
+
+// stubTransport answers requests with its responses in order, repeating the
+// last one once they run out
+type stubTransport struct {
+	responses []stubResponse
+	sent      int
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response := s.responses[min(s.sent, len(s.responses)-1)]
+	s.sent++
+	if response.err != nil {
+		return nil, response.err
+	}
+
+	header := make(http.Header)
+	for name, value := range response.header {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		StatusCode: response.status,
+		Status:     http.StatusText(response.status),
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"id":1}`)),
+		Request:    req,
+	}, nil
+}
+
+// stubClient returns a client sending its requests through a stub transport
+// answering with responses, which records the waits it would sleep
+func stubClient(responses ...stubResponse) (*Client, *stubTransport, *[]time.Duration) {
+	stub := &stubTransport{responses: responses}
+	client := NewClient(staticToken("test-token"), false, HTTPOptions{})
+	client.httpClient = &http.Client{Transport: stub}
+
+	waits := []time.Duration{}
+	client.sleep = func(wait time.Duration) { waits = append(waits, wait) }
+	return client, stub, &waits
+}
+
+// rateLimited is a 429 with the given usage of a 100 request window and a
+// 1000 request day
+func rateLimited(usage string, retryAfter string) stubResponse {
+	header := map[string]string{"X-RateLimit-Limit": "100,1000", "X-RateLimit-Usage": usage}
+	if retryAfter != "" {
+		header["Retry-After"] = retryAfter
+	}
+	return stubResponse{status: http.StatusTooManyRequests, header: header}
+}
+
+// checkBackoffs verifies that each wait is a jittered backoff, doubling from
+// retryBaseDelay
+func checkBackoffs(t *testing.T, waits []time.Duration) {
+	t.Helper()
+
+	for i, wait := range waits {
+		full := min(retryBaseDelay<<i, maxRetryDelay)
+		if wait < full/2 || wait > full {
+			t.Errorf("backoff %d = %s, want between %s and %s", i+1, wait, full/2, full)
+		}
+	}
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	ok := stubResponse{status: http.StatusOK}
+	networkErr := stubResponse{err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name      string
+		responses []stubResponse
+		requests  int    // Sent through the transport
+		errText   string // Expected in the error, empty for success
+	}{
+		{
+			name:      "server errors then success",
+			responses: []stubResponse{{status: 500}, {status: 502}, {status: 503}, {status: 504}, ok},
+			requests:  5,
+		},
+		{
+			name:      "network error then success",
+			responses: []stubResponse{networkErr, ok},
+			requests:  2,
+		},
+		{
+			name:      "server errors exhausting the retries",
+			responses: []stubResponse{{status: 503}},
+			requests:  maxRetries + 1,
+			errText:   "API error (status 503)",
+		},
+		{
+			name:      "network errors exhausting the retries",
+			responses: []stubResponse{networkErr},
+			requests:  maxRetries + 1,
+			errText:   "connection reset by peer",
+		},
+		{
+			name:      "client errors aren't retried",
+			responses: []stubResponse{{status: http.StatusUnauthorized}, ok},
+			requests:  1,
+			errText:   "API error (status 401)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, stub, waits := stubClient(tt.responses...)
+
+			_, err := client.makeRequest("GET", "/activities/1", nil)
+			if tt.errText == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+				t.Fatalf("err = %v, want %q", err, tt.errText)
+			}
+
+			if stub.sent != tt.requests {
+				t.Errorf("sent %d requests, want %d", stub.sent, tt.requests)
+			}
+			if retries := client.Stats().Retries; retries != tt.requests-1 || len(*waits) != retries {
+				t.Errorf("retried %d times after %d waits, want %d", retries, len(*waits), tt.requests-1)
+			}
+			checkBackoffs(t, *waits)
+			if client.Stats().RateLimitWaitSeconds != 0 {
+				t.Errorf("counted %gs of rate limit waits, want none", client.Stats().RateLimitWaitSeconds)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRateLimit(t *testing.T) {
+	t.Run("short term window with Retry-After", func(t *testing.T) {
+		client, stub, waits := stubClient(rateLimited("100,500", "42"), stubResponse{status: http.StatusOK})
+
+		if _, err := client.makeRequest("GET", "/activities/1", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// Retry-After is waited out with a second to spare, and the window
+		// is assumed fresh rather than waited for again
+		if stub.sent != 2 || len(*waits) != 1 || (*waits)[0] != 43*time.Second {
+			t.Errorf("sent %d requests after waiting %v, want 2 after [43s]", stub.sent, *waits)
+		}
+		if stats := client.Stats(); stats.Retries != 1 || stats.RateLimitWaitSeconds != 43 {
+			t.Errorf("stats = %+v, want 1 retry and 43s waited", stats)
+		}
+	})
+
+	t.Run("short term window until the next quarter hour", func(t *testing.T) {
+		client, stub, waits := stubClient(rateLimited("100,500", ""), stubResponse{status: http.StatusOK})
+
+		if _, err := client.makeRequest("GET", "/activities/1", nil); err != nil {
+			t.Fatal(err)
+		}
+		if stub.sent != 2 || len(*waits) != 1 {
+			t.Fatalf("sent %d requests after waiting %v, want 2 after one wait", stub.sent, *waits)
+		}
+		if wait := (*waits)[0]; wait <= time.Second || wait > rateLimitWindow+time.Second {
+			t.Errorf("waited %s, want at most a window", wait)
+		}
+	})
+
+	t.Run("short term window limited every time", func(t *testing.T) {
+		client, stub, waits := stubClient(rateLimited("100,500", "5"))
+
+		_, err := client.makeRequest("GET", "/activities/1", nil)
+		if err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+			t.Fatalf("err = %v, want the rate limit", err)
+		}
+		if stub.sent != maxRetries+1 || len(*waits) != maxRetries {
+			t.Errorf("sent %d requests after %d waits, want %d after %d", stub.sent, len(*waits), maxRetries+1, maxRetries)
+		}
+	})
+
+	t.Run("reset too far away", func(t *testing.T) {
+		client, stub, waits := stubClient(rateLimited("100,500", "3600"), stubResponse{status: http.StatusOK})
+
+		_, err := client.makeRequest("GET", "/activities/1", nil)
+		if err == nil || !strings.Contains(err.Error(), "rate limit exceeded, reset at") {
+			t.Fatalf("err = %v, want the rate limit reset", err)
+		}
+		if stub.sent != 1 || len(*waits) != 0 {
+			t.Errorf("sent %d requests after waiting %v, want to fail on the first without waiting", stub.sent, *waits)
+		}
+	})
+
+	t.Run("daily limit", func(t *testing.T) {
+		client, stub, waits := stubClient(rateLimited("40,1000", "60"), stubResponse{status: http.StatusOK})
+
+		_, err := client.makeRequest("GET", "/activities/1", nil)
+		if err == nil || !strings.Contains(err.Error(), "daily rate limit exceeded") {
+			t.Fatalf("err = %v, want the daily rate limit", err)
+		}
+		if stub.sent != 1 || len(*waits) != 0 {
+			t.Errorf("sent %d requests after waiting %v, want to fail on the first without waiting", stub.sent, *waits)
+		}
+		if stats := client.Stats(); stats.RateLimit == nil || stats.RateLimit.DailyRemaining != 0 {
+			t.Errorf("rate limit = %+v, want the day used up", stats.RateLimit)
+		}
+	})
+}
+
+func TestGetFirstActivityDate(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want time.Time
+	}{
+		{name: "activities", body: `[{"id":7,"start_date":"2014-05-03T08:15:00Z"}]`, want: time.Date(2014, 5, 3, 8, 15, 0, 0, time.UTC)},
+		{name: "none", body: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(staticToken("test-token"), false, HTTPOptions{})
+			client.httpClient = &http.Client{Transport: bodyTransport(tt.body)}
+
+			got, err := client.GetFirstActivityDate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("GetFirstActivityDate() = %s, want %s", got, tt.want)
+			}
+			if stats := client.Stats(); stats.Requests != 1 {
+				t.Errorf("made %d requests, want 1", stats.Requests)
+			}
+		})
+	}
+}
@@ -12,45 +12,99 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	AccessToken  string `json:"access_token"`
 	Athlete      struct {
-		ID int64 `json:"id"`
+		ID        int64  `json:"id"`
+		Username  string `json:"username"`
+		Firstname string `json:"firstname"`
 	} `json:"athlete"`
 }
 
 // SummaryActivity represents a summary of an activity from Strava API
 type SummaryActivity struct {
-	ID               int64     `json:"id"`
-	Name             string    `json:"name"`
-	Distance         float64   `json:"distance"`             // In meters
-	MovingTime       int       `json:"moving_time"`          // In seconds
-	ElapsedTime      int       `json:"elapsed_time"`         // In seconds
-	TotalElevGain    float64   `json:"total_elevation_gain"` // In meters
-	Type             string    `json:"type"`
-	StartDate        time.Time `json:"start_date"`
-	StartDateLocal   time.Time `json:"start_date_local"`
-	Timezone         string    `json:"timezone"`
-	AchievementCount int       `json:"achievement_count"`
-	PRCount          int       `json:"pr_count,omitempty"` // Number of PRs in this activity
-	AverageHeartrate float64   `json:"average_heartrate,omitempty"`
-	MaxHeartrate     float64   `json:"max_heartrate,omitempty"`
-	StartLatlng      []float64 `json:"start_latlng,omitempty"`
-	EndLatlng        []float64 `json:"end_latlng,omitempty"`
-	Map              struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Distance          float64   `json:"distance"`             // In meters
+	MovingTime        int       `json:"moving_time"`          // In seconds
+	ElapsedTime       int       `json:"elapsed_time"`         // In seconds
+	TotalElevGain     float64   `json:"total_elevation_gain"` // In meters
+	Type              string    `json:"type"`
+	StartDate         time.Time `json:"start_date"`
+	StartDateLocal    time.Time `json:"start_date_local"`
+	Timezone          string    `json:"timezone"`
+	AchievementCount  int       `json:"achievement_count"`
+	PRCount           int       `json:"pr_count,omitempty"`     // Number of PRs in this activity, counted from its efforts once details are fetched
+	WorkoutType       int       `json:"workout_type,omitempty"` // 1 race, 2 long run, 3 workout for runs; 11 race, 12 workout for rides
+	AverageHeartrate  float64   `json:"average_heartrate,omitempty"`
+	MaxHeartrate      float64   `json:"max_heartrate,omitempty"`
+	Kilojoules        float64   `json:"kilojoules,omitempty"`               // Work done, rides with power only
+	Calories          float64   `json:"calories,omitempty"`                 // Detailed activities only
+	Description       string    `json:"description,omitempty"`              // Detailed activities only
+	DetailsFetched    bool      `json:"details_fetched,omitempty"`          // Set once the detailed activity filled in the fields above
+	CorrectedElevGain *float64  `json:"corrected_elevation_gain,omitempty"` // Recomputed from the altitude stream, nil until corrected
+	AverageWatts      float64   `json:"average_watts,omitempty"`
+	WeightedAvgWatts  float64   `json:"weighted_average_watts,omitempty"` // Strava's normalized power estimate
+	DeviceWatts       bool      `json:"device_watts,omitempty"`           // True if power is measured rather than estimated
+	AverageCadence    float64   `json:"average_cadence,omitempty"`
+	StartLatlng       []float64 `json:"start_latlng,omitempty"`
+	EndLatlng         []float64 `json:"end_latlng,omitempty"`
+	Map               struct {
 		SummaryPolyline string `json:"summary_polyline"`
 	} `json:"map,omitempty"`
 }
 
+// DetailedActivity represents the full representation of an activity from
+// Strava API, which includes fields missing from the summary
+type DetailedActivity struct {
+	SummaryActivity
+	SegmentEfforts []Effort `json:"segment_efforts,omitempty"`
+	BestEfforts    []Effort `json:"best_efforts,omitempty"` // Fastest times over standard distances, runs only
+}
+
+// Effort is a segment or best effort within a detailed activity
+type Effort struct {
+	Name   string `json:"name"`
+	PRRank int    `json:"pr_rank,omitempty"` // 1 for the athlete's fastest, 2 or 3 for their next fastest, 0 otherwise
+}
+
+// PRs counts the segment and best efforts that set a personal record
+func (d *DetailedActivity) PRs() int {
+	count := 0
+	for _, efforts := range [][]Effort{d.SegmentEfforts, d.BestEfforts} {
+		for _, effort := range efforts {
+			if effort.PRRank == 1 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 // DailyActivity represents aggregated activities for a single day
 type DailyActivity struct {
-	Date           time.Time
-	Count          int
-	TotalDistance  float64        // In meters
-	TotalDuration  int            // In seconds
-	TotalElevation float64        // In meters
-	Activities     []int64        // IDs of activities on this day
-	MaxHeartRate   float64        // Max heart rate among all activities
-	AvgHeartRate   float64        // Average heart rate across all activities
-	HasPR          bool           // True if any activity on this day has a PR
-	Types          map[string]int // Count of each activity type
+	Date               time.Time
+	Count              int
+	TotalDistance      float64        // In meters
+	EquivalentDistance float64        // Meters credited to distance-less activities from their duration, 0 without the fallback
+	TotalDuration      int            // In seconds
+	TotalElevation     float64        // In meters
+	TotalKilojoules    float64        // Work done in kilojoules
+	TotalCalories      float64        // Energy burned in kcal
+	AvgPower           float64        // Average power in watts, weighted by moving time
+	NormalizedPower    float64        // Normalized power estimate in watts
+	PowerDuration      int            // Seconds of activity with power data
+	AvgCadence         float64        // Average cadence as reported by Strava
+	CadenceDuration    int            // Seconds of activity with cadence data
+	TrainingStress     float64        // TSS-like score relative to FTP
+	CompositeScore     float64        // Weighted blend of normalized metrics from 0 to 1, set by a scorer
+	IntensityFactor    float64        // Normalized power as a fraction of FTP
+	Activities         []int64        // IDs of activities on this day
+	MaxHeartRate       float64        // Max heart rate among all activities
+	AvgHeartRate       float64        // Average heart rate across all activities
+	HasPR              bool           // True if any activity on this day has a PR
+	PreDawnCount       int            // Activities started before sunrise
+	AfterDarkCount     int            // Activities started after sunset
+	Tags               map[string]int // Count of activities with each config-defined tag
+	Types              map[string]int // Count of each activity type
+	Workouts           map[string]int // Count of each workout kind, such as races and long runs
 }
 
 // HeatmapIntensity represents the intensity level for the heatmap cell
@@ -66,21 +120,60 @@ const (
 
 // ActivityStats represents summary statistics about activities
 type ActivityStats struct {
-	TotalActivities int
-	TotalDistance   float64 // In kilometers
-	TotalDuration   int     // In hours
-	TotalElevation  float64 // In meters
-	ActivityTypes   map[string]int
-	PRCount         int
-	ActiveDays      int
-	LongestStreak   int
+	TotalActivities int            `json:"totalActivities"`
+	TotalDistance   float64        `json:"totalDistance"`  // In kilometers
+	TotalDuration   int            `json:"totalDuration"`  // In hours
+	TotalSeconds    int            `json:"totalSeconds"`   // Total duration in seconds, for display
+	TotalElevation  float64        `json:"totalElevation"` // In meters
+	ActivityTypes   map[string]int `json:"activityTypes"`
+	PRCount         int            `json:"prCount"`
+	ActiveDays      int            `json:"activeDays"`
+	LongestStreak   int            `json:"longestStreak"`
+	PreDawn         int            `json:"preDawn"`   // Activities started before sunrise
+	AfterDark       int            `json:"afterDark"` // Activities started after sunset
 }
 
 // DatePeriodStats represents statistics for a specific time period
 type DatePeriodStats struct {
-	Period         string // "weekly", "monthly", "yearly"
-	TotalDistance  float64
-	TotalDuration  int
-	TotalElevation float64
-	ActivityCount  int
+	Period         string  `json:"period"` // "weekly", "monthly", "yearly"
+	TotalDistance  float64 `json:"totalDistance"`
+	TotalDuration  int     `json:"totalDuration"`
+	TotalElevation float64 `json:"totalElevation"`
+	TotalEnergy    float64 `json:"totalEnergy"` // In kcal
+	ActivityCount  int     `json:"activityCount"`
+}
+
+// ActivityTotal is an athlete's activity count and totals for one sport
+// over a period
+type ActivityTotal struct {
+	Count    int     `json:"count"`
+	Distance float64 `json:"distance"`    // In meters
+	Time     int     `json:"moving_time"` // In seconds
+}
+
+// AthleteStats holds an athlete's totals for the last four weeks, the year
+// to date and all time. Strava only keeps them for runs, rides and swims.
+type AthleteStats struct {
+	RecentRunTotals  ActivityTotal `json:"recent_run_totals"`
+	RecentRideTotals ActivityTotal `json:"recent_ride_totals"`
+	RecentSwimTotals ActivityTotal `json:"recent_swim_totals"`
+	YTDRunTotals     ActivityTotal `json:"ytd_run_totals"`
+	YTDRideTotals    ActivityTotal `json:"ytd_ride_totals"`
+	YTDSwimTotals    ActivityTotal `json:"ytd_swim_totals"`
+	AllRunTotals     ActivityTotal `json:"all_run_totals"`
+	AllRideTotals    ActivityTotal `json:"all_ride_totals"`
+	AllSwimTotals    ActivityTotal `json:"all_swim_totals"`
+}
+
+// WebhookEvent is a push notification from a Strava webhook subscription,
+// sent when an athlete creates, updates or deletes an activity or revokes
+// access
+type WebhookEvent struct {
+	ObjectType     string            `json:"object_type"` // "activity" or "athlete"
+	ObjectID       int64             `json:"object_id"`   // Activity or athlete ID
+	AspectType     string            `json:"aspect_type"` // "create", "update" or "delete"
+	Updates        map[string]string `json:"updates,omitempty"`
+	OwnerID        int64             `json:"owner_id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	EventTime      int64             `json:"event_time"` // Unix timestamp
 }
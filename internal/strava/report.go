@@ -0,0 +1,57 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FetchReport summarizes a run's interaction with the Strava API, written as
+// JSON so scheduled runs can be monitored
+type FetchReport struct {
+	StartedAt         time.Time `json:"startedAt"`
+	DurationSeconds   float64   `json:"durationSeconds"`
+	Incremental       bool      `json:"incremental"`       // Only recent activities were fetched, the rest came from the cache
+	ActivitiesFetched int       `json:"activitiesFetched"` // Activities returned by the API
+	ActivitiesAdded   int       `json:"activitiesAdded"`   // Activities not seen on an earlier run
+	ActivitiesUpdated int       `json:"activitiesUpdated"` // Cached activities that changed
+	ActivitiesRemoved int       `json:"activitiesRemoved"` // Cached activities deleted on Strava
+	TotalActivities   int       `json:"totalActivities"`   // Activities in the fetch range after merging
+	BudgetExhausted   bool      `json:"budgetExhausted"`   // Fetching stopped early at maxApiRequests
+	RequestStats
+}
+
+// NewFetchReport starts a report for a run beginning now
+func NewFetchReport() *FetchReport {
+	return &FetchReport{StartedAt: time.Now()}
+}
+
+// Finish records the client's request counts and the run's duration
+func (r *FetchReport) Finish(client *Client) {
+	r.RequestStats = client.Stats()
+	r.DurationSeconds = time.Since(r.StartedAt).Round(time.Millisecond).Seconds()
+}
+
+// JSON returns the report as single-line JSON
+func (r *FetchReport) JSON() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling fetch report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Write saves the report as indented JSON
+func (r *FetchReport) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling fetch report: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing fetch report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package strava
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxRetries       = 4                // Retries of a request after transient failures
+	retryBaseDelay   = time.Second      // Backoff before the first retry, doubled for each one after
+	maxRetryDelay    = 30 * time.Second // Longest backoff between retries
+	maxRateLimitWait = 15 * time.Minute // Longest wait for the rate limit window to reset
+	rateLimitWindow  = 15 * time.Minute // Strava's short term window, reset at each quarter hour
+)
+
+// isRetryableStatus reports whether a response status is a transient server
+// error worth retrying
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before a retry, doubling from retryBaseDelay up
+// to maxRetryDelay. The delay is jittered between half and all of it, so
+// clients failing together don't retry together.
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// nextRateLimitWindow returns when the short term window after now begins.
+// Strava resets the window at every quarter hour.
+func nextRateLimitWindow(now time.Time) time.Time {
+	return now.Truncate(rateLimitWindow).Add(rateLimitWindow)
+}
+
+// rateLimitWait returns how long to wait before retrying a request refused
+// with 429 Too Many Requests. Waiting out the 15 minute window is fine, but
+// a used up daily limit, or a reset further away than maxRateLimitWait, is
+// returned as an error.
+func rateLimitWait(header http.Header, now time.Time) (time.Duration, error) {
+	if rateLimit := parseRateLimit(header); rateLimit != nil && rateLimit.DailyRemaining <= 0 {
+		return 0, fmt.Errorf("daily rate limit exceeded, reset at %s",
+			now.UTC().Truncate(24*time.Hour).Add(24*time.Hour).Format(time.RFC3339))
+	}
+
+	reset := nextRateLimitWindow(now)
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		reset = now.Add(time.Duration(seconds) * time.Second)
+	} else if unix, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(unix, 0)
+	}
+
+	wait := reset.Sub(now)
+	if wait > maxRateLimitWait {
+		return 0, fmt.Errorf("rate limit exceeded, reset at %s", reset.Format(time.RFC3339))
+	}
+	return max(wait, 0) + time.Second, nil
+}
+
+// waitForRateLimit sleeps until the next window when the last response
+// showed the 15 minute limit used up but requests left for the day, rather
+// than sending a request bound to be refused
+func (c *Client) waitForRateLimit() {
+	rateLimit := c.stats.RateLimit
+	if rateLimit == nil || rateLimit.ShortTermRemaining > 0 || rateLimit.DailyRemaining <= 0 {
+		return
+	}
+
+	wait := time.Until(nextRateLimitWindow(time.Now())) + time.Second
+	c.logDebug(fmt.Sprintf("15 minute rate limit used up, waiting %s for the next window", wait.Round(time.Second)))
+	c.pause(wait)
+}
+
+// pause sleeps for a rate limit wait, adding it to the stats. The window
+// after the wait is assumed to start with the full limit, until the next
+// response reports otherwise.
+func (c *Client) pause(wait time.Duration) {
+	c.sleep(wait)
+	c.stats.RateLimitWaitSeconds += wait.Round(time.Second).Seconds()
+
+	if rateLimit := c.stats.RateLimit; rateLimit != nil {
+		rateLimit.ShortTermUsage = 0
+		rateLimit.ShortTermRemaining = rateLimit.ShortTermLimit
+	}
+}
@@ -0,0 +1,13 @@
+package strava
+
+import "time"
+
+// ActivitySource is a provider activities can be fetched from, such as the
+// Strava API, Garmin Connect or exported files, returning them as Strava
+// summaries
+type ActivitySource interface {
+	// FetchActivities returns the activities started between start and end,
+	// of the given types if any. A source that runs out of requests returns
+	// what it fetched and warns instead of failing.
+	FetchActivities(start, end time.Time, types []string) ([]SummaryActivity, error)
+}
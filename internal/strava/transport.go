@@ -0,0 +1,112 @@
+package strava
+
+import (
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Version identifies this build in the User-Agent, set at build time with
+// -ldflags "-X github.com/samuellee/StravaGraph/internal/strava.Version=v1.2.3"
+var Version = "dev"
+
+// BuildVersion returns Version, or when it wasn't set, the commit the binary
+// was built from as Go records it, marked -dirty for uncommitted changes.
+// Builds without either, such as tests, are "dev".
+func BuildVersion() string {
+	if Version != "dev" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+
+	revision, dirty := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			dirty = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// DefaultTimeout bounds each request, including reading the response body
+const DefaultTimeout = 30 * time.Second
+
+// DefaultUserAgent names the app, its version and where to find it, so
+// Strava can tell its requests apart from other tools sharing the app
+func DefaultUserAgent() string {
+	return "StravaGraph/" + BuildVersion() + " (+https://github.com/leesamuel423/StravaGraph)"
+}
+
+// transport is shared by every client so paginated and detail requests reuse
+// kept-alive connections to the API rather than reconnecting each time
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          10,
+	MaxIdleConnsPerHost:   4,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
+// HTTPOptions configures the HTTP client used for API requests
+type HTTPOptions struct {
+	Timeout   time.Duration // DefaultTimeout if 0
+	UserAgent string        // DefaultUserAgent if empty
+	Cassette  *Cassette     // Records or replays requests, nil to call the API directly
+}
+
+// NewHTTPClient returns a client on the shared transport that sends the
+// configured User-Agent with every request
+func NewHTTPClient(options HTTPOptions) *http.Client {
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = DefaultUserAgent()
+	}
+
+	var base http.RoundTripper = transport
+	if options.Cassette != nil {
+		base = options.Cassette.Transport(transport)
+	}
+
+	return &http.Client{
+		Timeout:   options.Timeout,
+		Transport: &userAgentTransport{userAgent: options.UserAgent, base: base},
+	}
+}
+
+// userAgentTransport sets the User-Agent header on outgoing requests
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+// RoundTrip sends the request with the User-Agent set. The request is cloned
+// since a RoundTripper must not modify its argument.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
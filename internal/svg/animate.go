@@ -0,0 +1,55 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+)
+
+// animationSpread is how many seconds pass between the first and the last
+// day starting to fade in
+const animationSpread = 3.0
+
+// animationStyle fades each day in after its delay. Days are drawn fully
+// opaque and only hidden while they wait for their turn, so renderers that
+// drop CSS animations, and readers who prefer reduced motion, see the
+// finished heatmap
+const animationStyle = `
+  .heatmap-day { animation: heatmap-fade-in 0.4s ease-out backwards; }
+  @keyframes heatmap-fade-in { from { opacity: 0; } to { opacity: 1; } }
+  @media (prefers-reduced-motion: reduce) { .heatmap-day { animation: none; } }`
+
+// openDay starts the group that fades in a day's cell together with its
+// markers and tooltip, if the heatmap is animated
+func (h *HeatmapData) openDay(sb *strings.Builder, date time.Time) {
+	if !h.Animate {
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<g class="heatmap-day" style="animation-delay: %.2fs">`, h.animationDelay(date)))
+}
+
+// closeDay ends the group started by openDay
+func (h *HeatmapData) closeDay(sb *strings.Builder) {
+	if h.Animate {
+		sb.WriteString(`</g>`)
+	}
+}
+
+// animationDelay returns when a day starts to fade in, spreading the days
+// evenly over animationSpread in date order. A row of a split heatmap times
+// its days within the whole range, so the rows fill in one after the other
+func (h *HeatmapData) animationDelay(date time.Time) float64 {
+	source := h
+	if h.whole != nil {
+		source = h.whole
+	}
+
+	days := processor.DaysBetween(source.StartDate, source.EndDate)
+	if days <= 0 {
+		return 0
+	}
+	day := max(processor.DaysBetween(source.StartDate, date), 0)
+	return animationSpread * float64(day) / float64(days)
+}
@@ -0,0 +1,34 @@
+package svg
+
+// Geometry of GitHub's contribution graph, which the compact layout matches
+const (
+	compactWeeks       = 53
+	compactCellSize    = 11
+	compactCellSpacing = 2
+)
+
+// compact returns the heatmap in the layout of GitHub's contribution graph,
+// with its cell size and at most its 53 weeks, keeping the latest weeks of a
+// longer range
+func (h *HeatmapData) compact() *HeatmapData {
+	c := *h
+	c.Compact = true
+	c.CellSize = compactCellSize
+
+	if from := len(h.Cells) - compactWeeks; from > 0 {
+		c.Cells = h.Cells[from:]
+		if h.WeekVolumes != nil {
+			c.WeekVolumes = h.WeekVolumes[from:]
+		}
+		if h.Phases != nil {
+			c.Phases = h.Phases[from:]
+		}
+		if h.Ratios != nil {
+			c.Ratios = h.Ratios[from:]
+		}
+		c.StartDate = laterDate(h.StartDate, c.Cells[0][0].Date)
+		c.generateLabels()
+	}
+
+	return &c
+}
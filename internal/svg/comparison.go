@@ -0,0 +1,154 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// sameChange is the relative change within which a day counts as the same
+// as last year in the diff view
+const sameChange = 0.1
+
+// changeClasses are the classes of the diff view's change levels, from much
+// less to much more than last year
+var changeClasses = []string{"change-down-2", "change-down-1", "change-same", "change-up-1", "change-up-2"}
+
+// changeColors are the colors of the change levels, red for less and green
+// for more, with brighter ends in dark mode
+var (
+	changeColors     = []string{"#cf222e", "#ff8182", "#8c959f", "#4ac26b", "#1a7f37"}
+	darkChangeColors = []string{"#ff7b72", "#b62324", "#6e7681", "#238636", "#56d364"}
+)
+
+// changeLevel compares a day's metric value with the same day last year: 2
+// for at least double, 1 for more, 0 for within sameChange, -1 for less and
+// -2 for at most half
+func changeLevel(current, previous float64) int {
+	switch {
+	case current == previous:
+		return 0
+	case previous == 0:
+		return 2
+	case current == 0:
+		return -2
+	}
+
+	ratio := current / previous
+	switch {
+	case ratio >= 2:
+		return 2
+	case ratio > 1+sameChange:
+		return 1
+	case ratio >= 1/(1+sameChange):
+		return 0
+	case ratio > 0.5:
+		return -1
+	default:
+		return -2
+	}
+}
+
+// hasComparison reports whether the day or the same day last year had an
+// activity, leaving days of rest in both years uncolored in the diff view
+func (c *HeatmapCell) hasComparison() bool {
+	return c.Count > 0 || c.Ghost != strava.None
+}
+
+// changeClass returns the class coloring a cell in the diff view
+func changeClass(cell *HeatmapCell) string {
+	if !cell.hasComparison() {
+		return "intensity-0"
+	}
+	return changeClasses[cell.Change+2]
+}
+
+// addChanges compares each displayed day with the day 52 weeks earlier, so
+// both fall on the same weekday, and notes last year's value and the change
+// in the cell tooltips
+func (h *HeatmapData) addChanges(activities, previousYear []*strava.DailyActivity, metricType string) {
+	current := make(map[string]*strava.DailyActivity)
+	for _, activity := range activities {
+		current[activity.Date.Format("2006-01-02")] = activity
+	}
+	previous := make(map[string]*strava.DailyActivity)
+	for _, activity := range previousYear {
+		previous[activity.Date.Format("2006-01-02")] = activity
+	}
+
+	nf := processor.GetNumberFormat(h.Language)
+	tr := processor.GetTranslation(h.Language)
+	unit := processor.MetricUnit(metricType, h.Units)
+	format := func(value float64) string {
+		display := nf.FormatFloat(processor.MetricDisplayValue(value, metricType, h.Units), 1)
+		if unit == "" {
+			return display
+		}
+		return nf.WithUnit(display, unit)
+	}
+
+	for _, column := range h.Cells {
+		for _, cell := range column {
+			var value, lastValue float64
+			if activity, ok := current[cell.Date.Format("2006-01-02")]; ok && activity.Count > 0 {
+				value = processor.MetricValue(activity, metricType)
+			}
+			last, ok := previous[cell.Date.AddDate(0, 0, -ghostOffsetDays).Format("2006-01-02")]
+			if ok && last.Count > 0 {
+				lastValue = processor.MetricValue(last, metricType)
+				cell.Ghost = intensityForValue(lastValue, h.Thresholds)
+			}
+			cell.Change = changeLevel(value, lastValue)
+
+			// Exact numbers are hidden in privacy mode
+			switch {
+			case cell.Ghost == strava.None:
+				cell.Tooltip += "\n" + tr.T("Same day last year: no activities")
+			case h.PrivacyMode:
+				cell.Tooltip += "\n" + tr.T("Same day last year: %s", tr.T(intensityLabel(cell.Ghost)+" day"))
+			default:
+				cell.Tooltip += "\n" + tr.T("Same day last year: %s (%s)",
+					format(lastValue), formatDelta(value, lastValue, nf, tr, false))
+			}
+		}
+	}
+}
+
+// writeChangeStyle adds the classes coloring the diff view's change levels
+func (h *HeatmapData) writeChangeStyle(sb *strings.Builder) {
+	for i, class := range changeClasses {
+		sb.WriteString(fmt.Sprintf(`
+  .%s { fill: %s; }`, class, changeColors[i]))
+	}
+	if h.DarkModeSupport {
+		sb.WriteString(`
+  @media (prefers-color-scheme: dark) {`)
+		for i, class := range changeClasses {
+			sb.WriteString(fmt.Sprintf(`
+    .%s { fill: %s; }`, class, darkChangeColors[i]))
+		}
+		sb.WriteString(`
+  }`)
+	}
+}
+
+// writeCaption writes the caption left of the month labels, if any
+func (h *HeatmapData) writeCaption(sb *strings.Builder) {
+	if h.Caption == "" {
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-month-label" text-anchor="end">%s</text>`,
+		h.Layout.DayLabelX, h.Layout.MonthLabelY, h.Caption))
+}
+
+// yearCaption returns the years a range covers, such as "2025" or "2025–26"
+func yearCaption(start, end time.Time) string {
+	if start.Year() == end.Year() {
+		return start.Format("2006")
+	}
+	return fmt.Sprintf("%d–%s", start.Year(), end.Format("06"))
+}
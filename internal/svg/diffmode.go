@@ -0,0 +1,82 @@
+package svg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// startTagRegex matches an opening or self-closing tag and its attributes
+	startTagRegex = regexp.MustCompile(`<([a-zA-Z][\w:-]*)((?:\s+[\w:-]+="[^"]*")*)\s*(/?)>`)
+
+	// attributeRegex matches a single name="value" attribute
+	attributeRegex = regexp.MustCompile(`([\w:-]+)="([^"]*)"`)
+
+	// decimalRegex matches numbers with more than two decimal places
+	decimalRegex = regexp.MustCompile(`-?\d+\.\d{3,}`)
+
+	// commentRegex matches XML comments, which may carry volatile metadata
+	commentRegex = regexp.MustCompile(`<!--[\s\S]*?-->`)
+)
+
+// MakeDiffFriendly rewrites an SVG so that re-rendering it with slightly
+// different data produces a minimal line-based diff. Attributes are sorted,
+// coordinates are rounded, comments are dropped and each element is placed
+// on its own line.
+func MakeDiffFriendly(svg string) string {
+	// Drop comments, which may contain timestamps or other volatile content
+	svg = commentRegex.ReplaceAllString(svg, "")
+
+	// Sort attributes and round coordinates within each tag
+	svg = startTagRegex.ReplaceAllStringFunc(svg, normalizeTag)
+
+	// Put each element on its own line
+	svg = strings.ReplaceAll(svg, "><", ">\n<")
+
+	if !strings.HasSuffix(svg, "\n") {
+		svg += "\n"
+	}
+
+	return svg
+}
+
+// normalizeTag sorts a tag's attributes by name and rounds numeric values
+func normalizeTag(tag string) string {
+	parts := startTagRegex.FindStringSubmatch(tag)
+	if len(parts) != 4 {
+		return tag
+	}
+
+	name, attrs, selfClosing := parts[1], parts[2], parts[3]
+
+	matches := attributeRegex.FindAllStringSubmatch(attrs, -1)
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i][1] < matches[j][1]
+	})
+
+	var sb strings.Builder
+	sb.WriteString("<" + name)
+	for _, attr := range matches {
+		sb.WriteString(fmt.Sprintf(` %s="%s"`, attr[1], roundDecimals(attr[2])))
+	}
+	if selfClosing != "" {
+		sb.WriteString(" /")
+	}
+	sb.WriteString(">")
+
+	return sb.String()
+}
+
+// roundDecimals rounds every number in a value to two decimal places
+func roundDecimals(value string) string {
+	return decimalRegex.ReplaceAllStringFunc(value, func(number string) string {
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return number
+		}
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	})
+}
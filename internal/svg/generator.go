@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/samuellee/StravaGraph/internal/config"
 	"github.com/samuellee/StravaGraph/internal/processor"
@@ -13,8 +14,11 @@ import (
 
 // Generator handles SVG generation
 type Generator struct {
-	Config *config.Config
-	Debug  bool
+	Config       *config.Config
+	Debug        bool
+	RampWarnings []processor.RampWarning // Weeks above the ACWR threshold, set by GenerateHeatmap
+	// Weeks with an elevated average heart rate, set by GenerateHeatmap
+	HeartRateWarnings []processor.HeartRateWarning
 }
 
 // NewGenerator creates a new SVG generator
@@ -27,8 +31,40 @@ func NewGenerator(cfg *config.Config) *Generator {
 
 // GenerateHeatmap creates a heatmap SVG from activity data
 func (g *Generator) GenerateHeatmap(activities []strava.SummaryActivity) (string, error) {
+	// Hash the settings before the timezone and first activity are filled in
+	configHash := g.Config.Hash()
+
+	// Strict mode refuses to draw in the GitHub colors in place of an
+	// unknown theme
+	if g.Config.Strict {
+		if _, err := LookupTheme(g.Config.ColorScheme, g.Config.CustomColors); err != nil {
+			return "", err
+		}
+	}
+
+	// Infer the timezone from the activities if none is configured
+	if g.Config.TimeZone == "" {
+		if inferred := processor.InferTimeZone(activities); inferred != "" {
+			g.Config.TimeZone = inferred
+			fmt.Fprintf(os.Stderr, "Inferred timezone %s from activities\n", inferred)
+		} else if g.Config.Strict {
+			return "", fmt.Errorf("could not infer timezone from activities, set timeZone")
+		} else {
+			fmt.Fprintf(os.Stderr, "Could not infer timezone from activities, using UTC\n")
+		}
+	}
+
+	// Start the "all" range at the first activity rather than years of
+	// empty weeks before it
+	if g.Config.FirstActivity.IsZero() {
+		g.Config.FirstActivity = processor.FirstActivityDate(activities)
+	}
+
 	// Get timezone location
 	location, err := g.Config.GetTimeZoneLocation()
+	if err != nil && g.Config.Strict {
+		return "", err
+	}
 	if err != nil && g.Debug {
 		// Use stderr to avoid polluting the SVG output
 		fmt.Fprintf(os.Stderr, "[DEBUG] %v\n", err)
@@ -41,42 +77,198 @@ func (g *Generator) GenerateHeatmap(activities []strava.SummaryActivity) (string
 	}
 
 	// Create activity aggregator
-	aggregator := processor.NewActivityAggregator(activities, location)
+	aggregator := processor.NewActivityAggregator(activities, location, float64(g.Config.FTP))
+	if len(g.Config.Tags) > 0 {
+		aggregator.Tagger = processor.NewTagger(g.Config.Tags)
+	}
+	aggregator.Fallback = g.Config.DistancelessFallback
+	aggregator.TimeBasis = g.Config.TimeBasis
 	aggregator.Aggregate()
 
 	// Convert map to ordered slice
 	orderedDailyData := aggregator.GetOrderedDates(startDate, endDate)
 
-	// Create heatmap data
-	heatmapData := NewHeatmapData(
-		orderedDailyData,
-		startDate,
-		endDate,
-		g.Config.ColorScheme,
-		g.Config.CustomColors,
-		g.Config.DarkModeColors,
-		g.Config.CellSize,
-		g.Config.WeekStart,
-		g.Config.DarkModeSupport,
-		g.Config.MetricType,
-	)
+	// Collect the days used to normalize intensity
+	normStart, normEnd, err := g.Config.GetNormalizationRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting normalization range: %w", err)
+	}
+	referenceData := aggregator.GetOrderedDates(normStart, normEnd)
+
+	// Blend the weighted metrics into a score for the composite metric
+	if g.Config.MetricType == "composite" || g.Config.SecondaryMetric == "composite" {
+		processor.NewScorer(g.Config.MetricWeights, referenceData).ScoreAll(aggregator.DailyData)
+	}
+
+	// Resolve annotation dates in the configured timezone
+	annotations, err := g.buildAnnotations(location)
+	if err != nil {
+		return "", err
+	}
+
+	// Mark where yearly distance passed each milestone, unless numbers are
+	// hidden
+	var milestones []processor.MilestoneCrossing
+	if len(g.Config.DistanceMilestones) > 0 && !g.Config.PrivacyMode {
+		yearStart, _, err := g.Config.GetMilestoneRange()
+		if err != nil {
+			return "", fmt.Errorf("error getting milestone range: %w", err)
+		}
+		scale := processor.GetUnitRule("", g.Config.Language, g.Config.Units).DistanceScale
+		milestones = processor.MilestoneCrossings(aggregator.GetOrderedDates(yearStart, endDate), g.Config.DistanceMilestones, scale)
+	}
+
+	// Streaks of at least this many active days are outlined
+	streakMinDays := 0
+	if g.Config.HighlightStreaks {
+		streakMinDays = g.Config.StreakMinDays
+		if streakMinDays == 0 {
+			streakMinDays = DefaultStreakMinDays
+		}
+	}
+
+	// Days 52 weeks earlier, drawn as the ghost overlay or compared with
+	comparison := g.Config.ComparisonMode == "yoy"
+	var previousYear []*strava.DailyActivity
+	var ghostStart, ghostEnd time.Time
+	if g.Config.GhostPreviousYear || comparison {
+		ghostStart, ghostEnd, err = g.Config.GetGhostRange()
+		if err != nil {
+			return "", fmt.Errorf("error getting ghost range: %w", err)
+		}
+		previousYear = aggregator.GetOrderedDates(ghostStart, ghostEnd)
+	}
+
+	// Stacked comparisons draw last year as a heatmap of its own rather
+	// than on the cells
+	diffView := comparison && g.Config.ComparisonView == "diff"
+	stacked := comparison && !diffView
+	cellPreviousYear := previousYear
+	if stacked {
+		cellPreviousYear = nil
+	}
+
+	// Create heatmap data, binned against the same reference days however
+	// many are drawn so their levels compare directly
+	newHeatmapData := func(days []*strava.DailyActivity, start, end time.Time, milestones []processor.MilestoneCrossing, previousYear []*strava.DailyActivity) *HeatmapData {
+		return NewHeatmapData(
+			days,
+			referenceData,
+			start,
+			end,
+			g.Config.ColorScheme,
+			g.Config.CustomColors,
+			g.Config.DarkModeColors,
+			g.Config.CellSize,
+			g.Config.GetWeekStart(),
+			g.Config.DarkModeSupport,
+			g.Config.MetricType,
+			g.Config.PrivacyMode,
+			g.Config.LegendUnits,
+			g.Config.Language,
+			g.Config.Units,
+			g.Config.LegendRanges,
+			g.Config.WeekNumbers,
+			g.Config.GetWeekLabelInterval(),
+			annotations,
+			g.Config.ShowAllMonthLabels,
+			g.Config.SecondaryMetric,
+			g.Config.SecondaryEncoding,
+			g.Config.DarkMarkers,
+			g.Config.Periodization,
+			g.Config.ACWRThreshold,
+			g.Config.Interactive,
+			g.Config.Animate,
+			g.Config.DurationStyle,
+			milestones,
+			streakMinDays,
+			g.Config.IntensityScale,
+			previousYear,
+			diffView,
+		)
+	}
+	heatmapData := newHeatmapData(orderedDailyData, startDate, endDate, milestones, cellPreviousYear)
+
+	// Summarize the weeks of a long all-time history before its latest
+	// years, which stay day by day
+	if detailStart, ok := g.Config.GetDetailStart(startDate, endDate); ok {
+		heatmapData.summarizeWeeks(orderedDailyData, processor.CivilDate(detailStart))
+	}
+
+	// Widgets only read the aggregator, so they render while the heatmap
+	// and stats are drawn and are composed below them at the end
+	widgetsDone := make(chan widgetResult, 1)
+	go func() {
+		widgets, err := g.renderWidgets(aggregator)
+		widgetsDone <- widgetResult{widgets: widgets, err: err}
+	}()
+
+	// Weeks with risky volume spikes, for the stats panel and step summary
+	g.RampWarnings = heatmapData.RampWarnings()
+	// Elevated heart rate is only reported for recent weeks, and only with the
+	// heart rate widget
+	if g.Config.HasWidget("heart_rate") {
+		warnings := processor.ElevatedHeartRateWeeks(processor.WeeklyHeartRate(orderedDailyData, g.weekStartDay()))
+		g.HeartRateWarnings = processor.RecentHeartRateWarnings(warnings, endDate)
+	}
 
 	// Generate SVG
-	svgContent := heatmapData.RenderSVG()
+	if stacked {
+		heatmapData.Caption = yearCaption(startDate, endDate)
+	}
+	svgContent := g.renderHeatmap(heatmapData)
+
+	// Add last year's heatmap below, with the same columns so each day sits
+	// under the same weekday a year later
+	if stacked {
+		lastYearData := newHeatmapData(previousYear, ghostStart, ghostEnd, nil, nil)
+		lastYearData.Caption = yearCaption(ghostStart, ghostEnd)
+		svgContent = combineWithWidgets(svgContent, []string{g.renderHeatmap(lastYearData)})
+	}
 
 	// Add stats if enabled
 	if g.Config.ShowStats {
-		statsGenerator := processor.NewStatsGenerator(orderedDailyData, startDate, endDate, g.Config.MetricType)
+		statsGenerator := processor.NewStatsGenerator(orderedDailyData, startDate, endDate, g.Config.MetricType, g.Config.Language, g.Config.Units)
 		stats := statsGenerator.GenerateStats()
 
 		statsSVG := g.generateStatsSVG(stats)
 
 		// Combine heatmap and stats
-		svgContent = g.combineHeatmapAndStats(svgContent, statsSVG)
+		svgContent = g.placePanel(svgContent, statsSVG)
 	}
 
-	// Sanity check to ensure we're returning valid SVG
-	if !strings.HasPrefix(svgContent, "<svg") {
+	// Add the location heatmap in the same way
+	if g.Config.IncludeLocationHeatmap {
+		locationSVG, err := g.GenerateLocationHeatmap(activities, g.Config.LocationPrivacyRadius)
+		if err != nil {
+			return "", err
+		}
+		svgContent = g.placePanel(svgContent, locationSVG)
+	}
+
+	// Add the weekly bar chart below, as wide as everything above it
+	if g.Config.ShowWeeklyChart {
+		width, _ := extractSVGDimensions(svgContent)
+		svgContent = combineWithWidgets(svgContent, []string{g.GenerateWeeklyBarChart(orderedDailyData, width)})
+	}
+
+	// Add the training load chart below in the same way
+	if g.Config.ShowTrainingLoad {
+		width, _ := extractSVGDimensions(svgContent)
+		history := aggregator.GetOrderedDates(startDate.AddDate(0, 0, -config.TrainingLoadWarmupDays), startDate.AddDate(0, 0, -1))
+		svgContent = combineWithWidgets(svgContent, []string{g.GenerateTrainingLoadChart(history, orderedDailyData, width)})
+	}
+
+	// Add widgets below the heatmap
+	result := <-widgetsDone
+	if result.err != nil {
+		return "", result.err
+	}
+	svgContent = g.placeWidgets(svgContent, result.widgets)
+
+	// Sanity check to ensure we're returning valid SVG, which strict mode
+	// leaves to the check below rather than trimming
+	if !strings.HasPrefix(svgContent, "<svg") && !g.Config.Strict {
 		if g.Debug {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Generated SVG does not start with <svg> tag!\n")
 		}
@@ -96,9 +288,36 @@ func (g *Generator) GenerateHeatmap(activities []strava.SummaryActivity) (string
 		return "", fmt.Errorf("generated content is not a valid SVG (does not start with <svg> tag)")
 	}
 
+	// Stabilize output for committed SVGs
+	if g.Config.DiffFriendly {
+		svgContent = MakeDiffFriendly(svgContent)
+	}
+
+	// Record what the image was rendered from, after diff-friendly output
+	// drops comments, since this one stays the same between identical runs
+	svgContent = withProvenance(svgContent, provenanceComment(configHash, startDate, endDate, orderedDailyData, g.Config.PrivacyMode))
+
 	return svgContent, nil
 }
 
+// buildAnnotations converts configured annotations into heatmap annotations
+func (g *Generator) buildAnnotations(location *time.Location) ([]HeatmapAnnotation, error) {
+	var annotations []HeatmapAnnotation
+	for _, a := range g.Config.Annotations {
+		date, err := time.ParseInLocation("2006-01-02", a.Date, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation date %q: %w", a.Date, err)
+		}
+
+		annotations = append(annotations, HeatmapAnnotation{
+			Date:  date,
+			Label: a.Label,
+			Icon:  a.Icon,
+		})
+	}
+	return annotations, nil
+}
+
 // generateStatsSVG creates an SVG for statistics
 func (g *Generator) generateStatsSVG(stats map[string]interface{}) string {
 	// This is a simplified version of the stats SVG generator
@@ -110,6 +329,23 @@ func (g *Generator) generateStatsSVG(stats map[string]interface{}) string {
 	// Create a simple stats panel
 	width := 300
 	height := 200
+	if pace, _ := stats["pace"].(string); pace != "" && !g.Config.PrivacyMode {
+		height += 25 // Room for the average pace row
+	}
+	weeklyEnergy, _ := stats["weeklyEnergy"].(map[string]float64)
+	showEnergy := weeklyEnergy["peak"] > 0 && !g.Config.PrivacyMode && g.hasStatType("weekly")
+	if showEnergy {
+		height += 50 // Room for the weekly energy rows
+	}
+	if overall != nil && overall.PreDawn+overall.AfterDark > 0 {
+		height += 25 // Room for the activities in the dark row
+	}
+	if g.Config.ACWRThreshold > 0 {
+		height += 25 // Room for the ramp warnings row
+	}
+	if len(g.HeartRateWarnings) > 0 {
+		height += 25 // Room for the elevated heart rate row
+	}
 
 	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
 		width, height, width, height))
@@ -140,37 +376,100 @@ func (g *Generator) generateStatsSVG(stats map[string]interface{}) string {
 	// Stats panel background
 	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="stats-panel" />`, width, height))
 
+	// Labels and numbers follow the configured language
+	tr := processor.GetTranslation(g.Config.Language)
+	nf := processor.GetNumberFormat(g.Config.Language)
+
 	// Title
-	sb.WriteString(`<text x="15" y="30" class="stats-title">Activity Summary</text>`)
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="stats-title">%s</text>`, tr.T("Activity Summary")))
 
 	// Stats grid
 	if overall != nil {
 		// Total activities
-		sb.WriteString(`<text x="15" y="60" class="stats-label">Total Activities</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="60" class="stats-value">%d</text>`, overall.TotalActivities))
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="60" class="stats-label">%s</text>`, tr.T("Total Activities")))
+		sb.WriteString(fmt.Sprintf(`<text x="150" y="60" class="stats-value">%s</text>`, nf.FormatInt(overall.TotalActivities)))
+
+		y := 85
+
+		// Distance and duration totals are hidden in privacy mode
+		if !g.Config.PrivacyMode {
+			// Total distance, in the units of the dominant activity type
+			units := processor.GetUnitRule(processor.DominantType(overall.ActivityTypes), g.Config.Language, g.Config.Units)
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Total Distance")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">%s</tspan></text>`,
+				y, units.FormatDistanceValue(overall.TotalDistance*1000), units.DistanceUnit))
+			y += 25
+
+			// Total duration
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Total Duration")))
+			if g.Config.DurationStyle == "" {
+				sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">%s</tspan></text>`,
+					y, nf.FormatInt(overall.TotalDuration), tr.T("hours")))
+			} else {
+				sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s</text>`,
+					y, processor.FormatDuration(overall.TotalSeconds, g.Config.DurationStyle, tr, nf)))
+			}
+			y += 25
+
+			// Average pace for pace-based activity types
+			if pace, _ := stats["pace"].(string); pace != "" {
+				sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Average Pace")))
+				sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s</text>`, y, pace))
+				y += 25
+			}
+		}
+
+		// Weekly energy totals
+		if showEnergy {
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Avg Weekly Energy")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">kcal</tspan></text>`,
+				y, nf.FormatFloat(weeklyEnergy["average"], 0)))
+			y += 25
+
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Peak Weekly Energy")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">kcal</tspan></text>`,
+				y, nf.FormatFloat(weeklyEnergy["peak"], 0)))
+			y += 25
+		}
 
-		// Total distance
-		sb.WriteString(`<text x="15" y="85" class="stats-label">Total Distance</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="85" class="stats-value">%.1f</text>`, overall.TotalDistance))
-		sb.WriteString(`<text x="185" y="85" class="stats-unit">km</text>`)
+		// Activities started before sunrise or after sunset
+		if dark := overall.PreDawn + overall.AfterDark; dark > 0 {
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("In the Dark")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">%s</tspan></text>`,
+				y, nf.FormatInt(dark), tr.T("%s pre-dawn", nf.FormatInt(overall.PreDawn))))
+			y += 25
+		}
 
-		// Total duration
-		sb.WriteString(`<text x="15" y="110" class="stats-label">Total Duration</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="110" class="stats-value">%d</text>`, overall.TotalDuration))
-		sb.WriteString(`<text x="170" y="110" class="stats-unit">hours</text>`)
+		// Weeks with a risky jump in training load
+		if g.Config.ACWRThreshold > 0 {
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Ramp Warnings")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">%s</tspan></text>`,
+				y, nf.FormatInt(len(g.RampWarnings)), tr.T("weeks")))
+			y += 25
+		}
+
+		// Weeks whose heart rate suggests fatigue
+		if len(g.HeartRateWarnings) > 0 {
+			sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Elevated HR")))
+			sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s <tspan class="stats-unit">%s</tspan></text>`,
+				y, nf.FormatInt(len(g.HeartRateWarnings)), tr.T("weeks")))
+			y += 25
+		}
 
 		// Active days
-		sb.WriteString(`<text x="15" y="135" class="stats-label">Active Days</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="135" class="stats-value">%d</text>`, overall.ActiveDays))
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Active Days")))
+		sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s</text>`, y, nf.FormatInt(overall.ActiveDays)))
+		y += 25
 
 		// Longest streak
-		sb.WriteString(`<text x="15" y="160" class="stats-label">Longest Streak</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="160" class="stats-value">%d</text>`, overall.LongestStreak))
-		sb.WriteString(`<text x="170" y="160" class="stats-unit">days</text>`)
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Longest Streak")))
+		sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s</text>`, y, nf.FormatInt(overall.LongestStreak)))
+		sb.WriteString(fmt.Sprintf(`<text x="170" y="%d" class="stats-unit">%s</text>`, y, tr.T("days")))
+		y += 25
 
 		// Personal records
-		sb.WriteString(`<text x="15" y="185" class="stats-label">Personal Records</text>`)
-		sb.WriteString(fmt.Sprintf(`<text x="150" y="185" class="stats-value">%d</text>`, overall.PRCount))
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="stats-label">%s</text>`, y, tr.T("Personal Records")))
+		sb.WriteString(fmt.Sprintf(`<text x="150" y="%d" class="stats-value">%s</text>`, y, nf.FormatInt(overall.PRCount)))
 	}
 
 	sb.WriteString(`</svg>`)
@@ -178,6 +477,16 @@ func (g *Generator) generateStatsSVG(stats map[string]interface{}) string {
 	return sb.String()
 }
 
+// hasStatType reports whether a statistic type is enabled in the config
+func (g *Generator) hasStatType(statType string) bool {
+	for _, t := range g.Config.StatTypes {
+		if t == statType {
+			return true
+		}
+	}
+	return false
+}
+
 // combineHeatmapAndStats combines the heatmap and stats SVGs into a single SVG
 func (g *Generator) combineHeatmapAndStats(heatmapSVG, statsSVG string) string {
 	// Extract width and height from heatmap
@@ -251,16 +560,12 @@ func max(a, b int) int {
 	return b
 }
 
-// GenerateLocationHeatmap creates a heatmap of activity locations
-func (g *Generator) GenerateLocationHeatmap(activities []strava.SummaryActivity, privacyRadius int) (string, error) {
-	// Placeholder for future implementation
-	// This would generate a map visualization of activity locations
-
-	// For now, return a placeholder SVG
-	return `<svg width="400" height="300" viewBox="0 0 400 300" xmlns="http://www.w3.org/2000/svg">
-  <rect x="0" y="0" width="400" height="300" fill="#f0f0f0" rx="6" />
-  <text x="200" y="150" text-anchor="middle" font-family="sans-serif" font-size="16">
-    Location heatmap to be implemented
-  </text>
-</svg>`, nil
+// scrubLocations removes the locations of activities in the configured
+// privacy zones, for anything that renders where activities took place
+func (g *Generator) scrubLocations(activities []strava.SummaryActivity) []strava.SummaryActivity {
+	zones := make([]processor.PrivacyZone, len(g.Config.PrivacyZones))
+	for i, zone := range g.Config.PrivacyZones {
+		zones[i] = processor.PrivacyZone{Lat: zone.Lat, Lng: zone.Lng, Radius: zone.Radius}
+	}
+	return processor.ScrubLocations(activities, zones)
 }
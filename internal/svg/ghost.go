@@ -0,0 +1,48 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// ghostOffsetDays is how far back the ghost overlay looks, 52 weeks so each
+// day lines up with the same weekday a year earlier
+const ghostOffsetDays = 364
+
+// addGhosts bins the days 52 weeks before each displayed cell with the
+// grid's thresholds, so last year's levels compare directly with this year's,
+// and notes them in the cell tooltips
+func (h *HeatmapData) addGhosts(previousYear []*strava.DailyActivity, metricType string) {
+	tr := processor.GetTranslation(h.Language)
+
+	activityMap := make(map[string]*strava.DailyActivity)
+	for _, activity := range previousYear {
+		activityMap[activity.Date.Format("2006-01-02")] = activity
+	}
+
+	for _, column := range h.Cells {
+		for _, cell := range column {
+			activity, ok := activityMap[cell.Date.AddDate(0, 0, -ghostOffsetDays).Format("2006-01-02")]
+			if !ok || activity.Count == 0 {
+				continue
+			}
+
+			cell.Ghost = intensityForValue(processor.MetricValue(activity, metricType), h.Thresholds)
+			cell.Tooltip += "\n" + tr.T("Same day last year: %s", tr.T(intensityLabel(cell.Ghost)+" day"))
+		}
+	}
+}
+
+// writeGhost outlines a cell in the color of its ghost level, just outside
+// its edges so the cell's own fill is drawn over the inner half of the line
+func (h *HeatmapData) writeGhost(sb *strings.Builder, cell *HeatmapCell, x, y int) {
+	if !h.GhostPreviousYear || cell.Ghost == strava.None {
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="ghost-cell ghost-%d" />`,
+		x-1, y-1, h.CellSize+2, h.CellSize+2, cell.Ghost))
+}
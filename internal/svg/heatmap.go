@@ -2,11 +2,15 @@ package svg
 
 import (
 	"fmt"
+	"html"
+	"math"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/internal/processor"
 	"github.com/samuellee/StravaGraph/internal/strava"
 )
 
@@ -14,32 +18,86 @@ import (
 type HeatmapCell struct {
 	Date      time.Time
 	Intensity strava.HeatmapIntensity
+	Secondary strava.HeatmapIntensity // Intensity of the secondary metric
+	Ghost     strava.HeatmapIntensity // Intensity 52 weeks earlier, for the ghost overlay and comparison
+	Change    int                     // Change from 52 weeks earlier, -2 to 2, for the year-over-year diff view
 	HasPR     bool
+	Dark      bool // True if an activity started before sunrise or after sunset
+	Race      bool // True if an activity was marked as a race
 	Count     int
+	Distance  float64  // In meters
+	Duration  int      // Moving time in seconds
+	Types     []string // Activity types, sorted
 	Tooltip   string
 }
 
+// HeatmapAnnotation marks a notable date with a flag above its week
+type HeatmapAnnotation struct {
+	Date  time.Time
+	Label string
+	Icon  string // Optional text or emoji drawn instead of the flag
+}
+
 // HeatmapData holds all data needed to generate the heatmap
 type HeatmapData struct {
-	StartDate   time.Time
-	EndDate     time.Time
+	StartDate   time.Time        // Civil date, midnight UTC
+	EndDate     time.Time        // Civil date, midnight UTC
 	Cells       [][]*HeatmapCell // [week][day]
-	WeekLabels  []string
+	WeekLabels  []string         // Date of each labeled column, empty for the rest
 	MonthLabels []struct {
 		Month string
 		X     int
 	}
-	ColorTheme      ColorTheme
-	DarkModeTheme   ColorTheme
-	CellSize        int
-	CellSpacing     int
-	WeekStart       string // "Sunday" or "Monday"
-	DarkModeSupport bool
+	ColorTheme          ColorTheme
+	DarkModeTheme       ColorTheme
+	CellSize            int
+	CellSpacing         int
+	WeekStart           string // "Sunday" or "Monday"
+	DarkModeSupport     bool
+	PrivacyMode         bool                  // Show qualitative labels instead of exact numbers
+	MetricType          string                // Metric used to determine intensity
+	LegendUnits         bool                  // Show the metric and its unit next to the legend
+	Language            string                // Language used for number formatting
+	Units               string                // "imperial" or metric, for tooltips and the legend
+	DurationStyle       string                // How tooltips write durations, see processor.FormatDuration
+	LegendRanges        bool                  // Show the value range of each intensity bin in the legend
+	Thresholds          []float64             // Upper bounds of the Low, Medium and High bins
+	IntensityScale      config.IntensityScale // How values are binned into the levels
+	WeekNumbers         string                // Where to print ISO week numbers: "top", "bottom" or "" for none
+	WeekLabelInterval   int                   // Columns between week labels, 0 for none
+	Annotations         []HeatmapAnnotation
+	ShowAllMonthLabels  bool      // Label every month, even a partial first month or crowded labels
+	DarkMarkers         bool      // Mark days with an activity started in the dark with a moon
+	Periodization       bool      // Show build and recovery weeks in a strip under the grid
+	WeekVolumes         []float64 // Training time in hours of each column
+	Phases              []processor.WeekPhase
+	ACWRThreshold       float64                       // Flag weeks whose workload ratio exceeds this, 0 to disable
+	Ratios              []float64                     // Acute:chronic workload ratio of each column
+	SecondaryMetric     string                        // Metric drawn as a border or dot on each cell, empty for none
+	SecondaryEncoding   string                        // "border" or "dot"
+	SecondaryThresholds []float64                     // Upper bounds of the secondary Low, Medium and High bins
+	Interactive         bool                          // Highlight cells on hover and make them keyboard focusable
+	Animate             bool                          // Fade the days in by date when the SVG loads
+	Milestones          []processor.MilestoneCrossing // Days yearly distance passed a milestone, marked at their week
+	StreakMinDays       int                           // Outline runs of at least this many active days, 0 for none
+	CurrentStreak       int                           // Active days ending with the range, or the day before, for the callouts
+	LongestStreak       int                           // Longest run of active days in the range, for the callouts
+	GhostPreviousYear   bool                          // Outline cells with their intensity 52 weeks earlier
+	YearOverYear        bool                          // Color cells by their change from 52 weeks earlier instead
+	Caption             string                        // Drawn left of the month labels, e.g. the year when comparing years
+	HideLegend          bool                          // Leave out the legend, for all but the last row of a split heatmap
+	Compact             bool                          // Use the geometry and small labels of GitHub's contribution graph
+	DetailStart         time.Time                     // Days before this are drawn as week summaries, zero to draw every day
+	Summaries           map[string]*HeatmapCell       // Cells summarizing the weeks before DetailStart, by their column's first date
+	Layout              Layout                        // Pixel geometry, computed when rendering
+
+	whole *HeatmapData // The heatmap this is a row of, whose days the legend counts
 }
 
 // NewHeatmapData creates a new heatmap data structure
 func NewHeatmapData(
 	activities []*strava.DailyActivity,
+	referenceActivities []*strava.DailyActivity,
 	startDate, endDate time.Time,
 	colorScheme string,
 	customColors []string,
@@ -48,6 +106,28 @@ func NewHeatmapData(
 	weekStart string,
 	darkModeSupport bool,
 	metricType string,
+	privacyMode bool,
+	legendUnits bool,
+	language string,
+	units string,
+	legendRanges bool,
+	weekNumbers string,
+	weekLabelInterval int,
+	annotations []HeatmapAnnotation,
+	showAllMonthLabels bool,
+	secondaryMetric string,
+	secondaryEncoding string,
+	darkMarkers bool,
+	periodization bool,
+	acwrThreshold float64,
+	interactive bool,
+	animate bool,
+	durationStyle string,
+	milestones []processor.MilestoneCrossing,
+	streakMinDays int,
+	intensityScale config.IntensityScale,
+	previousYear []*strava.DailyActivity,
+	yearOverYear bool,
 ) *HeatmapData {
 	// Get color themes
 	theme := GetTheme(colorScheme, customColors)
@@ -60,22 +140,60 @@ func NewHeatmapData(
 	if weekStart != "Sunday" && weekStart != "Monday" {
 		weekStart = "Monday" // Default to Monday
 	}
+	if secondaryEncoding != "dot" {
+		secondaryEncoding = "border" // Default to outlining cells
+	}
 	cellSpacing := 2
 
 	// Initialize heatmap data
 	heatmap := &HeatmapData{
-		StartDate:       startDate,
-		EndDate:         endDate,
-		ColorTheme:      theme,
-		DarkModeTheme:   darkTheme,
-		CellSize:        cellSize,
-		CellSpacing:     cellSpacing,
-		WeekStart:       weekStart,
-		DarkModeSupport: darkModeSupport,
+		StartDate:          processor.CivilDate(startDate),
+		EndDate:            processor.CivilDate(endDate),
+		ColorTheme:         theme,
+		DarkModeTheme:      darkTheme,
+		CellSize:           cellSize,
+		CellSpacing:        cellSpacing,
+		WeekStart:          weekStart,
+		DarkModeSupport:    darkModeSupport,
+		PrivacyMode:        privacyMode,
+		MetricType:         metricType,
+		LegendUnits:        legendUnits,
+		Language:           language,
+		Units:              units,
+		LegendRanges:       legendRanges,
+		WeekNumbers:        weekNumbers,
+		WeekLabelInterval:  weekLabelInterval,
+		Annotations:        annotations,
+		ShowAllMonthLabels: showAllMonthLabels,
+		SecondaryMetric:    secondaryMetric,
+		SecondaryEncoding:  secondaryEncoding,
+		DarkMarkers:        darkMarkers,
+		Periodization:      periodization,
+		ACWRThreshold:      acwrThreshold,
+		Interactive:        interactive,
+		Animate:            animate,
+		DurationStyle:      durationStyle,
+		Milestones:         milestones,
+		StreakMinDays:      streakMinDays,
+		IntensityScale:     intensityScale,
+		GhostPreviousYear:  previousYear != nil && !yearOverYear,
+		YearOverYear:       previousYear != nil && yearOverYear,
+	}
+
+	// Percentiles default to the displayed activities
+	if referenceActivities == nil {
+		referenceActivities = activities
 	}
 
 	// Create week and day grid
-	heatmap.createGrid(activities, metricType)
+	heatmap.createGrid(activities, referenceActivities, metricType)
+	heatmap.CurrentStreak = processor.CurrentStreak(activities)
+	heatmap.LongestStreak = processor.NewMetricsCalculator(activities, startDate, endDate).CalculateOverallStats().LongestStreak
+	if heatmap.YearOverYear {
+		heatmap.addChanges(activities, previousYear, metricType)
+	} else if previousYear != nil {
+		heatmap.addGhosts(previousYear, metricType)
+	}
 	heatmap.generateLabels()
 
 	return heatmap
@@ -94,8 +212,9 @@ func (h *HeatmapData) dayOffset(day time.Weekday) int {
 	return int(day)
 }
 
-// createGrid creates the grid of cells for the heatmap
-func (h *HeatmapData) createGrid(activities []*strava.DailyActivity, metricType string) {
+// createGrid creates the grid of cells for the heatmap, binning intensity
+// against the reference activities
+func (h *HeatmapData) createGrid(activities, referenceActivities []*strava.DailyActivity, metricType string) {
 	// Map of activities by date
 	activityMap := make(map[string]*strava.DailyActivity)
 	for _, activity := range activities {
@@ -107,7 +226,7 @@ func (h *HeatmapData) createGrid(activities []*strava.DailyActivity, metricType
 	startOffset := h.dayOffset(h.StartDate.Weekday())
 	endOffset := 6 - h.dayOffset(h.EndDate.Weekday())
 
-	totalDays := int(h.EndDate.Sub(h.StartDate).Hours()/24) + 1
+	totalDays := processor.DaysBetween(h.StartDate, h.EndDate) + 1
 	totalWeeks := (totalDays + startOffset + endOffset) / 7
 	if (totalDays+startOffset+endOffset)%7 > 0 {
 		totalWeeks++
@@ -118,54 +237,118 @@ func (h *HeatmapData) createGrid(activities []*strava.DailyActivity, metricType
 	for i := range h.Cells {
 		h.Cells[i] = make([]*HeatmapCell, 7)
 	}
+	h.WeekVolumes = make([]float64, totalWeeks)
+
+	// Bin boundaries are shared by every cell
+	h.Thresholds = calculateThresholds(metricType, h.Units, referenceActivities, h.IntensityScale)
+	if h.SecondaryMetric != "" {
+		// Fixed thresholds are in the primary metric's units
+		secondaryScale := h.IntensityScale
+		if secondaryScale.Mode == "fixed" {
+			secondaryScale = config.IntensityScale{}
+		}
+		h.SecondaryThresholds = calculateThresholds(h.SecondaryMetric, h.Units, referenceActivities, secondaryScale)
+	}
 
-	// Fill the grid with days
-	current := h.StartDate.AddDate(0, 0, -startOffset)
+	// Fill the grid with days, counting from the first cell so each cell
+	// lands on its own civil date
+	first := h.StartDate.AddDate(0, 0, -startOffset)
 	for week := 0; week < totalWeeks; week++ {
 		for day := 0; day < 7; day++ {
+			current := first.AddDate(0, 0, week*7+day)
 			dateKey := current.Format("2006-01-02")
 
 			// Check if we have activity data for this day
 			activity, exists := activityMap[dateKey]
 
 			// Calculate intensity
-			var intensity strava.HeatmapIntensity
+			var intensity, secondary strava.HeatmapIntensity
 			hasPR := false
+			dark := false
+			race := false
 			count := 0
+			var types []string
 
 			if exists && activity.Count > 0 {
 				// Determine intensity based on metric type
-				intensity = calculateIntensity(activity, metricType, activities)
+				intensity = intensityForValue(processor.MetricValue(activity, metricType), h.Thresholds)
+				if h.SecondaryMetric != "" {
+					secondary = secondaryIntensity(processor.MetricValue(activity, h.SecondaryMetric), h.SecondaryThresholds)
+				}
 				hasPR = activity.HasPR
+				dark = activity.PreDawnCount+activity.AfterDarkCount > 0
+				race = activity.Workouts[processor.WorkoutRace] > 0
 				count = activity.Count
+				for t := range activity.Types {
+					types = append(types, t)
+				}
+				sort.Strings(types)
+				h.WeekVolumes[week] += float64(activity.TotalDuration) / 3600
 			}
 
 			// Create tooltip
-			tooltip := createTooltip(current, activity)
+			var tooltip string
+			if h.PrivacyMode {
+				tooltip = createPrivateTooltip(current, intensity, hasPR, h.Language)
+			} else {
+				tooltip = createTooltip(current, activity, h.Language, h.Units, h.DurationStyle)
+			}
 
 			// Create the cell
 			h.Cells[week][day] = &HeatmapCell{
 				Date:      current,
 				Intensity: intensity,
+				Secondary: secondary,
 				HasPR:     hasPR,
+				Dark:      dark,
+				Race:      race,
 				Count:     count,
+				Types:     types,
 				Tooltip:   tooltip,
 			}
+			if count > 0 {
+				h.Cells[week][day].Distance = activity.TotalDistance
+				h.Cells[week][day].Duration = activity.TotalDuration
+			}
+		}
+	}
 
-			// Move to next day
-			current = current.AddDate(0, 0, 1)
+	// Weekly training time drives the build and recovery strip and the
+	// workload ratio. Partial weeks at either end of the range are scaled to
+	// a full week so the week in progress doesn't read as a recovery week.
+	if h.Periodization || h.ACWRThreshold > 0 {
+		volumes := make([]float64, totalWeeks)
+		for week, column := range h.Cells {
+			days := 0
+			for _, cell := range column {
+				if !cell.Date.Before(h.StartDate) && !cell.Date.After(h.EndDate) {
+					days++
+				}
+			}
+			if days > 0 {
+				volumes[week] = h.WeekVolumes[week] * 7 / float64(days)
+			}
 		}
+		h.Phases = processor.ClassifyWeeks(volumes)
+		h.Ratios = processor.ACWR(volumes)
 	}
 }
 
 // generateLabels creates week and month labels for the heatmap
 func (h *HeatmapData) generateLabels() {
-	// Week labels (for y-axis)
+	// Week labels, dating every Nth column by its first day in the range
+	tr := processor.GetTranslation(h.Language)
 	h.WeekLabels = make([]string, len(h.Cells))
-	for i := range h.Cells {
-		if i%2 == 0 { // Only label every other week to avoid clutter
-			week := h.Cells[i][0].Date
-			h.WeekLabels[i] = week.Format("Jan 2")
+	if h.WeekLabelInterval > 0 {
+		for i, column := range h.Cells {
+			if i%h.WeekLabelInterval != 0 {
+				continue
+			}
+			first := column[0].Date
+			if first.Before(h.StartDate) {
+				first = h.StartDate
+			}
+			h.WeekLabels[i] = tr.FormatMonthDay(first)
 		}
 	}
 
@@ -175,24 +358,31 @@ func (h *HeatmapData) generateLabels() {
 		X     int
 	}
 
-	// Group by month and find the first week of each month
-	currentMonth := -1
+	// Label each month at the column holding its first displayed day: the
+	// 1st of the month, or the start date for a range beginning mid-month.
+	// A range starting at the end of a month shares that column with the
+	// next month's 1st, and the next month takes the label.
 	for week := 0; week < len(h.Cells); week++ {
 		for day := 0; day < 7; day++ {
 			cell := h.Cells[week][day]
-			month := cell.Date.Month()
-
-			// If we found a new month, add a label
-			if int(month) != currentMonth {
-				currentMonth = int(month)
-				monthLabels = append(monthLabels, struct {
-					Month string
-					X     int
-				}{
-					Month: cell.Date.Format("Jan"),
-					X:     week,
-				})
-				break
+			if cell.Date.Before(h.StartDate) || cell.Date.After(h.EndDate) {
+				continue
+			}
+			if !cell.Date.Equal(h.StartDate) && cell.Date.Day() != 1 {
+				continue
+			}
+
+			label := struct {
+				Month string
+				X     int
+			}{
+				Month: tr.Month(cell.Date.Month()),
+				X:     week,
+			}
+			if n := len(monthLabels); n > 0 && monthLabels[n-1].X == week {
+				monthLabels[n-1] = label
+			} else {
+				monthLabels = append(monthLabels, label)
 			}
 		}
 	}
@@ -205,23 +395,16 @@ func (h *HeatmapData) RenderSVG() string {
 	// Make the heatmap extremely wide by displaying many days per row
 	// And organize into exactly 7 rows (one for each day of the week)
 
-	// Calculate approximately how many weeks we have data for
-	totalWeeks := len(h.Cells)
-
-	// Double the width by making cellsPerRow very large
-	cellsPerRow := totalWeeks
-
-	// We want 7 rows (one per day of the week)
-	rowsCount := 7
-
-	// Increase spacing between cells for better readability
+	// Increase spacing between cells for better readability, except in the
+	// compact layout, which spaces them like GitHub
 	h.CellSpacing = 4
+	if h.Compact {
+		h.CellSpacing = compactCellSpacing
+	}
 
-	// Wide padding for a very wide display
-	widthPadding := 100
-
-	totalWidth := (cellsPerRow * (h.CellSize + h.CellSpacing)) + widthPadding
-	totalHeight := (rowsCount * (h.CellSize + h.CellSpacing)) + 80 // +80 for labels
+	// Derive the geometry from the cell size and visible rows
+	h.Layout = h.computeLayout()
+	totalWidth, totalHeight := h.Layout.Width, h.Layout.Height
 
 	var sb strings.Builder
 
@@ -232,17 +415,38 @@ func (h *HeatmapData) RenderSVG() string {
 	// Add style
 	h.writeStyle(&sb)
 
-	// Write month labels
+	// Write the caption and month labels
+	h.writeCaption(&sb)
 	h.writeMonthLabels(&sb)
 
 	// Write week labels
 	h.writeWeekLabels(&sb)
 
+	// Write ISO week numbers
+	h.writeWeekNumbers(&sb)
+
+	// Write annotation flags
+	h.writeAnnotations(&sb)
+
+	// Write distance milestone markers
+	h.writeMilestones(&sb)
+
 	// Write cells
-	h.writeCells(&sb, totalWidth)
+	h.writeCells(&sb)
+
+	// Write the periodization strip
+	h.writePhases(&sb)
+
+	// Write ramp warning markers
+	h.writeRampWarnings(&sb)
+
+	// Outline long streaks over the cells
+	h.writeStreaks(&sb)
 
 	// Add legend
-	h.writeLegend(&sb, totalWidth)
+	if !h.HideLegend {
+		h.writeLegend(&sb)
+	}
 
 	// Close SVG
 	sb.WriteString(`</svg>`)
@@ -263,7 +467,44 @@ func (h *HeatmapData) writeStyle(sb *strings.Builder) {
   .heatmap-tooltip-rect { fill: white; stroke: #ddd; rx: 3; }
   .heatmap-tooltip-text { font-size: 11px; fill: #333; }
   .heatmap-tooltip-header { font-weight: bold; }
-  .pr-marker { fill: #ff8c00; }`)
+  .pr-marker { fill: #ff8c00; }
+  .phase-build { fill: #8b949e; }
+  .phase-recover { fill: #54aeff; }
+  .ramp-warning { fill: #cf222e; }
+  .dark-marker { fill: #3d4db7; }
+  .race-marker { fill: #8250df; }
+  .annotation-flag { fill: #ff8c00; }
+  .annotation-pole { stroke: #8b949e; stroke-width: 1; }
+  .annotation-icon { font-size: 11px; }
+  .streak-outline { fill: none; stroke: #fc4c02; stroke-width: 1.5; stroke-linecap: square; }
+  .milestone-line { stroke: #8b949e; stroke-width: 1; stroke-dasharray: 2 2; }
+  .milestone-label { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 10px; fill: #8b949e; }`)
+
+	// Shrink the labels to the size of GitHub's
+	if h.Compact {
+		sb.WriteString(`
+  .heatmap-month-label, .heatmap-day-label, .heatmap-legend-text { font-size: 9px; font-weight: normal; }`)
+	}
+
+	// Fade the days in one after the other
+	if h.Animate {
+		sb.WriteString(animationStyle)
+	}
+
+	// Highlight the hovered or focused cell, which only works when the SVG
+	// is opened directly rather than embedded as an image
+	if h.Interactive {
+		sb.WriteString(`
+  .heatmap-cell { transform-box: fill-box; transform-origin: center; transition: transform 0.1s; }
+  .heatmap-cell:hover, .heatmap-cell:focus { stroke: #1f2328; stroke-width: 1.5; transform: scale(1.2); outline: none; }
+  .heatmap-cell:focus + .heatmap-tooltip { opacity: 1; }`)
+		if h.DarkModeSupport {
+			sb.WriteString(`
+  @media (prefers-color-scheme: dark) {
+    .heatmap-cell:hover, .heatmap-cell:focus { stroke: #f0f6fc; }
+  }`)
+		}
+	}
 
 	// Add dark mode support if enabled
 	if h.DarkModeSupport {
@@ -275,6 +516,8 @@ func (h *HeatmapData) writeStyle(sb *strings.Builder) {
     .heatmap-legend-text { fill: #8b949e; }
     .heatmap-tooltip-rect { fill: #161b22; stroke: #30363d; }
     .heatmap-tooltip-text { fill: #c9d1d9; }
+    .dark-marker { fill: #c9d1d9; }
+    .race-marker { fill: #a371f7; }
   }`)
 	}
 
@@ -296,6 +539,47 @@ func (h *HeatmapData) writeStyle(sb *strings.Builder) {
   }`)
 	}
 
+	// Add ghost overlay classes, faint outlines in the theme's colors
+	if h.GhostPreviousYear {
+		sb.WriteString(`
+  .ghost-cell { fill: none; stroke-width: 1.5; opacity: 0.45; rx: 3; }`)
+		for i := 1; i < 5; i++ {
+			sb.WriteString(fmt.Sprintf(`
+  .ghost-%d { stroke: %s; }`, i, h.ColorTheme.Colors[i]))
+		}
+		if h.DarkModeSupport {
+			sb.WriteString(`
+  @media (prefers-color-scheme: dark) {`)
+			for i := 1; i < 5; i++ {
+				sb.WriteString(fmt.Sprintf(`
+    .ghost-%d { stroke: %s; }`, i, h.DarkModeTheme.Colors[i]))
+			}
+			sb.WriteString(`
+  }`)
+		}
+	}
+
+	// Add year-over-year change classes
+	if h.YearOverYear {
+		h.writeChangeStyle(sb)
+	}
+
+	// Add secondary metric classes
+	if h.SecondaryMetric != "" {
+		for i := 1; i < 5; i++ {
+			sb.WriteString(fmt.Sprintf(`
+  .secondary-border-%d { stroke: %s; stroke-width: 1.5; }`, i, SecondaryBorderColors[i]))
+		}
+		sb.WriteString(`
+  .secondary-dot { fill: #24292e; }`)
+		if h.DarkModeSupport {
+			sb.WriteString(`
+  @media (prefers-color-scheme: dark) {
+    .secondary-dot { fill: #c9d1d9; }
+  }`)
+		}
+	}
+
 	sb.WriteString(`
 </style>`)
 }
@@ -304,107 +588,332 @@ func (h *HeatmapData) writeStyle(sb *strings.Builder) {
 func (h *HeatmapData) writeMonthLabels(sb *strings.Builder) {
 	sb.WriteString(`<g class="heatmap-month-labels">`)
 
-	// Calculate total weeks to display
-	totalWeeks := len(h.Cells)
+	// Spacing for 3-letter abbreviations
+	minSpacingNeeded := 35
+
+	labels := h.MonthLabels
+	if !h.ShowAllMonthLabels && len(labels) > 1 {
+		// A partial first month too narrow for its label gives way to the next month
+		if (labels[1].X-labels[0].X)*h.Layout.Step < minSpacingNeeded {
+			labels = labels[1:]
+		}
+	}
 
-	// For our wide layout, we'll place month labels at the appropriate weeks
-	// First, organize weeks by month-year combination
-	monthYearStarts := make(map[string]int) // "month-year" -> first week
+	lastLabelX := -minSpacingNeeded * 2 // Start with a value that won't interfere
+	for _, label := range labels {
+		x := (label.X * h.Layout.Step) + h.Layout.GridLeft
 
-	// Find the first week of each month
-	for week := 0; week < totalWeeks; week++ {
-		if week < len(h.Cells) && len(h.Cells[week]) > 0 {
-			date := h.Cells[week][0].Date
-			month := int(date.Month())
-			year := date.Year()
-			key := fmt.Sprintf("%d-%d", month, year)
-
-			// If this is the first week we've seen this month-year combination
-			if _, exists := monthYearStarts[key]; !exists {
-				monthYearStarts[key] = week
-			}
+		// Only place label if there's enough space from the last one
+		if h.ShowAllMonthLabels || x-lastLabelX >= minSpacingNeeded {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-month-label">%s</text>`,
+				x, h.Layout.MonthLabelY, label.Month))
+
+			lastLabelX = x
 		}
 	}
 
-	// Standard 3-letter month abbreviations
-	monthNames := []string{"", "Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	sb.WriteString(`</g>`)
+}
+
+// writeWeekLabels adds the date of every Nth column under the grid, aligned
+// with the column's left edge
+func (h *HeatmapData) writeWeekLabels(sb *strings.Builder) {
+	if h.WeekLabelInterval <= 0 {
+		return
+	}
+
+	sb.WriteString(`<g class="heatmap-week-labels">`)
 
-	// Add month labels at the right positions
-	leftPadding := 70 // Same as cell padding
+	for week, label := range h.WeekLabels {
+		if label == "" {
+			continue
+		}
 
-	// Sort the month-year combinations by week position
-	type monthYearPosition struct {
-		monthYear string
-		week      int
+		x := (week * h.Layout.Step) + h.Layout.GridLeft
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-label">%s</text>`,
+			x, h.Layout.WeekLabelY, label))
 	}
 
-	var sortedPositions []monthYearPosition
-	for monthYear, week := range monthYearStarts {
-		sortedPositions = append(sortedPositions, monthYearPosition{monthYear, week})
+	sb.WriteString(`</g>`)
+}
+
+// writeWeekNumbers adds ISO week numbers above or below the grid
+func (h *HeatmapData) writeWeekNumbers(sb *strings.Builder) {
+	if h.WeekNumbers == "" {
+		return
 	}
 
-	// Sort by week position
-	sort.Slice(sortedPositions, func(i, j int) bool {
-		return sortedPositions[i].week < sortedPositions[j].week
-	})
+	sb.WriteString(`<g class="heatmap-week-numbers">`)
 
-	// Spacing for 3-letter abbreviations
-	minSpacingNeeded := 35
+	step := h.Layout.Step
 
-	var lastLabelX int = -minSpacingNeeded * 2 // Start with a value that won't interfere
+	// Label every other week when columns are too narrow for two digits
+	every := 1
+	if step < 14 {
+		every = 2
+	}
+
+	for week, column := range h.Cells {
+		if week%every != 0 {
+			continue
+		}
 
-	// Skip the first month in the timeline
-	var firstMonthSkipped bool = false
+		// A column's ISO week is the week of its Thursday
+		var thursday time.Time
+		for _, cell := range column {
+			if cell.Date.Weekday() == time.Thursday {
+				thursday = cell.Date
+				break
+			}
+		}
 
-	for _, pos := range sortedPositions {
-		parts := strings.Split(pos.monthYear, "-")
-		if len(parts) != 2 {
+		// Skip columns entirely outside the date range
+		if column[6].Date.Before(h.StartDate) || column[0].Date.After(h.EndDate) {
 			continue
 		}
 
-		month, _ := strconv.Atoi(parts[0])
+		_, isoWeek := thursday.ISOWeek()
+		x := (week * step) + h.Layout.GridLeft + (h.CellSize / 2)
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-label" text-anchor="middle">%d</text>`,
+			x, h.Layout.WeekNumberY, isoWeek))
+	}
+
+	sb.WriteString(`</g>`)
+}
+
+// cellDataAttributes returns the data-* attributes of a cell: its date,
+// activity count, intensity bin and types, plus its distance in meters and
+// moving time in seconds unless privacy mode hides exact numbers
+func (h *HeatmapData) cellDataAttributes(cell *HeatmapCell) string {
+	attrs := fmt.Sprintf(`data-date="%s" data-count="%d" data-intensity="%d" data-types="%s"`,
+		cell.Date.Format("2006-01-02"), cell.Count, cell.Intensity, html.EscapeString(strings.Join(cell.Types, ",")))
+	if !h.PrivacyMode {
+		attrs += fmt.Sprintf(` data-distance="%.0f" data-duration="%d"`, cell.Distance, cell.Duration)
+	}
+	if h.YearOverYear {
+		attrs += fmt.Sprintf(` data-change="%d"`, cell.Change)
+	}
+	return attrs
+}
+
+// RampWarnings returns the weeks whose acute:chronic workload ratio exceeds
+// the threshold
+func (h *HeatmapData) RampWarnings() []processor.RampWarning {
+	if h.ACWRThreshold <= 0 {
+		return nil
+	}
+
+	var warnings []processor.RampWarning
+	for week, ratio := range h.Ratios {
+		if ratio > h.ACWRThreshold {
+			warnings = append(warnings, processor.RampWarning{
+				WeekStart: h.Cells[week][0].Date,
+				Ratio:     ratio,
+			})
+		}
+	}
+	return warnings
+}
+
+// writeRampWarnings adds a warning triangle under each week whose workload
+// ratio exceeds the threshold
+func (h *HeatmapData) writeRampWarnings(sb *strings.Builder) {
+	if h.ACWRThreshold <= 0 {
+		return
+	}
+
+	sb.WriteString(`<g class="heatmap-ramp-warnings">`)
 
-		if month <= 0 || month > 12 {
+	nf := processor.GetNumberFormat(h.Language)
+	tr := processor.GetTranslation(h.Language)
+	for week, ratio := range h.Ratios {
+		if ratio <= h.ACWRThreshold {
 			continue
 		}
 
-		// Skip the first month that appears in the sequence
-		if !firstMonthSkipped {
-			firstMonthSkipped = true
+		x := float64((week * h.Layout.Step) + h.Layout.GridLeft)
+		y := float64(h.Layout.WarningY)
+		size := float64(h.CellSize)
+		sb.WriteString(fmt.Sprintf(`<path d="M %.1f %.1f L %.1f %.1f L %.1f %.1f Z" class="ramp-warning"><title>%s</title></path>`,
+			x+size/2, y, x+size, y+warningHeight, x, y+warningHeight, tr.T("Ramp warning: workload ratio %s", nf.FormatFloat(ratio, 2))))
+	}
+
+	sb.WriteString(`</g>`)
+}
+
+// writePhases adds a strip under the grid marking each week as a build or
+// recovery week
+func (h *HeatmapData) writePhases(sb *strings.Builder) {
+	if !h.Periodization {
+		return
+	}
+
+	tr := processor.GetTranslation(h.Language)
+
+	sb.WriteString(`<g class="heatmap-phases">`)
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-label" text-anchor="end">%s</text>`,
+		h.Layout.DayLabelX, h.Layout.PhaseY+phaseHeight, tr.T("Cycle")))
+
+	for week, phase := range h.Phases {
+		var class, label string
+		switch phase {
+		case processor.PhaseBuild:
+			class, label = "phase-build", tr.T("Build week")
+		case processor.PhaseRecover:
+			class, label = "phase-recover", tr.T("Recovery week")
+		default:
 			continue
 		}
 
-		// Position label at the start of each month
-		x := (pos.week * (h.CellSize + h.CellSpacing)) + leftPadding
-		y := 20 // Top margin for month labels
+		// Training time is hidden in privacy mode
+		if !h.PrivacyMode {
+			label += fmt.Sprintf(": %s h", processor.GetNumberFormat(h.Language).FormatFloat(h.WeekVolumes[week], 1))
+		}
 
-		// Only place label if there's enough space from the last one
-		if x-lastLabelX >= minSpacingNeeded {
-			// Use standard 3-letter abbreviation
-			labelText := monthNames[month]
+		x := (week * h.Layout.Step) + h.Layout.GridLeft
+		sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="%s"><title>%s</title></rect>`,
+			x, h.Layout.PhaseY, h.CellSize, phaseHeight, class, label))
+	}
 
-			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-month-label">%s</text>`,
-				x, y, labelText))
+	sb.WriteString(`</g>`)
+}
 
-			lastLabelX = x
+// writeAnnotations adds a flag above the week of each annotation, with the
+// labels shown as a tooltip
+func (h *HeatmapData) writeAnnotations(sb *strings.Builder) {
+	if len(h.Annotations) == 0 {
+		return
+	}
+
+	sb.WriteString(`<g class="heatmap-annotations">`)
+
+	// Map dates to their week column
+	weekByDate := make(map[string]int)
+	for week, column := range h.Cells {
+		for _, cell := range column {
+			weekByDate[cell.Date.Format("2006-01-02")] = week
 		}
 	}
 
+	// Group annotations by week so they share a single flag
+	tr := processor.GetTranslation(h.Language)
+	var weeks []int
+	grouped := make(map[int][]HeatmapAnnotation)
+	for _, annotation := range h.Annotations {
+		day := processor.CivilDate(annotation.Date)
+		week, ok := weekByDate[day.Format("2006-01-02")]
+		if !ok || day.Before(h.StartDate) || day.After(h.EndDate) {
+			continue
+		}
+		if _, exists := grouped[week]; !exists {
+			weeks = append(weeks, week)
+		}
+		grouped[week] = append(grouped[week], annotation)
+	}
+	sort.Ints(weeks)
+
+	poleTop := h.Layout.AnnotationTop
+	poleBottom := poleTop + annotationHeight
+
+	for _, week := range weeks {
+		annotations := grouped[week]
+		x := (week * h.Layout.Step) + h.Layout.GridLeft + (h.CellSize / 2)
+
+		var labels []string
+		for _, annotation := range annotations {
+			labels = append(labels, fmt.Sprintf("%s: %s", tr.FormatDate(annotation.Date), annotation.Label))
+		}
+
+		sb.WriteString(`<g class="heatmap-annotation">`)
+		sb.WriteString(fmt.Sprintf(`<title>%s</title>`, html.EscapeString(strings.Join(labels, "\n"))))
+
+		if icon := annotations[0].Icon; icon != "" {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="annotation-icon" text-anchor="middle">%s</text>`,
+				x, poleBottom, html.EscapeString(icon)))
+		} else {
+			sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" class="annotation-pole" />`,
+				x, poleTop, x, poleBottom))
+			sb.WriteString(fmt.Sprintf(`<path d="M %d %d l 7 3 l -7 3 z" class="annotation-flag" />`,
+				x, poleTop))
+		}
+
+		sb.WriteString(`</g>`)
+	}
+
 	sb.WriteString(`</g>`)
 }
 
-// writeWeekLabels adds week labels to the SVG
-func (h *HeatmapData) writeWeekLabels(sb *strings.Builder) {
-	sb.WriteString(`<g class="heatmap-week-labels">`)
+// milestoneWeeks groups the milestones within the displayed range by the
+// week column they were passed in, returning the columns in order
+func (h *HeatmapData) milestoneWeeks() ([]int, map[int][]processor.MilestoneCrossing) {
+	var weeks []int
+	grouped := make(map[int][]processor.MilestoneCrossing)
+	if len(h.Cells) == 0 {
+		return nil, grouped
+	}
+
+	first := h.Cells[0][0].Date
+	for _, milestone := range h.Milestones {
+		day := processor.CivilDate(milestone.Date)
+		if day.Before(h.StartDate) || day.After(h.EndDate) {
+			continue
+		}
+		week := processor.DaysBetween(first, day) / 7
+		if _, exists := grouped[week]; !exists {
+			weeks = append(weeks, week)
+		}
+		grouped[week] = append(grouped[week], milestone)
+	}
+	sort.Ints(weeks)
+
+	return weeks, grouped
+}
+
+// writeMilestones adds a dashed line before each week in which yearly
+// distance passed a milestone, labeled above the grid
+func (h *HeatmapData) writeMilestones(sb *strings.Builder) {
+	weeks, grouped := h.milestoneWeeks()
+	if len(weeks) == 0 {
+		return
+	}
 
-	// We're removing the confusing week labels since they weren't clear
-	// Instead, we're using increased left padding and focusing on the month labels
+	sb.WriteString(`<g class="heatmap-milestones">`)
+
+	// Milestones are given in kilometers, or miles in imperial units
+	tr := processor.GetTranslation(h.Language)
+	rule := processor.GetUnitRule("", h.Language, h.Units)
+	nf := rule.Number
+	lineTop := h.Layout.MilestoneY + 3
+	lineBottom := h.Layout.GridTop + h.Layout.GridHeight - h.CellSpacing
+
+	for _, week := range weeks {
+		x := h.Layout.GridLeft + week*h.Layout.Step - h.CellSpacing/2
+
+		var labels, titles []string
+		for _, milestone := range grouped[week] {
+			label := nf.FormatFloat(milestone.Milestone, 0)
+			if milestone.Milestone != math.Trunc(milestone.Milestone) {
+				label = nf.FormatFloat(milestone.Milestone, 1)
+			}
+			labels = append(labels, label)
+			titles = append(titles, tr.T("Passed %s this year on %s",
+				nf.WithUnit(label, rule.DistanceUnit), tr.FormatDate(milestone.Date)))
+		}
+
+		sb.WriteString(`<g class="heatmap-milestone">`)
+		sb.WriteString(fmt.Sprintf(`<title>%s</title>`, strings.Join(titles, "\n")))
+		sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" class="milestone-line" />`,
+			x, lineTop, x, lineBottom))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="milestone-label">%s</text>`,
+			x+2, h.Layout.MilestoneY, nf.WithUnit(strings.Join(labels, " / "), rule.DistanceUnit)))
+		sb.WriteString(`</g>`)
+	}
 
 	sb.WriteString(`</g>`)
 }
 
 // writeCells adds all cells to the SVG
-func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
+func (h *HeatmapData) writeCells(sb *strings.Builder) {
 	sb.WriteString(`<g class="heatmap-cells">`)
 
 	// Calculate total weeks to display
@@ -413,30 +922,38 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 	// We're using a fixed 7-row layout (one for each day of the week)
 	daysInWeek := 7
 
-	// Define day labels in standard order
-	standardDayLabels := []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
-
-	// Arrange day labels based on the configured week start
-	var dayLabels []string
+	// Arrange the rows' weekdays based on the configured week start
+	weekdays := []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
 	if h.WeekStart == "Monday" {
 		// Start with Monday (Monday, Tuesday, ..., Sunday)
-		dayLabels = append(standardDayLabels[1:], standardDayLabels[0])
-	} else {
-		// Start with Sunday (standard order)
-		dayLabels = standardDayLabels
+		weekdays = append(weekdays[1:], weekdays[0])
 	}
-
-	leftPadding := 70 // Increased for more space
-
-	// Add day of week labels on the left side
-	for i, label := range dayLabels {
-		y := (i * (h.CellSize + h.CellSpacing)) + 30 + (h.CellSize / 2) + 5
+	tr := processor.GetTranslation(h.Language)
+
+	// Add day of week labels on the left side, only every other day from
+	// Monday in the compact layout as on GitHub, whose smaller labels sit
+	// higher to stay centered on their row
+	dayLabelOffset := 5
+	if h.Compact {
+		dayLabelOffset = 3
+	}
+	for i, weekday := range weekdays {
+		if h.Compact && weekday != time.Monday && weekday != time.Wednesday && weekday != time.Friday {
+			continue
+		}
+		y := (i * h.Layout.Step) + h.Layout.GridTop + (h.CellSize / 2) + dayLabelOffset
 		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-day-label" text-anchor="end">%s</text>`,
-			leftPadding-10, y, label))
+			h.Layout.DayLabelX, y, tr.Weekday(weekday)))
 	}
 
 	// Loop through all cells and arrange them in a 7-row grid
 	for week := 0; week < totalWeeks; week++ {
+		// A summarized week is drawn as one cell instead of its days
+		if summary := h.summary(h.Cells[week]); summary != nil {
+			h.writeSummary(sb, summary, (week*h.Layout.Step)+h.Layout.GridLeft)
+			continue
+		}
+
 		for day := 0; day < daysInWeek; day++ {
 			// Skip if outside the array bounds
 			if week >= len(h.Cells) || day >= len(h.Cells[week]) {
@@ -454,17 +971,40 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 			// - Rows are days of the week (based on WeekStart configuration)
 			// - Columns are weeks (increasing from left to right)
 
-			x := (week * (h.CellSize + h.CellSpacing)) + leftPadding
-			y := (day * (h.CellSize + h.CellSpacing)) + 30 // Top padding for month labels
+			x := (week * h.Layout.Step) + h.Layout.GridLeft
+			y := (day * h.Layout.Step) + h.Layout.GridTop
+
+			h.openDay(sb, cell.Date)
 
-			// Determine fill color based on intensity
+			// Determine fill color based on intensity, outlining the cell by
+			// the secondary metric when it is drawn as a border
 			colorClass := fmt.Sprintf("intensity-%d", cell.Intensity)
+			if h.YearOverYear {
+				colorClass = changeClass(cell)
+			}
+			if h.SecondaryEncoding == "border" && cell.Secondary > strava.None {
+				colorClass += fmt.Sprintf(" secondary-border-%d", cell.Secondary)
+			}
 
-			// Add cell
-			sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="heatmap-cell %s" data-date="%s" data-count="%d">`,
-				x, y, h.CellSize, h.CellSize, colorClass, cell.Date.Format("2006-01-02"), cell.Count))
+			// Outline the cell with last year's level beneath its fill
+			h.writeGhost(sb, cell, x, y)
+
+			// Add cell, with its values as data attributes for scripts
+			attrs := h.cellDataAttributes(cell)
+			if h.Interactive {
+				attrs += ` tabindex="0"`
+			}
+			sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="heatmap-cell %s" %s>`,
+				x, y, h.CellSize, h.CellSize, colorClass, attrs))
 			sb.WriteString(fmt.Sprintf(`<title>%s</title></rect>`, cell.Tooltip))
 
+			// Add a dot sized by the secondary metric
+			if h.SecondaryEncoding == "dot" && cell.Secondary > strava.None {
+				sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%.1f" class="secondary-dot" />`,
+					float64(x)+float64(h.CellSize)/2, float64(y)+float64(h.CellSize)/2,
+					secondaryDotRadius(cell.Secondary, h.CellSize)))
+			}
+
 			// Add PR marker if applicable
 			if cell.HasPR {
 				prX := x + (h.CellSize * 3 / 4)
@@ -475,6 +1015,21 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 					prX, prY, prRadius))
 			}
 
+			// Add a moon for activities started in the dark, opposite the PR marker
+			if h.DarkMarkers && cell.Dark {
+				sb.WriteString(moonPath(float64(x)+float64(h.CellSize)/4, float64(y)+float64(h.CellSize)/4,
+					float64(h.CellSize)/4))
+			}
+
+			// Fold down the bottom right corner of race days, clear of the
+			// other markers
+			if cell.Race {
+				fold := float64(h.CellSize) * 0.4
+				right, bottom := float64(x+h.CellSize), float64(y+h.CellSize)
+				sb.WriteString(fmt.Sprintf(`<path d="M %.1f %.1f L %.1f %.1f L %.1f %.1f Z" class="race-marker" />`,
+					right, bottom-fold, right, bottom, right-fold, bottom))
+			}
+
 			// Add tooltip for hover
 			tooltipWidth := 200
 			tooltipHeight := 80
@@ -482,7 +1037,7 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 			tooltipY := y
 
 			// If tooltip would go off right edge, place it to the left of the cell
-			if tooltipX+tooltipWidth > totalWidth {
+			if tooltipX+tooltipWidth > h.Layout.Width {
 				tooltipX = x - tooltipWidth - 5
 			}
 
@@ -496,20 +1051,27 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 			if cell.Count > 0 {
 				// We'll use a simplified tooltip for now
 				sb.WriteString(fmt.Sprintf(`<text x="10" y="15" class="heatmap-tooltip-text heatmap-tooltip-header">%s</text>`,
-					cell.Date.Format("January 2, 2006")))
-
-				sb.WriteString(fmt.Sprintf(`<text x="10" y="35" class="heatmap-tooltip-text">%d activities</text>`,
-					cell.Count))
+					tr.FormatLongDate(cell.Date)))
+
+				if h.PrivacyMode {
+					sb.WriteString(fmt.Sprintf(`<text x="10" y="35" class="heatmap-tooltip-text">%s</text>`,
+						tr.T(intensityLabel(cell.Intensity)+" day")))
+				} else {
+					sb.WriteString(fmt.Sprintf(`<text x="10" y="35" class="heatmap-tooltip-text">%s</text>`,
+						tr.Plural(cell.Count, "%s activity", "%s activities", processor.GetNumberFormat(h.Language))))
+				}
 
 				if cell.HasPR {
-					sb.WriteString(`<text x="10" y="55" class="heatmap-tooltip-text" fill="#ff8c00">Personal Record!</text>`)
+					sb.WriteString(fmt.Sprintf(`<text x="10" y="55" class="heatmap-tooltip-text" fill="#ff8c00">%s</text>`,
+						tr.T("Personal Record!")))
 				}
 			} else {
-				sb.WriteString(fmt.Sprintf(`<text x="10" y="25" class="heatmap-tooltip-text">No activities on %s</text>`,
-					cell.Date.Format("January 2, 2006")))
+				sb.WriteString(fmt.Sprintf(`<text x="10" y="25" class="heatmap-tooltip-text">%s</text>`,
+					tr.T("No activities on %s", tr.FormatLongDate(cell.Date))))
 			}
 
 			sb.WriteString(`</g>`)
+			h.closeDay(sb)
 		}
 	}
 
@@ -517,175 +1079,413 @@ func (h *HeatmapData) writeCells(sb *strings.Builder, totalWidth int) {
 }
 
 // writeLegend adds the color legend to the SVG
-func (h *HeatmapData) writeLegend(sb *strings.Builder, totalWidth int) {
-	// We have 7 rows in our new layout
-	rowsCount := 7
-
-	// Position legend just below the last row of cells with minimal gap
-	legendY := (rowsCount * (h.CellSize + h.CellSpacing)) + 50
-
-	// Center the legend
-	legendWidth := 5*(h.CellSize+2) + 100 // space for boxes + labels
-
-	// Position legend at the center of the heatmap's width
-	centerX := (totalWidth - legendWidth) / 2
+func (h *HeatmapData) writeLegend(sb *strings.Builder) {
+	boxSize := h.Layout.LegendBox
+	boxStep := h.Layout.LegendStep
 
 	sb.WriteString(fmt.Sprintf(`<g class="heatmap-legend" transform="translate(%d, %d)">`,
-		centerX, legendY))
+		h.Layout.LegendX, h.Layout.LegendY))
+
+	tr := processor.GetTranslation(h.Language)
+	nf := processor.GetNumberFormat(h.Language)
 
 	// Legend label - Vertically center with boxes
-	sb.WriteString(`<text x="0" y="11" class="heatmap-legend-text" text-anchor="start">Less</text>`)
+	textY := boxSize/2 + 4
+	sb.WriteString(fmt.Sprintf(`<text x="0" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+		textY, tr.T("Less")))
+
+	// Bin ranges are hidden in privacy mode
+	var rangeLabels []string
+	if h.Layout.LegendRanges {
+		rangeLabels = h.legendRangeLabels()
+	}
+
+	// Each swatch tells on hover how many days fall into its bin
+	primaryDays, secondaryDays := h.levelDayCounts()
 
-	// Legend boxes - increase size for better visibility
-	boxSize := h.CellSize + 4 // Make boxes slightly larger
 	for i := 0; i < 5; i++ {
-		x := 40 + (i * (boxSize + 4))
+		x := legendTextWidth + (i * boxStep)
 
 		colorClass := fmt.Sprintf("intensity-%d", i)
+		if h.YearOverYear {
+			colorClass = changeClasses[i]
+		}
 
-		sb.WriteString(fmt.Sprintf(`<rect x="%d" y="0" width="%d" height="%d" class="heatmap-cell %s" />`,
-			x, boxSize, boxSize, colorClass))
+		sb.WriteString(fmt.Sprintf(`<rect x="%d" y="0" width="%d" height="%d" class="heatmap-cell %s">%s</rect>`,
+			x, boxSize, boxSize, colorClass, legendDaysTitle(primaryDays[i], tr, nf)))
+
+		if rangeLabels != nil {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-label" text-anchor="middle">%s</text>`,
+				x+boxSize/2, boxSize+12, rangeLabels[i]))
+		}
 	}
 
 	// More label - Vertically center with boxes
-	sb.WriteString(fmt.Sprintf(`<text x="%d" y="11" class="heatmap-legend-text" text-anchor="start">More</text>`,
-		40+(5*(boxSize+4))+5))
+	moreX := legendTextWidth + (5 * boxStep) + 5
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+		moreX, textY, tr.T("More")))
+
+	// Metric and unit caption, or what the diff view compares against
+	if h.Layout.LegendCaption {
+		caption := tr.T(metricLegendLabel(h.MetricType, h.Units))
+		if h.YearOverYear {
+			caption = tr.T("than last year")
+		}
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+			moreX+legendTextWidth+5, textY, caption))
+	}
 
-	sb.WriteString(`</g>`)
-}
+	// Second legend row for the secondary metric
+	if h.SecondaryMetric != "" {
+		y := h.Layout.SecondaryY - h.Layout.LegendY
+		sb.WriteString(fmt.Sprintf(`<text x="0" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+			y+textY, tr.T("Less")))
 
-// Helper function to calculate intensity for a day
-func calculateIntensity(day *strava.DailyActivity, metricType string, allActivities []*strava.DailyActivity) strava.HeatmapIntensity {
-	if day.Count == 0 {
-		return strava.None
+		for i := 0; i < 5; i++ {
+			x := legendTextWidth + (i * boxStep)
+			level := strava.HeatmapIntensity(i)
+
+			if h.SecondaryEncoding == "dot" {
+				sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="heatmap-cell intensity-0">%s</rect>`,
+					x, y, boxSize, boxSize, legendDaysTitle(secondaryDays[i], tr, nf)))
+				if level > strava.None {
+					sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%.1f" class="secondary-dot" />`,
+						float64(x)+float64(boxSize)/2, float64(y)+float64(boxSize)/2, secondaryDotRadius(level, boxSize)))
+				}
+			} else {
+				class := "heatmap-cell intensity-0"
+				if level > strava.None {
+					class += fmt.Sprintf(" secondary-border-%d", level)
+				}
+				sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="%s">%s</rect>`,
+					x, y, boxSize, boxSize, class, legendDaysTitle(secondaryDays[i], tr, nf)))
+			}
+		}
+
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+			moreX, y+textY, tr.T("More")))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-legend-text" text-anchor="start">%s</text>`,
+			moreX+legendTextWidth+5, y+textY, tr.T(metricLegendLabel(h.SecondaryMetric, h.Units))))
 	}
 
-	// Get all non-zero values for this metric to calculate percentiles
-	var values []float64
-	for _, data := range allActivities {
-		if data.Count == 0 {
-			continue
-		}
+	// Streak callouts, centered under the legend rows
+	if h.Layout.StreakY > 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="heatmap-legend-text" text-anchor="middle">%s</text>`,
+			h.Layout.Width/2-h.Layout.LegendX, h.Layout.StreakY-h.Layout.LegendY,
+			tr.T("Current streak: %s · Longest: %s",
+				tr.Plural(h.CurrentStreak, "%s day", "%s days", nf), tr.Plural(h.LongestStreak, "%s day", "%s days", nf))))
+	}
 
-		var value float64
-		switch metricType {
-		case "distance":
-			value = data.TotalDistance
-		case "duration":
-			value = float64(data.TotalDuration)
-		case "elevation":
-			value = data.TotalElevation
-		case "heart_rate":
-			value = data.AvgHeartRate
-		case "effort":
-			// Simple effort formula: distance * elevation gain / duration
-			// This rewards activities with higher distance, more elevation, but shorter time
-			if data.TotalDuration > 0 {
-				value = (data.TotalDistance * (1 + data.TotalElevation/100)) / float64(data.TotalDuration)
+	sb.WriteString(`</g>`)
+}
+
+// levelDayCounts returns how many displayed days fall into each intensity
+// level of the primary and secondary metrics, or into each change level in
+// the diff view
+func (h *HeatmapData) levelDayCounts() (primary, secondary [5]int) {
+	if h.whole != nil {
+		return h.whole.levelDayCounts()
+	}
+	for _, column := range h.Cells {
+		for _, cell := range column {
+			if cell == nil || cell.Date.Before(h.StartDate) || cell.Date.After(h.EndDate) {
+				continue
 			}
-		default:
-			value = float64(data.Count) // Default to count-based intensity
+			if h.YearOverYear {
+				if cell.hasComparison() {
+					primary[cell.Change+2]++
+				}
+			} else {
+				primary[cell.Intensity]++
+			}
+			secondary[cell.Secondary]++
 		}
+	}
+	return primary, secondary
+}
 
-		if value > 0 {
-			values = append(values, value)
+// legendDaysTitle returns the hover title of a legend swatch
+func legendDaysTitle(days int, tr processor.Translation, nf processor.NumberFormat) string {
+	return fmt.Sprintf("<title>%s</title>", tr.Plural(days, "%s day at this level", "%s days at this level", nf))
+}
+
+// legendRangeLabels returns the value range of each intensity bin in display
+// units, or nil if there are no thresholds
+func (h *HeatmapData) legendRangeLabels() []string {
+	if len(h.Thresholds) != 3 {
+		return nil
+	}
+
+	// Sports are counted one by one
+	if h.MetricType == "variety" && slices.Equal(h.Thresholds, varietyThresholds) {
+		return []string{"0", "1", "2", "3", "4+"}
+	}
+
+	nf := processor.GetNumberFormat(h.Language)
+	format := func(value float64) string {
+		value = processor.MetricDisplayValue(value, h.MetricType, h.Units)
+		if value < 10 {
+			return nf.FormatFloat(value, 1)
 		}
+		return nf.FormatFloat(value, 0)
 	}
 
-	// If no values, return low intensity for any day with activity
-	if len(values) == 0 {
-		return strava.Low
+	return []string{
+		"0",
+		"≤" + format(h.Thresholds[0]),
+		format(h.Thresholds[0]) + "–" + format(h.Thresholds[1]),
+		format(h.Thresholds[1]) + "–" + format(h.Thresholds[2]),
+		">" + format(h.Thresholds[2]),
 	}
+}
 
-	// Get the value for this day
-	var dayValue float64
+// metricLegendLabel returns a legend caption describing the metric and its
+// unit in the given units
+func metricLegendLabel(metricType, units string) string {
 	switch metricType {
 	case "distance":
-		dayValue = day.TotalDistance
+		if units == "imperial" {
+			return "Distance (mi)"
+		}
+		return "Distance (km)"
 	case "duration":
-		dayValue = float64(day.TotalDuration)
+		return "Duration (hours)"
 	case "elevation":
-		dayValue = day.TotalElevation
+		if units == "imperial" {
+			return "Elevation gain (ft)"
+		}
+		return "Elevation gain (m)"
 	case "heart_rate":
-		dayValue = day.AvgHeartRate
+		return "Avg heart rate (bpm)"
+	case "energy":
+		return "Energy (kcal)"
+	case "work":
+		return "Work (kJ)"
+	case "normalized_power":
+		return "Normalized power (W)"
+	case "tss":
+		return "Training stress (TSS)"
 	case "effort":
-		if day.TotalDuration > 0 {
-			dayValue = (day.TotalDistance * (1 + day.TotalElevation/100)) / float64(day.TotalDuration)
-		}
+		return "Effort"
+	case "composite":
+		return "Composite score"
+	case "variety":
+		return "Sports"
 	default:
-		dayValue = float64(day.Count)
+		return "Activities"
 	}
+}
 
-	// Simple percentile-based binning
-	// Here we're using a simple algorithm for demonstration
-	// In a real implementation, we might use more sophisticated statistical methods
+// varietyThresholds bin the variety metric at one, two, three and four or
+// more sports in a day
+var varietyThresholds = []float64{1, 2, 3}
+
+// calculateThresholds returns the upper bounds of the Low, Medium and High
+// intensity bins. By default they are the quartiles of all non-zero metric
+// values; the linear and logarithmic scales split the range up to the
+// highest value into even steps instead, and the fixed scale uses the
+// configured bounds, where an optional bound of the Very High level only
+// caps a level days beyond it stay in. It returns nil if there are no values
+// to bin against.
+func calculateThresholds(metricType, units string, allActivities []*strava.DailyActivity, scale config.IntensityScale) []float64 {
+	// Fixed bounds are given in display units, e.g. km or mi rather than meters
+	if scale.Mode == "fixed" && len(scale.Thresholds) >= 3 {
+		perUnit := processor.MetricDisplayValue(1, metricType, units)
+		return []float64{
+			scale.Thresholds[0] / perUnit,
+			scale.Thresholds[1] / perUnit,
+			scale.Thresholds[2] / perUnit,
+		}
+	}
+
+	// Each sport beyond the first is a level of its own, as quartiles of a
+	// handful of small counts would split days doing the same number
+	if metricType == "variety" && (scale.Mode == "" || scale.Mode == "percentile") {
+		return varietyThresholds
+	}
+
+	// Get all non-zero values for this metric to calculate percentiles
+	var values []float64
+	for _, data := range allActivities {
+		if data.Count == 0 {
+			continue
+		}
+
+		if value := processor.MetricValue(data, metricType); value > 0 {
+			values = append(values, value)
+		}
+	}
+
+	return scaleThresholds(values, scale.Mode)
+}
+
+// scaleThresholds returns the upper bounds of the Low, Medium and High bins
+// of non-zero values under a scale mode, by percentile unless the mode is
+// linear or logarithmic
+func scaleThresholds(values []float64, mode string) []float64 {
+	if len(values) == 0 {
+		return nil
+	}
 
 	// Sort values in ascending order
 	sort.Float64s(values)
+	n := len(values)
+	lowest, highest := values[0], values[n-1]
+
+	switch mode {
+	case "linear":
+		// Even steps from nothing to the highest value
+		return []float64{highest / 4, highest / 2, highest * 3 / 4}
+	case "logarithmic":
+		// Even ratios from the lowest to the highest value, so a few huge
+		// days don't leave every other day at the lowest level
+		ratio := math.Pow(highest/lowest, 0.25)
+		return []float64{lowest * ratio, lowest * ratio * ratio, lowest * ratio * ratio * ratio}
+	}
+
+	// A value falls at or below the p-th percentile exactly when it is no
+	// greater than the value at index floor(p*n)
+	return []float64{
+		values[n/4],
+		values[n/2],
+		values[(3*n)/4],
+	}
+}
 
-	// Find position of the day's value
-	pos := sort.SearchFloat64s(values, dayValue)
-	percentile := float64(pos) / float64(len(values))
+// intensityForValue bins a metric value into an intensity level
+func intensityForValue(value float64, thresholds []float64) strava.HeatmapIntensity {
+	// If no thresholds, return low intensity for any day with activity
+	if len(thresholds) == 0 {
+		return strava.Low
+	}
 
-	if percentile <= 0.25 {
+	if value <= thresholds[0] {
 		return strava.Low
-	} else if percentile <= 0.5 {
+	} else if value <= thresholds[1] {
 		return strava.Medium
-	} else if percentile <= 0.75 {
+	} else if value <= thresholds[2] {
 		return strava.High
 	} else {
 		return strava.VeryHigh
 	}
 }
 
+// moonPath returns a crescent moon centered at (cx, cy) with radius r
+func moonPath(cx, cy, r float64) string {
+	return fmt.Sprintf(`<path d="M %.1f %.1f A %.1f %.1f 0 1 0 %.1f %.1f A %.1f %.1f 0 1 1 %.1f %.1f Z" class="dark-marker" />`,
+		cx, cy-r, r, r, cx, cy+r, r*0.7, r, cx, cy-r)
+}
+
+// secondaryIntensity bins a secondary metric value, leaving days without a
+// value for that metric unmarked
+func secondaryIntensity(value float64, thresholds []float64) strava.HeatmapIntensity {
+	if value <= 0 {
+		return strava.None
+	}
+	return intensityForValue(value, thresholds)
+}
+
+// secondaryDotRadius returns the radius of a secondary metric dot, growing
+// with the intensity up to 40% of the cell
+func secondaryDotRadius(intensity strava.HeatmapIntensity, cellSize int) float64 {
+	return float64(cellSize) * float64(intensity) / 10
+}
+
 // Helper function to create a tooltip for a day
-func createTooltip(date time.Time, activity *strava.DailyActivity) string {
+func createTooltip(date time.Time, activity *strava.DailyActivity, language, unitSystem, durationStyle string) string {
+	tr := processor.GetTranslation(language)
 	if activity == nil || activity.Count == 0 {
-		return fmt.Sprintf("No activities on %s", date.Format("Jan 2, 2006"))
+		return tr.T("No activities on %s", tr.FormatDate(date))
 	}
 
-	// Format distance in km
-	distance := activity.TotalDistance / 1000
+	// Display units follow the day's dominant activity type
+	units := processor.GetUnitRule(processor.DominantType(activity.Types), language, unitSystem)
 
-	// Format duration in hours and minutes
-	hours := activity.TotalDuration / 3600
-	minutes := (activity.TotalDuration % 3600) / 60
+	tooltip := fmt.Sprintf("%s: %s",
+		tr.FormatDate(date),
+		tr.Plural(activity.Count, "%s activity", "%s activities", units.Number))
 
-	tooltip := fmt.Sprintf("%s: %d %s",
-		date.Format("Jan 2, 2006"),
-		activity.Count,
-		pluralize("activity", activity.Count))
+	if activity.TotalDistance > 0 {
+		tooltip += "\n" + tr.T("Total distance: %s", units.FormatDistance(activity.TotalDistance))
 
-	if distance > 0 {
-		tooltip += fmt.Sprintf("\nTotal distance: %.1f km", distance)
+		if pace := units.FormatPace(activity.TotalDistance, activity.TotalDuration); pace != "" {
+			tooltip += "\n" + tr.T("Pace: %s", pace)
+		}
 	}
 
 	if activity.TotalDuration > 0 {
-		if hours > 0 {
-			tooltip += fmt.Sprintf("\nTotal time: %d %s %d %s",
-				hours, pluralize("hour", hours),
-				minutes, pluralize("minute", minutes))
-		} else {
-			tooltip += fmt.Sprintf("\nTotal time: %d %s",
-				minutes, pluralize("minute", minutes))
-		}
+		tooltip += "\n" + tr.T("Total time: %s",
+			processor.FormatDuration(activity.TotalDuration, durationStyle, tr, units.Number))
 	}
 
 	if activity.TotalElevation > 0 {
-		tooltip += fmt.Sprintf("\nTotal elevation: %.0f m", activity.TotalElevation)
+		tooltip += "\n" + tr.T("Total elevation: %s", units.FormatElevation(activity.TotalElevation))
+	}
+
+	if activity.NormalizedPower > 0 {
+		tooltip += "\n" + tr.T("Power: %s avg, %s normalized",
+			units.Number.WithUnit(units.Number.FormatFloat(activity.AvgPower, 0), "W"),
+			units.Number.WithUnit(units.Number.FormatFloat(activity.NormalizedPower, 0), "W"))
+	}
+
+	if activity.TrainingStress > 0 {
+		tooltip += "\n" + tr.T("Training stress: %s TSS (IF %s)",
+			units.Number.FormatFloat(activity.TrainingStress, 0),
+			units.Number.FormatFloat(activity.IntensityFactor, 2))
+	}
+
+	if activity.AvgCadence > 0 {
+		tooltip += "\n" + tr.T("Cadence: %s", units.Number.FormatFloat(activity.AvgCadence, 0))
 	}
 
 	if activity.HasPR {
-		tooltip += "\nPersonal Record!"
+		tooltip += "\n" + tr.T("Personal Record!")
+	}
+
+	if activity.Workouts[processor.WorkoutRace] > 0 {
+		tooltip += "\n" + tr.T("Race day!")
+	}
+
+	if len(activity.Tags) > 0 {
+		tags := make([]string, 0, len(activity.Tags))
+		for tag := range activity.Tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		tooltip += "\n" + tr.T("Tags: %s", strings.Join(tags, ", "))
 	}
 
 	return tooltip
 }
 
-// Helper function to pluralize words
-func pluralize(word string, count int) string {
-	if count == 1 {
-		return word
+// Helper function to create a tooltip that only reveals relative intensity
+func createPrivateTooltip(date time.Time, intensity strava.HeatmapIntensity, hasPR bool, language string) string {
+	tr := processor.GetTranslation(language)
+	if intensity == strava.None {
+		return tr.T("No activities on %s", tr.FormatDate(date))
+	}
+
+	tooltip := fmt.Sprintf("%s: %s", tr.FormatDate(date), tr.T(intensityLabel(intensity)+" day"))
+
+	if hasPR {
+		tooltip += "\n" + tr.T("Personal Record!")
+	}
+
+	return tooltip
+}
+
+// intensityLabel returns a qualitative description of an intensity level
+func intensityLabel(intensity strava.HeatmapIntensity) string {
+	switch intensity {
+	case strava.Low:
+		return "light"
+	case strava.Medium:
+		return "moderate"
+	case strava.High:
+		return "hard"
+	case strava.VeryHigh:
+		return "very hard"
+	default:
+		return "rest"
 	}
-	return word + "s"
 }
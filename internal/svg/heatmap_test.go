@@ -0,0 +1,353 @@
+package svg
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// date returns midnight UTC of a civil date
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// gridFor builds the cell grid of a range, with one activity on each given day
+func gridFor(start, end time.Time, weekStart string, activeDays ...time.Time) *HeatmapData {
+	var activities []*strava.DailyActivity
+	for _, day := range activeDays {
+		activities = append(activities, &strava.DailyActivity{Date: day, Count: 1, TotalDistance: 5000, TotalDuration: 1800})
+	}
+
+	h := &HeatmapData{StartDate: start, EndDate: end, WeekStart: weekStart}
+	h.createGrid(activities, activities, "distance")
+	return h
+}
+
+// checkGrid verifies that every cell sits in the row of its weekday and the
+// column of its week, that the cells run day after day without gaps, and
+// that the range fills the grid with no whole week of padding at either end
+func checkGrid(t *testing.T, h *HeatmapData) {
+	t.Helper()
+
+	first := h.StartDate.AddDate(0, 0, -h.dayOffset(h.StartDate.Weekday()))
+	for week, column := range h.Cells {
+		for day, cell := range column {
+			if cell == nil {
+				t.Fatalf("cell %d/%d is missing", week, day)
+			}
+			if want := first.AddDate(0, 0, week*7+day); !cell.Date.Equal(want) {
+				t.Fatalf("cell %d/%d is %s, want %s", week, day, cell.Date.Format("2006-01-02"), want.Format("2006-01-02"))
+			}
+			if got := h.dayOffset(cell.Date.Weekday()); got != day {
+				t.Errorf("%s (%s) is in row %d, want row %d", cell.Date.Format("2006-01-02"), cell.Date.Weekday(), day, got)
+			}
+		}
+	}
+
+	// The first column holds the start and the last column the end
+	if len(h.Cells) == 0 {
+		t.Fatal("grid has no weeks")
+	}
+	if !h.Cells[0][h.dayOffset(h.StartDate.Weekday())].Date.Equal(h.StartDate) {
+		t.Errorf("first column doesn't start the range on %s", h.StartDate.Format("2006-01-02"))
+	}
+	last := h.Cells[len(h.Cells)-1]
+	if !last[h.dayOffset(h.EndDate.Weekday())].Date.Equal(h.EndDate) {
+		t.Errorf("last column doesn't end the range on %s", h.EndDate.Format("2006-01-02"))
+	}
+}
+
+// daysInRange counts the cells of the grid within its date range
+func daysInRange(h *HeatmapData) int {
+	count := 0
+	for _, column := range h.Cells {
+		for _, cell := range column {
+			if !cell.Date.Before(h.StartDate) && !cell.Date.After(h.EndDate) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestDayOffset(t *testing.T) {
+	tests := []struct {
+		weekStart string
+		want      [7]int // Rows of Sunday to Saturday
+	}{
+		{"Sunday", [7]int{0, 1, 2, 3, 4, 5, 6}},
+		{"Monday", [7]int{6, 0, 1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		h := &HeatmapData{WeekStart: tt.weekStart}
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if got := h.dayOffset(day); got != tt.want[day] {
+				t.Errorf("%s weeks: dayOffset(%s) = %d, want %d", tt.weekStart, day, got, tt.want[day])
+			}
+		}
+	}
+}
+
+func TestCreateGridYears(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		weekStart  string
+		weeks      int
+	}{
+		// 2020 is a leap year with 53 ISO weeks, starting on a Wednesday
+		{"2020 Sunday weeks", date(2020, 1, 1), date(2020, 12, 31), "Sunday", 53},
+		{"2020 Monday weeks", date(2020, 1, 1), date(2020, 12, 31), "Monday", 53},
+		// 2026 has 53 ISO weeks, starting on a Thursday
+		{"2026 Sunday weeks", date(2026, 1, 1), date(2026, 12, 31), "Sunday", 53},
+		{"2026 Monday weeks", date(2026, 1, 1), date(2026, 12, 31), "Monday", 53},
+		// A leap year starting on a Sunday spills into a 54th Monday week
+		{"2012 Sunday weeks", date(2012, 1, 1), date(2012, 12, 31), "Sunday", 53},
+		{"2012 Monday weeks", date(2012, 1, 1), date(2012, 12, 31), "Monday", 54},
+		// 2023 starts on a Sunday and isn't a leap year
+		{"2023 Sunday weeks", date(2023, 1, 1), date(2023, 12, 31), "Sunday", 53},
+		{"2023 Monday weeks", date(2023, 1, 1), date(2023, 12, 31), "Monday", 53},
+		// ISO week 53 of 2020 runs from Monday Dec 28 to Sunday Jan 3
+		{"ISO week 2020-W53", date(2020, 12, 28), date(2021, 1, 3), "Monday", 1},
+		{"ISO week 2026-W53", date(2026, 12, 28), date(2027, 1, 3), "Monday", 1},
+		{"ISO week 2020-W53 Sunday weeks", date(2020, 12, 28), date(2021, 1, 3), "Sunday", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := gridFor(tt.start, tt.end, tt.weekStart)
+			checkGrid(t, h)
+
+			if len(h.Cells) != tt.weeks {
+				t.Errorf("got %d weeks, want %d", len(h.Cells), tt.weeks)
+			}
+			if want := int(tt.end.Sub(tt.start).Hours()/24) + 1; daysInRange(h) != want {
+				t.Errorf("grid holds %d days of the range, want %d", daysInRange(h), want)
+			}
+
+			// Monday columns are ISO weeks, so each holds a single week number
+			if tt.weekStart == "Monday" {
+				for _, column := range h.Cells {
+					year, week := column[0].Date.ISOWeek()
+					for _, cell := range column[1:] {
+						if y, w := cell.Date.ISOWeek(); y != year || w != week {
+							t.Fatalf("column starting %s mixes ISO weeks %d-W%02d and %d-W%02d",
+								column[0].Date.Format("2006-01-02"), year, week, y, w)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreateGridLeapDay(t *testing.T) {
+	leapDay := date(2024, 2, 29)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"leap year", date(2024, 1, 1), date(2024, 12, 31)},
+		{"February", date(2024, 2, 1), date(2024, 2, 29)},
+		{"starting on the leap day", leapDay, date(2024, 3, 31)},
+		{"ending on the leap day", date(2024, 2, 1), leapDay},
+		{"the leap day alone", leapDay, leapDay},
+		{"a year back from the leap day", date(2023, 3, 1), leapDay},
+		{"a year on from the leap day", leapDay, date(2025, 2, 28)},
+	}
+
+	for _, tt := range tests {
+		for _, weekStart := range []string{"Sunday", "Monday"} {
+			t.Run(tt.name+" "+weekStart, func(t *testing.T) {
+				h := gridFor(tt.start, tt.end, weekStart, leapDay, leapDay.AddDate(0, 0, 1))
+				checkGrid(t, h)
+
+				// The leap day has a cell of its own, holding its activity,
+				// and March 1st follows it in the next row or column
+				var found *HeatmapCell
+				for _, column := range h.Cells {
+					for _, cell := range column {
+						if cell.Date.Equal(leapDay) {
+							if found != nil {
+								t.Fatal("Feb 29 appears twice")
+							}
+							found = cell
+						}
+					}
+				}
+				if found == nil {
+					t.Fatal("Feb 29 has no cell")
+				}
+				if found.Count != 1 {
+					t.Errorf("Feb 29 has %d activities, want 1", found.Count)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateGridStartWeekday(t *testing.T) {
+	// March 3rd 2024 is a Sunday; each range runs four weeks from a
+	// different weekday
+	for i := 0; i < 7; i++ {
+		start := date(2024, 3, 3+i)
+		end := start.AddDate(0, 0, 27)
+
+		for _, weekStart := range []string{"Sunday", "Monday"} {
+			t.Run(start.Weekday().String()+" start, "+weekStart+" weeks", func(t *testing.T) {
+				h := gridFor(start, end, weekStart, start, end)
+				checkGrid(t, h)
+
+				// Four weeks fill four columns only when they start on the
+				// first day of the week, and spill into a fifth otherwise
+				want := 5
+				if start.Weekday().String() == weekStart {
+					want = 4
+				}
+				if len(h.Cells) != want {
+					t.Errorf("got %d weeks, want %d", len(h.Cells), want)
+				}
+				if daysInRange(h) != 28 {
+					t.Errorf("grid holds %d days of the range, want 28", daysInRange(h))
+				}
+
+				// The first and last days land on their activities
+				row := h.dayOffset(start.Weekday())
+				if cell := h.Cells[0][row]; cell.Count != 1 {
+					t.Errorf("start %s has %d activities, want 1", cell.Date.Format("2006-01-02"), cell.Count)
+				}
+				if cell := h.Cells[len(h.Cells)-1][h.dayOffset(end.Weekday())]; cell.Count != 1 {
+					t.Errorf("end %s has %d activities, want 1", cell.Date.Format("2006-01-02"), cell.Count)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteMonthLabels(t *testing.T) {
+	type label struct {
+		month  string
+		column int
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		weekStart  string
+		all        []label // With showAllMonthLabels
+		fitting    []label // Without, dropping a first month too narrow for its label
+	}{
+		{
+			name:  "starting on the 1st",
+			start: date(2024, 3, 1), end: date(2024, 5, 31), weekStart: "Sunday",
+			all:     []label{{"Mar", 0}, {"Apr", 5}, {"May", 9}},
+			fitting: []label{{"Mar", 0}, {"Apr", 5}, {"May", 9}},
+		},
+		{
+			name:  "starting mid-month",
+			start: date(2024, 3, 15), end: date(2024, 5, 31), weekStart: "Sunday",
+			all:     []label{{"Mar", 0}, {"Apr", 3}, {"May", 7}},
+			fitting: []label{{"Mar", 0}, {"Apr", 3}, {"May", 7}},
+		},
+		{
+			name:  "starting mid-month a column before the next month",
+			start: date(2024, 3, 25), end: date(2024, 5, 31), weekStart: "Sunday",
+			all:     []label{{"Mar", 0}, {"Apr", 1}, {"May", 5}},
+			fitting: []label{{"Apr", 1}, {"May", 5}},
+		},
+		{
+			name:  "starting mid-month with Monday weeks",
+			start: date(2024, 3, 15), end: date(2024, 5, 31), weekStart: "Monday",
+			all:     []label{{"Mar", 0}, {"Apr", 3}, {"May", 7}},
+			fitting: []label{{"Mar", 0}, {"Apr", 3}, {"May", 7}},
+		},
+		{
+			// The next month's 1st shares the first column and takes its label
+			name:  "starting on the last day of a month",
+			start: date(2024, 3, 31), end: date(2024, 5, 31), weekStart: "Sunday",
+			all:     []label{{"Apr", 0}, {"May", 4}},
+			fitting: []label{{"Apr", 0}, {"May", 4}},
+		},
+		{
+			name:  "starting on the last day of a month with Monday weeks",
+			start: date(2024, 1, 31), end: date(2024, 3, 31), weekStart: "Monday",
+			all:     []label{{"Feb", 0}, {"Mar", 4}},
+			fitting: []label{{"Feb", 0}, {"Mar", 4}},
+		},
+		{
+			name:  "a single month starting on its last day",
+			start: date(2024, 4, 30), end: date(2024, 4, 30), weekStart: "Sunday",
+			all:     []label{{"Apr", 0}},
+			fitting: []label{{"Apr", 0}},
+		},
+	}
+
+	const step = 15
+	for _, tt := range tests {
+		for _, showAll := range []bool{true, false} {
+			want := tt.fitting
+			name := tt.name
+			if showAll {
+				want = tt.all
+				name += " showing all"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				h := gridFor(tt.start, tt.end, tt.weekStart)
+				h.ShowAllMonthLabels = showAll
+				h.generateLabels()
+				h.Layout = Layout{Step: step, GridLeft: 30}
+
+				var sb strings.Builder
+				h.writeMonthLabels(&sb)
+
+				var got []label
+				for _, match := range monthLabelPattern.FindAllStringSubmatch(sb.String(), -1) {
+					x, _ := strconv.Atoi(match[1])
+					if (x-h.Layout.GridLeft)%step != 0 {
+						t.Errorf("label %s at x=%d isn't aligned with a column", match[2], x)
+					}
+					got = append(got, label{match[2], (x - h.Layout.GridLeft) / step})
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got labels %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// monthLabelPattern matches a month label, capturing its x and its text
+var monthLabelPattern = regexp.MustCompile(`<text x="(\d+)" y="\d+" class="heatmap-month-label">([^<]+)</text>`)
+
+func TestRenderRadialSummaries(t *testing.T) {
+	start, end := date(2024, 1, 1), date(2024, 3, 31)
+	days := []*strava.DailyActivity{
+		{Date: date(2024, 1, 3), Count: 1, TotalDistance: 5000, TotalDuration: 1800},
+		{Date: date(2024, 3, 20), Count: 1, TotalDistance: 5000, TotalDuration: 1800},
+	}
+	h := &HeatmapData{StartDate: start, EndDate: end, WeekStart: "Monday", CellSize: 12, ColorTheme: GetTheme("strava", nil)}
+	h.createGrid(days, days, "distance")
+	h.summarizeWeeks(days, date(2024, 3, 1))
+
+	svg := h.RenderRadialSVG()
+
+	// January and February's weeks are one segment each, and March's days
+	// from the week holding the 1st are drawn one by one
+	if got, want := strings.Count(svg, `data-week="true"`), len(h.Summaries); got != want || want == 0 {
+		t.Errorf("drew %d week segments, want %d", got, want)
+	}
+	segments := regexp.MustCompile(`<path d="[^"]*" class="heatmap-cell `)
+	if got, want := len(segments.FindAllString(svg, -1)), len(h.Summaries)+processor.DaysBetween(h.DetailStart, end)+1; got != want {
+		t.Errorf("drew %d segments, want %d", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package svg
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/rendertest"
+)
+
+// visibleText matches the contents of text and title elements, the parts of
+// a render a reader sees
+var visibleText = regexp.MustCompile(`<(?:text|tspan|title)[^>]*>([^<]+)<`)
+
+// englishWord matches a run of Latin letters long enough to be a word rather
+// than a unit or an abbreviation
+var englishWord = regexp.MustCompile(`[A-Za-z]{3,}`)
+
+// untranslated are the Latin words a Japanese render may keep: units,
+// training metrics and the fixtures' own names
+var untranslated = map[string]bool{
+	"bpm": true, "kcal": true, "TSS": true, "NP": true,
+}
+
+// translatedConfigs are renders that together draw every card, widget and
+// overlay with text
+func translatedConfigs() map[string]*config.Config {
+	base := func() *config.Config {
+		cfg := &config.Config{
+			ActivityTypes: []string{"Run", "Ride", "Swim", "WeightTraining"},
+			MetricType:    "distance",
+			DateRange:     "custom",
+			CellSize:      10,
+			ColorScheme:   "github",
+			WeekStart:     "Monday",
+			Language:      "ja",
+			TimeZone:      "UTC",
+		}
+		cfg.CustomDateRange.Start = rendertest.Start.Format("2006-01-02")
+		cfg.CustomDateRange.End = rendertest.End.Format("2006-01-02")
+		return cfg
+	}
+
+	cards := base()
+	cards.Widgets = config.ValidWidgets
+	cards.YearlyDistanceGoal = 3000
+	cards.Tags = map[string][]string{"Tempo": {"tempo"}}
+	cards.ShowWeeklyChart = true
+	cards.ShowTrainingLoad = true
+	cards.IncludeLocationHeatmap = true
+
+	overlays := base()
+	overlays.Periodization = true
+	overlays.ACWRThreshold = 1.1
+	overlays.GhostPreviousYear = true
+
+	diff := base()
+	diff.ComparisonMode = "yoy"
+	diff.ComparisonView = "diff"
+
+	empty := base()
+	empty.Widgets = config.ValidWidgets
+	empty.ShowWeeklyChart = true
+	empty.ShowTrainingLoad = true
+	empty.IncludeLocationHeatmap = true
+
+	return map[string]*config.Config{"cards": cards, "overlays": overlays, "diff": diff, "empty": empty}
+}
+
+func TestRenderLeavesNoEnglish(t *testing.T) {
+	for name, cfg := range translatedConfigs() {
+		t.Run(name, func(t *testing.T) {
+			activities := rendertest.Activities()
+			if name == "empty" {
+				activities = nil
+			}
+
+			content, err := NewGenerator(cfg).GenerateHeatmap(activities)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, match := range visibleText.FindAllStringSubmatch(content, -1) {
+				text := html.UnescapeString(match[1])
+				for _, word := range englishWord.FindAllString(text, -1) {
+					if !untranslated[word] {
+						t.Errorf("untranslated %q in %q", word, strings.TrimSpace(text))
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package svg
+
+// Fixed room reserved for text, whose size doesn't change with the cell size
+const (
+	dayLabelWidth    = 60  // Day-of-week labels left of the grid
+	dayLabelGap      = 10  // Gap between the day labels and the first column
+	rightPadding     = 30  // Margin right of the last column
+	monthLabelRow    = 30  // Row above the grid holding the month labels
+	monthLabelY      = 20  // Baseline of the month labels
+	extraRow         = 15  // Week numbers, annotation flags or legend range labels
+	annotationHeight = 12  // Height of an annotation flag pole
+	legendGap        = 20  // Gap between the grid and the legend
+	legendTextWidth  = 40  // Room for "Less" and "More" beside the legend boxes
+	legendCaption    = 160 // Room for the metric and unit caption
+	legendRowGap     = 8   // Gap between the primary and secondary legend rows
+	phaseGap         = 4   // Gap between the grid and the periodization strip
+	phaseHeight      = 6   // Height of the periodization strip
+	warningHeight    = 8   // Height of a ramp warning marker
+	bottomPadding    = 16  // Margin below the legend
+	minLegendMargin  = 10  // Smallest margin either side of a centered legend
+)
+
+// margins holds the room reserved around the grid
+type margins struct {
+	dayLabelWidth, dayLabelGap int
+	monthLabelRow, monthLabelY int
+	right, legendGap, bottom   int
+}
+
+// defaultMargins leave room for large labels and a centered legend
+var defaultMargins = margins{dayLabelWidth, dayLabelGap, monthLabelRow, monthLabelY, rightPadding, legendGap, bottomPadding}
+
+// compactMargins match GitHub's contribution graph, 722px wide for 53 weeks
+// of 11px cells 2px apart, with small labels and the legend on the right
+var compactMargins = margins{
+	dayLabelWidth: 28, dayLabelGap: 4,
+	monthLabelRow: 20, monthLabelY: 13,
+	right: 1, legendGap: 8, bottom: 4,
+}
+
+// Layout holds the pixel geometry of the heatmap, derived from the cell size,
+// cell spacing and which optional rows are visible
+type Layout struct {
+	Step          int // Distance from one cell to the next
+	GridLeft      int // X of the first column
+	GridTop       int // Y of the first row
+	GridWidth     int
+	GridHeight    int
+	DayLabelX     int // Right edge of the day-of-week labels
+	MonthLabelY   int // Baseline of the month labels
+	AnnotationTop int // Top of the annotation flag poles
+	MilestoneY    int // Baseline of the distance milestone labels
+	WeekNumberY   int // Baseline of the ISO week numbers
+	WeekLabelY    int // Baseline of the week date labels
+	PhaseY        int // Top of the periodization strip
+	WarningY      int // Top of the ramp warning markers
+	LegendX       int
+	LegendY       int
+	LegendBox     int  // Size of a legend box
+	LegendStep    int  // Distance from one legend box to the next
+	LegendRanges  bool // Whether bin range labels are drawn under the legend boxes
+	LegendCaption bool // Whether the metric and unit caption is drawn beside the legend
+	SecondaryY    int  // Y of the secondary metric legend row, 0 if there is none
+	StreakY       int  // Baseline of the streak callouts under the legend, 0 if there are none
+	Width         int
+	Height        int
+}
+
+// computeLayout derives the heatmap geometry from the current settings
+func (h *HeatmapData) computeLayout() Layout {
+	m := defaultMargins
+	if h.Compact {
+		m = compactMargins
+	}
+
+	l := Layout{
+		Step:        h.CellSize + h.CellSpacing,
+		GridLeft:    m.dayLabelWidth + m.dayLabelGap,
+		DayLabelX:   m.dayLabelWidth,
+		MonthLabelY: m.monthLabelY,
+	}
+	l.GridWidth = len(h.Cells) * l.Step
+	l.GridHeight = 7 * l.Step
+
+	// Rows above the grid: month labels, then annotation flags, then
+	// distance milestones, then week numbers
+	l.GridTop = m.monthLabelRow
+	l.AnnotationTop = m.monthLabelRow + 1
+	if len(h.Annotations) > 0 {
+		l.GridTop += extraRow
+	}
+	if weeks, _ := h.milestoneWeeks(); len(weeks) > 0 {
+		l.GridTop += extraRow
+		l.MilestoneY = l.GridTop - 5
+	}
+	if h.WeekNumbers == "top" {
+		l.GridTop += extraRow
+		l.WeekNumberY = l.GridTop - 5
+	}
+
+	// Rows below the grid: the periodization strip, ramp warnings, week
+	// labels, week numbers, then the legend
+	below := l.GridTop + l.GridHeight
+	stripTop := below - h.CellSpacing + phaseGap
+	if h.Periodization {
+		l.PhaseY = stripTop
+		below = l.PhaseY + phaseHeight
+		stripTop = below + phaseGap
+	}
+	if h.ACWRThreshold > 0 {
+		l.WarningY = stripTop
+		below = l.WarningY + warningHeight
+	}
+	if h.WeekLabelInterval > 0 {
+		l.WeekLabelY = below + 10
+		below += extraRow
+	}
+	l.LegendY = below + m.legendGap
+	if h.WeekNumbers == "bottom" {
+		l.WeekNumberY = below + 10
+		l.LegendY += extraRow
+	}
+
+	// A row of a split heatmap may end at the grid, leaving the legend to
+	// the last row
+	if h.HideLegend {
+		l.Width = l.GridLeft + l.GridWidth + m.right
+		l.Height = l.LegendY - m.legendGap + m.bottom
+		return l
+	}
+
+	// Legend boxes are slightly larger than cells, or as large in the compact
+	// layout, and spread out to fit range labels underneath when those are
+	// shown
+	l.LegendBox = h.CellSize + 4
+	l.LegendStep = l.LegendBox + 4
+	if h.Compact {
+		l.LegendBox = h.CellSize
+		l.LegendStep = l.Step
+	}
+	l.LegendRanges = h.LegendRanges && !h.PrivacyMode && len(h.Thresholds) == 3 && !h.YearOverYear
+	legendHeight := l.LegendBox
+	if l.LegendRanges {
+		l.LegendStep = max(l.LegendStep, 40)
+		legendHeight += extraRow
+	}
+
+	// A second metric gets its own legend row, and both rows are captioned
+	// so they can be told apart, as is the diff view's legend of changes
+	l.LegendCaption = h.LegendUnits || h.SecondaryMetric != "" || h.YearOverYear
+	if h.SecondaryMetric != "" {
+		l.SecondaryY = l.LegendY + legendHeight + legendRowGap
+		legendHeight += legendRowGap + l.LegendBox
+	}
+
+	// Current and longest streaks are called out under the legend
+	if h.StreakMinDays > 0 {
+		legendHeight += legendRowGap + extraRow
+		l.StreakY = l.LegendY + legendHeight - 3
+	}
+
+	legendWidth := l.legendWidth()
+
+	// Wide enough for both the grid and the legend, which is centered or,
+	// like GitHub's, aligned with the right edge of the grid
+	l.Width = max(l.GridLeft+l.GridWidth+m.right, legendWidth+2*minLegendMargin)
+	l.Height = l.LegendY + legendHeight + m.bottom
+	l.LegendX = (l.Width - legendWidth) / 2
+	if h.Compact {
+		l.LegendX = l.Width - m.right - legendWidth
+	}
+
+	return l
+}
+
+// legendWidth returns the width of the legend, from "Less" to its caption
+func (l Layout) legendWidth() int {
+	width := 2*legendTextWidth + 5*l.LegendStep
+	if l.LegendCaption {
+		width += legendCaption
+	}
+	return width
+}
@@ -0,0 +1,102 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+const (
+	locationCols     = 54 // Cells across the location heatmap
+	locationRows     = 36 // Cells down the location heatmap
+	locationCellSize = 5  // Side of a location cell in pixels
+)
+
+// GenerateLocationHeatmap creates a card showing how often routes in the
+// displayed range passed through each part of the area they cover. Route
+// points within privacyRadius meters of where an activity started or ended
+// are left out, and activities in a privacy zone aren't shown at all.
+func (g *Generator) GenerateLocationHeatmap(activities []strava.SummaryActivity, privacyRadius int) (string, error) {
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	grid := processor.BuildDensityGrid(g.scrubLocations(activities), start, end,
+		locationCols, locationRows, float64(privacyRadius))
+
+	mapWidth := locationCols * locationCellSize
+	mapHeight := locationRows * locationCellSize
+	width := mapWidth + 30
+	height := mapHeight + 60
+
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+	g.writeLocationStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Where I Train")))
+
+	if grid.Max == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" class="card-muted">%s</text>`,
+			width/2, 45+mapHeight/2, tr.T("No routes in this period")))
+		sb.WriteString(`</svg>`)
+		return sb.String(), nil
+	}
+
+	// Levels grow with the log of the count, so a few daily loops don't
+	// wash out every other route
+	for row := 0; row < grid.Rows; row++ {
+		for col := 0; col < grid.Cols; col++ {
+			count := grid.Count(col, row)
+			if count == 0 {
+				continue
+			}
+			level := int(math.Ceil(4 * math.Log1p(float64(count)) / math.Log1p(float64(grid.Max))))
+			level = max(1, min(level, 4))
+
+			sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="location-%d" />`,
+				15+col*locationCellSize, 45+row*locationCellSize, locationCellSize, locationCellSize, level))
+		}
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// writeLocationStyle adds the location cell colors, taken from the heatmap's
+// color scheme
+func (g *Generator) writeLocationStyle(sb *strings.Builder) {
+	theme := GetTheme(g.Config.ColorScheme, g.Config.CustomColors)
+
+	sb.WriteString(`<style>`)
+	for i := 1; i < 5; i++ {
+		sb.WriteString(fmt.Sprintf(`
+  .location-%d { fill: %s; }`, i, theme.Colors[i]))
+	}
+
+	if g.Config.DarkModeSupport {
+		darkTheme := GetDarkModeTheme(theme, g.Config.DarkModeColors)
+		sb.WriteString(`
+  @media (prefers-color-scheme: dark) {`)
+		for i := 1; i < 5; i++ {
+			sb.WriteString(fmt.Sprintf(`
+    .location-%d { fill: %s; }`, i, darkTheme.Colors[i]))
+		}
+		sb.WriteString(`
+  }`)
+	}
+
+	sb.WriteString(`
+</style>`)
+}
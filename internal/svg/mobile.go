@@ -0,0 +1,97 @@
+package svg
+
+import "time"
+
+// mobileRows is how many rows the mobile target splits the weeks into
+const mobileRows = 2
+
+// rows splits the heatmap into n heatmaps of consecutive weeks, to be stacked
+// one above the other. The rows share the cells, bins and weekly strips of
+// the whole heatmap. Only the first keeps the caption and only the last the
+// legend, which still counts days and streaks across the whole range.
+func (h *HeatmapData) rows(n int) []*HeatmapData {
+	per := (len(h.Cells) + n - 1) / n
+	var rows []*HeatmapData
+	for from := 0; from < len(h.Cells); from += per {
+		to := min(from+per, len(h.Cells))
+		row := *h
+		row.Cells = h.Cells[from:to]
+		if h.WeekVolumes != nil {
+			row.WeekVolumes = h.WeekVolumes[from:to]
+		}
+		if h.Phases != nil {
+			row.Phases = h.Phases[from:to]
+		}
+		if h.Ratios != nil {
+			row.Ratios = h.Ratios[from:to]
+		}
+		row.StartDate = laterDate(h.StartDate, row.Cells[0][0].Date)
+		row.EndDate = earlierDate(h.EndDate, row.Cells[len(row.Cells)-1][6].Date)
+		if from > 0 {
+			row.Caption = ""
+		}
+		row.HideLegend = to < len(h.Cells)
+		row.whole = h
+		row.generateLabels()
+		rows = append(rows, &row)
+	}
+	return rows
+}
+
+// renderHeatmap draws a heatmap, split into rows stacked one above the other
+// for the mobile target. The radial layout is narrow enough for any screen,
+// and the github layout keeps the width of the contribution graph beside it,
+// so neither is split.
+func (g *Generator) renderHeatmap(h *HeatmapData) string {
+	switch g.Config.Layout {
+	case "radial":
+		return h.RenderRadialSVG()
+	case "github":
+		return h.compact().RenderSVG()
+	}
+	if g.Config.Target != "mobile" {
+		return h.RenderSVG()
+	}
+
+	rows := h.rows(mobileRows)
+	svgContent := rows[0].RenderSVG()
+	for _, row := range rows[1:] {
+		svgContent = combineWithWidgets(svgContent, []string{row.RenderSVG()})
+	}
+	return svgContent
+}
+
+// placePanel adds a panel right of the content, or below it for the mobile
+// target, whose narrow screens have no room beside the heatmap
+func (g *Generator) placePanel(content, panel string) string {
+	if g.Config.Target == "mobile" {
+		return combineWithWidgets(content, []string{panel})
+	}
+	return g.combineHeatmapAndStats(content, panel)
+}
+
+// placeWidgets adds the widgets in a row below the content, or one per row
+// for the mobile target
+func (g *Generator) placeWidgets(content string, widgets []string) string {
+	if g.Config.Target != "mobile" {
+		return combineWithWidgets(content, widgets)
+	}
+	for _, widget := range widgets {
+		content = combineWithWidgets(content, []string{widget})
+	}
+	return content
+}
+
+func laterDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlierDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,38 @@
+package svg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultPNGDPI renders PNGs at the SVG's own pixel size
+const DefaultPNGDPI = 96
+
+// RasterizePNG converts an SVG to PNG at the given resolution with
+// rsvg-convert from librsvg, which must be on the PATH. Hover styles and
+// dark mode media queries don't apply to the image, so it shows the light
+// colors without tooltips.
+func RasterizePNG(content string, dpi int) ([]byte, error) {
+	if dpi <= 0 {
+		dpi = DefaultPNGDPI
+	}
+
+	path, err := exec.LookPath("rsvg-convert")
+	if err != nil {
+		return nil, fmt.Errorf("rsvg-convert not found, install librsvg (e.g. apt-get install librsvg2-bin or brew install librsvg) to export PNGs")
+	}
+
+	// The SVG is sized in pixels, so scale it rather than set its DPI
+	cmd := exec.Command(path, "--format=png", fmt.Sprintf("--zoom=%g", float64(dpi)/DefaultPNGDPI))
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error converting SVG to PNG: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,42 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// provenanceComment describes what a heatmap was rendered from: the tool's
+// version, the config hash, the displayed range and how many activities
+// fell in it, which private heatmaps leave out. It has no timestamp, so
+// re-rendering the same data gives the same image, and a changed hash or
+// count shows a committed image is stale.
+func provenanceComment(configHash string, start, end time.Time, days []*strava.DailyActivity, private bool) string {
+	comment := fmt.Sprintf("<!-- StravaGraph %s; config %s; data %s to %s",
+		strava.BuildVersion(), configHash, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if private {
+		return comment + " -->"
+	}
+
+	activities := 0
+	for _, day := range days {
+		activities += day.Count
+	}
+	noun := "activities"
+	if activities == 1 {
+		noun = "activity"
+	}
+	return fmt.Sprintf("%s; %d %s -->", comment, activities, noun)
+}
+
+// withProvenance places the comment right inside the outer svg element, so
+// the image still starts with its opening tag
+func withProvenance(svgContent, comment string) string {
+	end := strings.Index(svgContent, ">")
+	if end == -1 {
+		return svgContent
+	}
+	return svgContent[:end+1] + comment + svgContent[end+1:]
+}
@@ -0,0 +1,33 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+func TestProvenanceComment(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+	days := []*strava.DailyActivity{{Date: start, Count: 2}, {Date: end, Count: 1}}
+
+	tests := []struct {
+		name    string
+		private bool
+		want    string
+	}{
+		{"public", false, "; config abc; data 2024-01-01 to 2024-12-31; 3 activities -->"},
+		{"private", true, "; config abc; data 2024-01-01 to 2024-12-31 -->"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := provenanceComment("abc", start, end, days, tt.private)
+			if !strings.HasPrefix(got, "<!-- StravaGraph "+strava.BuildVersion()) || !strings.HasSuffix(got, tt.want) {
+				t.Errorf("provenanceComment() = %q, want it to end with %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+)
+
+// Geometry of the radial layout, scaled by the cell size
+const (
+	radialRadiusCells = 15 // Outer radius of the day ring, in cells
+	radialHoleRatio   = 0.5
+	radialMargin      = 40 // Room around the ring for the month arcs and labels
+	radialArcGap      = 6  // Gap between the day ring and the month arcs
+	radialLabelGap    = 20 // Distance from the day ring to the month label baselines
+	radialMinLabel    = 30 // Shortest month arc, in pixels, that gets a label
+)
+
+// radialStyle draws the month arcs around the ring and enlarges the caption
+// in its middle
+const radialStyle = `<style>
+  .radial-month-arc { fill: none; stroke: #8b949e; stroke-width: 2; stroke-linecap: round; }
+  .radial-caption { font-size: 20px; }
+</style>`
+
+// RenderRadialSVG generates the SVG for the heatmap drawn as a ring, one
+// segment per day running clockwise from the top, with each month marked by
+// an arc outside the ring, and each summarized week as a single segment.
+// Annotations, milestones, week labels and the other overlays placed along
+// the grid's columns are left out
+func (h *HeatmapData) RenderRadialSVG() string {
+	h.CellSpacing = 4
+
+	// The legend keeps the grid layout's geometry, moved under the ring
+	layout := h.computeLayout()
+	outer := float64(radialRadiusCells * h.CellSize)
+	size := int(2*outer) + 2*radialMargin
+	layout.Width = max(size, layout.legendWidth()+2*minLegendMargin)
+	shift := size + legendGap - layout.LegendY
+	layout.LegendY += shift
+	layout.Height += shift
+	if layout.SecondaryY > 0 {
+		layout.SecondaryY += shift
+	}
+	if layout.StreakY > 0 {
+		layout.StreakY += shift
+	}
+	layout.LegendX = (layout.Width - layout.legendWidth()) / 2
+	h.Layout = layout
+
+	cx, cy := float64(layout.Width)/2, float64(size)/2
+	inner := outer * radialHoleRatio
+	days := processor.DaysBetween(h.StartDate, h.EndDate) + 1
+	angle := func(day int) float64 {
+		return 2*math.Pi*float64(day)/float64(days) - math.Pi/2
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		layout.Width, layout.Height, layout.Width, layout.Height))
+	h.writeStyle(&sb)
+	sb.WriteString(radialStyle)
+
+	h.writeRadialMonths(&sb, cx, cy, outer, angle)
+
+	// The caption, or else the years covered, fills the hole in the middle
+	caption := h.Caption
+	if caption == "" {
+		caption = yearCaption(h.StartDate, h.EndDate)
+	}
+	sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="heatmap-month-label radial-caption" text-anchor="middle">%s</text>`,
+		cx, cy+7, caption))
+
+	sb.WriteString(`<g class="heatmap-cells">`)
+	for _, column := range h.Cells {
+		// A summarized week is drawn as one segment spanning its days
+		if summary := h.summary(column); summary != nil {
+			first := processor.DaysBetween(h.StartDate, summary.Date)
+			last := processor.DaysBetween(h.StartDate, earlierDate(column[6].Date, h.EndDate))
+			h.writeRadialSummary(&sb, summary, cx, cy, inner, outer, angle(first), angle(last+1))
+			continue
+		}
+
+		for _, cell := range column {
+			if cell.Date.Before(h.StartDate) || cell.Date.After(h.EndDate) {
+				continue
+			}
+
+			day := processor.DaysBetween(h.StartDate, cell.Date)
+			from, to := angle(day), angle(day+1)
+
+			colorClass := fmt.Sprintf("intensity-%d", cell.Intensity)
+			if h.YearOverYear {
+				colorClass = changeClass(cell)
+			}
+
+			attrs := h.cellDataAttributes(cell)
+			if h.Interactive {
+				attrs += ` tabindex="0"`
+			}
+			h.openDay(&sb, cell.Date)
+			sb.WriteString(fmt.Sprintf(`<path d="%s" class="heatmap-cell %s" %s><title>%s</title></path>`,
+				ringSegment(cx, cy, inner, outer, from, to), colorClass, attrs, cell.Tooltip))
+
+			// Mark PRs with a dot near the outer edge of their segment
+			if cell.HasPR {
+				mid := (from + to) / 2
+				r := outer - float64(h.CellSize)/2
+				sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%.1f" class="pr-marker" />`,
+					cx+r*math.Cos(mid), cy+r*math.Sin(mid), float64(h.CellSize)/6))
+			}
+			h.closeDay(&sb)
+		}
+	}
+	sb.WriteString(`</g>`)
+
+	if !h.HideLegend {
+		h.writeLegend(&sb)
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String()
+}
+
+// writeRadialSummary adds the segment summarizing a week, between the
+// angles of its first day and the day after its last
+func (h *HeatmapData) writeRadialSummary(sb *strings.Builder, summary *HeatmapCell, cx, cy, inner, outer, from, to float64) {
+	attrs := h.cellDataAttributes(summary) + ` data-week="true"`
+	if h.Interactive {
+		attrs += ` tabindex="0"`
+	}
+	h.openDay(sb, summary.Date)
+	sb.WriteString(fmt.Sprintf(`<path d="%s" class="heatmap-cell intensity-%d" %s><title>%s</title></path>`,
+		ringSegment(cx, cy, inner, outer, from, to), summary.Intensity, attrs, summary.Tooltip))
+
+	if summary.HasPR {
+		mid := (from + to) / 2
+		r := outer - float64(h.CellSize)/2
+		sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%.1f" class="pr-marker" />`,
+			cx+r*math.Cos(mid), cy+r*math.Sin(mid), float64(h.CellSize)/6))
+	}
+	h.closeDay(sb)
+}
+
+// writeRadialMonths adds an arc outside the ring spanning each month's days,
+// labeled at its middle when the arc is long enough for the name
+func (h *HeatmapData) writeRadialMonths(sb *strings.Builder, cx, cy, outer float64, angle func(int) float64) {
+	sb.WriteString(`<g class="heatmap-month-labels">`)
+
+	arcRadius := outer + radialArcGap
+	labelRadius := outer + radialLabelGap
+	gap := 1.5 / arcRadius // Half the gap between neighboring arcs, in radians
+
+	for first := h.StartDate; !first.After(h.EndDate); {
+		next := time.Date(first.Year(), first.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		last := earlierDate(next.AddDate(0, 0, -1), h.EndDate)
+		start, label := first, processor.GetTranslation(h.Language).Month(first.Month())
+		first = next
+
+		from := angle(processor.DaysBetween(h.StartDate, start)) + gap
+		to := angle(processor.DaysBetween(h.StartDate, last)+1) - gap
+		if to <= from {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf(`<path d="%s" class="radial-month-arc" />`,
+			arcPath(cx, cy, arcRadius, from, to)))
+
+		if h.ShowAllMonthLabels || (to-from)*arcRadius >= radialMinLabel {
+			mid := (from + to) / 2
+			sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="heatmap-month-label" text-anchor="middle">%s</text>`,
+				cx+labelRadius*math.Cos(mid), cy+labelRadius*math.Sin(mid)+4, label))
+		}
+	}
+
+	sb.WriteString(`</g>`)
+}
+
+// ringSegment returns the path of the part of a ring between two angles,
+// measured clockwise in radians from the positive x axis
+func ringSegment(cx, cy, inner, outer, from, to float64) string {
+	large := 0
+	if to-from > math.Pi {
+		large = 1
+	}
+	return fmt.Sprintf("M %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f L %.2f %.2f A %.2f %.2f 0 %d 0 %.2f %.2f Z",
+		cx+outer*math.Cos(from), cy+outer*math.Sin(from),
+		outer, outer, large, cx+outer*math.Cos(to), cy+outer*math.Sin(to),
+		cx+inner*math.Cos(to), cy+inner*math.Sin(to),
+		inner, inner, large, cx+inner*math.Cos(from), cy+inner*math.Sin(from))
+}
+
+// arcPath returns the path of a circular arc between two angles
+func arcPath(cx, cy, r, from, to float64) string {
+	large := 0
+	if to-from > math.Pi {
+		large = 1
+	}
+	return fmt.Sprintf("M %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f",
+		cx+r*math.Cos(from), cy+r*math.Sin(from), r, r, large, cx+r*math.Cos(to), cy+r*math.Sin(to))
+}
@@ -0,0 +1,185 @@
+package svg_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/internal/svg"
+	"github.com/samuellee/StravaGraph/rendertest"
+)
+
+// update rewrites the golden files with the current output, e.g.
+// go test ./internal/svg -run TestSnapshots -update
+var update = flag.Bool("update", false, "Rewrite the golden snapshot files with the current output")
+
+// snapshotDir holds the golden files, diff-friendly so reviews show exactly
+// what moved
+var snapshotDir = filepath.Join("..", "..", "testdata", "snapshots")
+
+// layouts are the optional parts and shapes of the heatmap that change its
+// geometry
+var layouts = map[string]func(cfg *config.Config){
+	"plain": func(cfg *config.Config) {},
+	"labeled": func(cfg *config.Config) {
+		cfg.WeekNumbers = "top"
+		cfg.ShowWeekLabels = true
+		cfg.LegendUnits = true
+		cfg.LegendRanges = true
+	},
+	"stats": func(cfg *config.Config) {
+		cfg.ShowStats = true
+		cfg.StatTypes = []string{"weekly", "monthly"}
+		cfg.SecondaryMetric = "elevation"
+		cfg.SecondaryEncoding = "dot"
+	},
+	"github": func(cfg *config.Config) {
+		cfg.Layout = "github"
+	},
+	"radial": func(cfg *config.Config) {
+		cfg.Layout = "radial"
+	},
+	"mobile": func(cfg *config.Config) {
+		cfg.Target = "mobile"
+	},
+}
+
+// snapshot is one combination of the matrix
+type snapshot struct {
+	Name   string
+	Config *config.Config
+}
+
+// TestSnapshots renders the rendertest fixture activities with every color
+// scheme, layout, week start and dark mode setting and compares the results
+// with golden files, so visual regressions show up before they reach
+// anyone's profile. A changed render is kept next to its golden file as
+// .new.svg for diffing.
+func TestSnapshots(t *testing.T) {
+	if *update {
+		if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	activities := rendertest.Activities()
+	expected := make(map[string]bool)
+
+	for _, snap := range matrix() {
+		file := snap.Name + ".svg"
+		expected[file] = true
+
+		t.Run(snap.Name, func(t *testing.T) {
+			content, err := svg.NewGenerator(snap.Config).GenerateHeatmap(activities)
+			if err != nil {
+				t.Fatalf("error rendering: %v", err)
+			}
+			if err := rendertest.CheckSVG(content); err != nil {
+				t.Errorf("invalid SVG: %v", err)
+			}
+			actual := []byte(svg.MakeDiffFriendly(content))
+			path := filepath.Join(snapshotDir, file)
+			newPath := strings.TrimSuffix(path, ".svg") + ".new.svg"
+
+			if *update {
+				if err := os.WriteFile(path, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				os.Remove(newPath)
+				return
+			}
+
+			golden, err := os.ReadFile(path)
+			switch {
+			case os.IsNotExist(err):
+				t.Errorf("missing golden file %s; run with -update to create it", path)
+			case err != nil:
+				t.Fatal(err)
+			case !bytes.Equal(golden, actual):
+				if err := os.WriteFile(newPath, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				t.Errorf("render differs from %s (new output in %s); run with -update if the change is intended", path, newPath)
+			default:
+				os.Remove(newPath)
+			}
+		})
+	}
+
+	// Golden files for combinations that no longer exist are stale
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".new.svg") || expected[name] {
+			continue
+		}
+		if *update {
+			os.Remove(filepath.Join(snapshotDir, name))
+		} else {
+			t.Errorf("stale golden file %s; run with -update to remove it", filepath.Join(snapshotDir, name))
+		}
+	}
+}
+
+// matrix returns every combination of color scheme, layout, week start and
+// dark mode, named after its settings
+func matrix() []snapshot {
+	layoutNames := make([]string, 0, len(layouts))
+	for name := range layouts {
+		layoutNames = append(layoutNames, name)
+	}
+	sort.Strings(layoutNames)
+
+	var snapshots []snapshot
+	for _, scheme := range config.ValidColorSchemes {
+		for _, layout := range layoutNames {
+			for _, weekStart := range config.ValidWeekStarts {
+				for _, dark := range []bool{false, true} {
+					cfg := baseConfig()
+					cfg.ColorScheme = scheme
+					cfg.WeekStart = weekStart
+					cfg.DarkModeSupport = dark
+					layouts[layout](cfg)
+
+					mode := "light"
+					if dark {
+						mode = "dark"
+					}
+					snapshots = append(snapshots, snapshot{
+						Name:   strings.ToLower(fmt.Sprintf("%s-%s-%s-%s", scheme, layout, weekStart, mode)),
+						Config: cfg,
+					})
+				}
+			}
+		}
+	}
+	return snapshots
+}
+
+// baseConfig returns the settings shared by every snapshot, with a fixed
+// date range so the output doesn't depend on the current date
+func baseConfig() *config.Config {
+	cfg := &config.Config{
+		ActivityTypes:  []string{"Run", "Ride", "Swim", "WeightTraining"},
+		MetricType:     "distance",
+		DateRange:      "custom",
+		CellSize:       10,
+		IncludePRs:     true,
+		CustomColors:   []string{"#ebedf0", "#ffd8b1", "#ffa94d", "#f76707", "#d9480f"},
+		DarkModeColors: []string{"#161b22", "#0e4429", "#006d32", "#26a641", "#39d353"},
+		Language:       "en",
+		TimeZone:       "UTC",
+	}
+	cfg.CustomDateRange.Start = rendertest.Start.Format("2006-01-02")
+	cfg.CustomDateRange.End = rendertest.End.Format("2006-01-02")
+	return cfg
+}
@@ -0,0 +1,121 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+)
+
+// DefaultStreakMinDays is the shortest run of active days outlined when
+// streaks are highlighted without a minimum
+const DefaultStreakMinDays = 7
+
+// streakRun is a run of consecutive active days within the displayed range
+type streakRun struct {
+	Start, End time.Time
+	Days       int
+}
+
+// streakRuns returns every run of consecutive active days in the displayed
+// range, in date order
+func (h *HeatmapData) streakRuns() []streakRun {
+	var runs []streakRun
+	var current *streakRun
+	for week := range h.Cells {
+		for _, cell := range h.Cells[week] {
+			if cell.Date.Before(h.StartDate) || cell.Date.After(h.EndDate) {
+				continue
+			}
+
+			if cell.Count == 0 {
+				current = nil
+				continue
+			}
+			if current == nil {
+				runs = append(runs, streakRun{Start: cell.Date})
+				current = &runs[len(runs)-1]
+			}
+			current.End = cell.Date
+			current.Days++
+		}
+	}
+	return runs
+}
+
+// writeStreaks outlines each run of at least StreakMinDays active days. The
+// outline follows the edges of the run's cells that don't border another day
+// of the same run, so a run wrapping across columns gets one enclosing shape.
+func (h *HeatmapData) writeStreaks(sb *strings.Builder) {
+	if h.StreakMinDays <= 0 {
+		return
+	}
+
+	// A row of a split heatmap outlines its part of the whole heatmap's
+	// runs, so a streak crossing rows is still outlined in both
+	source := h
+	if h.whole != nil {
+		source = h.whole
+	}
+
+	tr := processor.GetTranslation(h.Language)
+	nf := processor.GetNumberFormat(h.Language)
+	var runs []streakRun
+	for _, run := range source.streakRuns() {
+		if run.Days >= h.StreakMinDays {
+			runs = append(runs, run)
+		}
+	}
+	if len(runs) == 0 {
+		return
+	}
+
+	sb.WriteString(`<g class="heatmap-streaks">`)
+
+	first := h.Cells[0][0].Date
+	last := h.Cells[len(h.Cells)-1][6].Date
+	half := h.CellSpacing / 2
+	for _, run := range runs {
+		inRun := func(date time.Time) bool {
+			return !date.Before(run.Start) && !date.After(run.End) && !date.Before(first) && !date.After(last) && !h.summarized(date)
+		}
+		if run.Start.After(last) || run.End.Before(first) {
+			continue
+		}
+
+		var path strings.Builder
+		for date := run.Start; !date.After(run.End); date = date.AddDate(0, 0, 1) {
+			if !inRun(date) {
+				continue
+			}
+			offset := int(date.Sub(first).Hours() / 24)
+			week, day := offset/7, offset%7
+
+			left := h.Layout.GridLeft + week*h.Layout.Step - half
+			top := h.Layout.GridTop + day*h.Layout.Step - half
+			right := left + h.CellSize + 2*half
+			bottom := top + h.CellSize + 2*half
+
+			// The days above and below are a day apart, and the days left
+			// and right a week apart, unless the column starts or ends
+			if day == 0 || !inRun(date.AddDate(0, 0, -1)) {
+				path.WriteString(fmt.Sprintf("M%d %dH%d", left, top, right))
+			}
+			if day == 6 || !inRun(date.AddDate(0, 0, 1)) {
+				path.WriteString(fmt.Sprintf("M%d %dH%d", left, bottom, right))
+			}
+			if !inRun(date.AddDate(0, 0, -7)) {
+				path.WriteString(fmt.Sprintf("M%d %dV%d", left, top, bottom))
+			}
+			if !inRun(date.AddDate(0, 0, 7)) {
+				path.WriteString(fmt.Sprintf("M%d %dV%d", right, top, bottom))
+			}
+		}
+
+		sb.WriteString(fmt.Sprintf(`<path d="%s" class="streak-outline"><title>%s</title></path>`,
+			path.String(), tr.T("%s-day streak, %s to %s", nf.FormatInt(run.Days), tr.FormatMonthDay(run.Start), tr.FormatDate(run.End))))
+	}
+
+	sb.WriteString(`</g>`)
+}
@@ -0,0 +1,176 @@
+package svg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// summarizeWeeks draws each week ending before detailStart as a single cell
+// spanning its column, colored by the week's total binned against the other
+// summarized weeks. The days stay in the grid, so the legend still counts
+// them, but only the summaries are drawn, which keeps the SVG of a long
+// history small.
+func (h *HeatmapData) summarizeWeeks(days []*strava.DailyActivity, detailStart time.Time) {
+	byDate := make(map[string]*strava.DailyActivity, len(days))
+	for _, day := range days {
+		byDate[processor.CivilDate(day.Date).Format("2006-01-02")] = day
+	}
+
+	var summaries []*HeatmapCell
+	var weeks [][]*strava.DailyActivity
+	for _, column := range h.Cells {
+		if !column[6].Date.Before(detailStart) {
+			h.DetailStart = column[0].Date
+			break
+		}
+
+		summary := &HeatmapCell{Date: laterDate(column[0].Date, h.StartDate)}
+		var weekDays []*strava.DailyActivity
+		for _, cell := range column {
+			if cell.Date.Before(h.StartDate) || cell.Date.After(h.EndDate) {
+				continue
+			}
+			summary.Count += cell.Count
+			summary.Distance += cell.Distance
+			summary.Duration += cell.Duration
+			summary.HasPR = summary.HasPR || cell.HasPR
+			summary.Race = summary.Race || cell.Race
+			summary.Types = mergeTypes(summary.Types, cell.Types)
+			if day, ok := byDate[cell.Date.Format("2006-01-02")]; ok {
+				weekDays = append(weekDays, day)
+			}
+		}
+		summaries = append(summaries, summary)
+		weeks = append(weeks, weekDays)
+	}
+	if len(summaries) == 0 {
+		return
+	}
+
+	// Weekly totals are binned among themselves, as they dwarf the days;
+	// fixed bounds are daily values, so weeks fall back to percentiles
+	values := make([]float64, len(weeks))
+	var nonZero []float64
+	for i, weekDays := range weeks {
+		values[i] = processor.PeriodValue(weekDays, h.MetricType)
+		if values[i] > 0 {
+			nonZero = append(nonZero, values[i])
+		}
+	}
+	mode := h.IntensityScale.Mode
+	if mode == "fixed" {
+		mode = ""
+	}
+	thresholds := scaleThresholds(nonZero, mode)
+	if h.MetricType == "variety" && (mode == "" || mode == "percentile") {
+		thresholds = varietyThresholds
+	}
+
+	h.Summaries = make(map[string]*HeatmapCell, len(summaries))
+	for i, summary := range summaries {
+		summary.Intensity = intensityForValue(values[i], thresholds)
+		if h.PrivacyMode {
+			summary.Tooltip = summaryPrivateTooltip(summary, h.Language)
+		} else {
+			summary.Tooltip = summaryTooltip(summary, weeks[i], h.Language, h.Units, h.DurationStyle)
+		}
+		h.Summaries[h.Cells[i][0].Date.Format("2006-01-02")] = summary
+	}
+}
+
+// summary returns the cell summarizing a column, or nil if its days are
+// drawn
+func (h *HeatmapData) summary(column []*HeatmapCell) *HeatmapCell {
+	if h.Summaries == nil {
+		return nil
+	}
+	return h.Summaries[column[0].Date.Format("2006-01-02")]
+}
+
+// summarized reports whether a date is drawn as part of a week summary
+func (h *HeatmapData) summarized(date time.Time) bool {
+	return !h.DetailStart.IsZero() && date.Before(h.DetailStart)
+}
+
+// writeSummary adds the cell summarizing a week, as tall as its column
+func (h *HeatmapData) writeSummary(sb *strings.Builder, summary *HeatmapCell, x int) {
+	attrs := h.cellDataAttributes(summary) + ` data-week="true"`
+	if h.Interactive {
+		attrs += ` tabindex="0"`
+	}
+	h.openDay(sb, summary.Date)
+	sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" class="heatmap-cell intensity-%d" %s><title>%s</title></rect>`,
+		x, h.Layout.GridTop, h.CellSize, 7*h.Layout.Step-h.CellSpacing, summary.Intensity, attrs, summary.Tooltip))
+
+	if summary.HasPR {
+		sb.WriteString(fmt.Sprintf(`<circle cx="%d" cy="%d" r="%d" class="pr-marker" />`,
+			x+(h.CellSize*3/4), h.Layout.GridTop+(h.CellSize/4), h.CellSize/6))
+	}
+	h.closeDay(sb)
+}
+
+// summaryTooltip describes a summarized week's totals
+func summaryTooltip(summary *HeatmapCell, days []*strava.DailyActivity, language, unitSystem, durationStyle string) string {
+	tr := processor.GetTranslation(language)
+	week := tr.FormatDate(summary.Date)
+	if summary.Count == 0 {
+		return tr.T("No activities in the week of %s", week)
+	}
+
+	// Display units follow the week's dominant activity type
+	types := make(map[string]int)
+	for _, day := range days {
+		for activityType, count := range day.Types {
+			types[activityType] += count
+		}
+	}
+	units := processor.GetUnitRule(processor.DominantType(types), language, unitSystem)
+
+	tooltip := fmt.Sprintf("%s: %s", tr.T("Week of %s", week),
+		tr.Plural(summary.Count, "%s activity", "%s activities", units.Number))
+
+	if summary.Distance > 0 {
+		tooltip += "\n" + tr.T("Total distance: %s", units.FormatDistance(summary.Distance))
+	}
+	if summary.Duration > 0 {
+		tooltip += "\n" + tr.T("Total time: %s", processor.FormatDuration(summary.Duration, durationStyle, tr, units.Number))
+	}
+	if summary.HasPR {
+		tooltip += "\n" + tr.T("Personal Record!")
+	}
+	if summary.Race {
+		tooltip += "\n" + tr.T("Race week!")
+	}
+
+	return tooltip
+}
+
+// summaryPrivateTooltip describes a summarized week by its level only
+func summaryPrivateTooltip(summary *HeatmapCell, language string) string {
+	tr := processor.GetTranslation(language)
+	week := tr.FormatDate(summary.Date)
+	if summary.Intensity == strava.None {
+		return tr.T("No activities in the week of %s", week)
+	}
+
+	tooltip := fmt.Sprintf("%s: %s", tr.T("Week of %s", week), tr.T(intensityLabel(summary.Intensity)+" week"))
+	if summary.HasPR {
+		tooltip += "\n" + tr.T("Personal Record!")
+	}
+	return tooltip
+}
+
+// mergeTypes returns the sorted union of two sorted lists of activity types
+func mergeTypes(a, b []string) []string {
+	for _, activityType := range b {
+		if i := sort.SearchStrings(a, activityType); i == len(a) || a[i] != activityType {
+			a = append(a[:i], append([]string{activityType}, a[i:]...)...)
+		}
+	}
+	return a
+}
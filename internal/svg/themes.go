@@ -1,47 +1,68 @@
 package svg
 
+import "fmt"
+
 // ColorTheme represents a set of colors for the heatmap
 type ColorTheme struct {
 	Name   string
 	Colors []string // From lowest to highest intensity, starting with "none"
 }
 
+// SecondaryBorderColors outline cells by the intensity of a secondary
+// metric, from lowest to highest and starting with "none". They are chosen
+// to stand out against every fill theme.
+var SecondaryBorderColors = []string{"none", "#ffd8a8", "#ffa94d", "#f76707", "#c92a2a"}
+
 // GetTheme returns a color theme by name or the default theme if not found
 func GetTheme(name string, customColors []string) ColorTheme {
+	theme, err := LookupTheme(name, customColors)
+	if err != nil {
+		// Fall back to the GitHub theme
+		theme, _ = LookupTheme("github", nil)
+	}
+	return theme
+}
+
+// LookupTheme returns a color theme by name, or an error if there is no such
+// theme or the custom colors aren't five
+func LookupTheme(name string, customColors []string) (ColorTheme, error) {
 	switch name {
 	case "github":
 		return ColorTheme{
 			Name:   "github",
 			Colors: []string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"},
-		}
+		}, nil
 	case "strava":
 		return ColorTheme{
 			Name:   "strava",
 			Colors: []string{"#494950", "#ffd4d1", "#ffad9f", "#fc7566", "#e34a33"},
-		}
+		}, nil
 	case "blue":
 		return ColorTheme{
 			Name:   "blue",
 			Colors: []string{"#ebedf0", "#c0dbf1", "#7ab3e5", "#3282ce", "#0a60b6"},
-		}
+		}, nil
 	case "purple":
 		return ColorTheme{
 			Name:   "purple",
 			Colors: []string{"#ebedf0", "#d9c6ec", "#b888e0", "#9c4acf", "#7222bc"},
-		}
+		}, nil
+	case "snow":
+		return ColorTheme{
+			Name:   "snow",
+			Colors: []string{"#ebedf0", "#cfe3f5", "#8fbde6", "#4a8fcf", "#1d5fa0"},
+		}, nil
 	case "custom":
 		// Validate custom colors
 		if len(customColors) == 5 {
 			return ColorTheme{
 				Name:   "custom",
 				Colors: customColors,
-			}
+			}, nil
 		}
-		// If custom colors are invalid, fall back to GitHub theme
-		return GetTheme("github", nil)
+		return ColorTheme{}, fmt.Errorf("customColors must contain exactly 5 colors, got %d", len(customColors))
 	default:
-		// Default to GitHub theme
-		return GetTheme("github", nil)
+		return ColorTheme{}, fmt.Errorf("unknown color scheme: %s", name)
 	}
 }
 
@@ -77,6 +98,11 @@ func GetDarkModeTheme(lightTheme ColorTheme, customDarkColors []string) ColorThe
 			Name:   "purple-dark",
 			Colors: []string{"#161b22", "#2a184a", "#422873", "#61359c", "#8047c9"},
 		}
+	case "snow":
+		return ColorTheme{
+			Name:   "snow-dark",
+			Colors: []string{"#161b22", "#1c3a5e", "#3f6f9e", "#9cc3e6", "#f5faff"},
+		}
 	case "custom":
 		// For custom light theme without custom dark theme, create a darkened version
 		// In a real implementation, we'd use color manipulation to create dark variants
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/samuellee/StravaGraph/internal/processor"
 	"github.com/samuellee/StravaGraph/internal/strava"
 )
 
@@ -19,6 +20,9 @@ type TooltipData struct {
 	ActivityTypes  map[string]int
 	HasPR          bool
 	CustomFields   map[string]string
+	Language       string // Language used for number formatting
+	Units          string // "imperial" or metric
+	DurationStyle  string // How the total time is written, see processor.FormatDuration
 }
 
 // NewTooltipData creates tooltip data from a daily activity
@@ -52,9 +56,11 @@ func (t *TooltipData) AddCustomField(key, value string) {
 func GenerateTooltipSVG(data *TooltipData) string {
 	// If no activities, generate empty day tooltip
 	if data.ActivityCount == 0 {
-		return generateEmptyTooltip(data.Date)
+		return generateEmptyTooltip(data.Date, data.Language)
 	}
 
+	tr := processor.GetTranslation(data.Language)
+
 	var sb strings.Builder
 
 	// Tooltip size - will adjust based on content
@@ -66,6 +72,9 @@ func GenerateTooltipSVG(data *TooltipData) string {
 	lines := 3 // Date and activity count + 1 empty line
 	if data.TotalDistance > 0 {
 		lines++
+		if processor.GetUnitRule(processor.DominantType(data.ActivityTypes), data.Language, data.Units).PaceUnit != "" && data.TotalDuration > 0 {
+			lines++
+		}
 	}
 	if data.TotalDuration > 0 {
 		lines++
@@ -107,35 +116,34 @@ func GenerateTooltipSVG(data *TooltipData) string {
 
 	// Title - date
 	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-title">%s</text>`,
-		padding, padding+lineHeight, data.Date.Format("Monday, January 2, 2006")))
+		padding, padding+lineHeight, tr.FormatWeekdayDate(data.Date)))
 
 	// Activity count
-	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%d %s</text>`,
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s</text>`,
 		padding, padding+(lineHeight*2),
-		data.ActivityCount, pluralize("activity", data.ActivityCount)))
+		tr.Plural(data.ActivityCount, "%s activity", "%s activities", processor.GetNumberFormat(data.Language))))
 
 	currentLine := 3
 
-	// Distance
+	// Distance and pace, in the units of the day's dominant activity type
 	if data.TotalDistance > 0 {
-		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%.1f km total distance</text>`,
-			padding, padding+(lineHeight*currentLine), data.TotalDistance/1000))
+		units := processor.GetUnitRule(processor.DominantType(data.ActivityTypes), data.Language, data.Units)
+
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s total distance</text>`,
+			padding, padding+(lineHeight*currentLine), units.FormatDistance(data.TotalDistance)))
 		currentLine++
+
+		if pace := units.FormatPace(data.TotalDistance, data.TotalDuration); pace != "" {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s pace</text>`,
+				padding, padding+(lineHeight*currentLine), pace))
+			currentLine++
+		}
 	}
 
 	// Duration
 	if data.TotalDuration > 0 {
-		hours := data.TotalDuration / 3600
-		minutes := (data.TotalDuration % 3600) / 60
-
-		durationText := ""
-		if hours > 0 {
-			durationText = fmt.Sprintf("%d %s %d %s",
-				hours, pluralize("hour", hours),
-				minutes, pluralize("minute", minutes))
-		} else {
-			durationText = fmt.Sprintf("%d %s", minutes, pluralize("minute", minutes))
-		}
+		durationText := processor.FormatDuration(data.TotalDuration, data.DurationStyle, tr,
+			processor.GetNumberFormat(data.Language))
 
 		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s total time</text>`,
 			padding, padding+(lineHeight*currentLine), durationText))
@@ -144,15 +152,16 @@ func GenerateTooltipSVG(data *TooltipData) string {
 
 	// Elevation
 	if data.TotalElevation > 0 {
-		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%.0f m elevation gain</text>`,
-			padding, padding+(lineHeight*currentLine), data.TotalElevation))
+		units := processor.GetUnitRule(processor.DominantType(data.ActivityTypes), data.Language, data.Units)
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s elevation gain</text>`,
+			padding, padding+(lineHeight*currentLine), units.FormatElevation(data.TotalElevation)))
 		currentLine++
 	}
 
 	// Personal Record
 	if data.HasPR {
-		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text tooltip-highlight">Personal Record!</text>`,
-			padding, padding+(lineHeight*currentLine)))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text tooltip-highlight">%s</text>`,
+			padding, padding+(lineHeight*currentLine), tr.T("Personal Record!")))
 		currentLine++
 	}
 
@@ -198,7 +207,8 @@ func GenerateTooltipSVG(data *TooltipData) string {
 }
 
 // generateEmptyTooltip creates a tooltip for days with no activities
-func generateEmptyTooltip(date time.Time) string {
+func generateEmptyTooltip(date time.Time, language string) string {
+	tr := processor.GetTranslation(language)
 	var sb strings.Builder
 
 	// Tooltip size
@@ -228,11 +238,11 @@ func generateEmptyTooltip(date time.Time) string {
 
 	// Date
 	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-title">%s</text>`,
-		padding, padding+lineHeight, date.Format("Monday, January 2, 2006")))
+		padding, padding+lineHeight, tr.FormatWeekdayDate(date)))
 
 	// No activities message
-	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">No activities on this day</text>`,
-		padding, padding+(lineHeight*2)))
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="tooltip-text">%s</text>`,
+		padding, padding+(lineHeight*2), tr.T("No activities on this day")))
 
 	sb.WriteString(`</svg>`)
 
@@ -0,0 +1,124 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// trainingLoadChartHeight is the height of the training load panel
+const trainingLoadChartHeight = 190
+
+// GenerateTrainingLoadChart creates a panel of the given width with lines
+// for the fitness, fatigue and form of each of the days, seeded from the
+// history before them. All three share a scale, with a zero line when form
+// drops below it, and the legend notes the last day's values unless privacy
+// mode hides the numbers.
+func (g *Generator) GenerateTrainingLoadChart(history, days []*strava.DailyActivity, width int) string {
+	loads := processor.TrainingLoads(history, days)
+
+	low, high := 0.0, 0.0
+	for _, load := range loads {
+		low = math.Min(low, load.Form)
+		high = math.Max(high, math.Max(load.Fitness, math.Max(load.Fatigue, load.Form)))
+	}
+
+	left, right := 45.0, float64(width-15)
+	top, bottom := 60.0, float64(trainingLoadChartHeight-30)
+
+	xFor := func(day int) float64 {
+		if len(loads) < 2 {
+			return (left + right) / 2
+		}
+		return left + float64(day)*(right-left)/float64(len(loads)-1)
+	}
+	yFor := func(value float64) float64 {
+		return bottom - (value-low)/(high-low)*(bottom-top)
+	}
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, trainingLoadChartHeight, width, trainingLoadChartHeight))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, trainingLoadChartHeight))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Training Load")))
+
+	if high == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%.1f" text-anchor="middle" class="card-muted">%s</text>`,
+			width/2, (top+bottom)/2, tr.T("No activities in this period")))
+		sb.WriteString(`</svg>`)
+		return sb.String()
+	}
+
+	lines := []struct {
+		label, class string
+		value        func(processor.TrainingLoad) float64
+	}{
+		{tr.T("Fitness"), "card-line", func(l processor.TrainingLoad) float64 { return l.Fitness }},
+		{tr.T("Fatigue"), "card-fatigue", func(l processor.TrainingLoad) float64 { return l.Fatigue }},
+		{tr.T("Form"), "card-form", func(l processor.TrainingLoad) float64 { return l.Form }},
+	}
+
+	// Legend, with the last day's values hidden in privacy mode
+	latest := loads[len(loads)-1]
+	x := 15.0
+	for _, line := range lines {
+		label := line.label
+		if !g.Config.PrivacyMode {
+			label += " " + nf.FormatFloat(line.value(latest), 0)
+		}
+		sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="46" x2="%.1f" y2="46" class="%s" />`, x, x+12, line.class))
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="50" class="card-label">%s</text>`, x+16, label))
+		x += 16 + float64(len(label))*7 + 14
+	}
+
+	// Axis, with the scale hidden in privacy mode
+	sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="card-axis" />`, left, yFor(0), right, yFor(0)))
+	if !g.Config.PrivacyMode {
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">%s</text>`,
+			left-5, top+4, nf.FormatFloat(high, 0)))
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">0</text>`,
+			left-5, yFor(0)+4))
+		if low < 0 {
+			sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">%s</text>`,
+				left-5, bottom+4, nf.FormatFloat(low, 0)))
+		}
+	}
+
+	// Months are labeled on their first day when there's room for them, or
+	// only January with the year otherwise
+	yearsOnly := (right-left)/float64(len(loads))*30 < 30
+	for i, load := range loads {
+		if load.Date.Day() != 1 || (yearsOnly && load.Date.Month() != 1) {
+			continue
+		}
+		label := tr.Month(load.Date.Month())
+		if yearsOnly {
+			label = load.Date.Format("2006")
+		}
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted">%s</text>`, xFor(i), bottom+18, label))
+	}
+
+	// Form first, so fitness and fatigue are drawn over it
+	for i := len(lines) - 1; i >= 0; i-- {
+		points := make([]string, len(loads))
+		for j, load := range loads {
+			points[j] = fmt.Sprintf("%.1f,%.1f", xFor(j), yFor(lines[i].value(load)))
+		}
+		sb.WriteString(fmt.Sprintf(`<polyline points="%s" class="%s"><title>%s</title></polyline>`,
+			strings.Join(points, " "), lines[i].class, lines[i].label))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// weeklyChartHeight is the height of the weekly bar chart panel
+const weeklyChartHeight = 170
+
+// GenerateWeeklyBarChart creates a panel of the given width with a bar per
+// ISO week of the days, showing the configured metric's weekly total. Bars
+// share a scale with the biggest week, and each has a tooltip with its value
+// unless privacy mode hides the numbers.
+func (g *Generator) GenerateWeeklyBarChart(days []*strava.DailyActivity, width int) string {
+	metricType := g.Config.MetricType
+	weeks := processor.WeeklyTotals(days, metricType)
+
+	peak := 0.0
+	for _, week := range weeks {
+		peak = math.Max(peak, week.Value)
+	}
+
+	left, right := 45.0, float64(width-15)
+	top, bottom := 45.0, float64(weeklyChartHeight-30)
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+	unit := processor.MetricUnit(metricType, g.Config.Units)
+	format := func(value float64) string {
+		display := nf.FormatFloat(processor.MetricDisplayValue(value, metricType, g.Config.Units), 1)
+		if unit == "" {
+			return display
+		}
+		return nf.WithUnit(display, unit)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, weeklyChartHeight, width, weeklyChartHeight))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, weeklyChartHeight))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`,
+		tr.T("Weekly %s", tr.T(metricLegendLabel(metricType, g.Config.Units)))))
+
+	if peak == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%.1f" text-anchor="middle" class="card-muted">%s</text>`,
+			width/2, (top+bottom)/2, tr.T("No activities in this period")))
+		sb.WriteString(`</svg>`)
+		return sb.String()
+	}
+
+	// Axis, with the scale hidden in privacy mode
+	sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="card-axis" />`, left, bottom, right, bottom))
+	if !g.Config.PrivacyMode {
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">%s</text>`,
+			left-5, top+4, nf.FormatFloat(processor.MetricDisplayValue(peak, metricType, g.Config.Units), 0)))
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">0</text>`,
+			left-5, bottom+4))
+	}
+
+	// Bars fill a slot per week with a small gap. Weeks are labeled with the
+	// month they end in when it starts, or only with the year in January
+	// when months would be too narrow to label.
+	slot := (right - left) / float64(len(weeks))
+	barWidth := math.Max(slot-2, 1)
+	yearsOnly := slot*4 < 30
+	for i, week := range weeks {
+		x := left + float64(i)*slot
+
+		if sunday := week.WeekStart.AddDate(0, 0, 6); sunday.Day() <= 7 && (!yearsOnly || sunday.Month() == time.January) {
+			label := tr.Month(sunday.Month())
+			if yearsOnly {
+				label = sunday.Format("2006")
+			}
+			sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted">%s</text>`, x, bottom+18, label))
+		}
+
+		if week.Value == 0 {
+			continue
+		}
+
+		height := week.Value / peak * (bottom - top)
+		title := tr.T("Week of %s", tr.FormatDate(week.WeekStart))
+		if !g.Config.PrivacyMode {
+			title += ": " + format(week.Value)
+		}
+		sb.WriteString(fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="1" class="card-marker"><title>%s</title></rect>`,
+			x+1, bottom-height, barWidth, height, title))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String()
+}
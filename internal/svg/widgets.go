@@ -0,0 +1,805 @@
+package svg
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	widgetGap   = 10  // Space between the heatmap and widgets, and between widgets
+	widgetWidth = 300 // Width of a widget card
+)
+
+// widgetResult carries rendered widgets back from a background render
+type widgetResult struct {
+	widgets []string
+	err     error
+}
+
+// renderWidgets renders each configured widget as a standalone SVG, drawing
+// on all aggregated days including history outside the displayed range.
+// Widgets only read the aggregator, so they render concurrently; the result
+// keeps the configured order. The first widget to fail fails the render.
+func (g *Generator) renderWidgets(aggregator *processor.ActivityAggregator) ([]string, error) {
+	rendered := make([]string, len(g.Config.Widgets))
+
+	var group errgroup.Group
+	for i, name := range g.Config.Widgets {
+		render := g.widgetRenderer(name)
+		if render == nil {
+			continue
+		}
+
+		group.Go(func() error {
+			content, err := render(aggregator)
+			if err != nil {
+				return fmt.Errorf("error rendering %s widget: %w", name, err)
+			}
+			rendered[i] = content
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var widgets []string
+	for _, content := range rendered {
+		if content != "" {
+			widgets = append(widgets, content)
+		}
+	}
+	return widgets, nil
+}
+
+// widgetRenderer returns the method drawing the named widget, or nil for an
+// unknown name
+func (g *Generator) widgetRenderer(name string) func(*processor.ActivityAggregator) (string, error) {
+	switch name {
+	case "month_comparison":
+		return g.generateMonthComparisonSVG
+	case "goal_progress":
+		return g.generateGoalProgressSVG
+	case "travel":
+		return g.generateTravelSVG
+	case "tags":
+		return g.generateTagBreakdownSVG
+	case "workouts":
+		return g.generateWorkoutBreakdownSVG
+	case "heart_rate":
+		return g.generateHeartRateSVG
+	case "time_of_day":
+		return g.generateTimeOfDaySVG
+	}
+	return nil
+}
+
+// combineWithWidgets places the widgets in a row below the main SVG
+func combineWithWidgets(mainSVG string, widgets []string) string {
+	if len(widgets) == 0 {
+		return mainSVG
+	}
+
+	mainWidth, mainHeight := extractSVGDimensions(mainSVG)
+
+	// Lay the widgets out left to right
+	rowWidth, rowHeight := 0, 0
+	var offsets []int
+	for _, widget := range widgets {
+		width, height := extractSVGDimensions(widget)
+		if rowWidth > 0 {
+			rowWidth += widgetGap
+		}
+		offsets = append(offsets, rowWidth)
+		rowWidth += width
+		rowHeight = max(rowHeight, height)
+	}
+
+	totalWidth := max(mainWidth, rowWidth)
+	totalHeight := mainHeight + widgetGap + rowHeight
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		totalWidth, totalHeight, totalWidth, totalHeight))
+
+	sb.WriteString(fmt.Sprintf(`<g transform="translate(0, 0)">%s</g>`, extractSVGContent(mainSVG)))
+
+	for i, widget := range widgets {
+		sb.WriteString(fmt.Sprintf(`<g transform="translate(%d, %d)">%s</g>`,
+			offsets[i], mainHeight+widgetGap, extractSVGContent(widget)))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String()
+}
+
+// writeCardStyle adds the CSS shared by widget cards
+func (g *Generator) writeCardStyle(sb *strings.Builder) {
+	sb.WriteString(`<style>
+  .card-panel { fill: #f6f8fa; stroke: #e1e4e8; rx: 6; }
+  .card-title { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 16px; font-weight: bold; fill: #24292e; }
+  .card-label { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 12px; fill: #586069; }
+  .card-value { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 14px; font-weight: bold; fill: #24292e; }
+  .card-muted { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 12px; fill: #8b949e; }
+  .card-up { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 12px; font-weight: bold; fill: #2da44e; }
+  .card-line { fill: none; stroke: #fc4c02; stroke-width: 2; }
+  .card-fatigue { fill: none; stroke: #0969da; stroke-width: 1.5; }
+  .card-form { fill: none; stroke: #2da44e; stroke-width: 1.5; stroke-dasharray: 4 2; }
+  .card-marker { fill: #fc4c02; }
+  .card-race { fill: #8250df; }
+  .card-alert { fill: #cf222e; }
+  .card-goal { fill: none; stroke: #8b949e; stroke-width: 1; stroke-dasharray: 4 3; }
+  .card-axis { stroke: #e1e4e8; stroke-width: 1; }
+  .card-ridge { fill: #ffe3d6; stroke: #fc4c02; stroke-width: 1; }
+  .card-down { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 12px; font-weight: bold; fill: #cf222e; }`)
+
+	if g.Config.DarkModeSupport {
+		sb.WriteString(`
+  @media (prefers-color-scheme: dark) {
+    .card-panel { fill: #0d1117; stroke: #30363d; }
+    .card-title { fill: #c9d1d9; }
+    .card-label { fill: #8b949e; }
+    .card-value { fill: #c9d1d9; }
+    .card-muted { fill: #6e7681; }
+    .card-axis { stroke: #30363d; }
+    .card-ridge { fill: #3d1d10; }
+    .card-race { fill: #a371f7; }
+    .card-fatigue { stroke: #58a6ff; }
+    .card-form { stroke: #3fb950; }
+  }`)
+	}
+
+	sb.WriteString(`
+</style>`)
+}
+
+// generateMonthComparisonSVG renders a card comparing the month to date with
+// the same calendar window a year earlier
+func (g *Generator) generateMonthComparisonSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	start, end, prevStart, prevEnd, err := g.Config.GetMonthComparisonRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting month comparison range: %w", err)
+	}
+
+	comparison := processor.PeriodComparison{
+		Current:  processor.SumPeriod(aggregator.GetOrderedDates(start, end)),
+		Previous: processor.SumPeriod(aggregator.GetOrderedDates(prevStart, prevEnd)),
+	}
+
+	// Distances follow the dominant type across both windows
+	types := make(map[string]int)
+	for _, totals := range []processor.PeriodTotals{comparison.Current, comparison.Previous} {
+		for t, count := range totals.Types {
+			types[t] += count
+		}
+	}
+	units := processor.GetUnitRule(processor.DominantType(types), g.Config.Language, g.Config.Units)
+	nf := units.Number
+	tr := processor.GetTranslation(g.Config.Language)
+
+	rows := []struct {
+		label             string
+		current, previous float64
+		format            func(float64) string
+	}{
+		{tr.T("Distance"), comparison.Current.Distance, comparison.Previous.Distance, units.FormatDistance},
+		{tr.T("Time"), float64(comparison.Current.Duration), float64(comparison.Previous.Duration), func(seconds float64) string {
+			// The compact style fits the column unless another is chosen
+			style := g.Config.DurationStyle
+			if style == "" {
+				style = "short"
+			}
+			return processor.FormatDuration(int(seconds), style, tr, nf)
+		}},
+		{tr.T("Active Days"), float64(comparison.Current.ActiveDays), float64(comparison.Previous.ActiveDays), func(days float64) string {
+			return nf.FormatInt(int(days))
+		}},
+	}
+
+	width := widgetWidth
+	height := 75 + len(rows)*25
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`,
+		tr.T("%s vs %d", tr.FormatMonthYear(start), prevStart.Year())))
+
+	// Column headers
+	sb.WriteString(fmt.Sprintf(`<text x="110" y="52" class="card-muted">%d</text>`, start.Year()))
+	sb.WriteString(fmt.Sprintf(`<text x="190" y="52" class="card-muted">%d</text>`, prevStart.Year()))
+
+	y := 75
+	for _, row := range rows {
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="card-label">%s</text>`, y, row.label))
+
+		// Exact values are hidden in privacy mode, leaving only the direction
+		if !g.Config.PrivacyMode {
+			sb.WriteString(fmt.Sprintf(`<text x="110" y="%d" class="card-value">%s</text>`, y, row.format(row.current)))
+			sb.WriteString(fmt.Sprintf(`<text x="190" y="%d" class="card-muted">%s</text>`, y, row.format(row.previous)))
+		}
+
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="%s" text-anchor="end">%s</text>`,
+			width-15, y, deltaClass(row.current, row.previous), formatDelta(row.current, row.previous, nf, tr, g.Config.PrivacyMode)))
+
+		y += 25
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// formatDelta returns an arrow and the relative change from previous to
+// current, or just the arrow when numbers are hidden
+func formatDelta(current, previous float64, nf processor.NumberFormat, tr processor.Translation, arrowOnly bool) string {
+	arrow := "–"
+	if current > previous {
+		arrow = "▲"
+	} else if current < previous {
+		arrow = "▼"
+	}
+
+	percent, ok := processor.PercentChange(current, previous)
+	if arrowOnly || !ok || current == previous {
+		if !ok && current > 0 && !arrowOnly {
+			return arrow + " " + tr.T("new")
+		}
+		return arrow
+	}
+
+	if percent < 0 {
+		percent = -percent
+	}
+	return fmt.Sprintf("%s %s%%", arrow, nf.FormatFloat(percent, 0))
+}
+
+// deltaClass returns the card class coloring a change
+func deltaClass(current, previous float64) string {
+	switch {
+	case current > previous:
+		return "card-up"
+	case current < previous:
+		return "card-down"
+	default:
+		return "card-muted"
+	}
+}
+
+// generateGoalProgressSVG renders the distance covered this year against an
+// even pace toward the yearly distance goal, which is in kilometers, or
+// miles in imperial units
+func (g *Generator) generateGoalProgressSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	yearStart, end, err := g.Config.GetGoalRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting goal range: %w", err)
+	}
+
+	daysInYear := processor.DaysBetween(yearStart, yearStart.AddDate(1, 0, 0))
+	rule := processor.GetUnitRule("", g.Config.Language, g.Config.Units)
+	goal := g.Config.YearlyDistanceGoal * rule.DistanceScale
+	progress := processor.NewGoalProgress(aggregator.GetOrderedDates(yearStart, end), goal, daysInYear)
+
+	nf := rule.Number
+	tr := processor.GetTranslation(g.Config.Language)
+	distance := func(meters float64) string {
+		return nf.WithUnit(nf.FormatFloat(rule.ConvertDistance(meters), 0), rule.DistanceUnit)
+	}
+
+	width, height := 400, 220
+	left, right := 45.0, float64(width-15)
+	top, bottom := 60.0, float64(height-30)
+
+	// Scale to the goal, or to the distance covered once it's exceeded
+	maxValue := math.Max(goal, progress.Actual())
+	xFor := func(day int) float64 {
+		return left + float64(day)*(right-left)/float64(daysInYear)
+	}
+	yFor := func(meters float64) float64 {
+		return bottom - meters/maxValue*(bottom-top)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+
+	// Title and status, with distances hidden in privacy mode
+	ahead := progress.Ahead()
+	title := tr.T("%d Goal", yearStart.Year())
+	status := tr.T("On schedule")
+	class := "card-muted"
+	switch {
+	case ahead > 0:
+		status, class = tr.T("Ahead of schedule"), "card-up"
+		if !g.Config.PrivacyMode {
+			status = tr.T("Ahead by %s", distance(ahead))
+		}
+	case ahead < 0:
+		status, class = tr.T("Behind schedule"), "card-down"
+		if !g.Config.PrivacyMode {
+			status = tr.T("Behind by %s", distance(-ahead))
+		}
+	}
+	if !g.Config.PrivacyMode {
+		title = tr.T("%s of %s", distance(progress.Actual()), distance(goal))
+	}
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, title))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="48" class="%s">%s</text>`, class, status))
+
+	// Axes and month ticks at the start of each quarter
+	sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="card-axis" />`, left, bottom, right, bottom))
+	for _, month := range []time.Month{time.January, time.April, time.July, time.October} {
+		first := time.Date(yearStart.Year(), month, 1, 0, 0, 0, 0, yearStart.Location())
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted">%s</text>`,
+			xFor(processor.DaysBetween(yearStart, first)), bottom+18, tr.Month(month)))
+	}
+	if !g.Config.PrivacyMode {
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">%s</text>`,
+			left-5, yFor(goal)+4, nf.FormatFloat(g.Config.YearlyDistanceGoal, 0)))
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" class="card-muted" text-anchor="end">0</text>`,
+			left-5, bottom+4))
+	}
+
+	// Even pace from nothing on January 1st to the goal on December 31st
+	sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="card-goal" />`,
+		xFor(0), yFor(0), xFor(daysInYear), yFor(goal)))
+
+	// Cumulative distance at the end of each day so far
+	points := []string{fmt.Sprintf("%.1f,%.1f", xFor(0), yFor(0))}
+	for i, meters := range progress.Cumulative {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", xFor(i+1), yFor(meters)))
+	}
+	sb.WriteString(fmt.Sprintf(`<polyline points="%s" class="card-line" />`, strings.Join(points, " ")))
+
+	if n := len(progress.Cumulative); n > 0 {
+		sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="3" class="card-marker" />`,
+			xFor(n), yFor(progress.Actual())))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// generateTravelSVG renders a card with the countries and cities activities
+// in the displayed range started in. Privacy mode leaves it out, since even
+// the countries say where someone has been.
+func (g *Generator) generateTravelSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	if g.Config.PrivacyMode {
+		return "", nil
+	}
+
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	// Activities in a privacy zone count nowhere rather than reveal home
+	travel := processor.SummarizeTravel(g.scrubLocations(aggregator.Activities), start, end)
+	countries := travel.TopCountries()
+
+	// Flags for the most visited countries, as many as fit on one row
+	const flagStep = 26
+	maxFlags := (widgetWidth - 30) / flagStep
+	shown := countries
+	if len(shown) > maxFlags {
+		shown = shown[:maxFlags]
+	}
+
+	showCities := len(travel.Cities) > 0
+
+	width := widgetWidth
+	height := 75
+	if len(shown) > 0 {
+		height += 30
+	}
+	if showCities {
+		height += 20
+	}
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Trained In")))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="55" class="card-value">%s, %s</text>`,
+		tr.Plural(len(countries), "%s country", "%s countries", nf), tr.Plural(len(travel.Cities), "%s city", "%s cities", nf)))
+
+	y := 55
+	if len(shown) > 0 {
+		y += 30
+		for i, code := range shown {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="18"><title>%s</title>%s</text>`,
+				15+i*flagStep, y, code, flagEmoji(code)))
+		}
+	}
+
+	if showCities {
+		y += 20
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="card-muted">%s</text>`,
+			y, html.EscapeString(tr.T("Most active: %s", strings.Join(travel.TopCities(3), ", ")))))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// generateTagBreakdownSVG renders a card with a bar per config-defined tag,
+// counting the tagged activities in the displayed range
+func (g *Generator) generateTagBreakdownSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	counts := processor.SumTags(aggregator.GetOrderedDates(start, end))
+	tags := processor.SortedTags(counts)
+
+	width := widgetWidth
+	height := 55 + max(len(tags), 1)*22
+
+	// Bars share a scale with the most used tag
+	barLeft, barRight := 110.0, float64(width-50)
+	peak := 0
+	for _, tag := range tags {
+		peak = max(peak, counts[tag])
+	}
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Tags")))
+
+	if len(tags) == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="62" class="card-muted">%s</text>`, tr.T("No tagged activities")))
+	}
+
+	y := 55
+	for _, tag := range tags {
+		barWidth := (barRight - barLeft) * float64(counts[tag]) / float64(peak)
+
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="card-label">%s</text>`, y+11, html.EscapeString(tag)))
+		sb.WriteString(fmt.Sprintf(`<rect x="%.1f" y="%d" width="%.1f" height="12" rx="2" class="card-marker" />`,
+			barLeft, y+1, barWidth))
+		// Counts are hidden in privacy mode, leaving only the relative bars
+		if !g.Config.PrivacyMode {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="card-value" text-anchor="end">%s</text>`,
+				width-15, y+12, nf.FormatInt(counts[tag])))
+		}
+
+		y += 22
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// workoutLabels name the workout kinds on the workouts card, as English
+// message keys
+var workoutLabels = map[string]string{
+	processor.WorkoutRace:    "Races",
+	processor.WorkoutLongRun: "Long runs",
+	processor.WorkoutSession: "Workouts",
+	processor.WorkoutOther:   "Other",
+}
+
+// generateWorkoutBreakdownSVG renders a card with a bar per workout kind,
+// counting the races, long runs and workouts marked in Strava over the
+// displayed range against the unmarked rest
+func (g *Generator) generateWorkoutBreakdownSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	counts := processor.SumWorkouts(aggregator.GetOrderedDates(start, end))
+
+	width := widgetWidth
+	height := 55 + len(processor.WorkoutKinds)*22
+
+	// Bars share a scale with the most common kind
+	barLeft, barRight := 110.0, float64(width-50)
+	peak := 0
+	for _, kind := range processor.WorkoutKinds {
+		peak = max(peak, counts[kind])
+	}
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Workouts")))
+
+	if peak == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="62" class="card-muted">%s</text>`, tr.T("No activities in this period")))
+		sb.WriteString(`</svg>`)
+		return sb.String(), nil
+	}
+
+	y := 55
+	for _, kind := range processor.WorkoutKinds {
+		barWidth := (barRight - barLeft) * float64(counts[kind]) / float64(peak)
+
+		// Races take the color of their cell markers
+		class := "card-marker"
+		if kind == processor.WorkoutRace {
+			class = "card-race"
+		}
+
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%d" class="card-label">%s</text>`, y+11, tr.T(workoutLabels[kind])))
+		sb.WriteString(fmt.Sprintf(`<rect x="%.1f" y="%d" width="%.1f" height="12" rx="2" class="%s" />`,
+			barLeft, y+1, barWidth, class))
+		// Counts are hidden in privacy mode, leaving only the relative bars
+		if !g.Config.PrivacyMode {
+			sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="card-value" text-anchor="end">%s</text>`,
+				width-15, y+12, nf.FormatInt(counts[kind])))
+		}
+
+		y += 22
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// generateHeartRateSVG renders a card with weekly average and max heart rate
+// over the displayed range as sparklines, marking weeks whose average was
+// elevated enough to suggest fatigue
+func (g *Generator) generateHeartRateSVG(aggregator *processor.ActivityAggregator) (string, error) {
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+
+	weeks := processor.WeeklyHeartRate(aggregator.GetOrderedDates(start, end), g.weekStartDay())
+	warnings := processor.ElevatedHeartRateWeeks(weeks)
+
+	// Scale to the range of recorded values, with a little headroom
+	low, high := math.Inf(1), 0.0
+	var latest processor.HeartRateWeek
+	for _, week := range weeks {
+		if week.AvgHeartRate == 0 {
+			continue
+		}
+		low = math.Min(low, week.AvgHeartRate)
+		high = math.Max(high, math.Max(week.AvgHeartRate, week.MaxHeartRate))
+		latest = week
+	}
+
+	width, height := widgetWidth, 150
+	left, right := 15.0, float64(width-15)
+	top, bottom := 65.0, float64(height-15)
+
+	xFor := func(week int) float64 {
+		if len(weeks) < 2 {
+			return (left + right) / 2
+		}
+		return left + float64(week)*(right-left)/float64(len(weeks)-1)
+	}
+	yFor := func(bpm float64) float64 {
+		return bottom - (bpm-low+5)/(high-low+10)*(bottom-top)
+	}
+
+	nf := processor.GetNumberFormat(g.Config.Language)
+	tr := processor.GetTranslation(g.Config.Language)
+	bpm := func(value float64) string {
+		return nf.WithUnit(nf.FormatFloat(value, 0), "bpm")
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Heart Rate")))
+
+	if latest.AvgHeartRate == 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="55" class="card-muted">%s</text>`, tr.T("No heart rate data")))
+		sb.WriteString(`</svg>`)
+		return sb.String(), nil
+	}
+
+	// Latest week's numbers are hidden in privacy mode, leaving the shape
+	status, class := tr.T("Steady"), "card-muted"
+	if !g.Config.PrivacyMode {
+		status = tr.T("Last week avg %s, max %s", bpm(latest.AvgHeartRate), bpm(latest.MaxHeartRate))
+	}
+	if n := len(warnings); n > 0 {
+		class = "card-down"
+		status = tr.Plural(n, "%s elevated week, possible fatigue", "%s elevated weeks, possible fatigue", nf)
+	}
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="50" class="%s">%s</text>`, class, status))
+
+	// Max heart rate as a dashed line behind the weekly average
+	var avgPoints, maxPoints []string
+	for i, week := range weeks {
+		if week.AvgHeartRate == 0 {
+			continue
+		}
+		avgPoints = append(avgPoints, fmt.Sprintf("%.1f,%.1f", xFor(i), yFor(week.AvgHeartRate)))
+		if week.MaxHeartRate > 0 {
+			maxPoints = append(maxPoints, fmt.Sprintf("%.1f,%.1f", xFor(i), yFor(week.MaxHeartRate)))
+		}
+	}
+	sb.WriteString(fmt.Sprintf(`<polyline points="%s" class="card-goal" />`, strings.Join(maxPoints, " ")))
+	sb.WriteString(fmt.Sprintf(`<polyline points="%s" class="card-line" />`, strings.Join(avgPoints, " ")))
+
+	for _, warning := range warnings {
+		for i, week := range weeks {
+			if !week.WeekStart.Equal(warning.WeekStart) {
+				continue
+			}
+			title := tr.T("Week of %s", tr.FormatMonthDay(week.WeekStart))
+			if !g.Config.PrivacyMode {
+				title = tr.T("%s: avg %s vs %s before", title, bpm(warning.AvgHeartRate), bpm(warning.Baseline))
+			}
+			sb.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="3" class="card-alert"><title>%s</title></circle>`,
+				xFor(i), yFor(week.AvgHeartRate), title))
+		}
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
+
+// weekStartDay returns the configured first day of the week
+func (g *Generator) weekStartDay() time.Weekday {
+	if g.Config.GetWeekStart() == "Monday" {
+		return time.Monday
+	}
+	return time.Sunday
+}
+
+// flagEmoji returns the flag of a two-letter country code, made of regional
+// indicator symbols
+func flagEmoji(code string) string {
+	if len(code) != 2 {
+		return code
+	}
+	var flag strings.Builder
+	for _, c := range strings.ToUpper(code) {
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return flag.String()
+}
+
+const (
+	ridgeMonths  = 12 // Months drawn by the time of day widget, ending with the range
+	ridgeSpacing = 14 // Distance between the baselines of consecutive months
+	ridgeHeight  = 26 // Height of a month's peak, overlapping the month above
+	ridgePoints  = 49 // Points sampled across the day, every half hour
+)
+
+// generateTimeOfDaySVG renders a ridgeline of when in the day activities
+// started in each of the last twelve months of the displayed range, so
+// seasonal shifts such as summer mornings and winter lunches stand out. Each
+// month is scaled to its own peak, showing the shape rather than the volume.
+func (g *Generator) generateTimeOfDaySVG(aggregator *processor.ActivityAggregator) (string, error) {
+	start, end, err := g.Config.GetDateRange()
+	if err != nil {
+		return "", fmt.Errorf("error getting date range: %w", err)
+	}
+	if earliest := time.Date(end.Year(), end.Month()-ridgeMonths+1, 1, 0, 0, 0, 0, end.Location()); start.Before(earliest) {
+		start = earliest
+	}
+
+	months := processor.StartTimesByMonth(aggregator.Activities, aggregator.TimeZone, start, end)
+
+	width := widgetWidth
+	left, right := 50.0, float64(width-20)
+	top := 50.0 + ridgeHeight
+	bottom := top + float64((len(months)-1)*ridgeSpacing)
+	height := int(bottom) + 35
+
+	tr := processor.GetTranslation(g.Config.Language)
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, width, height))
+
+	g.writeCardStyle(&sb)
+
+	sb.WriteString(fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" class="card-panel" />`, width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="15" y="30" class="card-title">%s</text>`, tr.T("Time of Day")))
+
+	active := false
+	for _, month := range months {
+		active = active || len(month.Hours) > 0
+	}
+	if !active {
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="55" class="card-muted">%s</text>`, tr.T("No activities in this period")))
+		sb.WriteString(`</svg>`)
+		return sb.String(), nil
+	}
+
+	// Hours along the bottom
+	for hour := 0; hour <= 24; hour += 6 {
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="middle" class="card-muted">%d:00</text>`,
+			left+float64(hour)/24*(right-left), bottom+20, hour))
+	}
+
+	// Months from the top down, each drawn over the bottom of the one above.
+	// Activity counts are hidden in privacy mode.
+	nf := processor.GetNumberFormat(g.Config.Language)
+	for i, month := range months {
+		baseline := top + float64(i*ridgeSpacing)
+		label := tr.FormatMonthYear(month.Month)
+
+		sb.WriteString(fmt.Sprintf(`<text x="15" y="%.1f" class="card-muted">%s</text>`, baseline-2, tr.Month(month.Month.Month())))
+
+		if len(month.Hours) == 0 {
+			sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="card-axis"><title>%s</title></line>`,
+				left, baseline, right, baseline, tr.T("%s: no activities", label)))
+			continue
+		}
+
+		var path strings.Builder
+		path.WriteString(fmt.Sprintf("M%.1f %.1f", left, baseline))
+		for j, value := range month.Density(ridgePoints) {
+			path.WriteString(fmt.Sprintf("L%.1f %.1f", left+float64(j)/float64(ridgePoints-1)*(right-left), baseline-value*ridgeHeight))
+		}
+		path.WriteString(fmt.Sprintf("L%.1f %.1fZ", right, baseline))
+
+		peak := month.PeakHour()
+		title := tr.T("%s: most often %d:00–%d:00", label, peak, peak+1)
+		if !g.Config.PrivacyMode {
+			title = tr.T("%s: %s, most often %d:00–%d:00",
+				label, tr.Plural(len(month.Hours), "%s activity", "%s activities", nf), peak, peak+1)
+		}
+		sb.WriteString(fmt.Sprintf(`<path d="%s" class="card-ridge"><title>%s</title></path>`, path.String(), title))
+	}
+
+	sb.WriteString(`</svg>`)
+
+	return sb.String(), nil
+}
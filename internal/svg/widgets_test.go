@@ -0,0 +1,110 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samuellee/StravaGraph/internal/config"
+	"github.com/samuellee/StravaGraph/rendertest"
+)
+
+// widgetConfig returns settings rendering the given widgets over the fixture
+// quarter
+func widgetConfig(widgets ...string) *config.Config {
+	cfg := &config.Config{
+		ActivityTypes:      []string{"Run", "Ride", "Swim", "WeightTraining"},
+		MetricType:         "distance",
+		DateRange:          "custom",
+		Language:           "en",
+		TimeZone:           "UTC",
+		Widgets:            widgets,
+		YearlyDistanceGoal: 3000,
+	}
+	cfg.CustomDateRange.Start = rendertest.Start.Format("2006-01-02")
+	cfg.CustomDateRange.End = rendertest.End.Format("2006-01-02")
+	return cfg
+}
+
+func TestRenderWidgetsKeepsOrder(t *testing.T) {
+	// Reversed so the order can't come from the list of valid widgets
+	var widgets []string
+	for i := len(config.ValidWidgets) - 1; i >= 0; i-- {
+		widgets = append(widgets, config.ValidWidgets[i])
+	}
+	g := NewGenerator(widgetConfig(append(widgets, "unknown")...))
+
+	rendered, err := g.renderWidgets(rendertest.Aggregator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != len(widgets) {
+		t.Fatalf("rendered %d widgets, want %d without the unknown one", len(rendered), len(widgets))
+	}
+	for i, name := range widgets {
+		want, err := g.widgetRenderer(name)(rendertest.Aggregator())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rendered[i] != want {
+			t.Errorf("widget %d isn't %s", i, name)
+		}
+	}
+}
+
+func TestRenderWidgetsFails(t *testing.T) {
+	cfg := widgetConfig("tags", "heart_rate")
+	cfg.CustomDateRange.End = "not a date"
+
+	_, err := NewGenerator(cfg).renderWidgets(rendertest.Aggregator())
+	if err == nil || !strings.Contains(err.Error(), "widget: error getting date range") {
+		t.Fatalf("err = %v, want a widget's date range error", err)
+	}
+}
+
+func TestRenderWidgetsPrivacyMode(t *testing.T) {
+	cfg := widgetConfig("travel", "tags")
+	cfg.PrivacyMode = true
+
+	rendered, err := NewGenerator(cfg).renderWidgets(rendertest.Aggregator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != 1 || strings.Contains(rendered[0], "Trained In") {
+		t.Errorf("rendered %d widgets, want only the tags widget without travel", len(rendered))
+	}
+}
+
+func TestWidgetsPassRenderChecks(t *testing.T) {
+	for _, name := range config.ValidWidgets {
+		t.Run(name, func(t *testing.T) {
+			if err := rendertest.CheckWidget(NewGenerator(widgetConfig(name)).widgetRenderer(name)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGoalProgressUnits(t *testing.T) {
+	tests := []struct {
+		units string
+		want  string
+	}{
+		{"metric", "of 3,000 km"},
+		{"imperial", "of 3,000 mi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			cfg := widgetConfig("goal_progress")
+			cfg.Units = tt.units
+
+			svg, err := NewGenerator(cfg).generateGoalProgressSVG(rendertest.Aggregator())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(svg, tt.want) {
+				t.Errorf("goal progress doesn't read %q", tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rendertest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+)
+
+// artifacts are strings that only show up in an SVG when a value was
+// formatted wrongly, such as a float divided by zero or a fmt verb missing
+// its argument
+var artifacts = []string{"NaN", "+Inf", "-Inf", "%!", "<nil>"}
+
+// dimension matches a width or height attribute, the first of which the
+// generator reads as the size of each panel it composes
+var dimension = regexp.MustCompile(`(width|height)="([^"]*)"`)
+
+// CheckSVG reports what makes an SVG unfit to be composed into the heatmap,
+// or nil: it must be a single well-formed <svg> element whose first width
+// and height attributes are its own, as positive whole pixels, and carry no
+// formatting artifacts
+func CheckSVG(content string) error {
+	if !strings.HasPrefix(content, "<svg") || !strings.HasSuffix(content, "</svg>") {
+		return fmt.Errorf("content is not a single <svg> element")
+	}
+
+	for _, name := range []string{"width", "height"} {
+		value := ""
+		for _, match := range dimension.FindAllStringSubmatch(content, -1) {
+			if match[1] == name {
+				value = match[2]
+				break
+			}
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("root %s %q is not a positive whole number of pixels", name, value)
+		}
+	}
+
+	for _, artifact := range artifacts {
+		if strings.Contains(content, artifact) {
+			return fmt.Errorf("content contains formatting artifact %q", artifact)
+		}
+	}
+
+	// The decoder accepts a sequence of elements, so a second one after the
+	// root closes is caught by counting the elements at the top level
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	depth, roots := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("content is not well-formed: %w", err)
+		}
+
+		switch token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots != 1 {
+		return fmt.Errorf("content is not a single <svg> element")
+	}
+	return nil
+}
+
+// Dimensions returns the width and height of an SVG as the generator reads
+// them when composing panels
+func Dimensions(content string) (int, int) {
+	width, height := 0, 0
+	for _, match := range dimension.FindAllStringSubmatch(content, -1) {
+		n, _ := strconv.Atoi(match[2])
+		if match[1] == "width" && width == 0 {
+			width = n
+		}
+		if match[1] == "height" && height == 0 {
+			height = n
+		}
+	}
+	return width, height
+}
+
+// CheckWidget renders a widget from the fixture activities and from none,
+// reporting the first failure or output that CheckSVG rejects, or nil. The
+// signature matches the widgets registered with the generator.
+func CheckWidget(render func(*processor.ActivityAggregator) (string, error)) error {
+	fixtures := []struct {
+		name       string
+		aggregator *processor.ActivityAggregator
+	}{
+		{"fixture activities", Aggregator()},
+		{"no activities", EmptyAggregator()},
+	}
+
+	for _, fixture := range fixtures {
+		content, err := render(fixture.aggregator)
+		if err != nil {
+			return fmt.Errorf("rendering %s: %w", fixture.name, err)
+		}
+		if err := CheckSVG(content); err != nil {
+			return fmt.Errorf("rendering %s: %w", fixture.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package rendertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+)
+
+// validSVG is a small panel that can be composed with others
+const validSVG = `<svg width="300" height="120" viewBox="0 0 300 120" xmlns="http://www.w3.org/2000/svg">` +
+	`<rect x="0" y="0" width="300" height="120" /><text x="15" y="30">Week of Jan 1 &amp; 8</text></svg>`
+
+func TestCheckSVG(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string // Expected in the error, empty for a valid SVG
+	}{
+		{"valid", validSVG, ""},
+		{"nested svg", `<svg width="20" height="10"><svg width="5" height="5"></svg></svg>`, ""},
+		{"empty", "", "not a single <svg> element"},
+		{"XML declaration first", `<?xml version="1.0"?>` + validSVG, "not a single <svg> element"},
+		{"trailing text", validSVG + "\n", "not a single <svg> element"},
+		{"another element", `<g width="1" height="1"></g>`, "not a single <svg> element"},
+		{"two svg elements", `<svg width="1" height="1"></svg><svg width="1" height="1"></svg>`, "not a single <svg> element"},
+		{"missing width", `<svg height="10"></svg>`, `root width ""`},
+		{"missing height", `<svg width="10"></svg>`, `root height ""`},
+		{"zero width", `<svg width="0" height="10"></svg>`, `root width "0"`},
+		{"negative height", `<svg width="10" height="-5"></svg>`, `root height "-5"`},
+		{"fractional width", `<svg width="10.5" height="10"></svg>`, `root width "10.5"`},
+		{"percentage width", `<svg width="100%" height="10"></svg>`, `root width "100%"`},
+		{"NaN", `<svg width="10" height="10"><rect x="NaN" /></svg>`, `artifact "NaN"`},
+		{"infinity", `<svg width="10" height="10"><rect width="+Inf" /></svg>`, `artifact "+Inf"`},
+		{"missing argument", `<svg width="10" height="10"><text>%!d(MISSING)</text></svg>`, `artifact "%!"`},
+		{"nil", `<svg width="10" height="10"><text><nil></text></svg>`, `artifact "<nil>"`},
+		{"unclosed element", `<svg width="10" height="10"><g></svg>`, "not well-formed"},
+		{"unescaped ampersand", `<svg width="10" height="10"><text>Run & ride</text></svg>`, "not well-formed"},
+		{"unquoted attribute", `<svg width="10" height="10"><rect x=5 /></svg>`, "not well-formed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSVG(tt.content)
+			switch {
+			case tt.errText == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)):
+				t.Errorf("err = %v, want %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		width, height int
+	}{
+		{"valid", validSVG, 300, 120},
+		{"root size before its children's", `<svg height="40" width="80"><rect width="5" height="6" /></svg>`, 80, 40},
+		{"missing", `<svg></svg>`, 0, 0},
+		{"not a whole number", `<svg width="10.5" height="12"></svg>`, 0, 12},
+	}
+
+	for _, tt := range tests {
+		if width, height := Dimensions(tt.content); width != tt.width || height != tt.height {
+			t.Errorf("%s: Dimensions = %d×%d, want %d×%d", tt.name, width, height, tt.width, tt.height)
+		}
+	}
+}
+
+func TestCheckWidget(t *testing.T) {
+	// A widget drawing a bar per day, as a built-in widget would
+	bars := func(aggregator *processor.ActivityAggregator) (string, error) {
+		days := aggregator.GetOrderedDates(Start, End)
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf(`<svg width="%d" height="50" xmlns="http://www.w3.org/2000/svg">`, len(days)*3))
+		for i, day := range days {
+			sb.WriteString(fmt.Sprintf(`<rect x="%d" y="0" width="2" height="%.1f" />`, i*3, day.TotalDistance/1000))
+		}
+		sb.WriteString(`</svg>`)
+		return sb.String(), nil
+	}
+
+	// A widget scaling to the average distance, which has none to divide
+	// by without activities
+	average := func(aggregator *processor.ActivityAggregator) (string, error) {
+		total, active := 0.0, 0
+		for _, day := range aggregator.GetOrderedDates(Start, End) {
+			if day.Count > 0 {
+				total += day.TotalDistance
+				active++
+			}
+		}
+		return fmt.Sprintf(`<svg width="100" height="20"><rect width="%.1f" height="10" /></svg>`, total/float64(active)/100), nil
+	}
+
+	tests := []struct {
+		name    string
+		render  func(*processor.ActivityAggregator) (string, error)
+		errText string // Expected in the error, empty for a passing widget
+	}{
+		{"valid", bars, ""},
+		{"failing", func(*processor.ActivityAggregator) (string, error) {
+			return "", errors.New("no goal set")
+		}, "rendering fixture activities: no goal set"},
+		{"malformed", func(*processor.ActivityAggregator) (string, error) {
+			return `<svg width="10" height="10"><g></svg>`, nil
+		}, "rendering fixture activities: content is not well-formed"},
+		{"unsized", func(*processor.ActivityAggregator) (string, error) {
+			return `<svg viewBox="0 0 10 10"></svg>`, nil
+		}, "rendering fixture activities: root width"},
+		{"broken without activities", average, `rendering no activities: content contains formatting artifact "NaN"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckWidget(tt.render)
+			switch {
+			case tt.errText == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)):
+				t.Errorf("err = %v, want %q", err, tt.errText)
+			}
+		})
+	}
+}
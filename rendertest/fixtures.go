@@ -0,0 +1,94 @@
+// Package rendertest holds the canonical activity fixtures and SVG checks
+// the renderers are held to, so widgets and panels written outside the svg
+// package can be tested against the same expectations as the built-in ones.
+// The fixtures are stable: changing them changes every golden file.
+package rendertest
+
+import (
+	"time"
+
+	"github.com/samuellee/StravaGraph/internal/processor"
+	"github.com/samuellee/StravaGraph/internal/strava"
+)
+
+// Start and End bound the fixture quarter
+var (
+	Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	End   = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// Activities returns a deterministic quarter of training with rest days, a
+// two-week break, long weekend rides, pool swims, PRs and distance-less gym
+// work, in UTC
+func Activities() []strava.SummaryActivity {
+	var activities []strava.SummaryActivity
+	start := Start.Add(7 * time.Hour)
+
+	for day := 0; day < 91; day++ {
+		// Rest every third day and through a two-week break in February
+		if day%3 == 2 || (day >= 42 && day < 56) {
+			continue
+		}
+
+		date := start.AddDate(0, 0, day)
+		activity := strava.SummaryActivity{
+			ID:             int64(day + 1),
+			Name:           "Morning Run",
+			Type:           "Run",
+			Distance:       float64(4000 + (day*7919)%9000),
+			MovingTime:     1500 + (day*104729)%2700,
+			TotalElevGain:  float64((day * 31) % 250),
+			StartDate:      date,
+			StartDateLocal: date,
+			Timezone:       "(GMT+00:00) UTC",
+		}
+
+		switch {
+		case date.Weekday() == time.Saturday:
+			activity.Name = "Long Ride"
+			activity.Type = "Ride"
+			activity.Distance *= 8
+			activity.MovingTime *= 4
+		case date.Weekday() == time.Wednesday:
+			activity.Name = "Strength"
+			activity.Type = "WeightTraining"
+			activity.Distance = 0
+			activity.TotalElevGain = 0
+		case day%17 == 0:
+			activity.Name = "Pool Swim"
+			activity.Type = "Swim"
+			activity.Distance /= 3
+		}
+		if day%29 == 0 {
+			activity.PRCount = 1
+		}
+
+		activities = append(activities, activity)
+	}
+
+	return activities
+}
+
+// Aggregator returns the fixture activities aggregated by day in UTC, as
+// widgets receive them
+func Aggregator() *processor.ActivityAggregator {
+	return aggregate(Activities())
+}
+
+// EmptyAggregator returns an aggregator without activities, which every
+// renderer must handle without failing
+func EmptyAggregator() *processor.ActivityAggregator {
+	return aggregate(nil)
+}
+
+// DailyActivities returns every day of the fixture quarter in date order,
+// rest days included, as panels drawn from daily data receive them
+func DailyActivities() []*strava.DailyActivity {
+	return Aggregator().GetOrderedDates(Start, End)
+}
+
+func aggregate(activities []strava.SummaryActivity) *processor.ActivityAggregator {
+	aggregator := processor.NewActivityAggregator(activities, time.UTC, 0)
+	aggregator.Aggregate()
+	return aggregator
+}